@@ -0,0 +1,278 @@
+package lungo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+// OplogArchive appends oplog events that are trimmed from the oplog to a file.
+// Together with a backup, the archived events allow restoring the catalog to
+// any point in time after the backup.
+type OplogArchive struct {
+	path  string
+	mode  os.FileMode
+	mutex sync.Mutex
+}
+
+// NewOplogArchive creates and returns a new oplog archive that appends events
+// to the file at the specified path.
+func NewOplogArchive(path string, mode os.FileMode) *OplogArchive {
+	return &OplogArchive{
+		path: path,
+		mode: mode,
+	}
+}
+
+// Append will append the specified events to the archive file. Every event is
+// written as a checksummed record and the file is synced before returning.
+func (a *OplogArchive) Append(events bsonkit.List) error {
+	// acquire mutex
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// encode events
+	payloads := make([][]byte, 0, len(events))
+	for _, event := range events {
+		payload, err := bson.Marshal(event)
+		if err != nil {
+			return err
+		}
+		payloads = append(payloads, payload)
+	}
+
+	return appendFrames(a.path, a.mode, payloads)
+}
+
+// ReadOplogArchive will read the events from an oplog archive. A partially
+// written record at the end of the archive is ignored.
+func ReadOplogArchive(r io.Reader) (bsonkit.List, error) {
+	// prepare reader
+	reader := bufio.NewReader(r)
+
+	// read events
+	var list bsonkit.List
+	var offset int64
+	for {
+		// read frame
+		payload, n, err := readFrame(reader, -1)
+		if err == io.EOF {
+			break
+		} else if err == errTornRecord {
+			// ignore torn record at the end of the archive
+			_, err = reader.Peek(1)
+			if err == io.EOF {
+				break
+			}
+
+			return nil, fmt.Errorf("corrupt archive record at offset %d", offset)
+		} else if err != nil {
+			return nil, err
+		}
+
+		// decode event
+		var event bson.D
+		err = bson.Unmarshal(payload, &event)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt archive record at offset %d", offset)
+		}
+
+		// add event
+		list = append(list, &event)
+
+		// advance offset
+		offset += n
+	}
+
+	return list, nil
+}
+
+func replayOplog(catalog *Catalog, backup *FileBackup, events bsonkit.List, until primitive.Timestamp) error {
+	// get oplog
+	oplog := catalog.Namespaces[Oplog]
+
+	// get cluster time and selection of backup, backups without details
+	// include all namespaces and the oplog up to the last event
+	last := lastClusterTime(catalog)
+	filter := namespaceFilter(nil, nil)
+	if backup != nil {
+		var handles []Handle
+		for _, name := range backup.Namespaces {
+			handle, err := ParseHandle(name)
+			if err != nil {
+				return err
+			}
+			handles = append(handles, handle)
+		}
+		last = backup.ClusterTime
+		filter = namespaceFilter(backup.Databases, handles)
+	}
+
+	// prepare selection, namespaces renamed into the selection are excluded as
+	// their documents are not contained in the backup
+	excluded := map[Handle]bool{}
+	selected := func(handle Handle) bool {
+		return filter(handle) && !excluded[handle]
+	}
+
+	// check timestamp
+	if !until.IsZero() && bsonkit.Compare(last, until) > 0 {
+		return fmt.Errorf("backup is newer than the requested cluster time")
+	}
+
+	// sort events
+	events = append(bsonkit.List{}, events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return bsonkit.Compare(bsonkit.Get(events[i], "_id.ts"), bsonkit.Get(events[j], "_id.ts")) < 0
+	})
+
+	// apply events
+	for _, event := range events {
+		// get timestamp
+		ts, _ := bsonkit.Get(event, "_id.ts").(primitive.Timestamp)
+
+		// skip applied events and stop after requested time
+		if bsonkit.Compare(ts, last) <= 0 {
+			continue
+		} else if !until.IsZero() && bsonkit.Compare(ts, until) > 0 {
+			break
+		}
+
+		// set timestamp
+		last = ts
+
+		// exclude namespaces renamed into the selection
+		if bsonkit.Get(event, "operationType") == "rename" {
+			from, to := eventHandle(event, "ns"), eventHandle(event, "to")
+			if !selected(from) && selected(to) {
+				delete(catalog.Namespaces, to)
+				excluded[to] = true
+				continue
+			}
+		}
+
+		// skip events of namespaces not included in the backup
+		if !eventSelected(event, selected) {
+			continue
+		}
+
+		// apply event
+		err := applyEvent(catalog, event, selected)
+		if err != nil {
+			return err
+		}
+
+		// add event
+		_, err = oplog.Insert(event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func eventSelected(event bsonkit.Doc, selected func(Handle) bool) bool {
+	// the selected namespaces of dropped databases are dropped
+	if bsonkit.Get(event, "operationType") == "dropDatabase" {
+		return true
+	}
+
+	return selected(eventHandle(event, "ns"))
+}
+
+func eventHandle(event bsonkit.Doc, field string) Handle {
+	// get handle
+	db, _ := bsonkit.Get(event, field+".db").(string)
+	coll, _ := bsonkit.Get(event, field+".coll").(string)
+
+	return Handle{db, coll}
+}
+
+func applyEvent(catalog *Catalog, event bsonkit.Doc, selected func(Handle) bool) error {
+	// get handle
+	handle := eventHandle(event, "ns")
+
+	// apply operation
+	switch op := bsonkit.Get(event, "operationType"); op {
+	case "insert", "replace", "update":
+		// get document
+		doc, ok := bsonkit.Get(event, "fullDocument").(bson.D)
+		if !ok {
+			return fmt.Errorf("missing full document in %s event", op)
+		}
+
+		// get namespace, the options and indexes of namespaces created after
+		// the backup are unknown
+		namespace := catalog.Namespaces[handle]
+		if namespace == nil {
+			return fmt.Errorf("unable to replay %s event for namespace %q missing in backup", op, handle.String())
+		}
+
+		// replace or insert document
+		id := bsonkit.Get(&doc, "_id")
+		if namespace.Get(id) != nil {
+			_, err := namespace.Replace(&bson.D{bson.E{Key: "_id", Value: id}}, &doc, nil, nil)
+			if err != nil {
+				return err
+			}
+		} else {
+			_, err := namespace.Insert(&doc)
+			if err != nil {
+				return err
+			}
+		}
+	case "delete":
+		// get namespace
+		namespace := catalog.Namespaces[handle]
+		if namespace == nil {
+			return fmt.Errorf("unable to replay %s event for namespace %q missing in backup", op, handle.String())
+		}
+
+		// remove document
+		doc := namespace.Get(bsonkit.Get(event, "documentKey._id"))
+		if doc != nil {
+			err := namespace.Remove(doc)
+			if err != nil {
+				return err
+			}
+		}
+	case "drop":
+		// remove namespace
+		delete(catalog.Namespaces, handle)
+	case "dropDatabase":
+		// remove namespaces
+		for h := range catalog.Namespaces {
+			if h[0] == handle[0] {
+				delete(catalog.Namespaces, h)
+			}
+		}
+	case "rename":
+		// get target
+		to := eventHandle(event, "to")
+
+		// get namespace
+		namespace := catalog.Namespaces[handle]
+		if namespace == nil {
+			return fmt.Errorf("unable to replay %s event for namespace %q missing in backup", op, handle.String())
+		}
+
+		// move namespace, namespaces renamed out of the selection are removed
+		delete(catalog.Namespaces, handle)
+		if selected(to) {
+			catalog.Namespaces[to] = namespace
+		}
+	default:
+		return fmt.Errorf("unsupported %v event", op)
+	}
+
+	return nil
+}
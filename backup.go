@@ -0,0 +1,226 @@
+package lungo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+)
+
+// BackupOptions is used to configure a backup.
+type BackupOptions struct {
+	// The databases and namespaces to include. All namespaces are included if
+	// both are empty. The buckets of included time-series collections are
+	// included automatically.
+	Databases  []string
+	Namespaces []Handle
+
+	// The compression and encryption of the backup.
+	Codec      FileCodec
+	Encryption *FileEncryption
+}
+
+// RestoreOptions is used to configure a restore.
+type RestoreOptions struct {
+	// The encryption of the backup.
+	Encryption *FileEncryption
+
+	// The sources of oplog events that are replayed onto the backup. A source
+	// is either an oplog archive or another backup, in which case the events
+	// of its oplog are replayed. Events that are already included in the
+	// backup are skipped.
+	Oplogs []io.Reader
+
+	// The cluster time up to which events are replayed, all events are
+	// replayed if zero.
+	Until primitive.Timestamp
+}
+
+// Backup will write a consistent snapshot of the catalog to the writer using
+// the file format. The snapshot is taken from an unlocked transaction and
+// therefore does not block concurrent writers.
+func (e *Engine) Backup(ctx context.Context, w io.Writer, opts BackupOptions) error {
+	// ensure context
+	ctx = ensureContext(ctx)
+
+	// begin snapshot transaction
+	txn, err := e.Begin(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	// build file from selected namespaces
+	file := BuildFile(selectNamespaces(txn.Catalog(), opts.Databases, opts.Namespaces))
+
+	// add backup details
+	file.Backup = &FileBackup{
+		ClusterTime: lastClusterTime(txn.Catalog()),
+		Databases:   opts.Databases,
+	}
+	for _, handle := range opts.Namespaces {
+		file.Backup.Namespaces = append(file.Backup.Namespaces, handle.String())
+	}
+
+	// write file
+	err = WriteFile(&contextWriter{ctx: ctx, w: w}, file, opts.Codec, opts.Encryption)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Restore will read a backup from the reader and write the catalog to the
+// provided store. Existing data in the store is replaced. If oplog sources are
+// configured, their events are replayed onto the backup up to the specified
+// cluster time to restore the catalog to that point in time. Only events of
+// namespaces selected by the backup are replayed and an error is returned if
+// an event targets a namespace missing in the backup. The lock acquired
+// by a locking store is released once the catalog has been written.
+func Restore(r io.Reader, store Store, opts RestoreOptions) error {
+	// read file
+	file, err := ReadFile(r, opts.Encryption)
+	if err != nil {
+		return err
+	}
+
+	// build catalog
+	catalog, err := file.BuildCatalog()
+	if err != nil {
+		return err
+	}
+
+	// replay oplog events
+	if len(opts.Oplogs) > 0 {
+		// collect events
+		var events bsonkit.List
+		for _, source := range opts.Oplogs {
+			list, err := readOplogSource(source, opts.Encryption)
+			if err != nil {
+				return err
+			}
+			events = append(events, list...)
+		}
+
+		// replay events
+		err = replayOplog(catalog, file.Backup, events, opts.Until)
+		if err != nil {
+			return err
+		}
+	}
+
+	// store catalog
+	err = store.Store(catalog)
+	if err != nil {
+		return err
+	}
+
+	// release store lock
+	if store, ok := store.(LockingStore); ok {
+		err = store.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func selectNamespaces(catalog *Catalog, databases []string, handles []Handle) *Catalog {
+	// check selection
+	if len(databases) == 0 && len(handles) == 0 {
+		return catalog
+	}
+
+	// get filter
+	filter := namespaceFilter(databases, handles)
+
+	// select namespaces
+	result := &Catalog{
+		Namespaces: map[Handle]*mongokit.Collection{},
+	}
+	for handle, namespace := range catalog.Namespaces {
+		if filter(handle) {
+			result.Namespaces[handle] = namespace
+		}
+	}
+
+	return result
+}
+
+func namespaceFilter(databases []string, handles []Handle) func(Handle) bool {
+	// check selection
+	if len(databases) == 0 && len(handles) == 0 {
+		return func(Handle) bool {
+			return true
+		}
+	}
+
+	// prepare lookup tables
+	dbs := map[string]bool{}
+	for _, db := range databases {
+		dbs[db] = true
+	}
+	selected := map[Handle]bool{}
+	for _, handle := range handles {
+		selected[handle] = true
+		selected[handle.Buckets()] = true
+	}
+
+	return func(handle Handle) bool {
+		return dbs[handle[0]] || selected[handle]
+	}
+}
+
+func lastClusterTime(catalog *Catalog) primitive.Timestamp {
+	// get oplog
+	oplog := catalog.Namespaces[Oplog].Documents.List
+	if len(oplog) == 0 {
+		return primitive.Timestamp{}
+	}
+
+	// get timestamp
+	ts, _ := bsonkit.Get(oplog[len(oplog)-1], "_id.ts").(primitive.Timestamp)
+
+	return ts
+}
+
+// contextWriter aborts writing once the context has been cancelled.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w *contextWriter) Write(p []byte) (int, error) {
+	// check context
+	err := w.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	return w.w.Write(p)
+}
+
+func readOplogSource(r io.Reader, encryption *FileEncryption) (bsonkit.List, error) {
+	// prepare reader
+	reader := bufio.NewReader(r)
+
+	// read oplog archive
+	magic, _ := reader.Peek(len(fileMagic))
+	if !bytes.Equal(magic, fileMagic) {
+		return ReadOplogArchive(reader)
+	}
+
+	// otherwise, read backup
+	file, err := ReadFile(reader, encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	return file.Namespaces[Oplog.String()].Documents, nil
+}
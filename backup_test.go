@@ -0,0 +1,383 @@
+package lungo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+)
+
+func TestEngineBackup(t *testing.T) {
+	engine, err := CreateEngine(Options{Store: NewMemoryStore()})
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	txn, err := engine.Begin(nil, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "a"}),
+	}, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(Handle{"baz", "qux"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "b"}),
+	}, true)
+	assert.NoError(t, err)
+	_, err = txn.CreateIndex(Handle{"baz", "qux"}, "n", mongokit.IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{"n": int32(1)}),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, engine.Commit(txn))
+
+	/* concurrent writer */
+
+	txn, err = engine.Begin(nil, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "c"}),
+	}, true)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = engine.Backup(nil, &buf, BackupOptions{})
+	assert.NoError(t, err)
+
+	snapshot := BuildFile(engine.Catalog())
+	assert.NoError(t, engine.Commit(txn))
+
+	store := NewMemoryStore()
+	err = Restore(&buf, store, RestoreOptions{})
+	assert.NoError(t, err)
+
+	catalog, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, BuildFile(catalog))
+	assert.Len(t, catalog.Namespaces[Handle{"foo", "bar"}].Documents.List, 1)
+
+	/* selection */
+
+	buf.Reset()
+	err = engine.Backup(nil, &buf, BackupOptions{
+		Databases:  []string{"baz"},
+		Namespaces: []Handle{{"foo", "bar"}},
+		Codec:      SnappyCompression,
+	})
+	assert.NoError(t, err)
+
+	store = NewMemoryStore()
+	err = Restore(&buf, store, RestoreOptions{})
+	assert.NoError(t, err)
+
+	catalog, err = store.Load()
+	assert.NoError(t, err)
+	assert.Len(t, catalog.Namespaces, 3)
+	assert.Len(t, catalog.Namespaces[Handle{"foo", "bar"}].Documents.List, 2)
+	assert.Len(t, catalog.Namespaces[Handle{"baz", "qux"}].Indexes, 2)
+	assert.Empty(t, catalog.Namespaces[Oplog].Documents.List)
+
+	buf.Reset()
+	err = engine.Backup(nil, &buf, BackupOptions{
+		Namespaces: []Handle{{"baz", "qux"}},
+	})
+	assert.NoError(t, err)
+
+	store = NewMemoryStore()
+	err = Restore(&buf, store, RestoreOptions{})
+	assert.NoError(t, err)
+
+	catalog, err = store.Load()
+	assert.NoError(t, err)
+	assert.Len(t, catalog.Namespaces, 2)
+	assert.NotNil(t, catalog.Namespaces[Handle{"baz", "qux"}])
+
+	/* encryption */
+
+	encryption := &FileEncryption{KeyID: "k1", Key: bytes.Repeat([]byte{1}, 32)}
+
+	buf.Reset()
+	err = engine.Backup(nil, &buf, BackupOptions{
+		Encryption: encryption,
+	})
+	assert.NoError(t, err)
+
+	err = Restore(bytes.NewReader(buf.Bytes()), NewMemoryStore(), RestoreOptions{})
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	err = Restore(bytes.NewReader(buf.Bytes()), NewMemoryStore(), RestoreOptions{
+		Encryption: encryption,
+	})
+	assert.NoError(t, err)
+
+	/* cancellation */
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = engine.Backup(ctx, &buf, BackupOptions{})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestRestoreOplog(t *testing.T) {
+	_ = os.Remove("./test.archive")
+	defer os.Remove("./test.archive")
+
+	engine, err := CreateEngine(Options{
+		Store:        NewMemoryStore(),
+		MinOplogSize: 1,
+		MaxOplogSize: 1,
+		MinOplogAge:  time.Nanosecond,
+		MaxOplogAge:  time.Nanosecond,
+		OplogArchive: NewOplogArchive("./test.archive", 0666),
+	})
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	handle := Handle{"foo", "bar"}
+
+	commit := func(fn func(txn *Transaction)) primitive.Timestamp {
+		txn, err := engine.Begin(nil, true)
+		assert.NoError(t, err)
+		fn(txn)
+		assert.NoError(t, engine.Commit(txn))
+		oplog := engine.Catalog().Namespaces[Oplog].Documents.List
+		return bsonkit.Get(oplog[len(oplog)-1], "_id.ts").(primitive.Timestamp)
+	}
+
+	commit(func(txn *Transaction) {
+		_, err := txn.Insert(handle, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": "a", "n": int32(1)}),
+			bsonkit.MustConvert(bson.M{"_id": "b", "n": int32(2)}),
+			bsonkit.MustConvert(bson.M{"_id": "c", "n": int32(3)}),
+		}, true)
+		assert.NoError(t, err)
+
+		_, err = txn.Insert(Handle{"foo", "baz"}, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": "w"}),
+		}, true)
+		assert.NoError(t, err)
+	})
+
+	var base bytes.Buffer
+	err = engine.Backup(nil, &base, BackupOptions{})
+	assert.NoError(t, err)
+
+	var selected bytes.Buffer
+	err = engine.Backup(nil, &selected, BackupOptions{
+		Namespaces: []Handle{handle, {"foo", "qux"}},
+	})
+	assert.NoError(t, err)
+
+	commit(func(txn *Transaction) {
+		_, err := txn.Update(handle, bsonkit.MustConvert(bson.M{"_id": "a"}), nil, bsonkit.MustConvert(bson.M{
+			"$set": bson.M{"n": int32(4)},
+		}), 0, 1, false, nil, nil)
+		assert.NoError(t, err)
+	})
+
+	until := commit(func(txn *Transaction) {
+		_, err := txn.Insert(handle, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": "d", "n": int32(5)}),
+		}, true)
+		assert.NoError(t, err)
+
+		_, err = txn.Insert(Handle{"foo", "baz"}, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": "x"}),
+		}, true)
+		assert.NoError(t, err)
+
+		err = txn.Rename(Handle{"foo", "baz"}, Handle{"foo", "qux"})
+		assert.NoError(t, err)
+	})
+
+	commit(func(txn *Transaction) {
+		_, err := txn.Delete(handle, bsonkit.MustConvert(bson.M{}), nil, 0, 0, nil)
+		assert.NoError(t, err)
+	})
+
+	commit(func(txn *Transaction) {
+		_, err := txn.Insert(handle, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": "e", "n": int32(6)}),
+		}, true)
+		assert.NoError(t, err)
+	})
+
+	var latest bytes.Buffer
+	err = engine.Backup(nil, &latest, BackupOptions{
+		Namespaces: []Handle{Oplog},
+	})
+	assert.NoError(t, err)
+
+	archive, err := ioutil.ReadFile("./test.archive")
+	assert.NoError(t, err)
+
+	events, err := ReadOplogArchive(bytes.NewReader(archive))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, events)
+
+	restore := func(until primitive.Timestamp) (*Catalog, error) {
+		store := NewMemoryStore()
+		err := Restore(bytes.NewReader(base.Bytes()), store, RestoreOptions{
+			Oplogs: []io.Reader{
+				bytes.NewReader(archive),
+				bytes.NewReader(latest.Bytes()),
+			},
+			Until: until,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return store.Load()
+	}
+
+	/* point in time */
+
+	catalog, err := restore(until)
+	assert.NoError(t, err)
+	assert.Equal(t, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "a", "n": int32(4)}),
+		bsonkit.MustConvert(bson.M{"_id": "b", "n": int32(2)}),
+		bsonkit.MustConvert(bson.M{"_id": "c", "n": int32(3)}),
+		bsonkit.MustConvert(bson.M{"_id": "d", "n": int32(5)}),
+	}, catalog.Namespaces[handle].Documents.List)
+	assert.Nil(t, catalog.Namespaces[Handle{"foo", "baz"}])
+	assert.Len(t, catalog.Namespaces[Handle{"foo", "qux"}].Documents.List, 2)
+
+	/* all events */
+
+	catalog, err = restore(primitive.Timestamp{})
+	assert.NoError(t, err)
+	assert.Equal(t, BuildFile(engine.Catalog()).Namespaces[handle.String()].Documents, catalog.Namespaces[handle].Documents.List)
+
+	/* torn archive */
+
+	archive = append(archive, 1, 2, 3)
+	catalog, err = restore(until)
+	assert.NoError(t, err)
+	assert.Len(t, catalog.Namespaces[handle].Documents.List, 4)
+
+	/* selection */
+
+	store := NewMemoryStore()
+	err = Restore(bytes.NewReader(selected.Bytes()), store, RestoreOptions{
+		Oplogs: []io.Reader{
+			bytes.NewReader(archive),
+			bytes.NewReader(latest.Bytes()),
+		},
+	})
+	assert.NoError(t, err)
+
+	catalog, err = store.Load()
+	assert.NoError(t, err)
+	assert.Len(t, catalog.Namespaces, 2)
+	assert.Nil(t, catalog.Namespaces[Handle{"foo", "qux"}])
+	assert.Equal(t, BuildFile(engine.Catalog()).Namespaces[handle.String()].Documents, catalog.Namespaces[handle].Documents.List)
+	for _, event := range catalog.Namespaces[Oplog].Documents.List {
+		assert.Equal(t, handle.String(), bsonkit.Get(event, "ns.db").(string)+"."+bsonkit.Get(event, "ns.coll").(string))
+	}
+
+	/* newer backup */
+
+	err = Restore(bytes.NewReader(latest.Bytes()), NewMemoryStore(), RestoreOptions{
+		Oplogs: []io.Reader{bytes.NewReader(archive)},
+		Until:  until,
+	})
+	assert.Error(t, err)
+}
+
+func TestRestoreOplogMissingNamespace(t *testing.T) {
+	engine, err := CreateEngine(Options{Store: NewMemoryStore()})
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	var base bytes.Buffer
+	err = engine.Backup(nil, &base, BackupOptions{})
+	assert.NoError(t, err)
+
+	txn, err := engine.Begin(nil, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "a"}),
+	}, true)
+	assert.NoError(t, err)
+	assert.NoError(t, engine.Commit(txn))
+
+	var latest bytes.Buffer
+	err = engine.Backup(nil, &latest, BackupOptions{
+		Namespaces: []Handle{Oplog},
+	})
+	assert.NoError(t, err)
+
+	err = Restore(bytes.NewReader(base.Bytes()), NewMemoryStore(), RestoreOptions{
+		Oplogs: []io.Reader{bytes.NewReader(latest.Bytes())},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, `unable to replay insert event for namespace "foo.bar" missing in backup`, err.Error())
+}
+
+type failingStore struct {
+	*MemoryStore
+	err error
+}
+
+func (s *failingStore) Store(catalog *Catalog) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.MemoryStore.Store(catalog)
+}
+
+func TestOplogArchiveFailedCommit(t *testing.T) {
+	_ = os.Remove("./test.archive")
+	defer os.Remove("./test.archive")
+
+	store := &failingStore{MemoryStore: NewMemoryStore()}
+
+	engine, err := CreateEngine(Options{
+		Store:        store,
+		MinOplogSize: 1,
+		MaxOplogSize: 1,
+		MinOplogAge:  time.Nanosecond,
+		MaxOplogAge:  time.Nanosecond,
+		OplogArchive: NewOplogArchive("./test.archive", 0666),
+	})
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	insert := func(id string) error {
+		txn, err := engine.Begin(nil, true)
+		assert.NoError(t, err)
+		_, err = txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": id}),
+		}, true)
+		assert.NoError(t, err)
+		return engine.Commit(txn)
+	}
+
+	assert.NoError(t, insert("a"))
+	event := engine.Catalog().Namespaces[Oplog].Documents.List[0]
+
+	store.err = io.ErrUnexpectedEOF
+	assert.Equal(t, io.ErrUnexpectedEOF, insert("b"))
+
+	_, err = os.Stat("./test.archive")
+	assert.True(t, os.IsNotExist(err))
+
+	store.err = nil
+	assert.NoError(t, insert("c"))
+
+	archive, err := ioutil.ReadFile("./test.archive")
+	assert.NoError(t, err)
+
+	events, err := ReadOplogArchive(bytes.NewReader(archive))
+	assert.NoError(t, err)
+	assert.Equal(t, bsonkit.List{event}, events)
+}
@@ -0,0 +1,175 @@
+package bsonkit
+
+import (
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Collator compares strings according to a MongoDB collation document. A nil
+// collator compares strings byte-wise. The collator is safe for concurrent
+// access, concurrent comparisons use separate instances of the underlying
+// collator from a pool.
+//
+// Note: The collation is implemented using golang.org/x/text/collate which
+// does not support the "caseFirst" and "maxVariable" options.
+type Collator struct {
+	spec bson.D
+	pool sync.Pool
+}
+
+// NewCollator will create and return a new collator from the provided MongoDB
+// collation document. If the locale is "simple", nil is returned.
+func NewCollator(spec Doc) (*Collator, error) {
+	// check spec
+	if spec == nil {
+		return nil, nil
+	}
+
+	// prepare options
+	var locale string
+	var strength int64 = 3
+	var opts []collate.Option
+	var extensions [][2]string
+
+	// parse spec
+	for _, el := range *spec {
+		switch el.Key {
+		case "locale":
+			str, ok := el.Value.(string)
+			if !ok {
+				return nil, fmt.Errorf("collation: expected string locale")
+			}
+			locale = str
+		case "strength":
+			num, ok := collationInteger(el.Value)
+			if !ok || num < 1 || num > 5 {
+				return nil, fmt.Errorf("collation: strength must be an integer 1 through 5")
+			}
+			strength = num
+		case "numericOrdering":
+			if b, ok := el.Value.(bool); !ok {
+				return nil, fmt.Errorf("collation: expected boolean numericOrdering")
+			} else if b {
+				opts = append(opts, collate.Numeric)
+			}
+		case "caseLevel":
+			if b, ok := el.Value.(bool); !ok {
+				return nil, fmt.Errorf("collation: expected boolean caseLevel")
+			} else if b {
+				extensions = append(extensions, [2]string{"kc", "true"})
+			}
+		case "backwards":
+			if b, ok := el.Value.(bool); !ok {
+				return nil, fmt.Errorf("collation: expected boolean backwards")
+			} else if b {
+				extensions = append(extensions, [2]string{"kb", "true"})
+			}
+		case "alternate":
+			switch el.Value {
+			case "non-ignorable":
+			case "shifted":
+				extensions = append(extensions, [2]string{"ka", "shifted"})
+			default:
+				return nil, fmt.Errorf("collation: alternate must be 'non-ignorable' or 'shifted'")
+			}
+		case "caseFirst", "maxVariable", "normalization", "version":
+			// not supported
+		default:
+			return nil, fmt.Errorf("collation: unknown field %q", el.Key)
+		}
+	}
+
+	// check locale
+	if locale == "" {
+		return nil, fmt.Errorf("collation: missing locale")
+	} else if locale == "simple" {
+		return nil, nil
+	}
+
+	// parse locale
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return nil, fmt.Errorf("collation: unsupported locale %q", locale)
+	}
+
+	// add strength
+	extensions = append(extensions, [2]string{"ks", collationStrengths[strength]})
+
+	// add extensions
+	for _, ext := range extensions {
+		tag, err = tag.SetTypeForKey(ext[0], ext[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// create collator
+	collator := &Collator{
+		spec: *Clone(spec),
+	}
+	collator.pool.New = func() interface{} {
+		return collate.New(tag, opts...)
+	}
+
+	return collator, nil
+}
+
+// Spec returns the collation document of the collator.
+func (c *Collator) Spec() Doc {
+	// handle nil
+	if c == nil {
+		return nil
+	}
+
+	return Clone(&c.spec)
+}
+
+// Compare will compare two BSON values like Compare but uses the collation to
+// compare strings.
+func (c *Collator) Compare(lv, rv interface{}) int {
+	return compare(lv, rv, c)
+}
+
+// CompareStrings will compare the two strings using the collation.
+func (c *Collator) CompareStrings(l, r string) int {
+	// handle nil
+	if c == nil {
+		if l < r {
+			return -1
+		} else if l > r {
+			return 1
+		}
+		return 0
+	}
+
+	// get collator
+	collator := c.pool.Get().(*collate.Collator)
+	defer c.pool.Put(collator)
+
+	return collator.CompareString(l, r)
+}
+
+var collationStrengths = map[int64]string{
+	1: "level1",
+	2: "level2",
+	3: "level3",
+	4: "level4",
+	5: "identic",
+}
+
+func collationInteger(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), float64(int64(v)) == v
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,79 @@
+package bsonkit
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCollator(t *testing.T) {
+	// missing
+	collator, err := NewCollator(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, collator)
+	assert.Equal(t, -1, collator.Compare("B", "a"))
+
+	// simple
+	collator, err = NewCollator(MustConvert(bson.M{"locale": "simple"}))
+	assert.NoError(t, err)
+	assert.Nil(t, collator)
+
+	// default strength
+	collator, err = NewCollator(MustConvert(bson.M{"locale": "en"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, collator.Compare("B", "a"))
+	assert.Equal(t, 1, collator.Compare("A", "a"))
+	assert.Equal(t, -1, collator.Compare("a", "á"))
+	assert.Equal(t, MustConvert(bson.M{"locale": "en"}), collator.Spec())
+
+	// case insensitive
+	collator, err = NewCollator(MustConvert(bson.M{"locale": "en", "strength": int32(2)}))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, collator.Compare("A", "a"))
+	assert.Equal(t, -1, collator.Compare("a", "á"))
+
+	// diacritic insensitive
+	collator, err = NewCollator(MustConvert(bson.M{"locale": "en", "strength": int32(1)}))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, collator.Compare("A", "á"))
+
+	// numeric ordering
+	collator, err = NewCollator(MustConvert(bson.M{"locale": "en", "numericOrdering": true}))
+	assert.NoError(t, err)
+	assert.Equal(t, -1, collator.Compare("2", "10"))
+
+	// nested values
+	collator, err = NewCollator(MustConvert(bson.M{"locale": "en", "strength": int32(2)}))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, collator.Compare(bson.A{"A"}, bson.A{"a"}))
+	assert.Equal(t, 0, collator.Compare(bson.D{{Key: "a", Value: "A"}}, bson.D{{Key: "a", Value: "a"}}))
+	assert.Equal(t, -1, collator.Compare(bson.D{{Key: "B", Value: "A"}}, bson.D{{Key: "a", Value: "a"}}))
+
+	// invalid
+	_, err = NewCollator(MustConvert(bson.M{"strength": int32(2)}))
+	assert.Error(t, err)
+	_, err = NewCollator(MustConvert(bson.M{"locale": "en", "strength": int32(6)}))
+	assert.Error(t, err)
+	_, err = NewCollator(MustConvert(bson.M{"locale": "en", "foo": "bar"}))
+	assert.Error(t, err)
+}
+
+func TestCollatorConcurrency(t *testing.T) {
+	collator, err := NewCollator(MustConvert(bson.M{"locale": "en", "strength": int32(2)}))
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				assert.Equal(t, 0, collator.CompareStrings("foo", "FOO"))
+				assert.Equal(t, -1, collator.CompareStrings("bar", "foo"))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,152 @@
+package bsonkit
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"hash"
+	"math"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Hash will compute the 64-bit hash of a BSON value as used by MongoDB for
+// hashed indexes. Numbers are truncated to 64-bit integers before hashing and
+// missing values hash like null values.
+func Hash(v interface{}) int64 {
+	// prepare hasher with seed
+	h := md5.New()
+	hashInt32(h, 0)
+
+	// hash value
+	hashValue(h, v)
+
+	// get digest
+	sum := h.Sum(nil)
+
+	return int64(binary.LittleEndian.Uint64(sum[:8]))
+}
+
+func hashValue(h hash.Hash, v interface{}) {
+	// get class
+	class, _ := Inspect(v)
+
+	// write canonical type and content
+	hashInt32(h, hashTypes[class])
+	hashContent(h, class, v)
+}
+
+func hashElement(h hash.Hash, key string, v interface{}) {
+	// get class
+	class, _ := Inspect(v)
+
+	// write canonical type, name and content
+	hashInt32(h, hashTypes[class])
+	_, _ = h.Write(append([]byte(key), 0))
+	hashContent(h, class, v)
+}
+
+func hashContent(h hash.Hash, class Class, v interface{}) {
+	switch class {
+	case Null:
+		// no content
+	case Number:
+		// write number as 64-bit integer
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], uint64(hashNumber(v)))
+		_, _ = h.Write(buf[:])
+	case Document:
+		// write fields and terminator
+		for _, e := range v.(bson.D) {
+			hashElement(h, e.Key, e.Value)
+		}
+		hashTerminator(h)
+	case Array:
+		// write items and terminator
+		for i, item := range v.(bson.A) {
+			hashElement(h, strconv.Itoa(i), item)
+		}
+		hashTerminator(h)
+	default:
+		// write raw value
+		_, raw, err := bson.MarshalValue(v)
+		if err != nil {
+			panic("bsonkit: " + err.Error())
+		}
+		_, _ = h.Write(raw)
+	}
+}
+
+func hashNumber(v interface{}) int64 {
+	switch v := v.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return truncateFloat(v)
+	case primitive.Decimal128:
+		// handle special values
+		if v.IsNaN() {
+			return 0
+		} else if v.IsInf() > 0 {
+			return math.MaxInt64
+		} else if v.IsInf() < 0 {
+			return math.MinInt64
+		}
+
+		// truncate and clamp
+		d := d128ToDec(v).Truncate(0)
+		if d.GreaterThanOrEqual(decimal.NewFromInt(math.MaxInt64)) {
+			return math.MaxInt64
+		} else if d.LessThanOrEqual(decimal.NewFromInt(math.MinInt64)) {
+			return math.MinInt64
+		}
+
+		return d.IntPart()
+	default:
+		panic("bsonkit: unreachable")
+	}
+}
+
+func truncateFloat(f float64) int64 {
+	if math.IsNaN(f) {
+		return 0
+	} else if f >= math.MaxInt64 {
+		return math.MaxInt64
+	} else if f < math.MinInt64 {
+		return math.MinInt64
+	}
+
+	return int64(f)
+}
+
+func hashTerminator(h hash.Hash) {
+	// the server hashes the terminating EOO element like any other element
+	// with canonical type 0 and an empty name
+	hashInt32(h, 0)
+	_, _ = h.Write([]byte{0})
+}
+
+func hashInt32(h hash.Hash, n int32) {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(n))
+	_, _ = h.Write(buf[:])
+}
+
+// the canonical BSON types used by the server when hashing
+var hashTypes = map[Class]int32{
+	Null:      5,
+	Number:    10,
+	String:    15,
+	Document:  20,
+	Array:     25,
+	Binary:    30,
+	ObjectID:  35,
+	Boolean:   40,
+	Date:      45,
+	Timestamp: 47,
+	Regex:     50,
+}
@@ -0,0 +1,61 @@
+package bsonkit
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHash(t *testing.T) {
+	// numbers are squashed to 64-bit integers
+	assert.Equal(t, Hash(int32(1)), Hash(int64(1)))
+	assert.Equal(t, Hash(int32(1)), Hash(1.0))
+	assert.Equal(t, Hash(int32(1)), Hash(1.9))
+	assert.Equal(t, Hash(int32(1)), Hash(primitive.NewDecimal128(0x3040000000000000, 1)))
+	assert.Equal(t, Hash(int64(0)), Hash(math.NaN()))
+	assert.Equal(t, Hash(int64(math.MaxInt64)), Hash(math.Inf(1)))
+	assert.NotEqual(t, Hash(int32(1)), Hash(int32(2)))
+
+	// missing values hash like null
+	assert.Equal(t, Hash(nil), Hash(Missing))
+	assert.NotEqual(t, Hash(nil), Hash(int64(0)))
+
+	// types are distinguished
+	assert.NotEqual(t, Hash("1"), Hash(int32(1)))
+	assert.NotEqual(t, Hash(false), Hash(int32(0)))
+
+	// strings
+	assert.Equal(t, Hash("foo"), Hash("foo"))
+	assert.NotEqual(t, Hash("foo"), Hash("bar"))
+
+	// documents depend on names, order and values
+	assert.Equal(t, Hash(bson.D{{Key: "a", Value: int32(1)}}), Hash(bson.D{{Key: "a", Value: 1.0}}))
+	assert.NotEqual(t, Hash(bson.D{{Key: "a", Value: int32(1)}}), Hash(bson.D{{Key: "b", Value: int32(1)}}))
+	assert.NotEqual(t, Hash(bson.D{
+		{Key: "a", Value: int32(1)},
+		{Key: "b", Value: int32(2)},
+	}), Hash(bson.D{
+		{Key: "b", Value: int32(2)},
+		{Key: "a", Value: int32(1)},
+	}))
+
+	// arrays differ from documents with index names
+	assert.NotEqual(t, Hash(bson.A{"a"}), Hash(bson.D{{Key: "0", Value: "a"}}))
+	assert.NotEqual(t, Hash(bson.A{}), Hash(bson.D{}))
+
+	// known values
+	assert.Equal(t, int64(5902408780260971510), Hash(int32(1)))
+	assert.Equal(t, int64(2338878944348059895), Hash(nil))
+	assert.Equal(t, int64(-5044491983541307904), Hash(bson.D{{Key: "a", Value: int32(1)}}))
+	assert.Equal(t, int64(-4231936587215443177), Hash(bson.A{}))
+	assert.Equal(t, int64(-2130392706917452751), Hash(bson.A{int32(1)}))
+	assert.Equal(t, int64(-8636796925960860978), Hash(bson.D{
+		{Key: "a", Value: bson.D{{Key: "b", Value: int32(1)}}},
+	}))
+	assert.Equal(t, int64(2112669900098346359), Hash(bson.D{
+		{Key: "a", Value: bson.A{int32(1), bson.D{{Key: "b", Value: int32(2)}}}},
+	}))
+}
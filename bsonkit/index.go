@@ -5,7 +5,8 @@ import "github.com/tidwall/btree"
 // Index is a basic btree based index for documents. The index is not safe from
 // concurrent access.
 type Index struct {
-	btree *btree.Generic[Doc]
+	btree   *btree.Generic[Doc]
+	columns []Column
 }
 
 // NewIndex creates and returns a new index.
@@ -14,6 +15,7 @@ func NewIndex(unique bool, columns []Column) *Index {
 		btree: btree.NewGeneric[Doc](func(a, b Doc) bool {
 			return Order(a, b, columns, !unique) < 0
 		}),
+		columns: columns,
 	}
 }
 
@@ -69,6 +71,46 @@ func (i *Index) Remove(doc Doc) bool {
 	return true
 }
 
+// Lookup will return an ascending list of all documents in the index that have
+// the same column values as the specified document.
+func (i *Index) Lookup(doc Doc) List {
+	// prepare list
+	var list List
+
+	// walk lower and equal entries
+	i.btree.Descend(doc, func(item Doc) bool {
+		if Order(item, doc, i.columns, false) != 0 {
+			return false
+		}
+		list = append(list, item)
+		return true
+	})
+
+	// reverse list
+	for l, r := 0, len(list)-1; l < r; l, r = l+1, r-1 {
+		list[l], list[r] = list[r], list[l]
+	}
+
+	// walk higher entries, skipping the entry equal to the pivot as it has
+	// already been added by the descending walk
+	i.btree.Ascend(doc, func(item Doc) bool {
+		if Order(item, doc, i.columns, false) != 0 {
+			return false
+		}
+		if i.btree.Less(doc, item) {
+			list = append(list, item)
+		}
+		return true
+	})
+
+	return list
+}
+
+// Len will return the number of documents in the index.
+func (i *Index) Len() int {
+	return i.btree.Len()
+}
+
 // List will return an ascending list of all documents in the index.
 func (i *Index) List() List {
 	// prepare list
@@ -88,7 +130,8 @@ func (i *Index) List() List {
 func (i *Index) Clone() *Index {
 	// create clone
 	clone := &Index{
-		btree: i.btree.Copy(),
+		btree:   i.btree.Copy(),
+		columns: i.columns,
 	}
 
 	return clone
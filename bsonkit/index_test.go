@@ -35,6 +35,7 @@ func TestIndex(t *testing.T) {
 	assert.True(t, index.Has(d1))
 	assert.True(t, index.Has(d2))
 	assert.Equal(t, List{d1, d2}, index.List())
+	assert.Equal(t, 2, index.Len())
 
 	ok = index.Remove(d1)
 	assert.True(t, ok)
@@ -250,3 +251,31 @@ func TestIndexClone(t *testing.T) {
 	assert.True(t, index2.Has(d3))
 	assert.Equal(t, List{d2, d3}, index2.List())
 }
+
+func TestIndexLookup(t *testing.T) {
+	d1 := MustConvert(bson.M{"a": "1"})
+	d2 := MustConvert(bson.M{"a": "2"})
+	d3 := MustConvert(bson.M{"a": "2"})
+	d4 := MustConvert(bson.M{"a": "3"})
+
+	index := NewIndex(false, []Column{
+		{Path: "a"},
+	})
+	assert.True(t, index.Build(List{d1, d2, d3, d4}))
+
+	assert.Equal(t, List{d1}, index.Lookup(MustConvert(bson.M{"a": "1"})))
+	assert.ElementsMatch(t, List{d2, d3}, index.Lookup(MustConvert(bson.M{"a": "2"})))
+	assert.ElementsMatch(t, List{d2, d3}, index.Lookup(d2))
+	assert.Empty(t, index.Lookup(MustConvert(bson.M{"a": "4"})))
+
+	unique := NewIndex(true, []Column{
+		{Path: "a"},
+	})
+	assert.True(t, unique.Build(List{d1, d2, d4}))
+
+	assert.Equal(t, List{d1}, unique.Lookup(MustConvert(bson.M{"a": "1"})))
+	assert.Equal(t, List{d2}, unique.Lookup(MustConvert(bson.M{"a": "2"})))
+	assert.Equal(t, List{d2}, unique.Lookup(d3))
+	assert.Equal(t, List{d4}, unique.Lookup(d4))
+	assert.Empty(t, unique.Lookup(MustConvert(bson.M{"a": "4"})))
+}
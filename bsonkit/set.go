@@ -1,5 +1,7 @@
 package bsonkit
 
+import "fmt"
+
 // Set is set of unique documents. The set is not safe from concurrent access.
 type Set struct {
 	List  List
@@ -36,6 +38,33 @@ func (s *Set) Add(doc Doc) bool {
 	return true
 }
 
+// Insert will insert the document at the specified position, if it has not
+// already been added. It may return false if the document has already been
+// added or the position is out of range.
+func (s *Set) Insert(doc Doc, i int) bool {
+	// check if already added
+	if _, ok := s.Index[doc]; ok {
+		return false
+	}
+
+	// check position
+	if i < 0 || i > len(s.List) {
+		return false
+	}
+
+	// insert document
+	s.List = append(s.List, nil)
+	copy(s.List[i+1:], s.List[i:])
+	s.List[i] = doc
+
+	// update index
+	for ; i < len(s.List); i++ {
+		s.Index[s.List[i]] = i
+	}
+
+	return true
+}
+
 // Replace will replace the first document with the second. It may return false
 // if the first document has not been added and the second already has been added.
 func (s *Set) Replace(d1, d2 Doc) bool {
@@ -81,6 +110,28 @@ func (s *Set) Remove(doc Doc) bool {
 	return true
 }
 
+// Verify will check that the list and index of the set are consistent. It
+// returns an error that describes the first inconsistency found.
+func (s *Set) Verify() error {
+	// check sizes
+	if len(s.List) != len(s.Index) {
+		return fmt.Errorf("set has %d documents but %d index positions", len(s.List), len(s.Index))
+	}
+
+	// check positions
+	for i, doc := range s.List {
+		if doc == nil {
+			return fmt.Errorf("set has a nil document at position %d", i)
+		} else if j, ok := s.Index[doc]; !ok {
+			return fmt.Errorf("set index misses the document at position %d", i)
+		} else if i != j {
+			return fmt.Errorf("set index has position %d for the document at position %d", j, i)
+		}
+	}
+
+	return nil
+}
+
 // Clone will clone the set. Mutating the new set will not mutate the original
 // set.
 func (s *Set) Clone() *Set {
@@ -125,3 +125,44 @@ func TestSetReplace(t *testing.T) {
 		},
 	}, set)
 }
+
+func TestSetInsert(t *testing.T) {
+	d1 := &bson.D{}
+	d2 := &bson.D{}
+	d3 := &bson.D{}
+
+	set := NewSet(List{d1, d3})
+
+	ok := set.Insert(d2, 1)
+	assert.True(t, ok)
+	assert.Equal(t, &Set{
+		List: List{d1, d2, d3},
+		Index: map[Doc]int{
+			d1: 0,
+			d2: 1,
+			d3: 2,
+		},
+	}, set)
+
+	ok = set.Insert(d2, 0)
+	assert.False(t, ok)
+
+	ok = set.Insert(&bson.D{}, 4)
+	assert.False(t, ok)
+}
+
+func TestSetVerify(t *testing.T) {
+	d1 := &bson.D{}
+	d2 := &bson.D{}
+
+	set := NewSet(List{d1, d2})
+	assert.NoError(t, set.Verify())
+
+	set.Index[d1] = 1
+	assert.Error(t, set.Verify())
+	assert.Equal(t, "set index has position 1 for the document at position 0", set.Verify().Error())
+
+	delete(set.Index, d1)
+	assert.Error(t, set.Verify())
+	assert.Equal(t, "set has 2 documents but 1 index positions", set.Verify().Error())
+}
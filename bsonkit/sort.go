@@ -7,8 +7,10 @@ import (
 
 // Column defines a column for ordering.
 type Column struct {
-	Path    string
-	Reverse bool
+	Path     string
+	Reverse  bool
+	Hashed   bool
+	Collator *Collator
 }
 
 // Sort will sort the list of documents in-place based on the specified columns.
@@ -26,8 +28,13 @@ func Order(l, r Doc, columns []Column, identity bool) int {
 		a := Get(l, column.Path)
 		b := Get(r, column.Path)
 
-		// compare values
-		res := Compare(a, b)
+		// compare values or hashes
+		var res int
+		if column.Hashed {
+			res = compareInt64s(Hash(a), Hash(b))
+		} else {
+			res = column.Collator.Compare(a, b)
+		}
 
 		// continue if equal
 		if res == 0 {
@@ -31,6 +31,28 @@ func (h Handle) Validate(needCollection bool) error {
 	return nil
 }
 
+// ParseHandle will parse a handle from its string form. Collection names may
+// contain dots.
+func ParseHandle(str string) (Handle, error) {
+	// split name
+	segments := strings.SplitN(str, ".", 2)
+	if len(segments) != 2 {
+		return Handle{}, fmt.Errorf("invalid namespace name %q", str)
+	}
+
+	return Handle{segments[0], segments[1]}, nil
+}
+
+// BucketsPrefix is the collection name prefix of the namespaces that store the
+// buckets of time-series collections.
+const BucketsPrefix = "system.buckets."
+
+// Buckets will return the handle of the namespace that stores the buckets of
+// the time-series collection with the specified handle.
+func (h Handle) Buckets() Handle {
+	return Handle{h[0], BucketsPrefix + h[1]}
+}
+
 // Local is the local database.
 const Local = "local"
 
@@ -40,6 +62,9 @@ var Oplog = Handle{Local, "oplog"}
 // Catalog is the top level object per database that contains all data.
 type Catalog struct {
 	Namespaces map[Handle]*mongokit.Collection
+
+	// the in-memory group state of materialized views
+	states map[*mongokit.Collection]materializedState
 }
 
 // NewCatalog creates and returns a new catalog.
@@ -63,5 +88,13 @@ func (d *Catalog) Clone() *Catalog {
 		clone.Namespaces[name] = namespace
 	}
 
+	// copy materialized view states
+	if len(d.states) > 0 {
+		clone.states = make(map[*mongokit.Collection]materializedState, len(d.states))
+		for view, state := range d.states {
+			clone.states[view] = state
+		}
+	}
+
 	return clone
 }
@@ -2,6 +2,8 @@ package lungo
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -20,8 +22,46 @@ type Collection struct {
 }
 
 // Aggregate implements the ICollection.Aggregate method.
-func (c *Collection) Aggregate(context.Context, interface{}, ...*options.AggregateOptions) (ICursor, error) {
-	panic("lungo: not implemented")
+func (c *Collection) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (ICursor, error) {
+	// merge options
+	opt := options.MergeAggregateOptions(opts...)
+
+	// assert supported options
+	assertOptions(opt, map[string]string{
+		"AllowDiskUse": ignored,
+		"BatchSize":    ignored,
+		"Collation":    supported,
+		"Comment":      ignored,
+		"MaxAwaitTime": ignored,
+		"MaxTime":      ignored,
+	})
+
+	// check pipeline
+	if pipeline == nil {
+		panic("lungo: missing pipeline")
+	}
+
+	// transform pipeline
+	list, err := bsonkit.TransformList(pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return nil, err
+	}
+
+	// run pipeline
+	res, err := useTransaction(ctx, c.engine, false, func(txn *Transaction) (interface{}, error) {
+		return txn.Aggregate(c.handle, list, collation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cursor{list: res.(bsonkit.List)}, nil
 }
 
 // BulkWrite implements the ICollection.BulkWrite method.
@@ -52,6 +92,7 @@ func (c *Collection) BulkWrite(ctx context.Context, models []mongo.WriteModel, o
 		var upsert *bool
 		var limit int
 		var arrayFilters []interface{}
+		var collation *options.Collation
 
 		// set variables
 		switch model := item.(type) {
@@ -65,6 +106,7 @@ func (c *Collection) BulkWrite(ctx context.Context, models []mongo.WriteModel, o
 			document = model.Replacement
 			upsert = model.Upsert
 			limit = 1
+			collation = model.Collation
 		case *mongo.UpdateOneModel:
 			opcode = Update
 			filter = model.Filter
@@ -74,6 +116,7 @@ func (c *Collection) BulkWrite(ctx context.Context, models []mongo.WriteModel, o
 			if model.ArrayFilters != nil {
 				arrayFilters = model.ArrayFilters.Filters
 			}
+			collation = model.Collation
 		case *mongo.UpdateManyModel:
 			opcode = Update
 			filter = model.Filter
@@ -83,14 +126,17 @@ func (c *Collection) BulkWrite(ctx context.Context, models []mongo.WriteModel, o
 			if model.ArrayFilters != nil {
 				arrayFilters = model.ArrayFilters.Filters
 			}
+			collation = model.Collation
 		case *mongo.DeleteOneModel:
 			opcode = Delete
 			filter = model.Filter
 			limit = 1
+			collation = model.Collation
 		case *mongo.DeleteManyModel:
 			opcode = Delete
 			filter = model.Filter
 			limit = 0
+			collation = model.Collation
 		}
 
 		// prepare operation
@@ -131,6 +177,15 @@ func (c *Collection) BulkWrite(ctx context.Context, models []mongo.WriteModel, o
 			op.ArrayFilters = arrFlt
 		}
 
+		// transform collation
+		if collation != nil {
+			coll, err := transformCollation(collation)
+			if err != nil {
+				return nil, err
+			}
+			op.Collation = coll
+		}
+
 		// add operation
 		ops = append(ops, op)
 	}
@@ -163,6 +218,14 @@ func (c *Collection) BulkWrite(ctx context.Context, models []mongo.WriteModel, o
 	for i, res := range results {
 		// check error
 		if res.Error != nil {
+			// keep write errors
+			if we, ok := res.Error.(mongo.WriteException); ok && len(we.WriteErrors) > 0 {
+				writeError := we.WriteErrors[0]
+				writeError.Index = i
+				errors = append(errors, writeError)
+				continue
+			}
+
 			errors = append(errors, mongo.WriteError{
 				Index:   i,
 				Code:    0,
@@ -228,9 +291,10 @@ func (c *Collection) CountDocuments(ctx context.Context, filter interface{}, opt
 
 	// assert supported options
 	assertOptions(opt, map[string]string{
-		"Limit":   supported,
-		"MaxTime": ignored,
-		"Skip":    supported,
+		"Collation": supported,
+		"Limit":     supported,
+		"MaxTime":   ignored,
+		"Skip":      supported,
 	})
 
 	// check filer
@@ -244,6 +308,12 @@ func (c *Collection) CountDocuments(ctx context.Context, filter interface{}, opt
 		return 0, err
 	}
 
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return 0, err
+	}
+
 	// get skip
 	var skip int
 	if opt.Skip != nil {
@@ -258,7 +328,7 @@ func (c *Collection) CountDocuments(ctx context.Context, filter interface{}, opt
 
 	// find documents
 	res, err := useTransaction(ctx, c.engine, false, func(txn *Transaction) (interface{}, error) {
-		return txn.Find(c.handle, query, nil, skip, limit)
+		return txn.Find(c.handle, query, nil, skip, limit, collation)
 	})
 	if err != nil {
 		return 0, err
@@ -280,18 +350,13 @@ func (c *Collection) Database() IDatabase {
 
 // DeleteMany implements the ICollection.DeleteMany method.
 func (c *Collection) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
-
-	// begin transaction
-	txn, err := c.engine.Begin(ctx, true)
-	if err != nil {
-		return nil, err
-	}
-
 	// merge options
 	opt := options.MergeDeleteOptions(opts...)
 
 	// assert supported options
-	assertOptions(opt, map[string]string{})
+	assertOptions(opt, map[string]string{
+		"Collation": supported,
+	})
 
 	// check filer
 	if filter == nil {
@@ -304,9 +369,15 @@ func (c *Collection) DeleteMany(ctx context.Context, filter interface{}, opts ..
 		return nil, err
 	}
 
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return nil, err
+	}
+
 	// delete documents
 	res, err := useTransaction(ctx, c.engine, true, func(txn *Transaction) (interface{}, error) {
-		return txn.Delete(c.handle, query, nil, 0, 0)
+		return txn.Delete(c.handle, query, nil, 0, 0, collation)
 	})
 	if err != nil {
 		return nil, err
@@ -315,12 +386,6 @@ func (c *Collection) DeleteMany(ctx context.Context, filter interface{}, opts ..
 	// get list
 	list := res.(*Result).Matched
 
-	// commit transaction
-	err = c.engine.Commit(txn)
-	if err != nil {
-		return nil, err
-	}
-
 	return &mongo.DeleteResult{
 		DeletedCount: int64(len(list)),
 	}, nil
@@ -328,16 +393,14 @@ func (c *Collection) DeleteMany(ctx context.Context, filter interface{}, opts ..
 
 // DeleteOne implements the ICollection.DeleteOne method.
 func (c *Collection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
-	txn, err := c.engine.Begin(ctx, true)
-	if err != nil {
-		return nil, err
-	}
 
 	// merge options
 	opt := options.MergeDeleteOptions(opts...)
 
 	// assert supported options
-	assertOptions(opt, map[string]string{})
+	assertOptions(opt, map[string]string{
+		"Collation": supported,
+	})
 
 	// check filer
 	if filter == nil {
@@ -350,9 +413,15 @@ func (c *Collection) DeleteOne(ctx context.Context, filter interface{}, opts ...
 		return nil, err
 	}
 
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return nil, err
+	}
+
 	// delete document
 	res, err := useTransaction(ctx, c.engine, true, func(txn *Transaction) (interface{}, error) {
-		return txn.Delete(c.handle, query, nil, 0, 1)
+		return txn.Delete(c.handle, query, nil, 0, 1, collation)
 	})
 	if err != nil {
 		return nil, err
@@ -361,11 +430,6 @@ func (c *Collection) DeleteOne(ctx context.Context, filter interface{}, opts ...
 	// get list
 	list := res.(*Result).Matched
 
-	err = c.engine.Commit(txn)
-	if err != nil {
-		return nil, err
-	}
-
 	return &mongo.DeleteResult{
 		DeletedCount: int64(len(list)),
 	}, nil
@@ -378,7 +442,8 @@ func (c *Collection) Distinct(ctx context.Context, field string, filter interfac
 
 	// assert supported options
 	assertOptions(opt, map[string]string{
-		"MaxTime": ignored,
+		"Collation": supported,
+		"MaxTime":   ignored,
 	})
 
 	// check field
@@ -397,21 +462,21 @@ func (c *Collection) Distinct(ctx context.Context, field string, filter interfac
 		return nil, err
 	}
 
-	// find documents
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return nil, err
+	}
+
+	// find distinct values
 	res, err := useTransaction(ctx, c.engine, false, func(txn *Transaction) (interface{}, error) {
-		return txn.Find(c.handle, query, nil, 0, 0)
+		return txn.Distinct(c.handle, field, query, collation)
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	// get list
-	list := res.(*Result).Matched
-
-	// collect distinct values
-	values := mongokit.Distinct(list, field)
-
-	return values, nil
+	return res.(bson.A), nil
 }
 
 // Drop implements the ICollection.Drop method.
@@ -470,7 +535,9 @@ func (c *Collection) Find(ctx context.Context, filter interface{}, opts ...*opti
 	assertOptions(opt, map[string]string{
 		"AllowPartialResults": ignored,
 		"BatchSize":           ignored,
+		"Collation":           supported,
 		"Comment":             ignored,
+		"CursorType":          supported,
 		"Limit":               supported,
 		"MaxAwaitTime":        ignored,
 		"MaxTime":             ignored,
@@ -492,6 +559,12 @@ func (c *Collection) Find(ctx context.Context, filter interface{}, opts ...*opti
 		return nil, err
 	}
 
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return nil, err
+	}
+
 	// get sort
 	var sort bsonkit.Doc
 	if opt.Sort != nil {
@@ -522,9 +595,19 @@ func (c *Collection) Find(ctx context.Context, filter interface{}, opts ...*opti
 		limit = int(*opt.Limit)
 	}
 
+	// handle tailable cursors
+	if opt.CursorType != nil && (*opt.CursorType == options.Tailable || *opt.CursorType == options.TailableAwait) {
+		// check sort
+		if sort != nil && len(*sort) > 0 {
+			return nil, fmt.Errorf("cannot use tailable option with a sort")
+		}
+
+		return c.tail(ctx, query, projection, collation)
+	}
+
 	// find documents
 	res, err := useTransaction(ctx, c.engine, false, func(txn *Transaction) (interface{}, error) {
-		return txn.Find(c.handle, query, sort, skip, limit)
+		return txn.Find(c.handle, query, sort, skip, limit, collation)
 	})
 	if err != nil {
 		return nil, err
@@ -544,6 +627,57 @@ func (c *Collection) Find(ctx context.Context, filter interface{}, opts ...*opti
 	return &Cursor{list: list}, nil
 }
 
+func (c *Collection) tail(ctx context.Context, query, projection, collation bsonkit.Doc) (ICursor, error) {
+	// subscribe to commits
+	signal, cancel, err := c.engine.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare fetch
+	var last bsonkit.Doc
+	fetch := func(ctx context.Context) (bsonkit.List, error) {
+		// tail documents
+		var list bsonkit.List
+		_, err := useTransaction(ctx, c.engine, false, func(txn *Transaction) (interface{}, error) {
+			var err error
+			list, last, err = txn.Tail(c.handle, query, last, collation)
+			return nil, err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// apply projection
+		if projection != nil && len(list) > 0 {
+			list, err = mongokit.ProjectList(list, projection)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return list, nil
+	}
+
+	// fetch initial documents
+	list, err := fetch(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &Cursor{
+		list: list,
+		tail: &tail{
+			id:     atomic.AddInt64(&cursorCounter, 1),
+			fetch:  fetch,
+			signal: signal,
+			cancel: cancel,
+			done:   make(chan struct{}),
+		},
+	}, nil
+}
+
 // FindOne implements the ICollection.FindOne method.
 func (c *Collection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) ISingleResult {
 	// merge options
@@ -553,6 +687,7 @@ func (c *Collection) FindOne(ctx context.Context, filter interface{}, opts ...*o
 	assertOptions(opt, map[string]string{
 		"AllowPartialResults": ignored,
 		"BatchSize":           ignored,
+		"Collation":           supported,
 		"Comment":             ignored,
 		"MaxAwaitTime":        ignored,
 		"MaxTime":             ignored,
@@ -574,6 +709,12 @@ func (c *Collection) FindOne(ctx context.Context, filter interface{}, opts ...*o
 		return &SingleResult{err: err}
 	}
 
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
 	// get sort
 	var sort bsonkit.Doc
 	if opt.Sort != nil {
@@ -600,7 +741,7 @@ func (c *Collection) FindOne(ctx context.Context, filter interface{}, opts ...*o
 
 	// find documents
 	res, err := useTransaction(ctx, c.engine, false, func(txn *Transaction) (interface{}, error) {
-		return txn.Find(c.handle, query, sort, skip, 1)
+		return txn.Find(c.handle, query, sort, skip, 1, collation)
 	})
 	if err != nil {
 		return &SingleResult{err: err}
@@ -632,6 +773,7 @@ func (c *Collection) FindOneAndDelete(ctx context.Context, filter interface{}, o
 
 	// assert supported options
 	assertOptions(opt, map[string]string{
+		"Collation":  supported,
 		"MaxTime":    ignored,
 		"Projection": supported,
 		"Sort":       supported,
@@ -648,6 +790,12 @@ func (c *Collection) FindOneAndDelete(ctx context.Context, filter interface{}, o
 		return &SingleResult{err: err}
 	}
 
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
 	// get projection
 	var projection bsonkit.Doc
 	if opt.Projection != nil {
@@ -668,7 +816,7 @@ func (c *Collection) FindOneAndDelete(ctx context.Context, filter interface{}, o
 
 	// delete documents
 	res, err := useTransaction(ctx, c.engine, true, func(txn *Transaction) (interface{}, error) {
-		return txn.Delete(c.handle, query, sort, 0, 1)
+		return txn.Delete(c.handle, query, sort, 0, 1, collation)
 	})
 	if err != nil {
 		return &SingleResult{err: err}
@@ -700,6 +848,7 @@ func (c *Collection) FindOneAndReplace(ctx context.Context, filter, replacement
 
 	// assert supported options
 	assertOptions(opt, map[string]string{
+		"Collation":      supported,
 		"MaxTime":        ignored,
 		"Projection":     supported,
 		"ReturnDocument": supported,
@@ -723,6 +872,12 @@ func (c *Collection) FindOneAndReplace(ctx context.Context, filter, replacement
 		return &SingleResult{err: err}
 	}
 
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
 	// get projection
 	var projection bsonkit.Doc
 	if opt.Projection != nil {
@@ -761,7 +916,7 @@ func (c *Collection) FindOneAndReplace(ctx context.Context, filter, replacement
 
 	// insert document
 	res, err := useTransaction(ctx, c.engine, true, func(txn *Transaction) (interface{}, error) {
-		return txn.Replace(c.handle, query, sort, repl, upsert)
+		return txn.Replace(c.handle, query, sort, repl, upsert, collation)
 	})
 	if err != nil {
 		return &SingleResult{err: err}
@@ -801,6 +956,7 @@ func (c *Collection) FindOneAndUpdate(ctx context.Context, filter, update interf
 
 	// assert supported options
 	assertOptions(opt, map[string]string{
+		"Collation":      supported,
 		"MaxTime":        ignored,
 		"Projection":     supported,
 		"ReturnDocument": supported,
@@ -825,6 +981,12 @@ func (c *Collection) FindOneAndUpdate(ctx context.Context, filter, update interf
 		return &SingleResult{err: err}
 	}
 
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
 	// get projection
 	var projection bsonkit.Doc
 	if opt.Projection != nil {
@@ -872,7 +1034,7 @@ func (c *Collection) FindOneAndUpdate(ctx context.Context, filter, update interf
 
 	// update documents
 	res, err := useTransaction(ctx, c.engine, true, func(txn *Transaction) (interface{}, error) {
-		return txn.Update(c.handle, query, sort, upd, 0, 1, upsert, arrayFilters)
+		return txn.Update(c.handle, query, sort, upd, 0, 1, upsert, arrayFilters, collation)
 	})
 	if err != nil {
 		return &SingleResult{err: err}
@@ -915,12 +1077,6 @@ func (c *Collection) Indexes() IIndexView {
 
 // InsertMany implements the ICollection.InsertMany method.
 func (c *Collection) InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
-	// begin transaction
-	txn, err := c.engine.Begin(ctx, true)
-	if err != nil {
-		return nil, err
-	}
-
 	// merge options
 	opt := options.MergeInsertManyOptions(opts...)
 
@@ -966,25 +1122,13 @@ func (c *Collection) InsertMany(ctx context.Context, documents []interface{}, op
 	// get result
 	result := res.(*Result)
 
-	// commit transaction
-	err = c.engine.Commit(txn)
-	if err != nil {
-		return nil, err
-	}
-
 	return &mongo.InsertManyResult{
 		InsertedIDs: bsonkit.Pick(result.Modified, "_id", false),
 	}, result.Error
 }
 
 // InsertOne implements the ICollection.InsertOne method.
-func (c *Collection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
-	// begin transaction
-	txn, err := c.engine.Begin(ctx, true)
-	if err != nil {
-		return nil, err
-	}
-	// merge options
+func (c *Collection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) { // merge options
 	opt := options.MergeInsertOneOptions(opts...)
 
 	// assert supported options
@@ -1017,12 +1161,6 @@ func (c *Collection) InsertOne(ctx context.Context, document interface{}, opts .
 		return nil, result.Error
 	}
 
-	// commit transaction
-	err = c.engine.Commit(txn)
-	if err != nil {
-		return nil, err
-	}
-
 	return &mongo.InsertOneResult{
 		InsertedID: bsonkit.Get(result.Modified[0], "_id"),
 	}, nil
@@ -1034,18 +1172,13 @@ func (c *Collection) Name() string {
 }
 
 // ReplaceOne implements the ICollection.ReplaceOne method.
-func (c *Collection) ReplaceOne(ctx context.Context, filter, replacement interface{}, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
-	// begin transaction
-	txn, err := c.engine.Begin(ctx, true)
-	if err != nil {
-		return nil, err
-	}
-	// merge options
+func (c *Collection) ReplaceOne(ctx context.Context, filter, replacement interface{}, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error) { // merge options
 	opt := options.MergeReplaceOptions(opts...)
 
 	// assert supported options
 	assertOptions(opt, map[string]string{
-		"Upsert": supported,
+		"Collation": supported,
+		"Upsert":    supported,
 	})
 
 	// check filer
@@ -1064,6 +1197,12 @@ func (c *Collection) ReplaceOne(ctx context.Context, filter, replacement interfa
 		return nil, err
 	}
 
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return nil, err
+	}
+
 	// transform document
 	doc, err := bsonkit.Transform(replacement)
 	if err != nil {
@@ -1078,7 +1217,7 @@ func (c *Collection) ReplaceOne(ctx context.Context, filter, replacement interfa
 
 	// insert document
 	res, err := useTransaction(ctx, c.engine, true, func(txn *Transaction) (interface{}, error) {
-		return txn.Replace(c.handle, query, nil, doc, upsert)
+		return txn.Replace(c.handle, query, nil, doc, upsert, collation)
 	})
 	if err != nil {
 		return nil, err
@@ -1095,12 +1234,6 @@ func (c *Collection) ReplaceOne(ctx context.Context, filter, replacement interfa
 		}, nil
 	}
 
-	// commit transaction
-	err = c.engine.Commit(txn)
-	if err != nil {
-		return nil, err
-	}
-
 	return &mongo.UpdateResult{
 		MatchedCount:  int64(len(result.Matched)),
 		ModifiedCount: int64(len(result.Modified)),
@@ -1118,17 +1251,12 @@ func (c *Collection) UpdateByID(ctx context.Context, id interface{}, update inte
 }
 
 // UpdateMany implements the ICollection.UpdateMany method.
-func (c *Collection) UpdateMany(ctx context.Context, filter, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
-	// begin transaction
-	txn, err := c.engine.Begin(ctx, true)
-	if err != nil {
-		return nil, err
-	}
-	// merge options
+func (c *Collection) UpdateMany(ctx context.Context, filter, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) { // merge options
 	opt := options.MergeUpdateOptions(opts...)
 
 	// assert supported options
 	assertOptions(opt, map[string]string{
+		"Collation":    supported,
 		"Upsert":       supported,
 		"ArrayFilters": supported,
 	})
@@ -1149,6 +1277,12 @@ func (c *Collection) UpdateMany(ctx context.Context, filter, update interface{},
 		return nil, err
 	}
 
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return nil, err
+	}
+
 	// transform document
 	doc, err := bsonkit.Transform(update)
 	if err != nil {
@@ -1172,7 +1306,7 @@ func (c *Collection) UpdateMany(ctx context.Context, filter, update interface{},
 
 	// update documents
 	res, err := useTransaction(ctx, c.engine, true, func(txn *Transaction) (interface{}, error) {
-		return txn.Update(c.handle, query, nil, doc, 0, 0, upsert, arrayFilters)
+		return txn.Update(c.handle, query, nil, doc, 0, 0, upsert, arrayFilters, collation)
 	})
 	if err != nil {
 		return nil, err
@@ -1189,12 +1323,6 @@ func (c *Collection) UpdateMany(ctx context.Context, filter, update interface{},
 		}, nil
 	}
 
-	// commit transaction
-	err = c.engine.Commit(txn)
-	if err != nil {
-		return nil, err
-	}
-
 	return &mongo.UpdateResult{
 		MatchedCount:  int64(len(result.Matched)),
 		ModifiedCount: int64(len(result.Modified)),
@@ -1203,17 +1331,12 @@ func (c *Collection) UpdateMany(ctx context.Context, filter, update interface{},
 
 // UpdateOne implements the ICollection.UpdateOne method.
 func (c *Collection) UpdateOne(ctx context.Context, filter, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
-	// begin transaction
-	txn, err := c.engine.Begin(ctx, true)
-	if err != nil {
-		return nil, err
-	}
-
 	// merge options
 	opt := options.MergeUpdateOptions(opts...)
 
 	// assert supported options
 	assertOptions(opt, map[string]string{
+		"Collation":    supported,
 		"Upsert":       supported,
 		"ArrayFilters": supported,
 	})
@@ -1234,6 +1357,12 @@ func (c *Collection) UpdateOne(ctx context.Context, filter, update interface{},
 		return nil, err
 	}
 
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return nil, err
+	}
+
 	// transform document
 	doc, err := bsonkit.Transform(update)
 	if err != nil {
@@ -1257,7 +1386,7 @@ func (c *Collection) UpdateOne(ctx context.Context, filter, update interface{},
 
 	// update documents
 	res, err := useTransaction(ctx, c.engine, true, func(txn *Transaction) (interface{}, error) {
-		return txn.Update(c.handle, query, nil, doc, 0, 1, upsert, arrayFilters)
+		return txn.Update(c.handle, query, nil, doc, 0, 1, upsert, arrayFilters, collation)
 	})
 	if err != nil {
 		return nil, err
@@ -1274,12 +1403,6 @@ func (c *Collection) UpdateOne(ctx context.Context, filter, update interface{},
 		}, nil
 	}
 
-	// commit transaction
-	err = c.engine.Commit(txn)
-	if err != nil {
-		return nil, err
-	}
-
 	return &mongo.UpdateResult{
 		MatchedCount:  int64(len(result.Matched)),
 		ModifiedCount: int64(len(result.Modified)),
@@ -1,8 +1,10 @@
 package lungo
 
 import (
+	"context"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
@@ -11,6 +13,45 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+func TestCollectionAggregate(t *testing.T) {
+	collectionTest(t, func(t *testing.T, c ICollection) {
+		_, err := c.InsertMany(nil, []interface{}{
+			bson.M{"_id": int32(1), "tag": "a", "n": int32(2)},
+			bson.M{"_id": int32(2), "tag": "b", "n": int32(5)},
+			bson.M{"_id": int32(3), "tag": "a", "n": int32(4)},
+		})
+		assert.NoError(t, err)
+
+		csr, err := c.Aggregate(nil, mongo.Pipeline{
+			bson.D{{Key: "$match", Value: bson.M{"n": bson.M{"$gt": int32(2)}}}},
+			bson.D{{Key: "$group", Value: bson.M{"_id": "$tag", "total": bson.M{"$sum": "$n"}}}},
+			bson.D{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": "a", "total": int32(4)},
+			{"_id": "b", "total": int32(5)},
+		}, readAll(csr))
+
+		csr, err = c.Aggregate(nil, bson.A{
+			bson.M{"$match": bson.M{"tag": "A"}},
+			bson.M{"$count": "n"},
+		}, options.Aggregate().SetCollation(&options.Collation{
+			Locale:   "en",
+			Strength: 1,
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"n": int32(2)},
+		}, readAll(csr))
+
+		_, err = c.Aggregate(nil, bson.A{
+			bson.M{"$foo": "bar"},
+		})
+		assert.Error(t, err)
+	})
+}
+
 func TestCollectionBulkWrite(t *testing.T) {
 	collectionTest(t, func(t *testing.T, c ICollection) {
 		id1 := primitive.NewObjectID()
@@ -168,6 +209,57 @@ func TestCollectionCountDocuments(t *testing.T) {
 	})
 }
 
+func TestCollectionCollation(t *testing.T) {
+	collectionTest(t, func(t *testing.T, c ICollection) {
+		_, err := c.InsertMany(nil, []interface{}{
+			bson.M{"_id": int32(1), "name": "Joe", "code": "10"},
+			bson.M{"_id": int32(2), "name": "joe", "code": "9"},
+			bson.M{"_id": int32(3), "name": "Jane", "code": "100"},
+		})
+		assert.NoError(t, err)
+
+		caseInsensitive := &options.Collation{Locale: "en", Strength: 2}
+		numeric := &options.Collation{Locale: "en", NumericOrdering: true}
+
+		// find
+		csr, err := c.Find(nil, bson.M{"name": "JOE"}, options.Find().SetCollation(caseInsensitive).SetSort(bson.M{"_id": 1}))
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": int32(1), "name": "Joe", "code": "10"},
+			{"_id": int32(2), "name": "joe", "code": "9"},
+		}, readAll(csr))
+
+		// sort
+		csr, err = c.Find(nil, bson.M{}, options.Find().SetCollation(numeric).SetSort(bson.M{"code": 1}))
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": int32(2), "name": "joe", "code": "9"},
+			{"_id": int32(1), "name": "Joe", "code": "10"},
+			{"_id": int32(3), "name": "Jane", "code": "100"},
+		}, readAll(csr))
+
+		// count
+		num, err := c.CountDocuments(nil, bson.M{"name": "joe"}, options.Count().SetCollation(caseInsensitive))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), num)
+
+		// distinct
+		res, err := c.Distinct(nil, "name", bson.M{}, options.Distinct().SetCollation(caseInsensitive))
+		assert.NoError(t, err)
+		assert.Len(t, res, 2)
+
+		// update
+		res2, err := c.UpdateMany(nil, bson.M{"name": "JOE"}, bson.M{"$set": bson.M{"found": true}}, options.Update().SetCollation(caseInsensitive))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), res2.ModifiedCount)
+
+		// delete
+		res3, err := c.DeleteMany(nil, bson.M{"name": "JOE"}, options.Delete().SetCollation(caseInsensitive))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), res3.DeletedCount)
+	})
+}
+
 func TestCollectionDatabase(t *testing.T) {
 	databaseTest(t, func(t *testing.T, d IDatabase) {
 		assert.Equal(t, d, d.Collection("").Database())
@@ -246,6 +338,27 @@ func TestCollectionDeleteMany(t *testing.T) {
 	})
 }
 
+func TestCollectionDeleteManyLock(t *testing.T) {
+	c := testLungoClient.Database(testDB).Collection(collectionName())
+
+	_, err := c.InsertOne(nil, bson.M{"foo": "bar"})
+	assert.NoError(t, err)
+
+	// the delete must not hold the engine token while it runs its own
+	// transaction, otherwise it blocks until the acquisition times out
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	res, err := c.DeleteMany(ctx, bson.M{})
+	assert.NoError(t, err)
+	assert.Equal(t, &mongo.DeleteResult{DeletedCount: 1}, res)
+
+	// the token must have been released
+	txn, err := testLungoEngine.Begin(ctx, true)
+	assert.NoError(t, err)
+	testLungoEngine.Abort(txn)
+}
+
 func TestCollectionDeleteOne(t *testing.T) {
 	// missing database
 	clientTest(t, func(t *testing.T, client IClient) {
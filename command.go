@@ -0,0 +1,1484 @@
+package lungo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+)
+
+// https://www.mongodb.com/docs/manual/reference/command/
+
+// Command is a database command that can be run using Database.RunCommand.
+type Command struct {
+	// A short description of the command.
+	Help string
+
+	// The handler that executes the command. The returned reply is completed
+	// with the "ok" field by the dispatcher.
+	Handler func(ctx context.Context, db *Database, cmd bson.D) (bson.D, error)
+}
+
+// Commands defines the available database commands.
+var Commands = map[string]Command{}
+
+func init() {
+	// register diagnostic commands
+	Commands["ping"] = Command{Help: "a way to check that the server is alive", Handler: cmdPing}
+	Commands["hello"] = Command{Help: "describes the role of the instance", Handler: cmdHello}
+	Commands["isMaster"] = Commands["hello"]
+	Commands["ismaster"] = Commands["hello"]
+	Commands["buildInfo"] = Command{Help: "returns build information", Handler: cmdBuildInfo}
+	Commands["buildinfo"] = Commands["buildInfo"]
+	Commands["listCommands"] = Command{Help: "lists the available commands", Handler: cmdListCommands}
+
+	// register query and write commands
+	Commands["count"] = Command{Help: "counts the documents in a collection", Handler: cmdCount}
+	Commands["distinct"] = Command{Help: "returns the distinct values of a field", Handler: cmdDistinct}
+	Commands["find"] = Command{Help: "queries documents in a collection", Handler: cmdFind}
+	Commands["insert"] = Command{Help: "inserts documents into a collection", Handler: cmdInsert}
+	Commands["update"] = Command{Help: "updates documents in a collection", Handler: cmdUpdate}
+	Commands["delete"] = Command{Help: "deletes documents from a collection", Handler: cmdDelete}
+	Commands["findAndModify"] = Command{Help: "modifies and returns a single document", Handler: cmdFindAndModify}
+	Commands["findandmodify"] = Commands["findAndModify"]
+
+	// register administrative commands
+	Commands["create"] = Command{Help: "creates a collection or view", Handler: cmdCreate}
+	Commands["drop"] = Command{Help: "drops a collection", Handler: cmdDrop}
+	Commands["listCollections"] = Command{Help: "lists the collections of a database", Handler: cmdListCollections}
+	Commands["listIndexes"] = Command{Help: "lists the indexes of a collection", Handler: cmdListIndexes}
+	Commands["createIndexes"] = Command{Help: "creates indexes on a collection", Handler: cmdCreateIndexes}
+	Commands["dropIndexes"] = Command{Help: "drops indexes from a collection", Handler: cmdDropIndexes}
+	Commands["collMod"] = Command{Help: "modifies collection options and indexes", Handler: cmdCollMod}
+	Commands["renameCollection"] = Command{Help: "renames a collection", Handler: cmdRenameCollection}
+	Commands["collStats"] = Command{Help: "returns storage statistics of a collection", Handler: cmdCollStats}
+	Commands["dbStats"] = Command{Help: "returns storage statistics of a database", Handler: cmdDBStats}
+	Commands["validate"] = Command{Help: "validates the documents and indexes of a collection", Handler: cmdValidate}
+}
+
+// RunCommand implements the IDatabase.RunCommand method.
+func (d *Database) RunCommand(ctx context.Context, runCommand interface{}, opts ...*options.RunCmdOptions) ISingleResult {
+	// merge options
+	opt := options.MergeRunCmdOptions(opts...)
+
+	// assert supported options
+	assertOptions(opt, map[string]string{
+		"ReadPreference": ignored,
+	})
+
+	// run command
+	reply, err := d.runCommand(ctx, runCommand)
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return &SingleResult{doc: reply}
+}
+
+// RunCommandCursor implements the IDatabase.RunCommandCursor method.
+func (d *Database) RunCommandCursor(ctx context.Context, runCommand interface{}, opts ...*options.RunCmdOptions) (ICursor, error) {
+	// merge options
+	opt := options.MergeRunCmdOptions(opts...)
+
+	// assert supported options
+	assertOptions(opt, map[string]string{
+		"ReadPreference": ignored,
+	})
+
+	// run command
+	reply, err := d.runCommand(ctx, runCommand)
+	if err != nil {
+		return nil, err
+	}
+
+	// get batch
+	batch, ok := bsonkit.Get(reply, "cursor.firstBatch").(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("database response does not contain a cursor")
+	}
+
+	// prepare list
+	list := make(bsonkit.List, 0, len(batch))
+	for _, item := range batch {
+		doc, ok := item.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("database response contains an invalid cursor")
+		}
+		list = append(list, &doc)
+	}
+
+	return &Cursor{list: list}, nil
+}
+
+func (d *Database) runCommand(ctx context.Context, runCommand interface{}) (bsonkit.Doc, error) {
+	// transform command
+	cmd, err := bsonkit.Transform(runCommand)
+	if err != nil {
+		return nil, err
+	}
+
+	// check command
+	if len(*cmd) == 0 {
+		return nil, commandError(59, "CommandNotFound", "no command specified")
+	}
+
+	// get command
+	name := (*cmd)[0].Key
+	command, ok := Commands[name]
+	if !ok {
+		return nil, commandError(59, "CommandNotFound", "no such command: '%s'", name)
+	}
+
+	// run command
+	reply, err := command.Handler(ctx, d, *cmd)
+	if err != nil {
+		return nil, wrapCommandError(err)
+	}
+
+	// add ok
+	reply = append(reply, bson.E{Key: "ok", Value: 1.0})
+
+	return &reply, nil
+}
+
+func cmdPing(context.Context, *Database, bson.D) (bson.D, error) {
+	return bson.D{}, nil
+}
+
+func cmdHello(_ context.Context, _ *Database, cmd bson.D) (bson.D, error) {
+	// get primary field
+	field := "isWritablePrimary"
+	if cmd[0].Key != "hello" {
+		field = "ismaster"
+	}
+
+	return bson.D{
+		bson.E{Key: field, Value: true},
+		bson.E{Key: "maxBsonObjectSize", Value: int32(16 * 1024 * 1024)},
+		bson.E{Key: "maxMessageSizeBytes", Value: int32(48000000)},
+		bson.E{Key: "maxWriteBatchSize", Value: int32(100000)},
+		bson.E{Key: "localTime", Value: primitive.NewDateTimeFromTime(time.Now())},
+		bson.E{Key: "logicalSessionTimeoutMinutes", Value: int32(30)},
+		bson.E{Key: "minWireVersion", Value: int32(0)},
+		bson.E{Key: "maxWireVersion", Value: int32(13)},
+		bson.E{Key: "readOnly", Value: false},
+	}, nil
+}
+
+func cmdBuildInfo(context.Context, *Database, bson.D) (bson.D, error) {
+	return bson.D{
+		bson.E{Key: "version", Value: "5.0.0"},
+		bson.E{Key: "gitVersion", Value: ""},
+		bson.E{Key: "versionArray", Value: bson.A{int32(5), int32(0), int32(0), int32(0)}},
+		bson.E{Key: "bits", Value: int32(64)},
+		bson.E{Key: "debug", Value: false},
+		bson.E{Key: "maxBsonObjectSize", Value: int32(16 * 1024 * 1024)},
+		bson.E{Key: "storageEngines", Value: bson.A{"lungo"}},
+	}, nil
+}
+
+func cmdListCommands(context.Context, *Database, bson.D) (bson.D, error) {
+	// sort names
+	names := make([]string, 0, len(Commands))
+	for name := range Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// collect commands
+	commands := make(bson.D, 0, len(names))
+	for _, name := range names {
+		commands = append(commands, bson.E{Key: name, Value: bson.D{
+			bson.E{Key: "help", Value: Commands[name].Help},
+			bson.E{Key: "requiresAuth", Value: false},
+			bson.E{Key: "adminOnly", Value: false},
+		}})
+	}
+
+	return bson.D{
+		bson.E{Key: "commands", Value: commands},
+	}, nil
+}
+
+func cmdCount(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get query and collation
+	query, err := commandDoc(cmd, "query", true)
+	if err != nil {
+		return nil, err
+	}
+	collation, err := commandDoc(cmd, "collation", false)
+	if err != nil {
+		return nil, err
+	}
+
+	// get skip and limit
+	skip, err := commandInt(cmd, "skip")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := commandInt(cmd, "limit")
+	if err != nil {
+		return nil, err
+	}
+	if limit < 0 {
+		limit = -limit
+	}
+
+	// find documents
+	res, err := useTransaction(ctx, db.engine, false, func(txn *Transaction) (interface{}, error) {
+		return txn.Find(handle, query, nil, skip, limit, collation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.D{
+		bson.E{Key: "n", Value: int32(len(res.(*Result).Matched))},
+	}, nil
+}
+
+func cmdDistinct(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get key
+	key, ok := bsonkit.Get(&cmd, "key").(string)
+	if !ok {
+		return nil, commandError(14, "TypeMismatch", "BSON field 'distinct.key' is missing or not a string")
+	}
+
+	// get query and collation
+	query, err := commandDoc(cmd, "query", true)
+	if err != nil {
+		return nil, err
+	}
+	collation, err := commandDoc(cmd, "collation", false)
+	if err != nil {
+		return nil, err
+	}
+
+	// find distinct values
+	res, err := useTransaction(ctx, db.engine, false, func(txn *Transaction) (interface{}, error) {
+		return txn.Distinct(handle, key, query, collation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.D{
+		bson.E{Key: "values", Value: res.(bson.A)},
+	}, nil
+}
+
+func cmdFind(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get filter, sort, projection and collation
+	filter, err := commandDoc(cmd, "filter", true)
+	if err != nil {
+		return nil, err
+	}
+	sort, err := commandDoc(cmd, "sort", false)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := commandDoc(cmd, "projection", false)
+	if err != nil {
+		return nil, err
+	}
+	collation, err := commandDoc(cmd, "collation", false)
+	if err != nil {
+		return nil, err
+	}
+
+	// get skip and limit
+	skip, err := commandInt(cmd, "skip")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := commandInt(cmd, "limit")
+	if err != nil {
+		return nil, err
+	}
+	if limit < 0 {
+		limit = -limit
+	}
+
+	// find documents
+	res, err := useTransaction(ctx, db.engine, false, func(txn *Transaction) (interface{}, error) {
+		return txn.Find(handle, filter, sort, skip, limit, collation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// get list
+	list := res.(*Result).Matched
+
+	// apply projection
+	if projection != nil {
+		list, err = mongokit.ProjectList(list, projection)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return commandCursor(handle.String(), list), nil
+}
+
+func cmdInsert(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get documents
+	docs, err := commandList(cmd, "documents")
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare operations
+	ops := make([]Operation, 0, len(docs))
+	for _, doc := range docs {
+		ops = append(ops, Operation{
+			Opcode:   Insert,
+			Document: doc,
+			Limit:    1,
+		})
+	}
+
+	return commandWrite(ctx, db, handle, cmd, ops)
+}
+
+func cmdUpdate(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get updates
+	updates, err := commandList(cmd, "updates")
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare operations
+	ops := make([]Operation, 0, len(updates))
+	for _, update := range updates {
+		// get filter, update and collation
+		filter, err := commandDoc(*update, "q", true)
+		if err != nil {
+			return nil, err
+		}
+		doc, ok := bsonkit.Get(update, "u").(bson.D)
+		if !ok {
+			return nil, commandError(14, "TypeMismatch", "BSON field 'update.updates.u' is missing or not an object")
+		}
+		collation, err := commandDoc(*update, "collation", false)
+		if err != nil {
+			return nil, err
+		}
+
+		// get array filters
+		var arrayFilters bsonkit.List
+		if _, ok := bsonkit.Get(update, "arrayFilters").(bson.A); ok {
+			arrayFilters, err = commandList(*update, "arrayFilters")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// get flags
+		upsert := commandBool(*update, "upsert")
+		multi := commandBool(*update, "multi")
+
+		// prepare operation
+		op := Operation{
+			Opcode:       Update,
+			Filter:       filter,
+			Document:     &doc,
+			Upsert:       upsert,
+			ArrayFilters: arrayFilters,
+			Collation:    collation,
+		}
+
+		// check replacement
+		if len(doc) == 0 || !strings.HasPrefix(doc[0].Key, "$") {
+			if multi {
+				return nil, commandError(9, "FailedToParse", "multi update is not supported for replacement-style update")
+			}
+			op.Opcode = Replace
+		}
+
+		// set limit
+		if !multi {
+			op.Limit = 1
+		}
+
+		ops = append(ops, op)
+	}
+
+	return commandWrite(ctx, db, handle, cmd, ops)
+}
+
+func cmdDelete(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get deletes
+	deletes, err := commandList(cmd, "deletes")
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare operations
+	ops := make([]Operation, 0, len(deletes))
+	for _, del := range deletes {
+		// get filter and collation
+		filter, err := commandDoc(*del, "q", true)
+		if err != nil {
+			return nil, err
+		}
+		collation, err := commandDoc(*del, "collation", false)
+		if err != nil {
+			return nil, err
+		}
+
+		// get limit
+		limit, err := commandInt(*del, "limit")
+		if err != nil {
+			return nil, err
+		} else if limit != 0 && limit != 1 {
+			return nil, commandError(9, "FailedToParse", "the limit field in delete objects must be 0 or 1. Got %d", limit)
+		}
+
+		ops = append(ops, Operation{
+			Opcode:    Delete,
+			Filter:    filter,
+			Limit:     limit,
+			Collation: collation,
+		})
+	}
+
+	return commandWrite(ctx, db, handle, cmd, ops)
+}
+
+func cmdFindAndModify(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get query, sort, fields and collation
+	query, err := commandDoc(cmd, "query", true)
+	if err != nil {
+		return nil, err
+	}
+	sort, err := commandDoc(cmd, "sort", false)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := commandDoc(cmd, "fields", false)
+	if err != nil {
+		return nil, err
+	}
+	collation, err := commandDoc(cmd, "collation", false)
+	if err != nil {
+		return nil, err
+	}
+	update, err := commandDoc(cmd, "update", false)
+	if err != nil {
+		return nil, err
+	}
+
+	// get array filters
+	var arrayFilters bsonkit.List
+	if _, ok := bsonkit.Get(&cmd, "arrayFilters").(bson.A); ok {
+		arrayFilters, err = commandList(cmd, "arrayFilters")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// get flags
+	remove := commandBool(cmd, "remove")
+	returnNew := commandBool(cmd, "new")
+	upsert := commandBool(cmd, "upsert")
+
+	// check flags
+	if remove == (update != nil) {
+		return nil, commandError(9, "FailedToParse", "Either an update or remove=true must be specified")
+	} else if remove && (returnNew || upsert) {
+		return nil, commandError(9, "FailedToParse", "Cannot specify both new=true or upsert=true and remove=true")
+	}
+
+	// run operation
+	res, err := useTransaction(ctx, db.engine, true, func(txn *Transaction) (interface{}, error) {
+		if remove {
+			return txn.Delete(handle, query, sort, 0, 1, collation)
+		} else if len(*update) > 0 && strings.HasPrefix((*update)[0].Key, "$") {
+			return txn.Update(handle, query, sort, update, 0, 1, upsert, arrayFilters, collation)
+		}
+		return txn.Replace(handle, query, sort, update, upsert, collation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// get result
+	result := res.(*Result)
+
+	// get document and status
+	var doc bsonkit.Doc
+	status := bson.D{}
+	if result.Upserted != nil {
+		status = append(status, bson.E{Key: "n", Value: int32(1)})
+		status = append(status, bson.E{Key: "updatedExisting", Value: false})
+		status = append(status, bson.E{Key: "upserted", Value: bsonkit.Get(result.Upserted, "_id")})
+		if returnNew {
+			doc = result.Upserted
+		}
+	} else if len(result.Matched) > 0 {
+		status = append(status, bson.E{Key: "n", Value: int32(1)})
+		if !remove {
+			status = append(status, bson.E{Key: "updatedExisting", Value: true})
+		}
+		doc = result.Matched[0]
+		if returnNew && len(result.Modified) > 0 {
+			doc = result.Modified[0]
+		}
+	} else {
+		status = append(status, bson.E{Key: "n", Value: int32(0)})
+		if !remove {
+			status = append(status, bson.E{Key: "updatedExisting", Value: false})
+		}
+	}
+
+	// apply projection
+	if doc != nil && fields != nil {
+		doc, err = mongokit.Project(doc, fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// get value
+	var value interface{}
+	if doc != nil {
+		value = *doc
+	}
+
+	return bson.D{
+		bson.E{Key: "lastErrorObject", Value: status},
+		bson.E{Key: "value", Value: value},
+	}, nil
+}
+
+func cmdCreate(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get config
+	config, err := createConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get view options
+	viewOn, _ := bsonkit.Get(&cmd, "viewOn").(string)
+	var pipeline bsonkit.List
+	if _, ok := bsonkit.Get(&cmd, "pipeline").(bson.A); ok {
+		pipeline, err = commandList(cmd, "pipeline")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// create collection or view
+	_, err = useTransaction(ctx, db.engine, true, func(txn *Transaction) (interface{}, error) {
+		// check existence
+		if txn.Catalog().Namespaces[handle] != nil {
+			return nil, commandError(48, "NamespaceExists", "Collection already exists. NS: %s", handle.String())
+		}
+
+		// create view
+		if viewOn != "" {
+			return nil, txn.CreateView(handle, viewOn, pipeline, config.Collation)
+		}
+
+		return nil, txn.Create(handle, config)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.D{}, nil
+}
+
+func createConfig(cmd bson.D) (mongokit.CollectionConfig, error) {
+	// get collation and validator
+	collation, err := commandDoc(cmd, "collation", false)
+	if err != nil {
+		return mongokit.CollectionConfig{}, err
+	}
+	validator, err := commandDoc(cmd, "validator", false)
+	if err != nil {
+		return mongokit.CollectionConfig{}, err
+	}
+
+	// get sizes
+	size, err := commandInt(cmd, "size")
+	if err != nil {
+		return mongokit.CollectionConfig{}, err
+	}
+	max, err := commandInt(cmd, "max")
+	if err != nil {
+		return mongokit.CollectionConfig{}, err
+	}
+	expireAfter, err := commandInt(cmd, "expireAfterSeconds")
+	if err != nil {
+		return mongokit.CollectionConfig{}, err
+	}
+
+	// prepare config
+	config := mongokit.CollectionConfig{
+		Collation:          collation,
+		Validator:          validator,
+		Capped:             commandBool(cmd, "capped"),
+		SizeInBytes:        int64(size),
+		MaxDocuments:       int64(max),
+		Clustered:          bsonkit.Get(&cmd, "clusteredIndex") != bsonkit.Missing,
+		ExpireAfterSeconds: int64(expireAfter),
+	}
+	config.ValidationLevel, _ = bsonkit.Get(&cmd, "validationLevel").(string)
+	config.ValidationAction, _ = bsonkit.Get(&cmd, "validationAction").(string)
+
+	// get time-series options
+	if series, ok := bsonkit.Get(&cmd, "timeseries").(bson.D); ok {
+		config.TimeField, _ = bsonkit.Get(&series, "timeField").(string)
+		config.MetaField, _ = bsonkit.Get(&series, "metaField").(string)
+		config.Granularity, _ = bsonkit.Get(&series, "granularity").(string)
+		if config.TimeField == "" {
+			return mongokit.CollectionConfig{}, commandError(40414, "Location40414", "BSON field 'create.timeseries.timeField' is missing but a required field")
+		}
+	}
+
+	return config, nil
+}
+
+func cmdDrop(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// drop collection
+	res, err := useTransaction(ctx, db.engine, true, func(txn *Transaction) (interface{}, error) {
+		// get namespace
+		namespace := txn.Catalog().Namespaces[handle]
+		if namespace == nil {
+			return nil, commandError(26, "NamespaceNotFound", "ns not found")
+		}
+
+		return len(namespace.Indexes), txn.Drop(handle)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.D{
+		bson.E{Key: "nIndexesWas", Value: int32(res.(int))},
+		bson.E{Key: "ns", Value: handle.String()},
+	}, nil
+}
+
+func cmdListCollections(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get filter
+	filter, err := commandDoc(cmd, "filter", true)
+	if err != nil {
+		return nil, err
+	}
+
+	// list collections
+	res, err := useTransaction(ctx, db.engine, false, func(txn *Transaction) (interface{}, error) {
+		return txn.ListCollections(Handle{db.name}, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// get list
+	list := res.(bsonkit.List)
+
+	// reduce to names and types if requested
+	if commandBool(cmd, "nameOnly") {
+		names := make(bsonkit.List, 0, len(list))
+		for _, doc := range list {
+			names = append(names, &bson.D{
+				bson.E{Key: "name", Value: bsonkit.Get(doc, "name")},
+				bson.E{Key: "type", Value: bsonkit.Get(doc, "type")},
+			})
+		}
+		list = names
+	}
+
+	return commandCursor(db.name+".$cmd.listCollections", list), nil
+}
+
+func cmdListIndexes(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// list indexes
+	res, err := useTransaction(ctx, db.engine, false, func(txn *Transaction) (interface{}, error) {
+		// check namespace
+		if txn.Catalog().Namespaces[handle] == nil {
+			return nil, commandError(26, "NamespaceNotFound", "ns does not exist: %s", handle.String())
+		}
+
+		return txn.ListIndexes(handle)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commandCursor(handle.String(), res.(bsonkit.List)), nil
+}
+
+func cmdCreateIndexes(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get indexes
+	specs, err := commandList(cmd, "indexes")
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare configs
+	names := make([]string, 0, len(specs))
+	configs := make([]mongokit.IndexConfig, 0, len(specs))
+	for _, spec := range specs {
+		// get config
+		name, config, err := indexConfig(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		// add config
+		names = append(names, name)
+		configs = append(configs, config)
+	}
+
+	// create indexes
+	res, err := useTransaction(ctx, db.engine, true, func(txn *Transaction) (interface{}, error) {
+		// count indexes
+		created := txn.Catalog().Namespaces[handle] == nil
+		before, err := txn.ListIndexes(handle)
+		if err != nil {
+			return nil, err
+		}
+
+		// create indexes
+		for i, config := range configs {
+			_, err = txn.CreateIndex(handle, names[i], config)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// count indexes
+		after, err := txn.ListIndexes(handle)
+		if err != nil {
+			return nil, err
+		}
+
+		// account for the default index of created collections
+		numBefore := len(before)
+		if created {
+			numBefore = 1
+		}
+
+		return bson.D{
+			bson.E{Key: "numIndexesBefore", Value: int32(numBefore)},
+			bson.E{Key: "numIndexesAfter", Value: int32(len(after))},
+			bson.E{Key: "createdCollectionAutomatically", Value: created},
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(bson.D), nil
+}
+
+func indexConfig(spec bsonkit.Doc) (string, mongokit.IndexConfig, error) {
+	// get key
+	key, err := commandDoc(*spec, "key", false)
+	if err != nil {
+		return "", mongokit.IndexConfig{}, err
+	} else if key == nil {
+		return "", mongokit.IndexConfig{}, commandError(9, "FailedToParse", "The 'key' field is a required property of an index specification")
+	}
+
+	// get name
+	name, ok := bsonkit.Get(spec, "name").(string)
+	if !ok {
+		return "", mongokit.IndexConfig{}, commandError(9, "FailedToParse", "The 'name' field is a required property of an index specification")
+	}
+
+	// get partial, collation and wildcard projection
+	partial, err := commandDoc(*spec, "partialFilterExpression", false)
+	if err != nil {
+		return "", mongokit.IndexConfig{}, err
+	}
+	collation, err := commandDoc(*spec, "collation", false)
+	if err != nil {
+		return "", mongokit.IndexConfig{}, err
+	}
+	wildcardProjection, err := commandDoc(*spec, "wildcardProjection", false)
+	if err != nil {
+		return "", mongokit.IndexConfig{}, err
+	}
+
+	// get expiry
+	var expiry time.Duration
+	if bsonkit.Get(spec, "expireAfterSeconds") != bsonkit.Missing {
+		seconds, err := commandInt(*spec, "expireAfterSeconds")
+		if err != nil {
+			return "", mongokit.IndexConfig{}, err
+		}
+		expiry = time.Duration(seconds) * time.Second
+		if expiry == 0 {
+			expiry = time.Nanosecond
+		}
+	}
+
+	return name, mongokit.IndexConfig{
+		Key:                key,
+		Unique:             commandBool(*spec, "unique"),
+		Sparse:             commandBool(*spec, "sparse"),
+		Partial:            partial,
+		Expiry:             expiry,
+		Collation:          collation,
+		WildcardProjection: wildcardProjection,
+		Hidden:             commandBool(*spec, "hidden"),
+	}, nil
+}
+
+func cmdDropIndexes(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// drop indexes
+	res, err := useTransaction(ctx, db.engine, true, func(txn *Transaction) (interface{}, error) {
+		// get namespace
+		namespace := txn.Catalog().Namespaces[handle]
+		if namespace == nil {
+			return nil, commandError(26, "NamespaceNotFound", "ns not found %s", handle.String())
+		}
+
+		// count indexes
+		count := len(namespace.Indexes)
+
+		// collect names
+		var names []string
+		switch index := bsonkit.Get(&cmd, "index").(type) {
+		case string:
+			if index == "*" {
+				names = []string{""}
+			} else {
+				names = []string{index}
+			}
+		case bson.A:
+			for _, item := range index {
+				name, ok := item.(string)
+				if !ok {
+					return nil, commandError(14, "TypeMismatch", "dropIndexes 'index' array must contain strings")
+				}
+				names = append(names, name)
+			}
+		case bson.D:
+			name, err := indexName(namespace, index)
+			if err != nil {
+				return nil, err
+			}
+			names = []string{name}
+		default:
+			return nil, commandError(14, "TypeMismatch", "BSON field 'dropIndexes.index' is missing or of an invalid type")
+		}
+
+		// drop indexes
+		for _, name := range names {
+			err := txn.DropIndex(handle, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return count, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.D{
+		bson.E{Key: "nIndexesWas", Value: int32(res.(int))},
+	}, nil
+}
+
+func cmdCollMod(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// check options
+	for _, opt := range cmd[1:] {
+		switch opt.Key {
+		case "index", "validator", "validationLevel", "validationAction", "expireAfterSeconds":
+		default:
+			return nil, commandError(72, "InvalidOptions", "unsupported collMod option: %s", opt.Key)
+		}
+	}
+
+	// modify collection
+	res, err := useTransaction(ctx, db.engine, true, func(txn *Transaction) (interface{}, error) {
+		// get namespace
+		namespace := txn.Catalog().Namespaces[handle]
+		if namespace == nil {
+			return nil, commandError(26, "NamespaceNotFound", "ns does not exist: %s", handle.String())
+		}
+
+		// prepare reply
+		reply := bson.D{}
+
+		// modify options
+		config, modified, err := collModConfig(namespace.Config(), cmd)
+		if err != nil {
+			return nil, err
+		} else if modified {
+			err = txn.Modify(handle, config)
+			if err != nil {
+				return nil, commandError(72, "InvalidOptions", "%s", err.Error())
+			}
+		}
+
+		// get index
+		index, ok := bsonkit.Get(&cmd, "index").(bson.D)
+		if !ok {
+			return reply, nil
+		}
+
+		// get name
+		name, _ := bsonkit.Get(&index, "name").(string)
+		if key, ok := bsonkit.Get(&index, "keyPattern").(bson.D); ok {
+			name, err = indexName(namespace, key)
+			if err != nil {
+				return nil, err
+			}
+		} else if name == "" {
+			return nil, commandError(72, "InvalidOptions", "must specify either index name or key pattern")
+		}
+
+		// get index
+		idx := namespace.Indexes[name]
+		if idx == nil {
+			return nil, commandError(27, "IndexNotFound", "cannot find index %s for ns %s", name, handle.String())
+		}
+
+		// toggle hidden
+		if hidden, ok := bsonkit.Get(&index, "hidden").(bool); ok {
+			reply = append(reply, bson.E{Key: "hidden_old", Value: idx.Config().Hidden})
+			reply = append(reply, bson.E{Key: "hidden_new", Value: hidden})
+			err = txn.HideIndex(handle, name, hidden)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// change expiry
+		if bsonkit.Get(&index, "expireAfterSeconds") != bsonkit.Missing {
+			seconds, err := commandInt(index, "expireAfterSeconds")
+			if err != nil {
+				return nil, err
+			}
+			reply = append(reply, bson.E{Key: "expireAfterSeconds_old", Value: int64(idx.Config().Expiry / time.Second)})
+			reply = append(reply, bson.E{Key: "expireAfterSeconds_new", Value: int64(seconds)})
+			err = txn.ExpireIndex(handle, name, time.Duration(seconds)*time.Second)
+			if err != nil {
+				return nil, commandError(72, "InvalidOptions", "%s", err.Error())
+			}
+		}
+
+		// convert to unique
+		if unique := bsonkit.Get(&index, "unique"); unique != bsonkit.Missing {
+			if unique != true {
+				return nil, commandError(2, "BadValue", "The field 'unique' can only be set to true")
+			}
+			if !idx.Config().Unique {
+				reply = append(reply, bson.E{Key: "unique_new", Value: true})
+			}
+			err = txn.ConvertIndex(handle, name)
+			if err != nil {
+				return nil, commandError(359, "CannotConvertIndexToUnique", "%s", err.Error())
+			}
+		}
+
+		return reply, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(bson.D), nil
+}
+
+func collModConfig(config mongokit.CollectionConfig, cmd bson.D) (mongokit.CollectionConfig, bool, error) {
+	// track modification
+	modified := false
+
+	// set validator, an empty validator removes validation
+	if bsonkit.Get(&cmd, "validator") != bsonkit.Missing {
+		validator, err := commandDoc(cmd, "validator", false)
+		if err != nil {
+			return config, false, err
+		}
+		if validator != nil && len(*validator) == 0 {
+			validator = nil
+		}
+		config.Validator = validator
+		modified = true
+	}
+
+	// set validation level and action
+	if value := bsonkit.Get(&cmd, "validationLevel"); value != bsonkit.Missing {
+		config.ValidationLevel, _ = value.(string)
+		modified = true
+	}
+	if value := bsonkit.Get(&cmd, "validationAction"); value != bsonkit.Missing {
+		config.ValidationAction, _ = value.(string)
+		modified = true
+	}
+
+	// set expiry, "off" disables expiry
+	if value := bsonkit.Get(&cmd, "expireAfterSeconds"); value == "off" {
+		config.ExpireAfterSeconds = 0
+		modified = true
+	} else if value != bsonkit.Missing {
+		seconds, err := commandInt(cmd, "expireAfterSeconds")
+		if err != nil {
+			return config, false, err
+		}
+		config.ExpireAfterSeconds = int64(seconds)
+		modified = true
+	}
+
+	return config, modified, nil
+}
+
+func cmdRenameCollection(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// check database
+	if db.name != "admin" {
+		return nil, commandError(13, "Unauthorized", "renameCollection may only be run against the admin database.")
+	}
+
+	// get handles
+	from, ok := commandNamespace(cmd[0].Value)
+	if !ok {
+		return nil, commandError(73, "InvalidNamespace", "Invalid source namespace: %v", cmd[0].Value)
+	}
+	to, ok := commandNamespace(bsonkit.Get(&cmd, "to"))
+	if !ok {
+		return nil, commandError(73, "InvalidNamespace", "Invalid target namespace: %v", bsonkit.Get(&cmd, "to"))
+	}
+
+	// get drop target
+	dropTarget := commandBool(cmd, "dropTarget")
+
+	// rename collection
+	_, err := useTransaction(ctx, db.engine, true, func(txn *Transaction) (interface{}, error) {
+		// drop existing target if requested
+		if dropTarget && from != to && txn.Catalog().Namespaces[from] != nil && txn.Catalog().Namespaces[to] != nil {
+			err := txn.Drop(to)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, txn.Rename(from, to)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.D{}, nil
+}
+
+func cmdCollStats(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get scale
+	scale, err := commandScale(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get stats
+	res, err := useTransaction(ctx, db.engine, false, func(txn *Transaction) (interface{}, error) {
+		return txn.CollectionStats(handle, scale)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return *res.(bsonkit.Doc), nil
+}
+
+func cmdDBStats(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get scale
+	scale, err := commandScale(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// get stats
+	res, err := useTransaction(ctx, db.engine, false, func(txn *Transaction) (interface{}, error) {
+		return txn.DatabaseStats(db.name, scale)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return *res.(bsonkit.Doc), nil
+}
+
+func cmdValidate(ctx context.Context, db *Database, cmd bson.D) (bson.D, error) {
+	// get handle
+	handle, err := commandHandle(db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate collection
+	res, err := useTransaction(ctx, db.engine, false, func(txn *Transaction) (interface{}, error) {
+		return txn.Validate(handle)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return *res.(bsonkit.Doc), nil
+}
+
+func commandWrite(ctx context.Context, db *Database, handle Handle, cmd bson.D, ops []Operation) (bson.D, error) {
+	// get ordered
+	ordered := true
+	if bsonkit.Get(&cmd, "ordered") != bsonkit.Missing {
+		ordered = commandBool(cmd, "ordered")
+	}
+
+	// run bulk
+	res, err := useTransaction(ctx, db.engine, true, func(txn *Transaction) (interface{}, error) {
+		return txn.Bulk(handle, ops, ordered)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// collect results
+	var n, modified int
+	var upserted, writeErrors bson.A
+	for i, result := range res.([]Result) {
+		// collect error
+		if result.Error != nil {
+			code, msg := writeErrorInfo(result.Error)
+			writeErrors = append(writeErrors, bson.D{
+				bson.E{Key: "index", Value: int32(i)},
+				bson.E{Key: "code", Value: code},
+				bson.E{Key: "errmsg", Value: msg},
+			})
+			continue
+		}
+
+		// count result
+		switch ops[i].Opcode {
+		case Insert:
+			n += len(result.Modified)
+		case Replace, Update:
+			n += len(result.Matched)
+			modified += len(result.Modified)
+			if result.Upserted != nil {
+				n++
+				upserted = append(upserted, bson.D{
+					bson.E{Key: "index", Value: int32(i)},
+					bson.E{Key: "_id", Value: bsonkit.Get(result.Upserted, "_id")},
+				})
+			}
+		case Delete:
+			n += len(result.Matched)
+		}
+	}
+
+	// prepare reply
+	reply := bson.D{
+		bson.E{Key: "n", Value: int32(n)},
+	}
+	if cmd[0].Key == "update" {
+		reply = append(reply, bson.E{Key: "nModified", Value: int32(modified)})
+		if upserted != nil {
+			reply = append(reply, bson.E{Key: "upserted", Value: upserted})
+		}
+	}
+	if writeErrors != nil {
+		reply = append(reply, bson.E{Key: "writeErrors", Value: writeErrors})
+	}
+
+	return reply, nil
+}
+
+func commandHandle(db *Database, cmd bson.D) (Handle, error) {
+	// get name
+	name, ok := cmd[0].Value.(string)
+	if !ok || name == "" {
+		return Handle{}, commandError(73, "InvalidNamespace", "collection name has invalid type %s", typeName(cmd[0].Value))
+	}
+
+	return Handle{db.name, name}, nil
+}
+
+func commandNamespace(value interface{}) (Handle, bool) {
+	// get namespace
+	ns, _ := value.(string)
+	segments := strings.SplitN(ns, ".", 2)
+	if len(segments) != 2 || segments[0] == "" || segments[1] == "" {
+		return Handle{}, false
+	}
+
+	return Handle{segments[0], segments[1]}, true
+}
+
+func commandDoc(cmd bson.D, key string, ensure bool) (bsonkit.Doc, error) {
+	// get value
+	value := bsonkit.Get(&cmd, key)
+	if value == bsonkit.Missing || value == nil {
+		if ensure {
+			return &bson.D{}, nil
+		}
+		return nil, nil
+	}
+
+	// check document
+	doc, ok := value.(bson.D)
+	if !ok {
+		return nil, commandError(14, "TypeMismatch", "BSON field '%s.%s' is the wrong type '%s', expected type 'object'", cmd[0].Key, key, typeName(value))
+	}
+
+	return &doc, nil
+}
+
+func commandList(cmd bson.D, key string) (bsonkit.List, error) {
+	// get array
+	array, ok := bsonkit.Get(&cmd, key).(bson.A)
+	if !ok {
+		return nil, commandError(14, "TypeMismatch", "BSON field '%s.%s' is missing or not an array", cmd[0].Key, key)
+	}
+
+	// collect documents
+	list := make(bsonkit.List, 0, len(array))
+	for _, item := range array {
+		doc, ok := item.(bson.D)
+		if !ok {
+			return nil, commandError(14, "TypeMismatch", "BSON field '%s.%s' must contain objects", cmd[0].Key, key)
+		}
+		list = append(list, &doc)
+	}
+
+	return list, nil
+}
+
+func commandInt(cmd bson.D, key string) (int, error) {
+	// get value
+	switch value := bsonkit.Get(&cmd, key).(type) {
+	case bsonkit.MissingType, nil:
+		return 0, nil
+	case int32:
+		return int(value), nil
+	case int64:
+		return int(value), nil
+	case float64:
+		return int(value), nil
+	default:
+		return 0, commandError(14, "TypeMismatch", "BSON field '%s.%s' is the wrong type '%s', expected a number", cmd[0].Key, key, typeName(value))
+	}
+}
+
+func commandScale(cmd bson.D) (int64, error) {
+	// get scale
+	scale, err := commandInt(cmd, "scale")
+	if err != nil {
+		return 0, err
+	} else if scale == 0 {
+		return 1, nil
+	} else if scale < 0 {
+		return 0, commandError(2, "BadValue", "scale has to be >= 1")
+	}
+
+	return int64(scale), nil
+}
+
+func commandBool(cmd bson.D, key string) bool {
+	// get value
+	switch value := bsonkit.Get(&cmd, key).(type) {
+	case bool:
+		return value
+	case int32:
+		return value != 0
+	case int64:
+		return value != 0
+	case float64:
+		return value != 0
+	default:
+		return false
+	}
+}
+
+func commandCursor(ns string, list bsonkit.List) bson.D {
+	// prepare batch
+	batch := make(bson.A, 0, len(list))
+	for _, doc := range list {
+		batch = append(batch, *doc)
+	}
+
+	return bson.D{
+		bson.E{Key: "cursor", Value: bson.D{
+			bson.E{Key: "firstBatch", Value: batch},
+			bson.E{Key: "id", Value: int64(0)},
+			bson.E{Key: "ns", Value: ns},
+		}},
+	}
+}
+
+func indexName(namespace *mongokit.Collection, key bson.D) (string, error) {
+	// find index by key
+	for name, index := range namespace.Indexes {
+		if bsonkit.Compare(*index.Config().Key, key) == 0 {
+			return name, nil
+		}
+	}
+
+	return "", commandError(27, "IndexNotFound", "can't find index with key: %v", key)
+}
+
+func typeName(v interface{}) string {
+	_, typ := bsonkit.Inspect(v)
+	return bsonkit.Type2Alias[typ]
+}
+
+func writeErrorInfo(err error) (int32, string) {
+	// check error
+	switch e := err.(type) {
+	case mongo.WriteException:
+		if len(e.WriteErrors) > 0 {
+			return int32(e.WriteErrors[0].Code), e.WriteErrors[0].Message
+		}
+	case mongo.CommandError:
+		return e.Code, e.Message
+	}
+
+	// check uniqueness
+	if IsUniquenessError(err) {
+		return 11000, err.Error()
+	}
+
+	return 2, err.Error()
+}
+
+func commandError(code int32, name, format string, args ...interface{}) error {
+	return mongo.CommandError{
+		Code:    code,
+		Name:    name,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+var commandErrorNames = map[int32]string{
+	2:     "BadValue",
+	11000: "DuplicateKey",
+}
+
+func wrapCommandError(err error) error {
+	// get code and message
+	code, msg := writeErrorInfo(err)
+
+	// get name
+	var name string
+	if ce, ok := err.(mongo.CommandError); ok {
+		name = ce.Name
+	} else if n, ok := commandErrorNames[code]; ok {
+		name = n
+	}
+
+	// prepare reply
+	raw, _ := bson.Marshal(bson.D{
+		bson.E{Key: "ok", Value: 0.0},
+		bson.E{Key: "errmsg", Value: msg},
+		bson.E{Key: "code", Value: code},
+		bson.E{Key: "codeName", Value: name},
+	})
+
+	return mongo.CommandError{
+		Code:    code,
+		Name:    name,
+		Message: msg,
+		Wrapped: err,
+		Raw:     raw,
+	}
+}
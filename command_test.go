@@ -0,0 +1,476 @@
+package lungo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func runCommand(t *testing.T, d IDatabase, cmd bson.D) bson.M {
+	var reply bson.M
+	err := d.RunCommand(nil, cmd).Decode(&reply)
+	assert.NoError(t, err)
+	return reply
+}
+
+func TestCommandDiagnostics(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		reply := runCommand(t, d, bson.D{{Key: "ping", Value: 1}})
+		assert.Equal(t, 1.0, reply["ok"])
+
+		reply = runCommand(t, d, bson.D{{Key: "isMaster", Value: 1}})
+		assert.Equal(t, true, reply["ismaster"])
+		assert.NotNil(t, reply["maxWireVersion"])
+
+		reply = runCommand(t, d, bson.D{{Key: "buildInfo", Value: 1}})
+		assert.IsType(t, "", reply["version"])
+
+		reply = runCommand(t, d, bson.D{{Key: "listCommands", Value: 1}})
+		assert.NotNil(t, reply["commands"].(bson.M)["find"])
+
+		err := d.RunCommand(nil, bson.D{{Key: "fooBar", Value: 1}}).Err()
+		assert.Error(t, err)
+		assert.Equal(t, int32(59), err.(mongo.CommandError).Code)
+	})
+}
+
+func TestCommandCRUD(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		name := collectionName()
+
+		reply := runCommand(t, d, bson.D{
+			{Key: "insert", Value: name},
+			{Key: "documents", Value: bson.A{
+				bson.M{"_id": int32(1), "foo": "bar"},
+				bson.M{"_id": int32(2), "foo": "baz"},
+				bson.M{"_id": int32(1), "foo": "qux"},
+			}},
+		})
+		assert.EqualValues(t, 2, reply["n"])
+		assert.Len(t, reply["writeErrors"], 1)
+		assert.EqualValues(t, 2, reply["writeErrors"].(bson.A)[0].(bson.M)["index"])
+		assert.EqualValues(t, 11000, reply["writeErrors"].(bson.A)[0].(bson.M)["code"])
+
+		reply = runCommand(t, d, bson.D{
+			{Key: "count", Value: name},
+			{Key: "query", Value: bson.M{"foo": "bar"}},
+		})
+		assert.EqualValues(t, 1, reply["n"])
+
+		reply = runCommand(t, d, bson.D{
+			{Key: "distinct", Value: name},
+			{Key: "key", Value: "foo"},
+		})
+		assert.Equal(t, bson.A{"bar", "baz"}, reply["values"])
+
+		reply = runCommand(t, d, bson.D{
+			{Key: "update", Value: name},
+			{Key: "updates", Value: bson.A{
+				bson.M{"q": bson.M{"_id": int32(1)}, "u": bson.M{"$set": bson.M{"foo": "quz"}}},
+				bson.M{"q": bson.M{"_id": int32(3)}, "u": bson.M{"foo": "new"}, "upsert": true},
+			}},
+		})
+		assert.EqualValues(t, 2, reply["n"])
+		assert.EqualValues(t, 1, reply["nModified"])
+		assert.Equal(t, bson.A{
+			bson.M{"index": int32(1), "_id": int32(3)},
+		}, reply["upserted"])
+
+		csr, err := d.RunCommandCursor(nil, bson.D{
+			{Key: "find", Value: name},
+			{Key: "filter", Value: bson.M{}},
+			{Key: "sort", Value: bson.M{"_id": -1}},
+			{Key: "limit", Value: 2},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": int32(3), "foo": "new"},
+			{"_id": int32(2), "foo": "baz"},
+		}, readAll(csr))
+
+		reply = runCommand(t, d, bson.D{
+			{Key: "findAndModify", Value: name},
+			{Key: "query", Value: bson.M{"_id": int32(2)}},
+			{Key: "update", Value: bson.M{"$set": bson.M{"foo": "mod"}}},
+			{Key: "new", Value: true},
+		})
+		assert.Equal(t, bson.M{"_id": int32(2), "foo": "mod"}, reply["value"])
+		assert.Equal(t, true, reply["lastErrorObject"].(bson.M)["updatedExisting"])
+
+		reply = runCommand(t, d, bson.D{
+			{Key: "findAndModify", Value: name},
+			{Key: "query", Value: bson.M{"_id": int32(2)}},
+			{Key: "remove", Value: true},
+		})
+		assert.Equal(t, bson.M{"_id": int32(2), "foo": "mod"}, reply["value"])
+
+		reply = runCommand(t, d, bson.D{
+			{Key: "delete", Value: name},
+			{Key: "deletes", Value: bson.A{
+				bson.M{"q": bson.M{}, "limit": 0},
+			}},
+		})
+		assert.EqualValues(t, 2, reply["n"])
+	})
+}
+
+func TestCommandNamespaces(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		name := collectionName()
+
+		runCommand(t, d, bson.D{
+			{Key: "create", Value: name},
+			{Key: "capped", Value: true},
+			{Key: "size", Value: 4096},
+		})
+
+		err := d.RunCommand(nil, bson.D{{Key: "create", Value: name}}).Err()
+		assert.Error(t, err)
+		assert.Equal(t, int32(48), err.(mongo.CommandError).Code)
+
+		csr, err := d.RunCommandCursor(nil, bson.D{
+			{Key: "listCollections", Value: 1},
+			{Key: "filter", Value: bson.M{"name": name}},
+			{Key: "nameOnly", Value: true},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"name": name, "type": "collection"},
+		}, readAll(csr))
+
+		reply := runCommand(t, d, bson.D{
+			{Key: "createIndexes", Value: name},
+			{Key: "indexes", Value: bson.A{
+				bson.M{"key": bson.M{"foo": 1}, "name": "foo_1"},
+			}},
+		})
+		assert.EqualValues(t, 1, reply["numIndexesBefore"])
+		assert.EqualValues(t, 2, reply["numIndexesAfter"])
+
+		reply = runCommand(t, d, bson.D{
+			{Key: "collMod", Value: name},
+			{Key: "index", Value: bson.M{"name": "foo_1", "hidden": true}},
+		})
+		assert.Equal(t, false, reply["hidden_old"])
+		assert.Equal(t, true, reply["hidden_new"])
+
+		csr, err = d.RunCommandCursor(nil, bson.D{{Key: "listIndexes", Value: name}})
+		assert.NoError(t, err)
+		list := readAll(csr)
+		assert.Len(t, list, 2)
+		assert.Equal(t, true, list[1]["hidden"])
+
+		runCommand(t, d, bson.D{
+			{Key: "dropIndexes", Value: name},
+			{Key: "index", Value: bson.M{"foo": 1}},
+		})
+
+		reply = runCommand(t, d, bson.D{{Key: "drop", Value: name}})
+		assert.EqualValues(t, 1, reply["nIndexesWas"])
+
+		err = d.RunCommand(nil, bson.D{{Key: "drop", Value: name}}).Err()
+		assert.Error(t, err)
+		assert.Equal(t, int32(26), err.(mongo.CommandError).Code)
+	})
+}
+
+func TestCommandRenameCollection(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		admin := d.Client().Database("admin")
+		from := collectionName()
+		to := collectionName()
+
+		_, err := d.Collection(from).InsertOne(nil, bson.M{"_id": "a", "foo": "bar"})
+		assert.NoError(t, err)
+
+		_, err = d.Collection(from).Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{"foo": 1},
+		})
+		assert.NoError(t, err)
+
+		_, err = d.Collection(to).InsertOne(nil, bson.M{"_id": "b"})
+		assert.NoError(t, err)
+
+		err = d.RunCommand(nil, bson.D{
+			{Key: "renameCollection", Value: d.Name() + "." + from},
+			{Key: "to", Value: d.Name() + "." + to},
+		}).Err()
+		assert.Error(t, err)
+
+		err = admin.RunCommand(nil, bson.D{
+			{Key: "renameCollection", Value: d.Name() + "." + from},
+			{Key: "to", Value: d.Name() + "." + to},
+		}).Err()
+		assert.Error(t, err)
+		assert.Equal(t, int32(48), err.(mongo.CommandError).Code)
+
+		stream, err := d.Collection(from).Watch(nil, bson.A{})
+		assert.NoError(t, err)
+
+		runCommand(t, admin, bson.D{
+			{Key: "renameCollection", Value: d.Name() + "." + from},
+			{Key: "to", Value: d.Name() + "." + to},
+			{Key: "dropTarget", Value: true},
+		})
+
+		csr, err := d.Collection(to).Find(nil, bson.M{})
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": "a", "foo": "bar"},
+		}, readAll(csr))
+
+		csr, err = d.Collection(to).Indexes().List(nil)
+		assert.NoError(t, err)
+		assert.Len(t, readAll(csr), 2)
+
+		names, err := d.ListCollectionNames(nil, bson.M{"name": from})
+		assert.NoError(t, err)
+		assert.Empty(t, names)
+
+		assert.True(t, stream.Next(nil))
+		var event bson.M
+		assert.NoError(t, stream.Decode(&event))
+		assert.Equal(t, "rename", event["operationType"])
+		assert.Equal(t, bson.M{"db": d.Name(), "coll": to}, event["to"])
+
+		assert.True(t, stream.Next(nil))
+		event = nil
+		assert.NoError(t, stream.Decode(&event))
+		assert.Equal(t, "invalidate", event["operationType"])
+
+		err = stream.Close(nil)
+		assert.NoError(t, err)
+
+		err = admin.RunCommand(nil, bson.D{
+			{Key: "renameCollection", Value: d.Name() + "." + from},
+			{Key: "to", Value: d.Name() + "." + to},
+		}).Err()
+		assert.Error(t, err)
+		assert.Equal(t, int32(26), err.(mongo.CommandError).Code)
+	})
+}
+
+func TestCommandCollMod(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		c := d.Collection(collectionName())
+
+		_, err := c.InsertMany(nil, []interface{}{
+			bson.M{"_id": "a", "n": int32(1), "m": int32(1)},
+			bson.M{"_id": "b", "n": int32(2), "m": int32(1)},
+		})
+		assert.NoError(t, err)
+
+		_, err = c.Indexes().CreateMany(nil, []mongo.IndexModel{
+			{Keys: bson.M{"n": 1}, Options: options.Index().SetExpireAfterSeconds(60)},
+			{Keys: bson.M{"m": 1}},
+		})
+		assert.NoError(t, err)
+
+		reply := runCommand(t, d, bson.D{
+			{Key: "collMod", Value: c.Name()},
+			{Key: "index", Value: bson.M{"keyPattern": bson.M{"n": 1}, "expireAfterSeconds": 120}},
+		})
+		assert.EqualValues(t, 60, reply["expireAfterSeconds_old"])
+		assert.EqualValues(t, 120, reply["expireAfterSeconds_new"])
+
+		csr, err := c.Indexes().List(nil)
+		assert.NoError(t, err)
+		for _, index := range readAll(csr) {
+			if index["name"] == "n_1" {
+				assert.EqualValues(t, 120, index["expireAfterSeconds"])
+			}
+		}
+
+		runCommand(t, d, bson.D{
+			{Key: "collMod", Value: c.Name()},
+			{Key: "validator", Value: bson.M{"n": bson.M{"$gte": 0}}},
+			{Key: "validationLevel", Value: "strict"},
+		})
+
+		_, err = c.InsertOne(nil, bson.M{"n": -1})
+		assert.Error(t, err)
+
+		csr, err = d.ListCollections(nil, bson.M{"name": c.Name()})
+		assert.NoError(t, err)
+		assert.Equal(t, bson.M{"n": bson.M{"$gte": int32(0)}}, readAll(csr)[0]["options"].(bson.M)["validator"])
+
+		err = d.RunCommand(nil, bson.D{
+			{Key: "collMod", Value: c.Name()},
+			{Key: "validationLevel", Value: "foo"},
+		}).Err()
+		assert.Error(t, err)
+	})
+}
+
+func TestCommandCollModUnique(t *testing.T) {
+	db := testLungoClient.Database(testDB)
+	c := db.Collection(collectionName())
+
+	_, err := c.InsertMany(nil, []interface{}{
+		bson.M{"_id": "a", "n": int32(1), "m": int32(1)},
+		bson.M{"_id": "b", "n": int32(2), "m": int32(1)},
+	})
+	assert.NoError(t, err)
+
+	_, err = c.Indexes().CreateMany(nil, []mongo.IndexModel{
+		{Keys: bson.M{"n": 1}},
+		{Keys: bson.M{"m": 1}},
+	})
+	assert.NoError(t, err)
+
+	reply := runCommand(t, db, bson.D{
+		{Key: "collMod", Value: c.Name()},
+		{Key: "index", Value: bson.M{"name": "n_1", "unique": true}},
+	})
+	assert.Equal(t, true, reply["unique_new"])
+
+	_, err = c.InsertOne(nil, bson.M{"n": int32(1)})
+	assert.Error(t, err)
+
+	err = db.RunCommand(nil, bson.D{
+		{Key: "collMod", Value: c.Name()},
+		{Key: "index", Value: bson.M{"name": "m_1", "unique": true}},
+	}).Err()
+	assert.Error(t, err)
+	assert.Equal(t, int32(359), err.(mongo.CommandError).Code)
+}
+
+func TestCommandStats(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		c := d.Collection(collectionName())
+
+		_, err := c.InsertMany(nil, []interface{}{
+			bson.M{"_id": "a", "foo": "bar"},
+			bson.M{"_id": "b", "foo": "baz"},
+		})
+		assert.NoError(t, err)
+
+		_, err = c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{"foo": 1},
+		})
+		assert.NoError(t, err)
+
+		reply := runCommand(t, d, bson.D{{Key: "collStats", Value: c.Name()}})
+		assert.Equal(t, d.Name()+"."+c.Name(), reply["ns"])
+		assert.EqualValues(t, 2, reply["count"])
+		assert.EqualValues(t, 2, reply["nindexes"])
+		assert.Greater(t, toFloat(reply["size"]), 0.0)
+		assert.Greater(t, toFloat(reply["avgObjSize"]), 0.0)
+		assert.Len(t, reply["indexSizes"], 2)
+		assert.Equal(t, false, reply["capped"])
+
+		reply = runCommand(t, d, bson.D{{Key: "dbStats", Value: 1}})
+		assert.Equal(t, d.Name(), reply["db"])
+		assert.GreaterOrEqual(t, toFloat(reply["collections"]), 1.0)
+		assert.GreaterOrEqual(t, toFloat(reply["objects"]), 2.0)
+		assert.Greater(t, toFloat(reply["dataSize"]), 0.0)
+
+		err = d.RunCommand(nil, bson.D{
+			{Key: "dbStats", Value: 1},
+			{Key: "scale", Value: -1},
+		}).Err()
+		assert.Error(t, err)
+	})
+}
+
+func TestCommandStatsStages(t *testing.T) {
+	collectionTest(t, func(t *testing.T, c ICollection) {
+		_, err := c.InsertMany(nil, []interface{}{
+			bson.M{"_id": "a", "foo": "bar"},
+			bson.M{"_id": "b", "foo": "baz"},
+		})
+		assert.NoError(t, err)
+
+		_, err = c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{"$**": 1},
+		})
+		assert.NoError(t, err)
+
+		csr, err := c.Aggregate(nil, bson.A{
+			bson.M{"$collStats": bson.M{"storageStats": bson.M{}, "count": bson.M{}}},
+		})
+		assert.NoError(t, err)
+		list := readAll(csr)
+		assert.Len(t, list, 1)
+		assert.Equal(t, c.Database().Name()+"."+c.Name(), list[0]["ns"])
+		assert.EqualValues(t, 2, list[0]["count"])
+		assert.EqualValues(t, 2, list[0]["storageStats"].(bson.M)["count"])
+
+		csr, err = c.Find(nil, bson.M{"foo": "bar"})
+		assert.NoError(t, err)
+		assert.Len(t, readAll(csr), 1)
+
+		csr, err = c.Aggregate(nil, bson.A{
+			bson.M{"$indexStats": bson.M{}},
+			bson.M{"$sort": bson.M{"name": 1}},
+		})
+		assert.NoError(t, err)
+		list = readAll(csr)
+		assert.Len(t, list, 2)
+		assert.Equal(t, "$**_1", list[0]["name"])
+		assert.EqualValues(t, 1, list[0]["accesses"].(bson.M)["ops"])
+		assert.Equal(t, "_id_", list[1]["name"])
+		assert.EqualValues(t, 0, list[1]["accesses"].(bson.M)["ops"])
+
+		_, err = c.Aggregate(nil, bson.A{
+			bson.M{"$match": bson.M{}},
+			bson.M{"$indexStats": bson.M{}},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	default:
+		return 0
+	}
+}
+
+func TestCommandValidate(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		c := d.Collection(collectionName())
+
+		_, err := c.InsertMany(nil, []interface{}{
+			bson.M{"_id": "a", "n": int32(1)},
+			bson.M{"_id": "b", "n": int32(-1)},
+		})
+		assert.NoError(t, err)
+
+		_, err = c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{"n": 1},
+		})
+		assert.NoError(t, err)
+
+		reply := runCommand(t, d, bson.D{{Key: "validate", Value: c.Name()}})
+		assert.Equal(t, d.Name()+"."+c.Name(), reply["ns"])
+		assert.Equal(t, true, reply["valid"])
+		assert.EqualValues(t, 2, reply["nrecords"])
+		assert.EqualValues(t, 2, reply["nIndexes"])
+		assert.EqualValues(t, 2, reply["keysPerIndex"].(bson.M)["n_1"])
+		assert.Equal(t, bson.A{}, reply["errors"])
+
+		runCommand(t, d, bson.D{
+			{Key: "collMod", Value: c.Name()},
+			{Key: "validator", Value: bson.M{"n": bson.M{"$gte": 0}}},
+		})
+
+		reply = runCommand(t, d, bson.D{{Key: "validate", Value: c.Name()}})
+		assert.Equal(t, true, reply["valid"])
+		assert.EqualValues(t, 1, reply["nNonCompliantDocuments"])
+		assert.Len(t, reply["warnings"], 1)
+
+		err = d.RunCommand(nil, bson.D{{Key: "validate", Value: collectionName()}}).Err()
+		assert.Error(t, err)
+		assert.Equal(t, int32(26), err.(mongo.CommandError).Code)
+	})
+}
@@ -11,14 +11,30 @@ import (
 
 var _ ICursor = &Cursor{}
 
-// Cursor wraps a list to be mongo compatible.
+// the counter used to generate the IDs of tailable cursors
+var cursorCounter int64
+
+// Cursor wraps a list to be mongo compatible. Tailable cursors will fetch
+// more documents when the list has been exhausted. Like the driver, Next keeps
+// waiting on tailable cursors until more documents are available while TryNext
+// returns immediately.
 type Cursor struct {
 	list   bsonkit.List
 	pos    int
+	tail   *tail
+	err    error
 	closed bool
 	mutex  sync.Mutex
 }
 
+type tail struct {
+	id     int64
+	fetch  func(context.Context) (bsonkit.List, error)
+	signal <-chan struct{}
+	cancel func()
+	done   chan struct{}
+}
+
 // All implements the ICursor.All method.
 func (c *Cursor) All(_ context.Context, out interface{}) error {
 	// acquire mutex
@@ -37,7 +53,7 @@ func (c *Cursor) All(_ context.Context, out interface{}) error {
 	}
 
 	// close cursor
-	c.closed = true
+	c.close()
 
 	return nil
 }
@@ -49,7 +65,7 @@ func (c *Cursor) Close(context.Context) error {
 	defer c.mutex.Unlock()
 
 	// close cursor
-	c.closed = true
+	c.close()
 
 	return nil
 }
@@ -76,40 +92,127 @@ func (c *Cursor) Decode(out interface{}) error {
 
 // Err implements the ICursor.Err method.
 func (c *Cursor) Err() error {
-	return nil
-}
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-// ID implements the ICursor.ID method.
-func (c *Cursor) ID() int64 {
-	return 0
+	return c.err
 }
 
-// Next implements the ICursor.Next method.
-func (c *Cursor) Next(context.Context) bool {
+// ID implements the ICursor.ID method. Tailable cursors return a non-zero ID
+// until they are closed.
+func (c *Cursor) ID() int64 {
 	// acquire mutex
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	// check if closed
-	if c.closed {
-		return false
+	// check tail
+	if c.tail == nil || c.closed {
+		return 0
 	}
 
-	// increment position
-	if c.pos < len(c.list) {
-		c.pos++
-		return true
-	}
+	return c.tail.id
+}
 
-	return false
+// Next implements the ICursor.Next method.
+func (c *Cursor) Next(ctx context.Context) bool {
+	return c.next(ctx, true)
 }
 
 // RemainingBatchLength implements the ICursor.RemainingBatchLength method.
 func (c *Cursor) RemainingBatchLength() int {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	return len(c.list) - c.pos
 }
 
 // TryNext implements the ICursor.TryNext method.
 func (c *Cursor) TryNext(ctx context.Context) bool {
-	return c.Next(ctx)
+	return c.next(ctx, false)
+}
+
+func (c *Cursor) next(ctx context.Context, block bool) bool {
+	// ensure context
+	ctx = ensureContext(ctx)
+
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for {
+		// check if closed
+		if c.closed {
+			return false
+		}
+
+		// increment position
+		if c.pos < len(c.list) {
+			c.pos++
+			return true
+		}
+
+		// check tail
+		if c.tail == nil {
+			return false
+		}
+
+		// fetch more documents
+		list, err := c.tail.fetch(ctx)
+		if err != nil {
+			c.err = err
+			c.close()
+			return false
+		}
+
+		// replace list
+		if len(list) > 0 {
+			c.list = list
+			c.pos = 0
+			continue
+		}
+
+		// handle non blocking calls
+		if !block {
+			return false
+		}
+
+		// await next commit or close without holding the mutex
+		tail := c.tail
+		c.mutex.Unlock()
+		var closed bool
+		var ctxErr error
+		select {
+		case _, ok := <-tail.signal:
+			closed = !ok
+		case <-tail.done:
+		case <-ctx.Done():
+			ctxErr = ctx.Err()
+		}
+		c.mutex.Lock()
+
+		// handle closed engine
+		if closed {
+			c.close()
+			return false
+		}
+
+		// handle cancelled context
+		if ctxErr != nil {
+			c.err = ctxErr
+			return false
+		}
+	}
+}
+
+func (c *Cursor) close() {
+	// cancel tail
+	if c.tail != nil && !c.closed {
+		c.tail.cancel()
+		close(c.tail.done)
+	}
+
+	// set flag
+	c.closed = true
 }
@@ -2,6 +2,7 @@ package lungo
 
 import (
 	"context"
+	"fmt"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -10,6 +11,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 
 	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
 )
 
 var _ IDatabase = &Database{}
@@ -52,11 +54,90 @@ func (d *Database) Collection(name string, opts ...*options.CollectionOptions) I
 
 // CreateCollection implements the IDatabase.CreateCollection method.
 func (d *Database) CreateCollection(ctx context.Context, name string, opts ...*options.CreateCollectionOptions) error {
+	return d.createCollection(ctx, name, false, opts...)
+}
+
+// CreateClusteredCollection will create a clustered collection that stores its
+// documents ordered by _id instead of maintaining a separate _id index. The
+// ExpireAfterSeconds option may be used to expire documents based on the date
+// or object id timestamp stored in _id. This is a lungo specific extension
+// that is not part of the IDatabase interface.
+func (d *Database) CreateClusteredCollection(ctx context.Context, name string, opts ...*options.CreateCollectionOptions) error {
+	return d.createCollection(ctx, name, true, opts...)
+}
+
+func (d *Database) createCollection(ctx context.Context, name string, clustered bool, opts ...*options.CreateCollectionOptions) error {
 	// merge options
 	opt := options.MergeCreateCollectionOptions(opts...)
 
 	// assert supported options
-	assertOptions(opt, map[string]string{})
+	assertOptions(opt, map[string]string{
+		"Capped":             supported,
+		"Collation":          supported,
+		"ExpireAfterSeconds": supported,
+		"MaxDocuments":       supported,
+		"SizeInBytes":        supported,
+		"TimeSeriesOptions":  supported,
+		"ValidationAction":   supported,
+		"ValidationLevel":    supported,
+		"Validator":          supported,
+	})
+
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return err
+	}
+
+	// transform validator
+	var validator bsonkit.Doc
+	if opt.Validator != nil {
+		validator, err = bsonkit.Transform(opt.Validator)
+		if err != nil {
+			return err
+		}
+	}
+
+	// get validation level and action
+	var validationLevel, validationAction string
+	if opt.ValidationLevel != nil {
+		validationLevel = *opt.ValidationLevel
+	}
+	if opt.ValidationAction != nil {
+		validationAction = *opt.ValidationAction
+	}
+
+	// get capped options
+	var capped bool
+	var size, max int64
+	if opt.Capped != nil {
+		capped = *opt.Capped
+	}
+	if opt.SizeInBytes != nil {
+		size = *opt.SizeInBytes
+	}
+	if opt.MaxDocuments != nil {
+		max = *opt.MaxDocuments
+	}
+
+	// get time-series options
+	var timeField, metaField, granularity string
+	var expireAfter int64
+	if opt.TimeSeriesOptions != nil {
+		if opt.TimeSeriesOptions.TimeField == "" {
+			return fmt.Errorf("the 'timeField' is required for time-series collections")
+		}
+		timeField = opt.TimeSeriesOptions.TimeField
+		if opt.TimeSeriesOptions.MetaField != nil {
+			metaField = *opt.TimeSeriesOptions.MetaField
+		}
+		if opt.TimeSeriesOptions.Granularity != nil {
+			granularity = *opt.TimeSeriesOptions.Granularity
+		}
+	}
+	if opt.ExpireAfterSeconds != nil {
+		expireAfter = *opt.ExpireAfterSeconds
+	}
 
 	// begin transaction
 	txn, err := d.engine.Begin(ctx, true)
@@ -68,7 +149,20 @@ func (d *Database) CreateCollection(ctx context.Context, name string, opts ...*o
 	defer d.engine.Abort(txn)
 
 	// create collection
-	err = txn.Create(Handle{d.name, name})
+	err = txn.Create(Handle{d.name, name}, mongokit.CollectionConfig{
+		Collation:          collation,
+		Validator:          validator,
+		ValidationLevel:    validationLevel,
+		ValidationAction:   validationAction,
+		Capped:             capped,
+		SizeInBytes:        size,
+		MaxDocuments:       max,
+		Clustered:          clustered,
+		TimeField:          timeField,
+		MetaField:          metaField,
+		Granularity:        granularity,
+		ExpireAfterSeconds: expireAfter,
+	})
 	if err != nil {
 		return err
 	}
@@ -83,8 +177,108 @@ func (d *Database) CreateCollection(ctx context.Context, name string, opts ...*o
 }
 
 // CreateView implements the IDatabase.CreateView method.
-func (d *Database) CreateView(_ context.Context, _, _ string, _ interface{}, _ ...*options.CreateViewOptions) error {
-	panic("lungo: not implemented")
+func (d *Database) CreateView(ctx context.Context, viewName, viewOn string, pipeline interface{}, opts ...*options.CreateViewOptions) error {
+	// merge options
+	opt := options.MergeCreateViewOptions(opts...)
+
+	// assert supported options
+	assertOptions(opt, map[string]string{
+		"Collation": supported,
+	})
+
+	// transform pipeline
+	var list bsonkit.List
+	if pipeline != nil {
+		var err error
+		list, err = bsonkit.TransformList(pipeline)
+		if err != nil {
+			return err
+		}
+	}
+
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return err
+	}
+
+	// begin transaction
+	txn, err := d.engine.Begin(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	// ensure abortion
+	defer d.engine.Abort(txn)
+
+	// create view
+	err = txn.CreateView(Handle{d.name, viewName}, viewOn, list, collation)
+	if err != nil {
+		return err
+	}
+
+	// commit transaction
+	err = d.engine.Commit(txn)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateMaterializedView will create a materialized view that stores the result
+// of the pipeline applied to the source collection. The pipeline must consist
+// of document stages followed by a final $group stage. The view is updated
+// incrementally whenever a transaction that changes the source is committed.
+// This is a lungo specific extension that is not part of the IDatabase
+// interface.
+func (d *Database) CreateMaterializedView(ctx context.Context, viewName, viewOn string, pipeline interface{}, opts ...*options.CreateViewOptions) error {
+	// merge options
+	opt := options.MergeCreateViewOptions(opts...)
+
+	// assert supported options
+	assertOptions(opt, map[string]string{
+		"Collation": supported,
+	})
+
+	// transform pipeline
+	var list bsonkit.List
+	if pipeline != nil {
+		var err error
+		list, err = bsonkit.TransformList(pipeline)
+		if err != nil {
+			return err
+		}
+	}
+
+	// get collation
+	collation, err := transformCollation(opt.Collation)
+	if err != nil {
+		return err
+	}
+
+	// begin transaction
+	txn, err := d.engine.Begin(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	// ensure abortion
+	defer d.engine.Abort(txn)
+
+	// create view
+	err = txn.CreateMaterializedView(Handle{d.name, viewName}, viewOn, list, collation)
+	if err != nil {
+		return err
+	}
+
+	// commit transaction
+	err = d.engine.Commit(txn)
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
 
 // Drop implements the IDatabase.Drop method.
@@ -183,16 +377,6 @@ func (d *Database) ReadPreference() *readpref.ReadPref {
 	return readpref.Primary()
 }
 
-// RunCommand implements the IDatabase.RunCommand method.
-func (d *Database) RunCommand(context.Context, interface{}, ...*options.RunCmdOptions) ISingleResult {
-	panic("lungo: not implemented")
-}
-
-// RunCommandCursor implements the IDatabase.RunCommandCursor method.
-func (d *Database) RunCommandCursor(context.Context, interface{}, ...*options.RunCmdOptions) (ICursor, error) {
-	panic("lungo: not implemented")
-}
-
 // Watch implements the IDatabase.Watch method.
 func (d *Database) Watch(_ context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (IChangeStream, error) {
 	// merge options
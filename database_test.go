@@ -1,10 +1,17 @@
 package lungo
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readconcern"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
@@ -27,6 +34,401 @@ func TestDatabaseCreate(t *testing.T) {
 	})
 }
 
+func TestDatabaseCreateCollation(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		name := collectionName()
+		err := d.CreateCollection(nil, name, options.CreateCollection().SetCollation(&options.Collation{
+			Locale:   "en",
+			Strength: 2,
+		}))
+		assert.NoError(t, err)
+
+		c := d.Collection(name)
+		_, err = c.InsertOne(nil, bson.M{"name": "Joe"})
+		assert.NoError(t, err)
+
+		num, err := c.CountDocuments(nil, bson.M{"name": "joe"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), num)
+
+		num, err = c.CountDocuments(nil, bson.M{"name": "joe"}, options.Count().SetCollation(&options.Collation{
+			Locale: "simple",
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), num)
+	})
+}
+
+func TestDatabaseCreateValidator(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		name := collectionName()
+		err := d.CreateCollection(nil, name, options.CreateCollection().SetValidator(bson.M{
+			"$jsonSchema": bson.M{
+				"required": bson.A{"name"},
+				"properties": bson.M{
+					"name": bson.M{"bsonType": "string"},
+				},
+			},
+		}))
+		assert.NoError(t, err)
+
+		c := d.Collection(name)
+		_, err = c.InsertOne(nil, bson.M{"_id": 1, "name": "Joe"})
+		assert.NoError(t, err)
+
+		_, err = c.InsertOne(nil, bson.M{"_id": 2, "name": 42})
+		assert.Error(t, err)
+
+		var we mongo.WriteException
+		assert.True(t, errors.As(err, &we))
+		assert.Len(t, we.WriteErrors, 1)
+		assert.Equal(t, 121, we.WriteErrors[0].Code)
+		assert.Equal(t, "Document failed validation", we.WriteErrors[0].Message)
+		assert.Equal(t, int32(2), we.WriteErrors[0].Details.Lookup("failingDocumentId").Int32())
+		assert.Equal(t, "$jsonSchema", we.WriteErrors[0].Details.Lookup("details", "operatorName").StringValue())
+
+		_, err = c.UpdateOne(nil, bson.M{"_id": 1}, bson.M{
+			"$unset": bson.M{"name": ""},
+		})
+		assert.Error(t, err)
+
+		_, err = c.ReplaceOne(nil, bson.M{"_id": 1}, bson.M{"name": "Jane"})
+		assert.NoError(t, err)
+
+		assert.Equal(t, []bson.M{
+			{"_id": int32(1), "name": "Jane"},
+		}, dumpCollection(c, false))
+
+		name = collectionName()
+		err = d.CreateCollection(nil, name, options.CreateCollection().SetValidator(bson.M{
+			"age": bson.M{"$gte": 18},
+		}).SetValidationAction("warn"))
+		assert.NoError(t, err)
+
+		c = d.Collection(name)
+		_, err = c.InsertOne(nil, bson.M{"_id": 1, "age": 17})
+		assert.NoError(t, err)
+
+		csr, err := d.ListCollections(nil, bson.M{"name": name})
+		assert.NoError(t, err)
+		list := readAll(csr)
+		assert.Len(t, list, 1)
+		assert.Equal(t, "warn", list[0]["options"].(bson.M)["validationAction"])
+		assert.Equal(t, "strict", list[0]["options"].(bson.M)["validationLevel"])
+	})
+}
+
+func TestDatabaseCreateCapped(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		name := collectionName()
+		err := d.CreateCollection(nil, name, options.CreateCollection().
+			SetCapped(true).SetSizeInBytes(4096).SetMaxDocuments(3))
+		assert.NoError(t, err)
+
+		c := d.Collection(name)
+		for i := 1; i <= 5; i++ {
+			_, err = c.InsertOne(nil, bson.M{"_id": int32(i)})
+			assert.NoError(t, err)
+		}
+
+		assert.Equal(t, []bson.M{
+			{"_id": int32(3)},
+			{"_id": int32(4)},
+			{"_id": int32(5)},
+		}, dumpCollection(c, false))
+
+		_, err = c.DeleteOne(nil, bson.M{"_id": int32(3)})
+		assert.Error(t, err)
+
+		csr, err := d.ListCollections(nil, bson.M{"name": name})
+		assert.NoError(t, err)
+		list := readAll(csr)
+		assert.Len(t, list, 1)
+		assert.Equal(t, true, list[0]["options"].(bson.M)["capped"])
+		assert.EqualValues(t, 3, list[0]["options"].(bson.M)["max"])
+
+		/* size */
+
+		name = collectionName()
+		err = d.CreateCollection(nil, name, options.CreateCollection().
+			SetCapped(true).SetSizeInBytes(4096))
+		assert.NoError(t, err)
+
+		c = d.Collection(name)
+		for i := 1; i <= 10; i++ {
+			_, err = c.InsertOne(nil, bson.M{"_id": int32(i), "data": strings.Repeat("x", 1000)})
+			assert.NoError(t, err)
+		}
+
+		n, err := c.CountDocuments(nil, bson.M{})
+		assert.NoError(t, err)
+		assert.True(t, n < 5)
+	})
+}
+
+func TestDatabaseCreateCappedTailable(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		name := collectionName()
+		err := d.CreateCollection(nil, name, options.CreateCollection().
+			SetCapped(true).SetSizeInBytes(4096))
+		assert.NoError(t, err)
+
+		c := d.Collection(name)
+		_, err = c.InsertMany(nil, []interface{}{
+			bson.M{"_id": int32(1), "job": "a"},
+			bson.M{"_id": int32(2), "job": "b"},
+		})
+		assert.NoError(t, err)
+
+		csr, err := c.Find(nil, bson.M{}, options.Find().SetCursorType(options.TailableAwait))
+		assert.NoError(t, err)
+
+		var doc bson.M
+		assert.True(t, csr.Next(nil))
+		assert.NoError(t, csr.Decode(&doc))
+		assert.Equal(t, bson.M{"_id": int32(1), "job": "a"}, doc)
+		assert.True(t, csr.Next(nil))
+		assert.NoError(t, csr.Decode(&doc))
+		assert.Equal(t, bson.M{"_id": int32(2), "job": "b"}, doc)
+		assert.False(t, csr.TryNext(nil))
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			_, _ = c.InsertOne(nil, bson.M{"_id": int32(3), "job": "c"})
+		}()
+
+		assert.True(t, csr.Next(timeout(1000)))
+		assert.NoError(t, csr.Decode(&doc))
+		assert.Equal(t, bson.M{"_id": int32(3), "job": "c"}, doc)
+		assert.NoError(t, csr.Close(nil))
+
+		/* non capped */
+
+		name = collectionName()
+		assert.NoError(t, d.CreateCollection(nil, name))
+
+		_, err = d.Collection(name).Find(nil, bson.M{}, options.Find().SetCursorType(options.Tailable))
+		assert.Error(t, err)
+	})
+}
+
+func TestDatabaseCreateCappedTailableLungo(t *testing.T) {
+	d := testLungoClient.Database(testDB)
+
+	name := collectionName()
+	err := d.CreateCollection(nil, name, options.CreateCollection().
+		SetCapped(true).SetSizeInBytes(4096))
+	assert.NoError(t, err)
+
+	c := d.Collection(name)
+	_, err = c.InsertOne(nil, bson.M{"_id": int32(1)})
+	assert.NoError(t, err)
+
+	/* tailable */
+
+	csr, err := c.Find(nil, bson.M{}, options.Find().SetCursorType(options.Tailable))
+	assert.NoError(t, err)
+	assert.NotZero(t, csr.ID())
+	assert.True(t, csr.Next(nil))
+	assert.Equal(t, 0, csr.RemainingBatchLength())
+	assert.False(t, csr.TryNext(nil))
+	assert.False(t, csr.Next(timeout(50)))
+	assert.Equal(t, context.DeadlineExceeded, csr.Err())
+	assert.NotZero(t, csr.ID())
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = c.InsertOne(nil, bson.M{"_id": int32(2)})
+	}()
+
+	var doc bson.M
+	assert.True(t, csr.Next(timeout(1000)))
+	assert.NoError(t, csr.Decode(&doc))
+	assert.Equal(t, bson.M{"_id": int32(2)}, doc)
+	assert.NoError(t, csr.Close(nil))
+	assert.Zero(t, csr.ID())
+
+	/* loop */
+
+	csr, err = c.Find(nil, bson.M{}, options.Find().SetCursorType(options.Tailable))
+	assert.NoError(t, err)
+
+	var ids []int32
+	for csr.ID() != 0 {
+		if !csr.TryNext(nil) {
+			assert.NoError(t, csr.Close(nil))
+			continue
+		}
+		assert.NoError(t, csr.Decode(&doc))
+		ids = append(ids, doc["_id"].(int32))
+	}
+	assert.Equal(t, []int32{1, 2}, ids)
+
+	/* close */
+
+	csr, err = c.Find(nil, bson.M{}, options.Find().SetCursorType(options.TailableAwait))
+	assert.NoError(t, err)
+	assert.True(t, csr.Next(nil))
+	assert.True(t, csr.Next(nil))
+
+	done := make(chan bool)
+	go func() {
+		done <- csr.Next(timeout(5000))
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, csr.Close(nil))
+
+	select {
+	case ok := <-done:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		assert.Fail(t, "close blocked by awaiting cursor")
+	}
+}
+
+func TestDatabaseCreateTimeSeries(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		name := collectionName()
+		err := d.CreateCollection(nil, name, options.CreateCollection().
+			SetTimeSeriesOptions(options.TimeSeries().SetTimeField("ts").SetMetaField("sensor")))
+		assert.NoError(t, err)
+
+		now := time.Now().Truncate(time.Second)
+		ts := func(sec int) primitive.DateTime {
+			return primitive.NewDateTimeFromTime(now.Add(time.Duration(sec) * time.Second))
+		}
+
+		c := d.Collection(name)
+		_, err = c.InsertMany(nil, []interface{}{
+			bson.M{"_id": int32(1), "ts": ts(0), "sensor": "a", "value": int32(1)},
+			bson.M{"_id": int32(2), "ts": ts(1), "sensor": "b", "value": int32(2)},
+			bson.M{"_id": int32(3), "ts": ts(2), "sensor": "a", "value": int32(3)},
+		})
+		assert.NoError(t, err)
+
+		csr, err := c.Find(nil, bson.M{"sensor": "a"}, options.Find().SetSort(bson.M{"ts": 1}))
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": int32(1), "ts": ts(0), "sensor": "a", "value": int32(1)},
+			{"_id": int32(3), "ts": ts(2), "sensor": "a", "value": int32(3)},
+		}, readAll(csr))
+
+		csr, err = c.Aggregate(nil, bson.A{
+			bson.M{"$group": bson.M{"_id": "$sensor", "total": bson.M{"$sum": "$value"}}},
+			bson.M{"$sort": bson.M{"_id": 1}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": "a", "total": int32(4)},
+			{"_id": "b", "total": int32(2)},
+		}, readAll(csr))
+
+		_, err = c.InsertOne(nil, bson.M{"sensor": "a", "value": int32(4)})
+		assert.Error(t, err)
+
+		_, err = c.UpdateOne(nil, bson.M{"_id": int32(1)}, bson.M{"$set": bson.M{"value": int32(5)}})
+		assert.Error(t, err)
+
+		res, err := c.DeleteMany(nil, bson.M{"sensor": "a"})
+		assert.NoError(t, err)
+		assert.EqualValues(t, 2, res.DeletedCount)
+
+		n, err := c.CountDocuments(nil, bson.M{})
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1, n)
+
+		csr, err = d.ListCollections(nil, bson.M{"name": name})
+		assert.NoError(t, err)
+		list := readAll(csr)
+		assert.Len(t, list, 1)
+		assert.Equal(t, "timeseries", list[0]["type"])
+		assert.Equal(t, "ts", list[0]["options"].(bson.M)["timeseries"].(bson.M)["timeField"])
+		assert.Equal(t, "sensor", list[0]["options"].(bson.M)["timeseries"].(bson.M)["metaField"])
+		assert.Equal(t, "seconds", list[0]["options"].(bson.M)["timeseries"].(bson.M)["granularity"])
+
+		err = c.Drop(nil)
+		assert.NoError(t, err)
+
+		names, err := d.ListCollectionNames(nil, bson.M{})
+		assert.NoError(t, err)
+		assert.NotContains(t, names, name)
+		assert.NotContains(t, names, "system.buckets."+name)
+	})
+}
+
+func TestDatabaseCreateView(t *testing.T) {
+	databaseTest(t, func(t *testing.T, d IDatabase) {
+		source := collectionName()
+		_, err := d.Collection(source).InsertMany(nil, []interface{}{
+			bson.M{"_id": int32(1), "name": "Joe", "age": int32(31)},
+			bson.M{"_id": int32(2), "name": "Jane", "age": int32(17)},
+			bson.M{"_id": int32(3), "name": "Bob", "age": int32(42)},
+		})
+		assert.NoError(t, err)
+
+		name := collectionName()
+		err = d.CreateView(nil, name, source, mongo.Pipeline{
+			bson.D{{Key: "$match", Value: bson.M{"age": bson.M{"$gte": int32(18)}}}},
+			bson.D{{Key: "$project", Value: bson.M{"age": 0}}},
+		})
+		assert.NoError(t, err)
+
+		// list
+		csr, err := d.ListCollections(nil, bson.M{"name": name})
+		assert.NoError(t, err)
+		res := readAll(csr)
+		assert.Len(t, res, 1)
+		assert.Equal(t, "view", res[0]["type"])
+		assert.Equal(t, source, res[0]["options"].(bson.M)["viewOn"])
+		assert.Equal(t, bson.A{
+			bson.M{"$match": bson.M{"age": bson.M{"$gte": int32(18)}}},
+			bson.M{"$project": bson.M{"age": int32(0)}},
+		}, res[0]["options"].(bson.M)["pipeline"])
+		assert.Equal(t, true, res[0]["info"].(bson.M)["readOnly"])
+
+		// find
+		view := d.Collection(name)
+		csr, err = view.Find(nil, bson.M{"name": bson.M{"$ne": "Bob"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": int32(1), "name": "Joe"},
+		}, readAll(csr))
+
+		// count
+		num, err := view.CountDocuments(nil, bson.M{})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), num)
+
+		// aggregate
+		csr, err = view.Aggregate(nil, bson.A{
+			bson.M{"$sort": bson.M{"name": 1}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": int32(3), "name": "Bob"},
+			{"_id": int32(1), "name": "Joe"},
+		}, readAll(csr))
+
+		// writes
+		_, err = view.InsertOne(nil, bson.M{"name": "Alice"})
+		assert.Error(t, err)
+		assert.Equal(t, int32(166), err.(mongo.CommandError).Code)
+
+		_, err = view.DeleteMany(nil, bson.M{})
+		assert.Error(t, err)
+		assert.Equal(t, int32(166), err.(mongo.CommandError).Code)
+
+		// drop
+		err = view.Drop(nil)
+		assert.NoError(t, err)
+
+		num, err = d.Collection(source).CountDocuments(nil, bson.M{})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), num)
+	})
+}
+
 func TestDatabaseDrop(t *testing.T) {
 	collectionTest(t, func(t *testing.T, c ICollection) {
 		_, err := c.InsertOne(nil, bson.M{
@@ -107,3 +509,124 @@ func TestDatabaseWriteConcern(t *testing.T) {
 		assert.Nil(t, d.WriteConcern())
 	})
 }
+
+func TestDatabaseCreateMaterializedView(t *testing.T) {
+	client, engine, err := Open(nil, Options{
+		Store: NewMemoryStore(),
+	})
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	db := client.Database("foo").(*Database)
+	coll := db.Collection("bar")
+
+	_, err = coll.InsertMany(nil, []interface{}{
+		bson.M{"_id": 1, "cat": "a", "n": int32(1)},
+		bson.M{"_id": 2, "cat": "b", "n": int32(2)},
+	})
+	assert.NoError(t, err)
+
+	err = db.CreateMaterializedView(nil, "stats", "bar", bson.A{
+		bson.M{"$group": bson.M{
+			"_id":   "$cat",
+			"total": bson.M{"$sum": "$n"},
+		}},
+	})
+	assert.NoError(t, err)
+
+	_, err = coll.InsertOne(nil, bson.M{"_id": 3, "cat": "a", "n": int32(3)})
+	assert.NoError(t, err)
+
+	_, err = coll.DeleteOne(nil, bson.M{"_id": 2})
+	assert.NoError(t, err)
+
+	csr, err := db.Collection("stats").Find(nil, bson.M{})
+	assert.NoError(t, err)
+	assert.Equal(t, []bson.M{
+		{"_id": "a", "total": int32(4)},
+	}, readAll(csr))
+
+	_, err = db.Collection("stats").InsertOne(nil, bson.M{"_id": "c"})
+	assert.Error(t, err)
+
+	csr, err = db.ListCollections(nil, bson.M{"name": "stats"})
+	assert.NoError(t, err)
+	list := readAll(csr)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "view", list[0]["type"])
+	assert.Equal(t, true, list[0]["options"].(bson.M)["materialized"])
+}
+
+func TestDatabaseCreateClusteredCollection(t *testing.T) {
+	client, engine, err := Open(nil, Options{
+		Store: NewMemoryStore(),
+	})
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	db := client.Database("foo").(*Database)
+
+	err = db.CreateClusteredCollection(nil, "bar", options.CreateCollection().
+		SetExpireAfterSeconds(3600))
+	assert.NoError(t, err)
+
+	now := time.Now()
+	id1 := primitive.NewObjectIDFromTimestamp(now.Add(-2 * time.Hour))
+	id2 := primitive.NewObjectIDFromTimestamp(now.Add(-time.Minute))
+	id3 := primitive.NewObjectIDFromTimestamp(now)
+
+	coll := db.Collection("bar")
+	_, err = coll.InsertMany(nil, []interface{}{
+		bson.M{"_id": id3},
+		bson.M{"_id": id1},
+		bson.M{"_id": id2},
+	})
+	assert.NoError(t, err)
+
+	_, err = coll.InsertOne(nil, bson.M{"_id": id2})
+	assert.Error(t, err)
+
+	assert.Equal(t, []bson.M{
+		{"_id": id1},
+		{"_id": id2},
+		{"_id": id3},
+	}, dumpCollection(coll, false))
+
+	csr, err := coll.Find(nil, bson.M{"_id": bson.M{"$gt": id1, "$lte": id3}})
+	assert.NoError(t, err)
+	assert.Equal(t, []bson.M{
+		{"_id": id2},
+		{"_id": id3},
+	}, readAll(csr))
+
+	csr, err = coll.Indexes().List(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []bson.M{
+		{
+			"v":         int32(2),
+			"key":       bson.M{"_id": int32(1)},
+			"name":      "_id_",
+			"unique":    true,
+			"clustered": true,
+		},
+	}, readAll(csr))
+
+	csr, err = db.ListCollections(nil, bson.M{"name": "bar"})
+	assert.NoError(t, err)
+	list := readAll(csr)
+	assert.Len(t, list, 1)
+	assert.NotNil(t, list[0]["options"].(bson.M)["clusteredIndex"])
+	assert.EqualValues(t, 3600, list[0]["options"].(bson.M)["expireAfterSeconds"])
+
+	txn, err := engine.Begin(nil, true)
+	assert.NoError(t, err)
+	err = txn.Expire()
+	assert.NoError(t, err)
+	err = engine.Commit(txn)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []bson.M{
+		{"_id": id2},
+		{"_id": id3},
+	}, dumpCollection(coll, false))
+}
@@ -0,0 +1,71 @@
+package dbkit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrFileLocked is returned if a file lock is held by another process.
+var ErrFileLocked = errors.New("file locked")
+
+// FileLock is an advisory inter-process lock on a file.
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile will open or create the file named by path and acquire an advisory
+// lock on it. A shared lock may be held by multiple processes while an
+// exclusive lock is held by a single process. If wait is false, ErrFileLocked is
+// returned if the lock is held by another process. On platforms without
+// flock(2), all locks are acquired exclusively using a separate lock file.
+func LockFile(path string, mode os.FileMode, shared, wait bool) (*FileLock, error) {
+	// check path
+	if path == "" {
+		return nil, fmt.Errorf("empty file path")
+	}
+
+	// set default mode
+	if mode == 0 {
+		mode = 0666
+	}
+
+	// open file
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %q: %v", path, err)
+	}
+
+	// acquire lock
+	err = lockFile(file, shared, wait)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &FileLock{
+		file: file,
+	}, nil
+}
+
+// Unlock will release the lock and close the file.
+func (l *FileLock) Unlock() error {
+	// check file
+	if l.file == nil {
+		return nil
+	}
+
+	// release lock
+	err := unlockFile(l.file)
+	if err != nil {
+		_ = l.file.Close()
+		l.file = nil
+		return err
+	}
+
+	// close file
+	err = l.file.Close()
+	l.file = nil
+
+	return err
+}
@@ -0,0 +1,48 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package dbkit
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Platforms without flock(2) use an exclusive lock file that is created next
+// to the file. Shared locks are therefore acquired exclusively and a lock file
+// left behind by a crashed process must be removed manually.
+
+func lockFile(file *os.File, _, wait bool) error {
+	for {
+		// create lock file
+		f, err := os.OpenFile(exclusiveLockPath(file), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			return f.Close()
+		} else if !os.IsExist(err) {
+			return fmt.Errorf("failed to lock file %q: %v", file.Name(), err)
+		}
+
+		// check wait
+		if !wait {
+			return ErrFileLocked
+		}
+
+		// retry later
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func unlockFile(file *os.File) error {
+	// remove lock file
+	err := os.Remove(exclusiveLockPath(file))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to unlock file %q: %v", file.Name(), err)
+	}
+
+	return nil
+}
+
+func exclusiveLockPath(file *os.File) string {
+	return file.Name() + ".excl"
+}
@@ -0,0 +1,55 @@
+package dbkit
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockFile(t *testing.T) {
+	defer os.Remove("./lock")
+
+	lock1, err := LockFile("./lock", 0, false, false)
+	assert.NoError(t, err)
+
+	lock2, err := LockFile("./lock", 0, false, false)
+	assert.Equal(t, ErrFileLocked, err)
+	assert.Nil(t, lock2)
+
+	lock2, err = LockFile("./lock", 0, true, false)
+	assert.Equal(t, ErrFileLocked, err)
+	assert.Nil(t, lock2)
+
+	done := make(chan struct{})
+	go func() {
+		lock, err := LockFile("./lock", 0, false, true)
+		assert.NoError(t, err)
+		assert.NoError(t, lock.Unlock())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("lock acquired")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	err = lock1.Unlock()
+	assert.NoError(t, err)
+	<-done
+
+	lock1, err = LockFile("./lock", 0, true, false)
+	assert.NoError(t, err)
+
+	lock2, err = LockFile("./lock", 0, true, false)
+	assert.NoError(t, err)
+
+	_, err = LockFile("./lock", 0, false, false)
+	assert.Equal(t, ErrFileLocked, err)
+
+	assert.NoError(t, lock1.Unlock())
+	assert.NoError(t, lock2.Unlock())
+	assert.NoError(t, lock2.Unlock())
+}
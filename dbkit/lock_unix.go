@@ -0,0 +1,45 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package dbkit
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func lockFile(file *os.File, shared, wait bool) error {
+	// prepare operation
+	op := syscall.LOCK_EX
+	if shared {
+		op = syscall.LOCK_SH
+	}
+	if !wait {
+		op |= syscall.LOCK_NB
+	}
+
+	// acquire lock, retry if interrupted
+	for {
+		err := syscall.Flock(int(file.Fd()), op)
+		if err == syscall.EINTR {
+			continue
+		} else if err == syscall.EWOULDBLOCK {
+			return ErrFileLocked
+		} else if err != nil {
+			return fmt.Errorf("failed to lock file %q: %v", file.Name(), err)
+		}
+
+		return nil
+	}
+}
+
+func unlockFile(file *os.File) error {
+	// release lock
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	if err != nil {
+		return fmt.Errorf("failed to unlock file %q: %v", file.Name(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package lungo
+
+import (
+	"reflect"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+)
+
+// DeltaStore is an optional interface implemented by storage adapters that
+// persist the changes of a commit rather than the whole catalog. If the store
+// of an engine implements the interface, StoreDelta is called with the new
+// catalog and the changes to the previously stored catalog instead of Store.
+type DeltaStore interface {
+	Store
+	StoreDelta(catalog *Catalog, deltas []Delta) error
+}
+
+// Delta describes the changes of a single namespace between two catalogs.
+type Delta struct {
+	// The handle of the changed namespace.
+	Handle Handle
+
+	// The new namespace, nil if the namespace has been dropped.
+	Namespace *mongokit.Collection
+
+	// Whether the namespace has been created or dropped.
+	Created bool
+	Dropped bool
+
+	// Whether the namespace options (validator, view, capped, etc.) changed.
+	Options bool
+
+	// The inserted, replaced and removed documents. The documents of created
+	// namespaces are reported as inserted and those of dropped namespaces as
+	// removed.
+	Inserted bsonkit.List
+	Replaced bsonkit.List
+	Removed  bsonkit.List
+
+	// The created or modified indexes and the names of the dropped indexes.
+	CreatedIndexes map[string]mongokit.IndexConfig
+	DroppedIndexes []string
+}
+
+// Diff will compute the changes between the specified catalogs. The document
+// changes tracked by namespaces cloned from the previous catalog are used if
+// available. Otherwise, the documents are compared by pointer as namespaces
+// and documents are cloned on write. The deltas are ordered by namespace.
+func Diff(from, to *Catalog) []Delta {
+	// collect changed handles
+	var handles []Handle
+	for handle, namespace := range to.Namespaces {
+		if from.Namespaces[handle] != namespace {
+			handles = append(handles, handle)
+		}
+	}
+	for handle := range from.Namespaces {
+		if to.Namespaces[handle] == nil {
+			handles = append(handles, handle)
+		}
+	}
+
+	// sort handles
+	sort.Slice(handles, func(i, j int) bool {
+		return handles[i].String() < handles[j].String()
+	})
+
+	// compute deltas
+	deltas := make([]Delta, 0, len(handles))
+	for _, handle := range handles {
+		deltas = append(deltas, diffNamespaces(handle, from.Namespaces[handle], to.Namespaces[handle]))
+	}
+
+	return deltas
+}
+
+func diffNamespaces(handle Handle, from, to *mongokit.Collection) Delta {
+	// handle dropped namespace
+	if to == nil {
+		return Delta{
+			Handle:         handle,
+			Dropped:        true,
+			Removed:        from.Documents.List,
+			DroppedIndexes: sortedIndexes(from),
+		}
+	}
+
+	// handle created namespace
+	if from == nil {
+		d := Delta{
+			Handle:    handle,
+			Namespace: to,
+			Created:   true,
+			Options:   true,
+			Inserted:  to.Documents.List,
+		}
+		d.CreatedIndexes, d.DroppedIndexes = diffIndexes(&mongokit.Collection{}, to)
+		return d
+	}
+
+	// prepare delta
+	d := Delta{
+		Handle:    handle,
+		Namespace: to,
+		Options:   !equalOptions(from, to),
+	}
+
+	// diff indexes
+	d.CreatedIndexes, d.DroppedIndexes = diffIndexes(from, to)
+
+	// use tracked changes
+	if changes, ok := to.TrackedChanges(from); ok {
+		d.Inserted, d.Replaced, d.Removed = collectChanges(changes)
+		return d
+	}
+
+	// otherwise, collect removed and inserted documents, replaced documents
+	// cannot be distinguished and are reported as removed and inserted
+	for _, doc := range from.Documents.List {
+		if _, ok := to.Documents.Index[doc]; !ok {
+			d.Removed = append(d.Removed, doc)
+		}
+	}
+	for _, doc := range to.Documents.List {
+		if _, ok := from.Documents.Index[doc]; !ok {
+			d.Inserted = append(d.Inserted, doc)
+		}
+	}
+
+	return d
+}
+
+func collectChanges(changes []mongokit.DocumentChange) (bsonkit.List, bsonkit.List, bsonkit.List) {
+	// prepare state, changed documents map to the original document they
+	// replaced or nil if they have been inserted
+	origins := map[bsonkit.Doc]bsonkit.Doc{}
+	positions := map[bsonkit.Doc]int{}
+	var added, removed bsonkit.List
+
+	// apply changes
+	for _, change := range changes {
+		// get origin and position of removed document
+		origin := change.Removed
+		position := -1
+		if change.Removed != nil {
+			if o, ok := origins[change.Removed]; ok {
+				origin = o
+				position = positions[change.Removed]
+				delete(origins, change.Removed)
+				delete(positions, change.Removed)
+			}
+		}
+
+		// handle removal
+		if change.Added == nil {
+			if position >= 0 {
+				added[position] = nil
+			}
+			if origin != nil {
+				removed = append(removed, origin)
+			}
+			continue
+		}
+
+		// handle insertion and replacement, documents keep their position
+		if position < 0 {
+			position = len(added)
+			added = append(added, nil)
+		}
+		added[position] = change.Added
+		origins[change.Added] = origin
+		positions[change.Added] = position
+	}
+
+	// split inserted and replaced documents
+	var inserted, replaced bsonkit.List
+	for _, doc := range added {
+		if doc == nil {
+			continue
+		} else if origins[doc] == nil {
+			inserted = append(inserted, doc)
+		} else {
+			replaced = append(replaced, doc)
+		}
+	}
+
+	return inserted, replaced, removed
+}
+
+func diffIndexes(from, to *mongokit.Collection) (map[string]mongokit.IndexConfig, []string) {
+	// collect created and modified indexes
+	var created map[string]mongokit.IndexConfig
+	for name, index := range to.Indexes {
+		prev := from.Indexes[name]
+		if prev != nil && reflect.DeepEqual(prev.Config(), index.Config()) {
+			continue
+		}
+		if created == nil {
+			created = map[string]mongokit.IndexConfig{}
+		}
+		created[name] = index.Config()
+	}
+
+	// collect dropped indexes
+	var dropped []string
+	for _, name := range sortedIndexes(from) {
+		if to.Indexes[name] == nil {
+			dropped = append(dropped, name)
+		}
+	}
+
+	return created, dropped
+}
+
+func equalOptions(a, b *mongokit.Collection) bool {
+	// build namespaces without documents and indexes
+	na := BuildFileNamespace(a)
+	nb := BuildFileNamespace(b)
+	na.Documents, na.Indexes = nil, nil
+	nb.Documents, nb.Indexes = nil, nil
+
+	return reflect.DeepEqual(na, nb)
+}
+
+func sortedIndexes(namespace *mongokit.Collection) []string {
+	// collect names
+	names := make([]string, 0, len(namespace.Indexes))
+	for name := range namespace.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func idKey(id interface{}) string {
+	// encode id
+	typ, raw, _ := bson.MarshalValue(id)
+
+	return string(append([]byte{byte(typ)}, raw...))
+}
@@ -0,0 +1,183 @@
+package lungo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+)
+
+type deltaStore struct {
+	*MemoryStore
+	deltas [][]Delta
+}
+
+func (s *deltaStore) StoreDelta(catalog *Catalog, deltas []Delta) error {
+	s.deltas = append(s.deltas, deltas)
+	return s.Store(catalog)
+}
+
+func TestDeltaStore(t *testing.T) {
+	store := &deltaStore{MemoryStore: NewMemoryStore()}
+
+	engine, err := CreateEngine(Options{Store: store})
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	handle := Handle{"foo", "bar"}
+
+	commit := func(fn func(txn *Transaction)) []Delta {
+		txn, err := engine.Begin(nil, true)
+		assert.NoError(t, err)
+		fn(txn)
+		err = engine.Commit(txn)
+		assert.NoError(t, err)
+
+		// filter oplog
+		var deltas []Delta
+		for _, d := range store.deltas[len(store.deltas)-1] {
+			if d.Handle != Oplog {
+				deltas = append(deltas, d)
+			}
+		}
+
+		return deltas
+	}
+
+	a := bsonkit.MustConvert(bson.M{"_id": "a", "n": int32(1)})
+	b := bsonkit.MustConvert(bson.M{"_id": "b", "n": int32(2)})
+
+	deltas := commit(func(txn *Transaction) {
+		_, err := txn.Insert(handle, bsonkit.List{a, b}, true)
+		assert.NoError(t, err)
+	})
+	assert.Len(t, deltas, 1)
+	assert.Equal(t, handle, deltas[0].Handle)
+	assert.True(t, deltas[0].Created)
+	assert.True(t, deltas[0].Options)
+	assert.Equal(t, bsonkit.List{a, b}, deltas[0].Inserted)
+	assert.Equal(t, []string{"_id_"}, indexNames(deltas[0].CreatedIndexes))
+
+	deltas = commit(func(txn *Transaction) {
+		_, err := txn.CreateIndex(handle, "n", mongokit.IndexConfig{
+			Key:    bsonkit.MustConvert(bson.M{"n": int32(1)}),
+			Expiry: time.Minute,
+		})
+		assert.NoError(t, err)
+
+		_, err = txn.Update(handle, bsonkit.MustConvert(bson.M{"_id": "b"}), nil, bsonkit.MustConvert(bson.M{
+			"$set": bson.M{"n": int32(3)},
+		}), 0, 1, false, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = txn.Delete(handle, bsonkit.MustConvert(bson.M{"_id": "a"}), nil, 0, 1, nil)
+		assert.NoError(t, err)
+
+		_, err = txn.Insert(handle, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": "c", "n": int32(4)}),
+		}, true)
+		assert.NoError(t, err)
+	})
+	assert.Len(t, deltas, 1)
+	assert.False(t, deltas[0].Created)
+	assert.False(t, deltas[0].Options)
+	assert.Equal(t, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "c", "n": int32(4)}),
+	}, deltas[0].Inserted)
+	assert.Equal(t, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "b", "n": int32(3)}),
+	}, deltas[0].Replaced)
+	assert.Equal(t, bsonkit.List{a}, deltas[0].Removed)
+	assert.Equal(t, map[string]mongokit.IndexConfig{
+		"n": {
+			Key:    bsonkit.MustConvert(bson.M{"n": int32(1)}),
+			Expiry: time.Minute,
+		},
+	}, deltas[0].CreatedIndexes)
+	assert.Empty(t, deltas[0].DroppedIndexes)
+
+	deltas = commit(func(txn *Transaction) {
+		_, err := txn.Delete(handle, bsonkit.MustConvert(bson.M{"_id": "c"}), nil, 0, 1, nil)
+		assert.NoError(t, err)
+
+		_, err = txn.Insert(handle, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": "c", "n": int32(5)}),
+		}, true)
+		assert.NoError(t, err)
+	})
+	assert.Len(t, deltas, 1)
+	assert.Equal(t, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "c", "n": int32(5)}),
+	}, deltas[0].Inserted)
+	assert.Empty(t, deltas[0].Replaced)
+	assert.Equal(t, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "c", "n": int32(4)}),
+	}, deltas[0].Removed)
+
+	deltas = commit(func(txn *Transaction) {
+		err := txn.DropIndex(handle, "n")
+		assert.NoError(t, err)
+
+		err = txn.Create(Handle{"foo", "baz"}, mongokit.CollectionConfig{})
+		assert.NoError(t, err)
+	})
+	assert.Len(t, deltas, 2)
+	assert.Equal(t, Handle{"foo", "bar"}, deltas[0].Handle)
+	assert.Empty(t, deltas[0].CreatedIndexes)
+	assert.Equal(t, []string{"n"}, deltas[0].DroppedIndexes)
+	assert.Empty(t, deltas[0].Inserted)
+	assert.Empty(t, deltas[0].Replaced)
+	assert.Empty(t, deltas[0].Removed)
+	assert.Equal(t, Handle{"foo", "baz"}, deltas[1].Handle)
+	assert.True(t, deltas[1].Created)
+
+	deltas = commit(func(txn *Transaction) {
+		err := txn.Drop(handle)
+		assert.NoError(t, err)
+	})
+	assert.Len(t, deltas, 1)
+	assert.True(t, deltas[0].Dropped)
+	assert.Nil(t, deltas[0].Namespace)
+	assert.Len(t, deltas[0].Removed, 2)
+	assert.Equal(t, []string{"_id_"}, deltas[0].DroppedIndexes)
+
+	assert.Equal(t, engine.Catalog(), store.catalog)
+}
+
+func TestCollectChanges(t *testing.T) {
+	a1 := bsonkit.MustConvert(bson.M{"_id": "a", "n": int32(1)})
+	a2 := bsonkit.MustConvert(bson.M{"_id": "a", "n": int32(2)})
+	b1 := bsonkit.MustConvert(bson.M{"_id": "b", "n": int32(1)})
+	b2 := bsonkit.MustConvert(bson.M{"_id": "b", "n": int32(2)})
+	c1 := bsonkit.MustConvert(bson.M{"_id": "c", "n": int32(1)})
+	c2 := bsonkit.MustConvert(bson.M{"_id": "c", "n": int32(2)})
+	d1 := bsonkit.MustConvert(bson.M{"_id": "d", "n": int32(1)})
+	e1 := bsonkit.MustConvert(bson.M{"_id": "e", "n": int32(1)})
+
+	// a1 and b1 exist
+	inserted, replaced, removed := collectChanges([]mongokit.DocumentChange{
+		{Added: c1},
+		{Added: d1},
+		{Removed: c1, Added: c2},
+		{Removed: a1, Added: a2},
+		{Removed: a2},
+		{Removed: b1, Added: b2},
+		{Removed: d1},
+		{Added: e1},
+	})
+	assert.Equal(t, bsonkit.List{c2, e1}, inserted)
+	assert.Equal(t, bsonkit.List{b2}, replaced)
+	assert.Equal(t, bsonkit.List{a1}, removed)
+}
+
+func indexNames(m map[string]mongokit.IndexConfig) []string {
+	var list []string
+	for key := range m {
+		list = append(list, key)
+	}
+	return list
+}
@@ -0,0 +1,318 @@
+package lungo
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/dbkit"
+)
+
+// the name of the manifest file
+const dirManifest = "manifest.bson"
+
+// the prefix and suffix of namespace files
+const (
+	dirFilePrefix = "ns-"
+	dirFileSuffix = ".bson"
+)
+
+// the name of the lock file, the manifest itself is replaced when written
+const dirLock = "manifest.bson.lock"
+
+// DirStore writes every namespace of the catalog to a separate file in a
+// directory. A manifest file maps the namespaces to their files. Only the files
+// of namespaces that changed since the last stored catalog are rewritten. An
+// exclusive lock is acquired on the manifest when the catalog is loaded.
+type DirStore struct {
+	path     string
+	mode     os.FileMode
+	locking  FileLocking
+	lock     *dbkit.FileLock
+	catalog  *Catalog
+	manifest dirManifestFile
+	mutex    sync.Mutex
+}
+
+type dirManifestFile struct {
+	Sequence   int64             `bson:"sequence"`
+	Namespaces map[string]string `bson:"namespaces"`
+}
+
+// NewDirStore creates and returns a new directory store that stores the
+// catalog in the specified directory.
+func NewDirStore(path string, mode os.FileMode) *DirStore {
+	return &DirStore{
+		path: path,
+		mode: mode,
+	}
+}
+
+// Load will read the manifest and the namespace files from disk and return the
+// catalog. If no manifest exists in the directory an empty catalog is
+// returned.
+func (s *DirStore) Load() (*Catalog, error) {
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// acquire lock
+	err := s.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	// read manifest
+	var manifest dirManifestFile
+	buf, err := ioutil.ReadFile(filepath.Join(s.path, dirManifest))
+	if os.IsNotExist(err) {
+		s.catalog = NewCatalog()
+		s.manifest = dirManifestFile{Namespaces: map[string]string{}}
+		return s.catalog, s.clean(s.manifest)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// decode manifest
+	err = bson.Unmarshal(buf, &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// ensure namespaces
+	if manifest.Namespaces == nil {
+		manifest.Namespaces = map[string]string{}
+	}
+
+	// create catalog
+	catalog := NewCatalog()
+
+	// read namespaces
+	for name, file := range manifest.Namespaces {
+		// parse handle
+		handle, err := ParseHandle(name)
+		if err != nil {
+			return nil, err
+		}
+
+		// read file
+		ns, err := s.read(file, name)
+		if err != nil {
+			return nil, err
+		}
+
+		// build namespace
+		namespace, err := ns.BuildNamespace()
+		if err != nil {
+			return nil, err
+		}
+
+		// add namespace
+		catalog.Namespaces[handle] = namespace
+	}
+
+	// remove files of interrupted stores
+	err = s.clean(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// set state
+	s.catalog = catalog
+	s.manifest = manifest
+
+	return catalog, nil
+}
+
+// Store will write the files of the namespaces that changed since the last
+// stored catalog and atomically replace the manifest. Namespaces are cloned
+// on write and can therefore be compared by pointer. The files of changed and
+// dropped namespaces are removed once the manifest has been written. Files that
+// cannot be removed do not fail the store and are removed by the next load.
+func (s *DirStore) Store(catalog *Catalog) error {
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// acquire lock
+	err := s.acquire()
+	if err != nil {
+		return err
+	}
+
+	// get previous catalog
+	prev := s.catalog
+	if prev == nil {
+		prev = NewCatalog()
+	}
+
+	// ensure directory
+	err = os.MkdirAll(s.path, dirMode(s.mode))
+	if err != nil {
+		return err
+	}
+
+	// prepare manifest
+	manifest := dirManifestFile{
+		Sequence:   s.manifest.Sequence,
+		Namespaces: make(map[string]string, len(catalog.Namespaces)),
+	}
+
+	// write changed namespaces, new files are used to keep the current
+	// manifest valid until it is replaced
+	for handle, namespace := range catalog.Namespaces {
+		// keep unchanged namespaces
+		name := handle.String()
+		if file, ok := s.manifest.Namespaces[name]; ok && prev.Namespaces[handle] == namespace {
+			manifest.Namespaces[name] = file
+			continue
+		}
+
+		// write file
+		manifest.Sequence++
+		file := fmt.Sprintf("%s%d%s", dirFilePrefix, manifest.Sequence, dirFileSuffix)
+		err = storeFile(filepath.Join(s.path, file), s.mode, &File{
+			Namespaces: map[string]FileNamespace{
+				name: BuildFileNamespace(namespace),
+			},
+		}, NoCompression, nil)
+		if err != nil {
+			return err
+		}
+
+		// set file
+		manifest.Namespaces[name] = file
+	}
+
+	// encode manifest
+	buf, err := bson.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	// write manifest
+	err = dbkit.AtomicWriteFile(filepath.Join(s.path, dirManifest), bytes.NewReader(buf), s.mode)
+	if err != nil {
+		return err
+	}
+
+	// get previous manifest
+	prevManifest := s.manifest
+
+	// update state
+	s.catalog = catalog
+	s.manifest = manifest
+
+	// remove obsolete files, files that cannot be removed are cleaned up when
+	// the catalog is loaded again
+	for name, file := range prevManifest.Namespaces {
+		if manifest.Namespaces[name] != file {
+			_ = os.Remove(filepath.Join(s.path, file))
+		}
+	}
+
+	return nil
+}
+
+func (s *DirStore) read(file, name string) (*FileNamespace, error) {
+	// open file
+	f, err := os.Open(filepath.Join(s.path, file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// read file
+	data, err := ReadFile(f, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// get namespace
+	ns, ok := data.Namespaces[name]
+	if !ok || len(data.Namespaces) != 1 {
+		return nil, fmt.Errorf("namespace file %q does not contain namespace %q", file, name)
+	}
+
+	return &ns, nil
+}
+
+func (s *DirStore) clean(manifest dirManifestFile) error {
+	// collect files
+	files := make(map[string]bool, len(manifest.Namespaces))
+	for _, file := range manifest.Namespaces {
+		files[file] = true
+	}
+
+	// read directory
+	entries, err := ioutil.ReadDir(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// remove namespace and temporary files that are not listed in the
+	// manifest
+	for _, entry := range entries {
+		name := entry.Name()
+		file := strings.TrimSuffix(name, ".tmp")
+		if entry.IsDir() || files[name] || !strings.HasPrefix(file, dirFilePrefix) || !strings.HasSuffix(file, dirFileSuffix) {
+			continue
+		}
+		err = os.Remove(filepath.Join(s.path, name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Unlock will release the lock acquired when the catalog was loaded.
+func (s *DirStore) Unlock() error {
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// release lock
+	err := releaseLock(s.lock)
+	s.lock = nil
+
+	return err
+}
+
+func (s *DirStore) acquire() error {
+	// check lock
+	if s.lock != nil || s.locking.Disabled {
+		return nil
+	}
+
+	// ensure directory
+	err := os.MkdirAll(s.path, dirMode(s.mode))
+	if err != nil {
+		return err
+	}
+
+	// acquire lock
+	lock, err := acquireLock(s.lock, filepath.Join(s.path, dirLock), s.mode, s.locking)
+	if err != nil {
+		return err
+	}
+
+	// set lock
+	s.lock = lock
+
+	return nil
+}
+
+func dirMode(mode os.FileMode) os.FileMode {
+	// make readable directories searchable
+	return mode.Perm() | (mode.Perm()&0444)>>2
+}
@@ -29,6 +29,11 @@ type Options struct {
 	// The function that is called with errors from the expiry goroutine.
 	ExpireErrors func(error)
 
+	// The function that is called with validation errors of collections that
+	// use the "warn" validation action. It is called once the transaction that
+	// wrote the documents has been committed.
+	ValidationWarnings func(error)
+
 	// The minimum and maximum size of the oplog.
 	//
 	// Default: 100, 1000.
@@ -40,6 +45,9 @@ type Options struct {
 	// Default: 5m, 1h.
 	MinOplogAge time.Duration
 	MaxOplogAge time.Duration
+
+	// The archive that receives the events trimmed from the oplog.
+	OplogArchive *OplogArchive
 }
 
 // Engine manages the catalog loaded from a store and provides access to it
@@ -50,6 +58,7 @@ type Engine struct {
 	store   Store
 	catalog *Catalog
 	streams map[*Stream]struct{}
+	signals map[chan struct{}]struct{}
 	token   *dbkit.Semaphore
 	txn     *Transaction
 	closed  bool
@@ -85,12 +94,16 @@ func CreateEngine(opts Options) (*Engine, error) {
 		opts:    opts,
 		store:   opts.Store,
 		streams: map[*Stream]struct{}{},
+		signals: map[chan struct{}]struct{}{},
 		token:   dbkit.NewSemaphore(1),
 	}
 
 	// load catalog
 	data, err := e.store.Load()
 	if err != nil {
+		if store, ok := e.store.(LockingStore); ok {
+			_ = store.Unlock()
+		}
 		return nil, err
 	}
 
@@ -166,8 +179,20 @@ func (e *Engine) Begin(ctx context.Context, lock bool) (*Transaction, error) {
 
 // Commit will attempt to store the modified catalog and on success replace the
 // current catalog. If an error is returned the transaction has been aborted
-// and become invalid.
+// and become invalid. Trimmed oplog events are archived only after the catalog
+// has been stored. If archiving fails, the catalog is still committed and the
+// error is returned.
 func (e *Engine) Commit(txn *Transaction) error {
+	// report validation warnings after the lock has been released
+	var warnings []error
+	defer func() {
+		if e.opts.ValidationWarnings != nil {
+			for _, err := range warnings {
+				e.opts.ValidationWarnings(err)
+			}
+		}
+	}()
+
 	// acquire lock
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
@@ -193,18 +218,44 @@ func (e *Engine) Commit(txn *Transaction) error {
 		return nil
 	}
 
+	// refresh materialized views
+	err := txn.Refresh()
+	if err != nil {
+		return err
+	}
+
 	// clean oplog
-	txn.Clean(e.opts.MinOplogSize, e.opts.MaxOplogSize, e.opts.MinOplogAge, e.opts.MaxOplogAge)
+	trimmed := txn.Clean(e.opts.MinOplogSize, e.opts.MaxOplogSize, e.opts.MinOplogAge, e.opts.MaxOplogAge)
 
-	// write catalog
-	err := e.store.Store(txn.Catalog())
+	// write catalog or changes
+	if store, ok := e.store.(DeltaStore); ok {
+		err = store.StoreDelta(txn.Catalog(), Diff(e.catalog, txn.Catalog()))
+	} else {
+		err = e.store.Store(txn.Catalog())
+	}
 	if err != nil {
 		return err
 	}
 
+	// archive trimmed events once the catalog has been written
+	var archiveErr error
+	if e.opts.OplogArchive != nil && len(trimmed) > 0 {
+		archiveErr = e.opts.OplogArchive.Append(trimmed)
+	}
+
+	// track the changes of future transactions against the new namespaces
+	for handle, namespace := range txn.Catalog().Namespaces {
+		if e.catalog.Namespaces[handle] != namespace {
+			namespace.ResetChanges()
+		}
+	}
+
 	// set new catalog
 	e.catalog = txn.Catalog()
 
+	// get validation warnings
+	warnings = txn.Warnings()
+
 	// broadcast change
 	for stream := range e.streams {
 		select {
@@ -213,8 +264,15 @@ func (e *Engine) Commit(txn *Transaction) error {
 			// stream already got earlier signal
 		}
 	}
+	for signal := range e.signals {
+		select {
+		case signal <- struct{}{}:
+		default:
+			// subscriber already got earlier signal
+		}
+	}
 
-	return nil
+	return archiveErr
 }
 
 // Abort will abort the specified transaction. To ensure a transaction is
@@ -339,6 +397,33 @@ func (e *Engine) Watch(handle Handle, pipeline bsonkit.List, resumeAfter, startA
 	return stream, nil
 }
 
+// Subscribe will return a channel that receives a signal whenever a
+// transaction has been committed. The channel is closed when the engine is
+// closed. The returned function must be called to cancel the subscription.
+func (e *Engine) Subscribe() (<-chan struct{}, func(), error) {
+	// acquire lock
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// check if closed
+	if e.closed {
+		return nil, nil, ErrEngineClosed
+	}
+
+	// register signal
+	signal := make(chan struct{}, 1)
+	e.signals[signal] = struct{}{}
+
+	// prepare cancel
+	cancel := func() {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		delete(e.signals, signal)
+	}
+
+	return signal, cancel, nil
+}
+
 // Close will close the engine.
 func (e *Engine) Close() {
 	// acquire lock
@@ -355,6 +440,16 @@ func (e *Engine) Close() {
 		close(stream.signal)
 	}
 
+	// close signals
+	for signal := range e.signals {
+		close(signal)
+	}
+
+	// release store lock
+	if store, ok := e.store.(LockingStore); ok {
+		_ = store.Unlock()
+	}
+
 	// set flag
 	e.closed = true
 }
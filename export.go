@@ -0,0 +1,886 @@
+package lungo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+// the magic number and terminator of the mongodump archive format
+const (
+	dumpMagic      uint32 = 0x8199e26d
+	dumpTerminator uint32 = 0xffffffff
+)
+
+// the file suffixes of the mongodump directory layout
+const (
+	dumpDataSuffix     = ".bson"
+	dumpMetadataSuffix = ".metadata.json"
+	dumpGzipSuffix     = ".gz"
+)
+
+// the maximum accepted size of a document in a dump
+const dumpMaxDocSize = 48 * 1024 * 1024
+
+var dumpCRCTable = crc64.MakeTable(crc64.ECMA)
+
+// ExportOptions is used to configure an export.
+type ExportOptions struct {
+	// The databases and namespaces to include. All namespaces are included if
+	// both are empty. The local database is never exported.
+	Databases  []string
+	Namespaces []Handle
+
+	// The directory to write the export to using the mongodump directory
+	// layout. The writer is ignored if a directory is configured.
+	Directory string
+
+	// Whether the archive or the files in the directory are compressed using
+	// gzip like with "mongodump --gzip".
+	Gzip bool
+}
+
+// ImportOptions is used to configure an import.
+type ImportOptions struct {
+	// The directory to read the import from using the mongodump directory
+	// layout. The reader is ignored if a directory is configured.
+	Directory string
+
+	// Whether existing namespaces are dropped before they are imported like
+	// with "mongorestore --drop".
+	Drop bool
+}
+
+// dumpNamespace is a namespace of a dump. Namespaces without metadata only
+// provide documents, while namespaces without data are only created.
+type dumpNamespace struct {
+	handle    Handle
+	metadata  bsonkit.Doc
+	data      bool
+	documents bsonkit.List
+}
+
+// Export will write a consistent snapshot of the engine in the format written
+// by "mongodump --archive" to the writer or using the directory layout to the
+// configured directory. The metadata of every namespace includes its options
+// and index definitions. Time-series collections are exported with the
+// documents of their bucket namespace.
+func Export(engine *Engine, w io.Writer, opts ExportOptions) error {
+	// begin snapshot transaction
+	txn, err := engine.Begin(nil, false)
+	if err != nil {
+		return err
+	}
+
+	// collect namespaces
+	namespaces := exportNamespaces(selectNamespaces(txn.Catalog(), opts.Databases, opts.Namespaces))
+
+	// write directory
+	if opts.Directory != "" {
+		return writeDumpDirectory(opts.Directory, namespaces, opts.Gzip)
+	}
+
+	// prepare compression
+	if opts.Gzip {
+		zw := gzip.NewWriter(w)
+		err = writeDumpArchive(zw, namespaces)
+		if err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+
+	return writeDumpArchive(w, namespaces)
+}
+
+// Import will read namespaces in the format written by "mongodump --archive"
+// from the reader or using the directory layout from the configured directory
+// and import them in a single transaction. Gzip compressed archives and files
+// are detected automatically. Namespaces are created with their options and
+// indexes unless they already exist. Views are created after all other
+// namespaces. Namespaces in the local database and system namespaces other
+// than time-series buckets are skipped.
+func Import(engine *Engine, r io.Reader, opts ImportOptions) error {
+	// read namespaces
+	var namespaces []*dumpNamespace
+	var err error
+	if opts.Directory != "" {
+		namespaces, err = readDumpDirectory(opts.Directory)
+	} else {
+		namespaces, err = readDumpArchive(r)
+	}
+	if err != nil {
+		return err
+	}
+
+	// begin transaction
+	txn, err := engine.Begin(nil, true)
+	if err != nil {
+		return err
+	}
+
+	// ensure abortion
+	defer engine.Abort(txn)
+
+	// import namespaces
+	err = importNamespaces(txn, namespaces, opts.Drop)
+	if err != nil {
+		return err
+	}
+
+	// commit transaction
+	err = engine.Commit(txn)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func exportNamespaces(catalog *Catalog) []*dumpNamespace {
+	// sort handles
+	handles := make([]Handle, 0, len(catalog.Namespaces))
+	for handle := range catalog.Namespaces {
+		handles = append(handles, handle)
+	}
+	sort.Slice(handles, func(i, j int) bool {
+		return handles[i].String() < handles[j].String()
+	})
+
+	// prepare list
+	var list []*dumpNamespace
+	for _, handle := range handles {
+		// skip local and bucket namespaces
+		if handle[0] == Local || strings.HasPrefix(handle[1], BucketsPrefix) {
+			continue
+		}
+
+		// get namespace and info
+		namespace := catalog.Namespaces[handle]
+		info := collectionInfo(handle, namespace)
+		kind := bsonkit.Get(info, "type").(string)
+
+		// collect indexes
+		indexes := bson.A{}
+		if kind != "view" {
+			for _, spec := range listIndexes(namespace) {
+				indexes = append(indexes, *spec)
+			}
+		}
+
+		// add namespace
+		list = append(list, &dumpNamespace{
+			handle: handle,
+			metadata: &bson.D{
+				bson.E{Key: "options", Value: bsonkit.Get(info, "options")},
+				bson.E{Key: "indexes", Value: indexes},
+				bson.E{Key: "collectionName", Value: handle[1]},
+				bson.E{Key: "type", Value: kind},
+			},
+			data:      kind == "collection",
+			documents: namespace.Documents.List,
+		})
+
+		// add buckets of time-series collections
+		if kind == "timeseries" && catalog.Namespaces[handle.Buckets()] != nil {
+			list = append(list, &dumpNamespace{
+				handle:    handle.Buckets(),
+				data:      true,
+				documents: catalog.Namespaces[handle.Buckets()].Documents.List,
+			})
+		}
+	}
+
+	return list
+}
+
+func importNamespaces(txn *Transaction, namespaces []*dumpNamespace, drop bool) error {
+	// filter local and system namespaces
+	var list []*dumpNamespace
+	for _, ns := range namespaces {
+		if ns.handle[0] != Local && (!strings.HasPrefix(ns.handle[1], "system.") || strings.HasPrefix(ns.handle[1], BucketsPrefix)) {
+			list = append(list, ns)
+		}
+	}
+
+	// drop existing namespaces
+	if drop {
+		for _, ns := range list {
+			if txn.Catalog().Namespaces[ns.handle] != nil {
+				err := txn.Drop(ns.handle)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// prepare views
+	var views []*dumpNamespace
+
+	// import namespaces
+	for _, ns := range list {
+		// handle metadata
+		if ns.metadata != nil {
+			// get options
+			options, _ := bsonkit.Get(ns.metadata, "options").(bson.D)
+
+			// defer views
+			if _, ok := bsonkit.Get(&options, "viewOn").(string); ok {
+				views = append(views, ns)
+				continue
+			}
+
+			// create namespace
+			if txn.Catalog().Namespaces[ns.handle] == nil {
+				config, err := createConfig(options)
+				if err != nil {
+					return err
+				}
+				err = txn.Create(ns.handle, config)
+				if err != nil {
+					return err
+				}
+			}
+
+			// create indexes
+			indexes, _ := bsonkit.Get(ns.metadata, "indexes").(bson.A)
+			for _, item := range indexes {
+				// get spec
+				spec, ok := item.(bson.D)
+				if !ok {
+					return fmt.Errorf("invalid index specification in metadata of %s", ns.handle.String())
+				}
+
+				// get config
+				name, config, err := indexConfig(&spec)
+				if err != nil {
+					return err
+				}
+
+				// skip default index
+				if name == "_id_" {
+					continue
+				}
+
+				// create index
+				_, err = txn.CreateIndex(ns.handle, name, config)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// insert documents
+		if len(ns.documents) > 0 {
+			res, err := txn.Insert(ns.handle, ns.documents, true)
+			if err != nil {
+				return err
+			} else if res.Error != nil {
+				return res.Error
+			}
+		}
+	}
+
+	// create views
+	for _, ns := range views {
+		// skip existing views
+		if txn.Catalog().Namespaces[ns.handle] != nil {
+			continue
+		}
+
+		// get options
+		options := bsonkit.Get(ns.metadata, "options").(bson.D)
+		viewOn := bsonkit.Get(&options, "viewOn").(string)
+		collation, err := commandDoc(options, "collation", false)
+		if err != nil {
+			return err
+		}
+		var pipeline bsonkit.List
+		if _, ok := bsonkit.Get(&options, "pipeline").(bson.A); ok {
+			pipeline, err = commandList(options, "pipeline")
+			if err != nil {
+				return err
+			}
+		}
+
+		// create view
+		if commandBool(options, "materialized") {
+			err = txn.CreateMaterializedView(ns.handle, viewOn, pipeline, collation)
+		} else {
+			err = txn.CreateView(ns.handle, viewOn, pipeline, collation)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeDumpArchive(w io.Writer, namespaces []*dumpNamespace) error {
+	// prepare writer
+	bw := bufio.NewWriter(w)
+
+	// write magic number
+	err := binary.Write(bw, binary.LittleEndian, dumpMagic)
+	if err != nil {
+		return err
+	}
+
+	// write header
+	err = writeDumpDoc(bw, bson.D{
+		bson.E{Key: "concurrent_collections", Value: int32(1)},
+		bson.E{Key: "version", Value: "0.1"},
+		bson.E{Key: "server_version", Value: "5.0.0"},
+		bson.E{Key: "tool_version", Value: "lungo"},
+	})
+	if err != nil {
+		return err
+	}
+
+	// write metadata
+	for _, ns := range namespaces {
+		// skip namespaces without metadata
+		if ns.metadata == nil {
+			continue
+		}
+
+		// encode metadata
+		metadata, err := bson.MarshalExtJSON(ns.metadata, true, false)
+		if err != nil {
+			return err
+		}
+
+		// write metadata
+		err = writeDumpDoc(bw, bson.D{
+			bson.E{Key: "db", Value: ns.handle[0]},
+			bson.E{Key: "collection", Value: ns.handle[1]},
+			bson.E{Key: "metadata", Value: string(metadata)},
+			bson.E{Key: "type", Value: bsonkit.Get(ns.metadata, "type")},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// write terminator
+	err = binary.Write(bw, binary.LittleEndian, dumpTerminator)
+	if err != nil {
+		return err
+	}
+
+	// write data
+	for _, ns := range namespaces {
+		// skip namespaces without data
+		if !ns.data {
+			continue
+		}
+
+		// prepare checksum
+		checksum := crc64.New(dumpCRCTable)
+
+		// write documents
+		if len(ns.documents) > 0 {
+			err = writeDumpHeader(bw, ns.handle, false, 0)
+			if err != nil {
+				return err
+			}
+			for _, doc := range ns.documents {
+				err = writeDumpDoc(io.MultiWriter(bw, checksum), doc)
+				if err != nil {
+					return err
+				}
+			}
+			err = binary.Write(bw, binary.LittleEndian, dumpTerminator)
+			if err != nil {
+				return err
+			}
+		}
+
+		// write end
+		err = writeDumpHeader(bw, ns.handle, true, int64(checksum.Sum64()))
+		if err != nil {
+			return err
+		}
+		err = binary.Write(bw, binary.LittleEndian, dumpTerminator)
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func readDumpArchive(r io.Reader) ([]*dumpNamespace, error) {
+	// prepare reader
+	br, err := gzipReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// read magic number
+	var magic uint32
+	err = binary.Read(br, binary.LittleEndian, &magic)
+	if err != nil || magic != dumpMagic {
+		return nil, fmt.Errorf("invalid archive: missing magic number")
+	}
+
+	// read header
+	buf, err := readDumpDoc(br)
+	if err != nil {
+		return nil, err
+	} else if buf == nil {
+		return nil, fmt.Errorf("invalid archive: missing header")
+	}
+
+	// prepare namespaces
+	namespaces := map[Handle]*dumpNamespace{}
+	get := func(handle Handle) *dumpNamespace {
+		if namespaces[handle] == nil {
+			namespaces[handle] = &dumpNamespace{handle: handle}
+		}
+		return namespaces[handle]
+	}
+
+	// read metadata
+	for {
+		// read document
+		buf, err := readDumpDoc(br)
+		if err != nil {
+			return nil, err
+		} else if buf == nil {
+			break
+		}
+
+		// decode document
+		var meta struct {
+			DB         string `bson:"db"`
+			Collection string `bson:"collection"`
+			Metadata   string `bson:"metadata"`
+		}
+		err = bson.Unmarshal(buf, &meta)
+		if err != nil {
+			return nil, err
+		}
+
+		// decode metadata
+		get(Handle{meta.DB, meta.Collection}).metadata, err = decodeDumpMetadata([]byte(meta.Metadata))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// read data
+	checksums := map[Handle]hash.Hash64{}
+	for {
+		// read header
+		buf, err := readDumpDoc(br)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		} else if buf == nil {
+			return nil, fmt.Errorf("invalid archive: unexpected terminator")
+		}
+
+		// decode header
+		var header struct {
+			DB         string `bson:"db"`
+			Collection string `bson:"collection"`
+			EOF        bool   `bson:"EOF"`
+			CRC        int64  `bson:"CRC"`
+		}
+		err = bson.Unmarshal(buf, &header)
+		if err != nil {
+			return nil, err
+		}
+
+		// get namespace and checksum
+		handle := Handle{header.DB, header.Collection}
+		ns := get(handle)
+		ns.data = true
+		if checksums[handle] == nil {
+			checksums[handle] = crc64.New(dumpCRCTable)
+		}
+
+		// read documents
+		for {
+			// read document
+			buf, err := readDumpDoc(br)
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			} else if err != nil {
+				return nil, err
+			} else if buf == nil {
+				break
+			}
+
+			// check end
+			if header.EOF {
+				return nil, fmt.Errorf("invalid archive: unexpected document after end of %s", handle.String())
+			}
+
+			// update checksum
+			_, _ = checksums[handle].Write(buf)
+
+			// decode document
+			var doc bson.D
+			err = bson.Unmarshal(buf, &doc)
+			if err != nil {
+				return nil, err
+			}
+
+			// add document
+			ns.documents = append(ns.documents, &doc)
+		}
+
+		// verify checksum
+		if header.EOF && header.CRC != int64(checksums[handle].Sum64()) {
+			return nil, fmt.Errorf("invalid archive: checksum mismatch for %s", handle.String())
+		}
+	}
+
+	return sortDumpNamespaces(namespaces), nil
+}
+
+func writeDumpDirectory(dir string, namespaces []*dumpNamespace, compress bool) error {
+	// prepare suffix
+	suffix := ""
+	if compress {
+		suffix = dumpGzipSuffix
+	}
+
+	// write namespaces
+	for _, ns := range namespaces {
+		// ensure database directory
+		path := filepath.Join(dir, url.PathEscape(ns.handle[0]))
+		err := os.MkdirAll(path, 0777)
+		if err != nil {
+			return err
+		}
+
+		// get base name
+		base := filepath.Join(path, url.PathEscape(ns.handle[1]))
+
+		// write metadata
+		if ns.metadata != nil {
+			err = writeDumpFile(base+dumpMetadataSuffix+suffix, compress, func(w io.Writer) error {
+				buf, err := bson.MarshalExtJSON(ns.metadata, true, false)
+				if err != nil {
+					return err
+				}
+				_, err = w.Write(buf)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// write data
+		if ns.data {
+			err = writeDumpFile(base+dumpDataSuffix+suffix, compress, func(w io.Writer) error {
+				for _, doc := range ns.documents {
+					err := writeDumpDoc(w, doc)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func readDumpDirectory(dir string) ([]*dumpNamespace, error) {
+	// list databases
+	dbs, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare namespaces
+	namespaces := map[Handle]*dumpNamespace{}
+	get := func(handle Handle) *dumpNamespace {
+		if namespaces[handle] == nil {
+			namespaces[handle] = &dumpNamespace{handle: handle}
+		}
+		return namespaces[handle]
+	}
+
+	// read databases
+	for _, db := range dbs {
+		// skip files
+		if !db.IsDir() {
+			continue
+		}
+
+		// get name
+		dbName, err := url.PathUnescape(db.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		// list files
+		files, err := ioutil.ReadDir(filepath.Join(dir, db.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		// read files
+		for _, file := range files {
+			// get name and compression
+			name := file.Name()
+			compressed := strings.HasSuffix(name, dumpGzipSuffix)
+			name = strings.TrimSuffix(name, dumpGzipSuffix)
+
+			// determine kind
+			var metadata bool
+			if strings.HasSuffix(name, dumpMetadataSuffix) {
+				metadata = true
+				name = strings.TrimSuffix(name, dumpMetadataSuffix)
+			} else if strings.HasSuffix(name, dumpDataSuffix) {
+				name = strings.TrimSuffix(name, dumpDataSuffix)
+			} else {
+				continue
+			}
+
+			// get namespace
+			collName, err := url.PathUnescape(name)
+			if err != nil {
+				return nil, err
+			}
+			ns := get(Handle{dbName, collName})
+
+			// read file
+			err = readDumpFile(filepath.Join(dir, db.Name(), file.Name()), compressed, func(r *bufio.Reader) error {
+				// read metadata
+				if metadata {
+					buf, err := ioutil.ReadAll(r)
+					if err != nil {
+						return err
+					}
+					ns.metadata, err = decodeDumpMetadata(buf)
+					return err
+				}
+
+				// read documents
+				ns.data = true
+				for {
+					buf, err := readDumpDoc(r)
+					if err == io.EOF {
+						return nil
+					} else if err != nil {
+						return err
+					} else if buf == nil {
+						return fmt.Errorf("invalid file: unexpected terminator")
+					}
+					var doc bson.D
+					err = bson.Unmarshal(buf, &doc)
+					if err != nil {
+						return err
+					}
+					ns.documents = append(ns.documents, &doc)
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sortDumpNamespaces(namespaces), nil
+}
+
+func writeDumpFile(path string, compress bool, fn func(io.Writer) error) error {
+	// create file
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	// ensure close
+	defer file.Close()
+
+	// prepare writer
+	bw := bufio.NewWriter(file)
+	var w io.Writer = bw
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(bw)
+		w = zw
+	}
+
+	// yield writer
+	err = fn(w)
+	if err != nil {
+		return err
+	}
+
+	// close compression
+	if zw != nil {
+		err = zw.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	// flush writer
+	err = bw.Flush()
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+func readDumpFile(path string, compressed bool, fn func(*bufio.Reader) error) error {
+	// open file
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	// ensure close
+	defer file.Close()
+
+	// prepare reader
+	var r io.Reader = file
+	if compressed {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		r = zr
+	}
+
+	return fn(bufio.NewReader(r))
+}
+
+func gzipReader(r io.Reader) (*bufio.Reader, error) {
+	// check gzip magic
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	if !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return br, nil
+	}
+
+	// prepare decompression
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	return bufio.NewReader(zr), nil
+}
+
+func decodeDumpMetadata(buf []byte) (bsonkit.Doc, error) {
+	// decode extended JSON
+	var doc bson.D
+	err := bson.UnmarshalExtJSON(buf, false, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+func sortDumpNamespaces(namespaces map[Handle]*dumpNamespace) []*dumpNamespace {
+	// collect namespaces
+	list := make([]*dumpNamespace, 0, len(namespaces))
+	for _, ns := range namespaces {
+		list = append(list, ns)
+	}
+
+	// sort namespaces, namespaces with metadata first
+	sort.Slice(list, func(i, j int) bool {
+		if (list[i].metadata == nil) != (list[j].metadata == nil) {
+			return list[i].metadata != nil
+		}
+		return list[i].handle.String() < list[j].handle.String()
+	})
+
+	return list
+}
+
+func writeDumpHeader(w io.Writer, handle Handle, eof bool, crc int64) error {
+	return writeDumpDoc(w, bson.D{
+		bson.E{Key: "db", Value: handle[0]},
+		bson.E{Key: "collection", Value: handle[1]},
+		bson.E{Key: "EOF", Value: eof},
+		bson.E{Key: "CRC", Value: crc},
+	})
+}
+
+func writeDumpDoc(w io.Writer, doc interface{}) error {
+	// encode document
+	buf, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	// write document
+	_, err = w.Write(buf)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func readDumpDoc(r *bufio.Reader) ([]byte, error) {
+	// read size
+	head := make([]byte, 4)
+	n, err := io.ReadFull(r, head)
+	if n == 0 && err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	// check terminator
+	size := binary.LittleEndian.Uint32(head)
+	if size == dumpTerminator {
+		return nil, nil
+	} else if size < 5 || size > dumpMaxDocSize {
+		return nil, fmt.Errorf("invalid document size %d", size)
+	}
+
+	// read document
+	buf := make([]byte, size)
+	copy(buf, head)
+	_, err = io.ReadFull(r, buf[4:])
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	// validate document
+	err = bsoncore.Document(buf).Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
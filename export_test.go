@@ -0,0 +1,244 @@
+package lungo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+)
+
+func TestExportImport(t *testing.T) {
+	_ = os.RemoveAll("./test")
+	defer os.RemoveAll("./test")
+
+	engine, err := CreateEngine(Options{Store: NewMemoryStore()})
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	txn, err := engine.Begin(nil, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "a", "cat": "x", "n": int32(1)}),
+		bsonkit.MustConvert(bson.M{"_id": "b", "cat": "x", "n": int32(2)}),
+		bsonkit.MustConvert(bson.M{"_id": "c", "cat": "y", "n": int32(3)}),
+	}, true)
+	assert.NoError(t, err)
+	_, err = txn.CreateIndex(Handle{"foo", "bar"}, "n_1", mongokit.IndexConfig{
+		Key:     bsonkit.MustConvert(bson.M{"n": int32(1)}),
+		Unique:  true,
+		Partial: bsonkit.MustConvert(bson.M{"n": bson.M{"$gt": int32(0)}}),
+	})
+	assert.NoError(t, err)
+	err = txn.Create(Handle{"foo", "log"}, mongokit.CollectionConfig{
+		Capped:      true,
+		SizeInBytes: 4096,
+	})
+	assert.NoError(t, err)
+	err = txn.CreateView(Handle{"foo", "view"}, "bar", bsonkit.List{
+		bsonkit.MustConvert(bson.M{"$match": bson.M{"cat": "x"}}),
+	}, nil)
+	assert.NoError(t, err)
+	err = txn.CreateMaterializedView(Handle{"foo", "stats"}, "bar", bsonkit.List{
+		bsonkit.MustConvert(bson.M{"$group": bson.M{"_id": "$cat", "n": bson.M{"$sum": "$n"}}}),
+	}, nil)
+	assert.NoError(t, err)
+	err = txn.Create(Handle{"baz", "series"}, mongokit.CollectionConfig{
+		TimeField: "ts",
+	})
+	assert.NoError(t, err)
+	_, err = txn.Insert(Handle{"baz", "series"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"ts": time.Unix(1, 0).UTC(), "v": int32(1)}),
+	}, true)
+	assert.NoError(t, err)
+	assert.NoError(t, engine.Commit(txn))
+
+	snapshot := BuildFile(engine.Catalog())
+	delete(snapshot.Namespaces, Oplog.String())
+
+	check := func(e *Engine) {
+		file := BuildFile(e.Catalog())
+		delete(file.Namespaces, Oplog.String())
+		assert.Equal(t, snapshot, file)
+	}
+
+	/* archive */
+
+	var buf bytes.Buffer
+	err = Export(engine, &buf, ExportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x6d, 0xe2, 0x99, 0x81}, buf.Bytes()[:4])
+
+	target, err := CreateEngine(Options{Store: NewMemoryStore()})
+	assert.NoError(t, err)
+	err = Import(target, bytes.NewReader(buf.Bytes()), ImportOptions{})
+	assert.NoError(t, err)
+	check(target)
+	target.Close()
+
+	/* gzip archive */
+
+	buf.Reset()
+	err = Export(engine, &buf, ExportOptions{Gzip: true})
+	assert.NoError(t, err)
+
+	target, err = CreateEngine(Options{Store: NewMemoryStore()})
+	assert.NoError(t, err)
+	err = Import(target, &buf, ImportOptions{})
+	assert.NoError(t, err)
+	check(target)
+
+	/* drop */
+
+	err = Export(engine, nil, ExportOptions{Directory: "./test"})
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join("test", "foo", "bar.bson"))
+	assert.FileExists(t, filepath.Join("test", "foo", "bar.metadata.json"))
+	assert.FileExists(t, filepath.Join("test", "foo", "view.metadata.json"))
+	assert.FileExists(t, filepath.Join("test", "baz", "system.buckets.series.bson"))
+
+	err = Import(target, nil, ImportOptions{Directory: "./test"})
+	assert.Error(t, err)
+
+	err = Import(target, nil, ImportOptions{Directory: "./test", Drop: true})
+	assert.NoError(t, err)
+	check(target)
+	target.Close()
+
+	/* gzip directory */
+
+	assert.NoError(t, os.RemoveAll("./test"))
+	err = Export(engine, nil, ExportOptions{
+		Namespaces: []Handle{{"foo", "bar"}},
+		Directory:  "./test",
+		Gzip:       true,
+	})
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join("test", "foo", "bar.bson.gz"))
+	assert.FileExists(t, filepath.Join("test", "foo", "bar.metadata.json.gz"))
+
+	target, err = CreateEngine(Options{Store: NewMemoryStore()})
+	assert.NoError(t, err)
+	err = Import(target, nil, ImportOptions{Directory: "./test"})
+	assert.NoError(t, err)
+	assert.Len(t, target.Catalog().Namespaces, 2)
+	assert.Equal(t, snapshot.Namespaces["foo.bar"], BuildFile(target.Catalog()).Namespaces["foo.bar"])
+	target.Close()
+
+	/* checksum */
+
+	buf.Reset()
+	err = Export(engine, &buf, ExportOptions{})
+	assert.NoError(t, err)
+
+	data := buf.Bytes()
+	i := bytes.LastIndex(data, []byte("cat"))
+	data[i+8] = 'z'
+
+	target, err = CreateEngine(Options{Store: NewMemoryStore()})
+	assert.NoError(t, err)
+	err = Import(target, bytes.NewReader(data), ImportOptions{})
+	assert.Error(t, err)
+	target.Close()
+}
+
+func TestImportMongodump(t *testing.T) {
+	// build archive as written by mongodump
+	var buf bytes.Buffer
+	write := func(v interface{}) {
+		data, err := bson.Marshal(v)
+		assert.NoError(t, err)
+		buf.Write(data)
+	}
+	terminate := func() {
+		_ = binary.Write(&buf, binary.LittleEndian, dumpTerminator)
+	}
+
+	_ = binary.Write(&buf, binary.LittleEndian, dumpMagic)
+	write(bson.M{
+		"concurrent_collections": int32(4),
+		"version":                "0.1",
+		"server_version":         "6.0.5",
+		"tool_version":           "100.7.0",
+	})
+	write(bson.M{
+		"db":         "app",
+		"collection": "users",
+		"metadata":   `{"indexes":[{"v":{"$numberInt":"2"},"key":{"_id":{"$numberInt":"1"}},"name":"_id_"},{"v":{"$numberInt":"2"},"key":{"email":{"$numberInt":"1"}},"name":"email_1","unique":true},{"v":{"$numberInt":"2"},"key":{"created":{"$numberInt":"1"}},"name":"created_1","expireAfterSeconds":{"$numberInt":"3600"}}],"uuid":"8f0d5d9cb3d84c0a9e3c7a3f5e8f2a11","collectionName":"users","type":"collection"}`,
+		"size":       int64(0),
+		"type":       "collection",
+	})
+	write(bson.M{
+		"db":         "app",
+		"collection": "admins",
+		"metadata":   `{"options":{"viewOn":"users","pipeline":[{"$match":{"admin":true}}]},"indexes":[],"collectionName":"admins","type":"view"}`,
+		"size":       int64(0),
+		"type":       "view",
+	})
+	terminate()
+
+	checksum := crc64.New(dumpCRCTable)
+	write(bson.M{"db": "app", "collection": "users", "EOF": false, "CRC": int64(0)})
+	for _, doc := range []bson.D{
+		{{Key: "_id", Value: int32(1)}, {Key: "email", Value: "a@example.com"}, {Key: "admin", Value: true}},
+		{{Key: "_id", Value: int32(2)}, {Key: "email", Value: "b@example.com"}, {Key: "admin", Value: false}},
+	} {
+		data, err := bson.Marshal(doc)
+		assert.NoError(t, err)
+		buf.Write(data)
+		checksum.Write(data)
+	}
+	terminate()
+	write(bson.M{"db": "app", "collection": "users", "EOF": true, "CRC": int64(checksum.Sum64())})
+	terminate()
+
+	engine, err := CreateEngine(Options{Store: NewMemoryStore()})
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	err = Import(engine, &buf, ImportOptions{})
+	assert.NoError(t, err)
+
+	txn, err := engine.Begin(nil, false)
+	assert.NoError(t, err)
+
+	indexes, err := txn.ListIndexes(Handle{"app", "users"})
+	assert.NoError(t, err)
+	assert.Equal(t, bsonkit.List{
+		&bson.D{
+			{Key: "v", Value: 2},
+			{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+			{Key: "name", Value: "_id_"},
+		},
+		&bson.D{
+			{Key: "v", Value: 2},
+			{Key: "key", Value: bson.D{{Key: "created", Value: int32(1)}}},
+			{Key: "name", Value: "created_1"},
+			{Key: "expireAfterSeconds", Value: int32(3600)},
+		},
+		&bson.D{
+			{Key: "v", Value: 2},
+			{Key: "key", Value: bson.D{{Key: "email", Value: int32(1)}}},
+			{Key: "name", Value: "email_1"},
+			{Key: "unique", Value: true},
+		},
+	}, indexes)
+
+	res, err := txn.Find(Handle{"app", "admins"}, bsonkit.MustConvert(bson.M{}), nil, 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, bsonkit.List{
+		bsonkit.MustConvert(bson.D{
+			{Key: "_id", Value: int32(1)},
+			{Key: "email", Value: "a@example.com"},
+			{Key: "admin", Value: true},
+		}),
+	}, res.Matched)
+}
@@ -2,9 +2,10 @@ package lungo
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
 	"github.com/256dpi/lungo/bsonkit"
 	"github.com/256dpi/lungo/mongokit"
 )
@@ -12,20 +13,56 @@ import (
 // File is a format for storing catalogs in a single structure.
 type File struct {
 	Namespaces map[string]FileNamespace `bson:"namespaces"`
+
+	// The sequence of the last log record included in a WAL store snapshot.
+	Sequence int64 `bson:"sequence,omitempty"`
+
+	// The details of a backup.
+	Backup *FileBackup `bson:"backup,omitempty"`
+}
+
+// FileBackup describes the backup stored in a file.
+type FileBackup struct {
+	// The cluster time of the last oplog event included in the backup.
+	ClusterTime primitive.Timestamp `bson:"clusterTime"`
+
+	// The selected databases and namespaces, all namespaces are included if
+	// both are empty.
+	Databases  []string `bson:"databases,omitempty"`
+	Namespaces []string `bson:"namespaces,omitempty"`
 }
 
 // FileNamespace is a single namespace stored in a file.
 type FileNamespace struct {
-	Documents bsonkit.List         `bson:"documents"`
-	Indexes   map[string]FileIndex `bson:"indexes"`
+	Documents        bsonkit.List         `bson:"documents"`
+	Indexes          map[string]FileIndex `bson:"indexes"`
+	Collation        bsonkit.Doc          `bson:"collation,omitempty"`
+	ViewOn           string               `bson:"viewOn,omitempty"`
+	Pipeline         bsonkit.List         `bson:"pipeline,omitempty"`
+	Materialized     bool                 `bson:"materialized,omitempty"`
+	Validator        bsonkit.Doc          `bson:"validator,omitempty"`
+	ValidationLevel  string               `bson:"validationLevel,omitempty"`
+	ValidationAction string               `bson:"validationAction,omitempty"`
+	Capped           bool                 `bson:"capped,omitempty"`
+	SizeInBytes      int64                `bson:"size,omitempty"`
+	MaxDocuments     int64                `bson:"max,omitempty"`
+	Clustered        bool                 `bson:"clustered,omitempty"`
+	TimeField        string               `bson:"timeField,omitempty"`
+	MetaField        string               `bson:"metaField,omitempty"`
+	Granularity      string               `bson:"granularity,omitempty"`
+	ExpireAfter      int64                `bson:"expireAfterSeconds,omitempty"`
 }
 
 // FileIndex is a single index stored in a file.
 type FileIndex struct {
-	Key     bsonkit.Doc   `bson:"key"`
-	Unique  bool          `bson:"unique"`
-	Partial bsonkit.Doc   `bson:"partial"`
-	Expiry  time.Duration `bson:"expiry"`
+	Key                bsonkit.Doc   `bson:"key"`
+	Unique             bool          `bson:"unique"`
+	Sparse             bool          `bson:"sparse,omitempty"`
+	Partial            bsonkit.Doc   `bson:"partial"`
+	Expiry             time.Duration `bson:"expiry"`
+	Collation          bsonkit.Doc   `bson:"collation,omitempty"`
+	WildcardProjection bsonkit.Doc   `bson:"wildcardProjection,omitempty"`
+	Hidden             bool          `bson:"hidden,omitempty"`
 }
 
 // BuildFile will build a new file from the provided catalog.
@@ -37,29 +74,56 @@ func BuildFile(catalog *Catalog) *File {
 
 	// add namespaces
 	for handle, namespace := range catalog.Namespaces {
-		// collect indexes
-		indexes := map[string]FileIndex{}
-		for name, index := range namespace.Indexes {
-			// get config
-			config := index.Config()
-
-			// add index
-			indexes[name] = FileIndex{
-				Key:     config.Key,
-				Unique:  config.Unique,
-				Partial: config.Partial,
-				Expiry:  config.Expiry,
-			}
-		}
+		file.Namespaces[handle.String()] = BuildFileNamespace(namespace)
+	}
 
-		// add namespace
-		file.Namespaces[handle.String()] = FileNamespace{
-			Documents: namespace.Documents.List,
-			Indexes:   indexes,
+	return file
+}
+
+// BuildFileNamespace will build a new file namespace from the provided
+// namespace.
+func BuildFileNamespace(namespace *mongokit.Collection) FileNamespace {
+	// collect indexes
+	indexes := map[string]FileIndex{}
+	for name, index := range namespace.Indexes {
+		// get config
+		config := index.Config()
+
+		// add index
+		indexes[name] = FileIndex{
+			Key:                config.Key,
+			Unique:             config.Unique,
+			Sparse:             config.Sparse,
+			Partial:            config.Partial,
+			Expiry:             config.Expiry,
+			Collation:          config.Collation,
+			WildcardProjection: config.WildcardProjection,
+			Hidden:             config.Hidden,
 		}
 	}
 
-	return file
+	// get config
+	config := namespace.Config()
+
+	return FileNamespace{
+		Documents:        namespace.Documents.List,
+		Indexes:          indexes,
+		Collation:        config.Collation,
+		ViewOn:           config.ViewOn,
+		Pipeline:         config.Pipeline,
+		Materialized:     config.Materialized,
+		Validator:        config.Validator,
+		ValidationLevel:  config.ValidationLevel,
+		ValidationAction: config.ValidationAction,
+		Capped:           config.Capped,
+		SizeInBytes:      config.SizeInBytes,
+		MaxDocuments:     config.MaxDocuments,
+		Clustered:        config.Clustered,
+		TimeField:        config.TimeField,
+		MetaField:        config.MetaField,
+		Granularity:      config.Granularity,
+		ExpireAfter:      config.ExpireAfterSeconds,
+	}
 }
 
 // BuildCatalog will build a new catalog from the file.
@@ -69,44 +133,16 @@ func (f *File) BuildCatalog() (*Catalog, error) {
 
 	// process namespaces
 	for name, ns := range f.Namespaces {
-		// split name
-		segments := strings.Split(name, ".")
-		if len(segments) != 2 {
-			return nil, fmt.Errorf("invalid namespace name %q", name)
+		// parse handle
+		handle, err := ParseHandle(name)
+		if err != nil {
+			return nil, err
 		}
 
-		// prepare handle
-		handle := Handle{segments[0], segments[1]}
-
-		// create namespace
-		namespace := mongokit.NewCollection(false)
-
-		// add documents
-		namespace.Documents = bsonkit.NewSet(ns.Documents)
-
-		// add indexes
-		for name, idx := range ns.Indexes {
-			// create index
-			index, err := mongokit.CreateIndex(mongokit.IndexConfig{
-				Key:     idx.Key,
-				Unique:  idx.Unique,
-				Partial: idx.Partial,
-				Expiry:  idx.Expiry,
-			})
-			if err != nil {
-				return nil, err
-			}
-
-			// build index
-			ok, err := index.Build(ns.Documents)
-			if err != nil {
-				return nil, err
-			} else if !ok {
-				return nil, fmt.Errorf("duplicate document for index %q", name)
-			}
-
-			// add index
-			namespace.Indexes[name] = index
+		// build namespace
+		namespace, err := ns.BuildNamespace()
+		if err != nil {
+			return nil, err
 		}
 
 		// add namespace
@@ -115,3 +151,62 @@ func (f *File) BuildCatalog() (*Catalog, error) {
 
 	return catalog, nil
 }
+
+// BuildNamespace will build a new namespace from the file namespace.
+func (n *FileNamespace) BuildNamespace() (*mongokit.Collection, error) {
+	// create namespace
+	namespace, err := mongokit.CreateCollection(mongokit.CollectionConfig{
+		Collation:          n.Collation,
+		ViewOn:             n.ViewOn,
+		Pipeline:           n.Pipeline,
+		Materialized:       n.Materialized,
+		Validator:          n.Validator,
+		ValidationLevel:    n.ValidationLevel,
+		ValidationAction:   n.ValidationAction,
+		Capped:             n.Capped,
+		SizeInBytes:        n.SizeInBytes,
+		MaxDocuments:       n.MaxDocuments,
+		Clustered:          n.Clustered,
+		TimeField:          n.TimeField,
+		MetaField:          n.MetaField,
+		Granularity:        n.Granularity,
+		ExpireAfterSeconds: n.ExpireAfter,
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// add documents
+	namespace.Documents = bsonkit.NewSet(n.Documents)
+
+	// add indexes
+	for name, idx := range n.Indexes {
+		// create index
+		index, err := mongokit.CreateIndex(mongokit.IndexConfig{
+			Key:                idx.Key,
+			Unique:             idx.Unique,
+			Sparse:             idx.Sparse,
+			Partial:            idx.Partial,
+			Expiry:             idx.Expiry,
+			Collation:          idx.Collation,
+			WildcardProjection: idx.WildcardProjection,
+			Hidden:             idx.Hidden,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// build index
+		ok, err := index.Build(n.Documents)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, fmt.Errorf("duplicate document for index %q", name)
+		}
+
+		// add index
+		namespace.Indexes[name] = index
+	}
+
+	return namespace, nil
+}
@@ -0,0 +1,844 @@
+package lungo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// ErrFileTruncated is returned if an encoded file ends prematurely.
+var ErrFileTruncated = errors.New("file truncated")
+
+// ErrFileCorrupted is returned if an encoded file does not match its checksum
+// or cannot be decoded.
+var ErrFileCorrupted = errors.New("file corrupted")
+
+// ErrDecryptionFailed is returned if an encrypted file cannot be decrypted with
+// the configured keys.
+var ErrDecryptionFailed = errors.New("file decryption failed")
+
+// FileEncryption configures the encryption of encoded files using AES-256-GCM.
+// To rotate keys, the new key is configured as the current key while the
+// provider continues to return the old key. Files are then still decrypted
+// with the old key, but encrypted with the new key when written.
+type FileEncryption struct {
+	// The identifier and 32-byte value of the key used to encrypt files.
+	KeyID string
+	Key   []byte
+
+	// The function that returns the key with the specified identifier to
+	// decrypt files that have been encrypted with another key. It may return
+	// a nil key if the key is unknown.
+	Provider func(keyID string) ([]byte, error)
+}
+
+func (e *FileEncryption) lookup(keyID string) ([]byte, error) {
+	// check current key
+	if keyID == e.KeyID && e.Key != nil {
+		return e.Key, nil
+	}
+
+	// check provider
+	if e.Provider == nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	// get key
+	key, err := e.Provider(keyID)
+	if err != nil {
+		return nil, err
+	} else if key == nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return key, nil
+}
+
+// FileCodec defines the compression of an encoded file.
+type FileCodec uint8
+
+// The available file codecs.
+const (
+	NoCompression FileCodec = iota
+	SnappyCompression
+	ZstdCompression
+)
+
+// FileVersion is the current version of the file format.
+const FileVersion uint16 = 2
+
+// the checksum table used for files and log records
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// the magic number that precedes encoded files
+var fileMagic = []byte("LNGO")
+
+// the size of the file header (magic, version, codec and flags)
+const fileHeaderSize = 8
+
+// the flag that marks encrypted files
+const fileEncrypted = 1
+
+// the size of the plaintext chunks of encrypted files
+const fileChunkSize = 64 << 10
+
+// the size of the random nonce prefix of encrypted files
+const fileNoncePrefixSize = 7
+
+// the maximum size of a single record
+const fileMaxRecordSize = 1 << 30
+
+// the record types
+const (
+	fileBackupRecord    = 'b'
+	fileNamespaceRecord = 'n'
+	fileDocumentRecord  = 'd'
+	fileEndRecord       = 'e'
+)
+
+// the readers of all supported file versions, readers of previous versions
+// are kept to migrate existing files which are written using the current
+// version when stored again
+var fileReaders = map[uint16]func(r *bufio.Reader, encryption *FileEncryption) (*File, error){}
+
+func init() {
+	// register readers
+	fileReaders[0] = readFileV0
+	fileReaders[2] = readFileV2
+}
+
+// EncodeFile will encode the provided file into a buffer. See WriteFile for
+// details.
+func EncodeFile(file *File, codec FileCodec, encryption *FileEncryption) ([]byte, error) {
+	// write file
+	var buf bytes.Buffer
+	err := WriteFile(&buf, file, codec, encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DecodeFile will decode the provided encoded file. See ReadFile for details.
+func DecodeFile(buf []byte, encryption *FileEncryption) (*File, error) {
+	return ReadFile(bytes.NewReader(buf), encryption)
+}
+
+// WriteFile will incrementally encode the provided file to the writer. The file
+// starts with a header that contains the magic number, the format version, the
+// codec and flags. It is followed by an optional backup record, a record per
+// namespace and document and an end record that holds the record count, the optional sequence and a CRC32C
+// checksum of the records and the sequence.
+// The records are compressed using the specified codec and encrypted in chunks
+// if an encryption is provided.
+func WriteFile(w io.Writer, file *File, codec FileCodec, encryption *FileEncryption) error {
+	// check codec
+	if codec > ZstdCompression {
+		return fmt.Errorf("unsupported file codec %d", codec)
+	}
+
+	// prepare header
+	header := make([]byte, fileHeaderSize)
+	copy(header, fileMagic)
+	binary.LittleEndian.PutUint16(header[4:], FileVersion)
+	header[6] = byte(codec)
+	if encryption != nil {
+		header[7] = fileEncrypted
+	}
+
+	// write header
+	_, err := w.Write(header)
+	if err != nil {
+		return err
+	}
+
+	// prepare encryption
+	var sealer *fileSealer
+	if encryption != nil {
+		sealer, err = newFileSealer(w, header, encryption)
+		if err != nil {
+			return err
+		}
+		w = sealer
+	}
+
+	// prepare compression
+	var compressor io.WriteCloser
+	switch codec {
+	case SnappyCompression:
+		compressor = snappy.NewBufferedWriter(w)
+	case ZstdCompression:
+		compressor, err = zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+	}
+	if compressor != nil {
+		w = compressor
+	}
+
+	// prepare record writer
+	buffer := bufio.NewWriter(w)
+	checksum := crc32.New(crc32c)
+	records := io.MultiWriter(buffer, checksum)
+
+	// get sorted namespaces
+	names := make([]string, 0, len(file.Namespaces))
+	for name := range file.Namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// write backup record
+	var count int64
+	if file.Backup != nil {
+		err = writeFileRecord(records, fileBackupRecord, file.Backup)
+		if err != nil {
+			return err
+		}
+		count++
+	}
+
+	// write namespaces
+	for _, name := range names {
+		// get namespace
+		ns := file.Namespaces[name]
+		documents := ns.Documents
+		ns.Documents = nil
+
+		// write namespace record
+		err = writeFileRecord(records, fileNamespaceRecord, bson.D{
+			bson.E{Key: "ns", Value: name},
+			bson.E{Key: "meta", Value: ns},
+		})
+		if err != nil {
+			return err
+		}
+		count++
+
+		// write document records
+		for _, doc := range documents {
+			err = writeFileRecord(records, fileDocumentRecord, doc)
+			if err != nil {
+				return err
+			}
+			count++
+		}
+	}
+
+	// prepare end record
+	end := bson.D{
+		bson.E{Key: "count", Value: count},
+	}
+	if file.Sequence != 0 {
+		end = append(end, bson.E{Key: "sequence", Value: file.Sequence})
+		_, _ = checksum.Write(fileSequence(file.Sequence))
+	}
+	end = append(end, bson.E{Key: "checksum", Value: int64(checksum.Sum32())})
+
+	// write end record
+	err = writeFileRecord(buffer, fileEndRecord, end)
+	if err != nil {
+		return err
+	}
+
+	// flush buffer
+	err = buffer.Flush()
+	if err != nil {
+		return err
+	}
+
+	// close compressor
+	if compressor != nil {
+		err = compressor.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	// close sealer
+	if sealer != nil {
+		err = sealer.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadFile will incrementally decode an encoded file from the reader. Files
+// without a header and files of previous format versions are migrated to the
+// current version. ErrFileTruncated or ErrFileCorrupted is returned if the file
+// is incomplete or damaged and ErrDecryptionFailed if an encrypted file cannot
+// be decrypted using the provided encryption.
+func ReadFile(r io.Reader, encryption *FileEncryption) (*File, error) {
+	// prepare reader
+	reader := bufio.NewReader(r)
+
+	// peek header
+	buf, err := reader.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, err
+	} else if len(buf) < 4 {
+		return nil, ErrFileTruncated
+	}
+
+	// get version, files without magic number have version zero
+	var version uint16
+	if bytes.Equal(buf[:4], fileMagic) {
+		if len(buf) < 6 {
+			return nil, ErrFileTruncated
+		}
+		version = binary.LittleEndian.Uint16(buf[4:])
+	}
+
+	// get reader
+	read, ok := fileReaders[version]
+	if !ok {
+		return nil, fmt.Errorf("unsupported file version %d", version)
+	}
+
+	return read(reader, encryption)
+}
+
+func readFileV0(r *bufio.Reader, _ *FileEncryption) (*File, error) {
+	// read file
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// check length
+	if len(buf) < 4 || int64(binary.LittleEndian.Uint32(buf)) > int64(len(buf)) {
+		return nil, ErrFileTruncated
+	}
+
+	return unmarshalFile(buf)
+}
+
+func readFileV2(r *bufio.Reader, encryption *FileEncryption) (*File, error) {
+	// read header
+	header := make([]byte, fileHeaderSize)
+	_, err := io.ReadFull(r, header)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrFileTruncated
+	} else if err != nil {
+		return nil, err
+	}
+
+	// get codec and flags
+	codec := FileCodec(header[6])
+	flags := header[7]
+
+	// track errors of the underlying reader
+	source := &fileSource{r: r}
+	var in io.Reader = source
+
+	// prepare decryption
+	if flags&fileEncrypted != 0 {
+		if encryption == nil {
+			return nil, ErrDecryptionFailed
+		}
+		in, err = newFileOpener(source, header, encryption)
+		if err != nil {
+			return nil, source.classify(err)
+		}
+	}
+
+	// prepare decompression
+	switch codec {
+	case NoCompression:
+	case SnappyCompression:
+		in = snappy.NewReader(in)
+	case ZstdCompression:
+		decoder, err := zstd.NewReader(in)
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		in = decoder
+	default:
+		return nil, fmt.Errorf("unsupported file codec %d", codec)
+	}
+
+	// prepare record reader
+	records := bufio.NewReader(in)
+	checksum := crc32.New(crc32c)
+
+	// prepare file
+	file := &File{
+		Namespaces: map[string]FileNamespace{},
+	}
+
+	// read records
+	var name string
+	var ns FileNamespace
+	var count int64
+	for {
+		// read record
+		typ, buf, err := readFileRecord(records, checksum)
+		if err != nil {
+			return nil, source.classify(err)
+		}
+
+		// handle record
+		switch typ {
+		case fileBackupRecord:
+			// check position
+			if count > 0 {
+				return nil, ErrFileCorrupted
+			}
+
+			// decode backup
+			var backup FileBackup
+			err = bson.Unmarshal(buf, &backup)
+			if err != nil {
+				return nil, ErrFileCorrupted
+			}
+
+			// set backup
+			file.Backup = &backup
+		case fileNamespaceRecord:
+			// decode namespace
+			var record struct {
+				NS   string        `bson:"ns"`
+				Meta FileNamespace `bson:"meta"`
+			}
+			err = bson.Unmarshal(buf, &record)
+			if err != nil || record.NS == "" {
+				return nil, ErrFileCorrupted
+			}
+
+			// add previous namespace
+			if name != "" {
+				file.Namespaces[name] = ns
+			}
+
+			// set namespace
+			name = record.NS
+			ns = record.Meta
+		case fileDocumentRecord:
+			// check namespace
+			if name == "" {
+				return nil, ErrFileCorrupted
+			}
+
+			// decode document
+			var doc bson.D
+			err = bson.Unmarshal(buf, &doc)
+			if err != nil {
+				return nil, ErrFileCorrupted
+			}
+
+			// add document
+			ns.Documents = append(ns.Documents, &doc)
+		case fileEndRecord:
+			// decode end
+			var end struct {
+				Count    int64 `bson:"count"`
+				Sequence int64 `bson:"sequence"`
+				Checksum int64 `bson:"checksum"`
+			}
+			err = bson.Unmarshal(buf, &end)
+			if err != nil {
+				return nil, ErrFileCorrupted
+			}
+
+			// add sequence
+			if end.Sequence != 0 {
+				_, _ = checksum.Write(fileSequence(end.Sequence))
+				file.Sequence = end.Sequence
+			}
+
+			// verify count and checksum
+			if end.Count != count || end.Checksum != int64(checksum.Sum32()) {
+				return nil, ErrFileCorrupted
+			}
+
+			// ensure end of records
+			n, err := records.Read(make([]byte, 1))
+			if n > 0 {
+				return nil, ErrFileCorrupted
+			} else if err != io.EOF {
+				return nil, source.classify(err)
+			}
+
+			// ensure end of file, the decoding layers may stop reading
+			// before the end of the underlying reader
+			n, err = r.Read(make([]byte, 1))
+			if n > 0 {
+				return nil, ErrFileCorrupted
+			} else if err != io.EOF {
+				return nil, err
+			}
+
+			// add last namespace
+			if name != "" {
+				file.Namespaces[name] = ns
+			}
+
+			return file, nil
+		default:
+			return nil, ErrFileCorrupted
+		}
+
+		// count record
+		count++
+	}
+}
+
+func fileSequence(sequence int64) []byte {
+	// encode sequence
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(sequence))
+
+	return buf
+}
+
+func unmarshalFile(buf []byte) (*File, error) {
+	// validate document
+	err := bsoncore.Document(buf).Validate()
+	if err != nil {
+		return nil, ErrFileCorrupted
+	}
+
+	// decode file
+	var file File
+	err = bson.Unmarshal(buf, &file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
+
+func writeFileRecord(w io.Writer, typ byte, doc interface{}) error {
+	// encode document
+	buf, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	// write type and document
+	_, err = w.Write(append([]byte{typ}, buf...))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func readFileRecord(r *bufio.Reader, checksum hash.Hash32) (byte, []byte, error) {
+	// read type
+	typ, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// read length
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// check length
+	length := int64(binary.LittleEndian.Uint32(buf))
+	if length < 5 || length > fileMaxRecordSize {
+		return 0, nil, ErrFileCorrupted
+	}
+
+	// read document
+	buf = append(buf, make([]byte, length-4)...)
+	_, err = io.ReadFull(r, buf[4:])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// update checksum, the end record holds the checksum itself
+	if typ != fileEndRecord {
+		_, _ = checksum.Write([]byte{typ})
+		_, _ = checksum.Write(buf)
+	}
+
+	return typ, buf, nil
+}
+
+// fileSource records the error returned by the underlying reader to classify
+// the errors returned by the decoding layers.
+type fileSource struct {
+	r   io.Reader
+	err error
+}
+
+func (s *fileSource) Read(p []byte) (int, error) {
+	// read data
+	n, err := s.r.Read(p)
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+
+	return n, err
+}
+
+func (s *fileSource) classify(err error) error {
+	// keep sentinel errors
+	switch err {
+	case ErrFileTruncated, ErrFileCorrupted, ErrDecryptionFailed:
+		return err
+	}
+
+	// check underlying error
+	switch s.err {
+	case nil:
+		return ErrFileCorrupted
+	case io.EOF, io.ErrUnexpectedEOF:
+		return ErrFileTruncated
+	default:
+		return s.err
+	}
+}
+
+// fileSealer encrypts the written data in chunks. Every chunk is prefixed with
+// a final flag, its length and a CRC32C checksum. The nonce of a chunk is
+// derived from a random prefix, the chunk counter and the final flag.
+type fileSealer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	data    []byte
+	buf     []byte
+	counter uint32
+}
+
+func newFileSealer(w io.Writer, header []byte, encryption *FileEncryption) (*fileSealer, error) {
+	// check key id
+	if len(encryption.KeyID) > 255 {
+		return nil, fmt.Errorf("encryption key id too long")
+	}
+
+	// create cipher
+	aead, err := fileCipher(encryption.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	// generate nonce prefix
+	prefix := make([]byte, fileNoncePrefixSize)
+	_, err = rand.Read(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// write key id and nonce prefix
+	buf := append([]byte{byte(len(encryption.KeyID))}, encryption.KeyID...)
+	_, err = w.Write(append(buf, prefix...))
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileSealer{
+		w:      w,
+		aead:   aead,
+		prefix: prefix,
+		data:   append(append([]byte{}, header...), encryption.KeyID...),
+	}, nil
+}
+
+func (s *fileSealer) Write(p []byte) (int, error) {
+	// buffer data
+	s.buf = append(s.buf, p...)
+
+	// seal full chunks, the last chunk is sealed when closed
+	for len(s.buf) > fileChunkSize {
+		err := s.seal(s.buf[:fileChunkSize], false)
+		if err != nil {
+			return 0, err
+		}
+		s.buf = s.buf[fileChunkSize:]
+	}
+
+	return len(p), nil
+}
+
+func (s *fileSealer) Close() error {
+	// seal final chunk
+	err := s.seal(s.buf, true)
+	s.buf = nil
+
+	return err
+}
+
+func (s *fileSealer) seal(chunk []byte, final bool) error {
+	// seal chunk
+	sealed := s.aead.Seal(nil, fileNonce(s.prefix, s.counter, final), chunk, s.data)
+	s.counter++
+
+	// prepare frame
+	frame := make([]byte, 9, 9+len(sealed))
+	if final {
+		frame[0] = 1
+	}
+	binary.LittleEndian.PutUint32(frame[1:], uint32(len(sealed)))
+	binary.LittleEndian.PutUint32(frame[5:], crc32.Checksum(sealed, crc32c))
+
+	// write frame
+	_, err := s.w.Write(append(frame, sealed...))
+
+	return err
+}
+
+// fileOpener decrypts the chunks written by a fileSealer.
+type fileOpener struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	data    []byte
+	buf     []byte
+	counter uint32
+	done    bool
+}
+
+func newFileOpener(r io.Reader, header []byte, encryption *FileEncryption) (*fileOpener, error) {
+	// read key id length
+	buf := make([]byte, 1)
+	_, err := io.ReadFull(r, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// read key id and nonce prefix
+	buf = make([]byte, int(buf[0])+fileNoncePrefixSize)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return nil, err
+	}
+	keyID := string(buf[:len(buf)-fileNoncePrefixSize])
+
+	// get key
+	key, err := encryption.lookup(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// create cipher
+	aead, err := fileCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileOpener{
+		r:      r,
+		aead:   aead,
+		prefix: buf[len(keyID):],
+		data:   append(append([]byte{}, header...), keyID...),
+	}, nil
+}
+
+func (o *fileOpener) Read(p []byte) (int, error) {
+	// open chunks until data is available
+	for len(o.buf) == 0 {
+		if o.done {
+			return 0, io.EOF
+		}
+		err := o.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// copy data
+	n := copy(p, o.buf)
+	o.buf = o.buf[n:]
+
+	return n, nil
+}
+
+func (o *fileOpener) open() error {
+	// read frame, a missing final chunk means the file is truncated
+	frame := make([]byte, 9)
+	_, err := io.ReadFull(o.r, frame)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrFileTruncated
+	} else if err != nil {
+		return err
+	}
+
+	// check frame
+	final := frame[0] == 1
+	length := binary.LittleEndian.Uint32(frame[1:])
+	if frame[0] > 1 || length > uint32(fileChunkSize+o.aead.Overhead()) {
+		return ErrFileCorrupted
+	}
+
+	// read sealed chunk
+	sealed := make([]byte, length)
+	_, err = io.ReadFull(o.r, sealed)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrFileTruncated
+	} else if err != nil {
+		return err
+	}
+
+	// verify checksum
+	if crc32.Checksum(sealed, crc32c) != binary.LittleEndian.Uint32(frame[5:]) {
+		return ErrFileCorrupted
+	}
+
+	// open chunk
+	chunk, err := o.aead.Open(nil, fileNonce(o.prefix, o.counter, final), sealed, o.data)
+	if err != nil {
+		return ErrDecryptionFailed
+	}
+	o.counter++
+
+	// set state
+	o.buf = chunk
+	o.done = final
+
+	return nil
+}
+
+func fileNonce(prefix []byte, counter uint32, final bool) []byte {
+	// prepare nonce
+	nonce := make([]byte, fileNoncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[fileNoncePrefixSize:], counter)
+	if final {
+		nonce[fileNoncePrefixSize+4] = 1
+	}
+
+	return nonce
+}
+
+func fileCipher(key []byte) (cipher.AEAD, error) {
+	// check key
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes")
+	}
+
+	// create block
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,318 @@
+package lungo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+func TestEncodeDecodeFile(t *testing.T) {
+	file := &File{
+		Namespaces: map[string]FileNamespace{
+			"foo.bar": {
+				Documents: bsonkit.List{
+					bsonkit.MustConvert(bson.M{"_id": "a", "foo": "bar"}),
+					bsonkit.MustConvert(bson.M{"_id": "b", "foo": "bar"}),
+				},
+				Indexes: map[string]FileIndex{
+					"_id_": {
+						Key:    bsonkit.MustConvert(bson.M{"_id": int32(1)}),
+						Unique: true,
+					},
+				},
+			},
+		},
+	}
+
+	for _, codec := range []FileCodec{NoCompression, SnappyCompression, ZstdCompression} {
+		buf, err := EncodeFile(file, codec, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "LNGO", string(buf[:4]))
+		assert.Equal(t, FileVersion, binary.LittleEndian.Uint16(buf[4:]))
+		assert.Equal(t, byte(codec), buf[6])
+
+		out, err := DecodeFile(buf, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, file, out)
+
+		/* truncated */
+
+		_, err = DecodeFile(buf[:len(buf)-1], nil)
+		assert.Equal(t, ErrFileTruncated, err)
+
+		_, err = DecodeFile(buf[:10], nil)
+		assert.Equal(t, ErrFileTruncated, err)
+
+		/* corrupted */
+
+		corrupt := append([]byte{}, buf...)
+		corrupt[len(corrupt)-5] ^= 0xFF
+		_, err = DecodeFile(corrupt, nil)
+		assert.Equal(t, ErrFileCorrupted, err)
+
+		_, err = DecodeFile(append(buf, 0), nil)
+		if codec == NoCompression {
+			assert.Equal(t, ErrFileCorrupted, err)
+		} else {
+			assert.Contains(t, []error{ErrFileCorrupted, ErrFileTruncated}, err)
+		}
+
+		/* trailing */
+
+		encryption := &FileEncryption{KeyID: "k1", Key: bytes.Repeat([]byte{1}, 32)}
+		buf, err = EncodeFile(file, codec, encryption)
+		assert.NoError(t, err)
+
+		_, err = DecodeFile(append(buf, 0), encryption)
+		assert.Equal(t, ErrFileCorrupted, err)
+	}
+
+	/* legacy */
+
+	legacy, err := bson.Marshal(file)
+	assert.NoError(t, err)
+
+	out, err := DecodeFile(legacy, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, file, out)
+
+	_, err = DecodeFile(legacy[:len(legacy)-10], nil)
+	assert.Equal(t, ErrFileTruncated, err)
+
+	corrupt := append([]byte{}, legacy...)
+	corrupt[len(corrupt)-1] = 42
+	_, err = DecodeFile(corrupt, nil)
+	assert.Equal(t, ErrFileCorrupted, err)
+
+	/* version 1 */
+
+	v1 := make([]byte, 20)
+	copy(v1, "LNGO")
+	binary.LittleEndian.PutUint16(v1[4:], 1)
+	v1 = append(v1, legacy...)
+
+	_, err = DecodeFile(v1, nil)
+	assert.Error(t, err)
+	assert.Equal(t, "unsupported file version 1", err.Error())
+
+	/* unsupported */
+
+	buf, err := EncodeFile(file, NoCompression, nil)
+	assert.NoError(t, err)
+	binary.LittleEndian.PutUint16(buf[4:], FileVersion+1)
+	_, err = DecodeFile(buf, nil)
+	assert.Error(t, err)
+	assert.Equal(t, "unsupported file version 3", err.Error())
+}
+
+func TestFileStoreFormat(t *testing.T) {
+	_ = os.Remove("./test.bson")
+	defer os.Remove("./test.bson")
+	defer os.Remove("./test.bson.lock")
+
+	/* legacy migration */
+
+	legacy, err := bson.Marshal(BuildFile(NewCatalog()))
+	assert.NoError(t, err)
+	err = ioutil.WriteFile("./test.bson", legacy, 0666)
+	assert.NoError(t, err)
+
+	store := NewFileStoreWithOptions("./test.bson", 0666, FileOptions{
+		Codec: ZstdCompression,
+	})
+
+	engine, err := CreateEngine(Options{Store: store})
+	assert.NoError(t, err)
+
+	txn, err := engine.Begin(nil, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "a"}),
+	}, true)
+	assert.NoError(t, err)
+	assert.NoError(t, engine.Commit(txn))
+	engine.Close()
+
+	buf, err := ioutil.ReadFile("./test.bson")
+	assert.NoError(t, err)
+	assert.Equal(t, "LNGO", string(buf[:4]))
+	assert.Equal(t, byte(ZstdCompression), buf[6])
+
+	catalog, err := loadStore(NewFileStore("./test.bson", 0666))
+	assert.NoError(t, err)
+	assert.Equal(t, BuildFile(engine.Catalog()), BuildFile(catalog))
+
+	/* truncated */
+
+	err = ioutil.WriteFile("./test.bson", buf[:len(buf)/2], 0666)
+	assert.NoError(t, err)
+
+	_, err = loadStore(NewFileStore("./test.bson", 0666))
+	assert.Equal(t, ErrFileTruncated, err)
+}
+
+func TestEncryptFile(t *testing.T) {
+	file := &File{
+		Namespaces: map[string]FileNamespace{
+			"foo.bar": {
+				Documents: bsonkit.List{
+					bsonkit.MustConvert(bson.M{"_id": "a", "secret": "foo"}),
+				},
+				Indexes: map[string]FileIndex{},
+			},
+		},
+	}
+
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := bytes.Repeat([]byte{2}, 32)
+
+	encryption := &FileEncryption{KeyID: "k1", Key: key1}
+
+	_, err := EncodeFile(file, NoCompression, &FileEncryption{Key: key1[:16]})
+	assert.Error(t, err)
+
+	for _, codec := range []FileCodec{NoCompression, SnappyCompression, ZstdCompression} {
+		buf, err := EncodeFile(file, codec, encryption)
+		assert.NoError(t, err)
+		assert.Equal(t, byte(1), buf[7])
+		assert.False(t, bytes.Contains(buf, []byte("secret")))
+
+		out, err := DecodeFile(buf, encryption)
+		assert.NoError(t, err)
+		assert.Equal(t, file, out)
+
+		/* missing or wrong key */
+
+		_, err = DecodeFile(buf, nil)
+		assert.Equal(t, ErrDecryptionFailed, err)
+
+		_, err = DecodeFile(buf, &FileEncryption{KeyID: "k1", Key: key2})
+		assert.Equal(t, ErrDecryptionFailed, err)
+
+		_, err = DecodeFile(buf, &FileEncryption{KeyID: "k2", Key: key2})
+		assert.Equal(t, ErrDecryptionFailed, err)
+
+		/* corrupted */
+
+		corrupt := append([]byte{}, buf...)
+		corrupt[len(corrupt)-1] ^= 0xFF
+		_, err = DecodeFile(corrupt, encryption)
+		assert.Equal(t, ErrFileCorrupted, err)
+
+		/* tampered */
+
+		chunk := fileHeaderSize + 1 + 2 + fileNoncePrefixSize
+		binary.LittleEndian.PutUint32(corrupt[chunk+5:], crc32.Checksum(corrupt[chunk+9:], crc32c))
+		_, err = DecodeFile(corrupt, encryption)
+		assert.Equal(t, ErrDecryptionFailed, err)
+	}
+}
+
+func TestFileStoreEncryption(t *testing.T) {
+	_ = os.Remove("./test.bson")
+	defer os.Remove("./test.bson")
+	defer os.Remove("./test.bson.lock")
+
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := bytes.Repeat([]byte{2}, 32)
+
+	commit := func(store Store, id string) {
+		engine, err := CreateEngine(Options{Store: store})
+		assert.NoError(t, err)
+		defer engine.Close()
+
+		txn, err := engine.Begin(nil, true)
+		assert.NoError(t, err)
+		_, err = txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": id}),
+		}, true)
+		assert.NoError(t, err)
+		assert.NoError(t, engine.Commit(txn))
+	}
+
+	/* plain to encrypted */
+
+	commit(NewFileStore("./test.bson", 0666), "a")
+
+	commit(NewFileStoreWithOptions("./test.bson", 0666, FileOptions{
+		Encryption: &FileEncryption{KeyID: "k1", Key: key1},
+	}), "b")
+
+	_, err := loadStore(NewFileStore("./test.bson", 0666))
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	/* rotation */
+
+	commit(NewFileStoreWithOptions("./test.bson", 0666, FileOptions{
+		Encryption: &FileEncryption{
+			KeyID: "k2",
+			Key:   key2,
+			Provider: func(keyID string) ([]byte, error) {
+				assert.Equal(t, "k1", keyID)
+				return key1, nil
+			},
+		},
+	}), "c")
+
+	_, err = loadStore(NewFileStoreWithOptions("./test.bson", 0666, FileOptions{
+		Encryption: &FileEncryption{KeyID: "k1", Key: key1},
+	}))
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	catalog, err := loadStore(NewFileStoreWithOptions("./test.bson", 0666, FileOptions{
+		Encryption: &FileEncryption{KeyID: "k2", Key: key2},
+	}))
+	assert.NoError(t, err)
+	assert.Len(t, catalog.Namespaces[Handle{"foo", "bar"}].Documents.List, 3)
+}
+
+func TestStreamFile(t *testing.T) {
+	list := make(bsonkit.List, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		list = append(list, bsonkit.MustConvert(bson.M{
+			"_id": int64(i),
+			"foo": strings.Repeat("x", i%100),
+		}))
+	}
+
+	file := &File{
+		Namespaces: map[string]FileNamespace{
+			"foo.bar": {
+				Documents: list,
+				Indexes:   map[string]FileIndex{},
+			},
+			"foo.baz": {
+				Documents: bsonkit.List{},
+				Indexes:   map[string]FileIndex{},
+			},
+		},
+	}
+
+	encryption := &FileEncryption{KeyID: "k1", Key: bytes.Repeat([]byte{1}, 32)}
+
+	for _, codec := range []FileCodec{NoCompression, SnappyCompression, ZstdCompression} {
+		for _, enc := range []*FileEncryption{nil, encryption} {
+			var buf bytes.Buffer
+			err := WriteFile(&buf, file, codec, enc)
+			assert.NoError(t, err)
+
+			out, err := ReadFile(bytes.NewReader(buf.Bytes()), enc)
+			assert.NoError(t, err)
+			assert.Len(t, out.Namespaces, 2)
+			assert.Len(t, out.Namespaces["foo.baz"].Documents, 0)
+			assert.Equal(t, list, out.Namespaces["foo.bar"].Documents)
+
+			_, err = ReadFile(bytes.NewReader(buf.Bytes()[:buf.Len()/2]), enc)
+			assert.Equal(t, ErrFileTruncated, err)
+		}
+	}
+}
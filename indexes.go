@@ -62,11 +62,15 @@ func (v *IndexView) CreateOne(ctx context.Context, index mongo.IndexModel, opts
 	if index.Options != nil {
 		assertOptions(index.Options, map[string]string{
 			"Background":              ignored,
+			"Collation":               supported,
 			"ExpireAfterSeconds":      supported,
+			"Hidden":                  supported,
 			"Name":                    supported,
+			"Sparse":                  supported,
 			"Unique":                  supported,
 			"Version":                 ignored,
 			"PartialFilterExpression": supported,
+			"WildcardProjection":      supported,
 		})
 	}
 
@@ -98,6 +102,18 @@ func (v *IndexView) CreateOne(ctx context.Context, index mongo.IndexModel, opts
 		unique = *index.Options.Unique
 	}
 
+	// get sparse
+	var sparse bool
+	if index.Options != nil && index.Options.Sparse != nil {
+		sparse = *index.Options.Sparse
+	}
+
+	// get hidden
+	var hidden bool
+	if index.Options != nil && index.Options.Hidden != nil {
+		hidden = *index.Options.Hidden
+	}
+
 	// get partial
 	var partial bsonkit.Doc
 	if index.Options != nil && index.Options.PartialFilterExpression != nil {
@@ -107,6 +123,24 @@ func (v *IndexView) CreateOne(ctx context.Context, index mongo.IndexModel, opts
 		}
 	}
 
+	// get wildcard projection
+	var wildcardProjection bsonkit.Doc
+	if index.Options != nil && index.Options.WildcardProjection != nil {
+		wildcardProjection, err = bsonkit.Transform(index.Options.WildcardProjection)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// get collation
+	var collation bsonkit.Doc
+	if index.Options != nil {
+		collation, err = transformCollation(index.Options.Collation)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	// begin transaction
 	txn, err := v.engine.Begin(ctx, true)
 	if err != nil {
@@ -118,10 +152,14 @@ func (v *IndexView) CreateOne(ctx context.Context, index mongo.IndexModel, opts
 
 	// create index
 	name, err = txn.CreateIndex(v.handle, name, mongokit.IndexConfig{
-		Key:     key,
-		Unique:  unique,
-		Partial: partial,
-		Expiry:  expiry,
+		Key:                key,
+		Unique:             unique,
+		Sparse:             sparse,
+		Partial:            partial,
+		Expiry:             expiry,
+		Collation:          collation,
+		WildcardProjection: wildcardProjection,
+		Hidden:             hidden,
 	})
 	if err != nil {
 		return "", err
@@ -473,3 +473,308 @@ func TestIndexExpiry(t *testing.T) {
 		}, readAll(csr))
 	})
 }
+
+func TestIndexCollation(t *testing.T) {
+	collectionTest(t, func(t *testing.T, c ICollection) {
+		// case-insensitive unique index
+		name, err := c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{
+				"name": 1,
+			},
+			Options: options.Index().SetUnique(true).SetCollation(&options.Collation{
+				Locale:   "en",
+				Strength: 2,
+			}),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "name_1", name)
+
+		_, err = c.InsertOne(nil, bson.M{
+			"name": "Joe",
+		})
+		assert.NoError(t, err)
+
+		_, err = c.InsertOne(nil, bson.M{
+			"name": "joe",
+		})
+		assert.Error(t, err)
+		assert.True(t, IsUniquenessError(err))
+
+		_, err = c.InsertOne(nil, bson.M{
+			"name": "Jane",
+		})
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndexWildcard(t *testing.T) {
+	collectionTest(t, func(t *testing.T, c ICollection) {
+		name, err := c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{
+				"$**": 1,
+			},
+			Options: options.Index().SetName("all").SetWildcardProjection(bson.M{
+				"secret": 0,
+			}),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "all", name)
+
+		name, err = c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{
+				"attrs.$**": 1,
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "attrs.$**_1", name)
+
+		// list
+		csr, err := c.Indexes().List(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{
+				"key": bson.M{
+					"_id": int32(1),
+				},
+				"name": "_id_",
+				"v":    int32(2),
+			},
+			{
+				"key": bson.M{
+					"$**": int32(1),
+				},
+				"name": "all",
+				"v":    int32(2),
+				"wildcardProjection": bson.M{
+					"secret": int32(0),
+				},
+			},
+			{
+				"key": bson.M{
+					"attrs.$**": int32(1),
+				},
+				"name": "attrs.$**_1",
+				"v":    int32(2),
+			},
+		}, readAll(csr))
+
+		_, err = c.InsertMany(nil, []interface{}{
+			bson.M{"_id": int32(1), "attrs": bson.M{"color": "red", "size": int32(3)}},
+			bson.M{"_id": int32(2), "attrs": bson.A{bson.M{"color": "blue"}, bson.M{"color": "red"}}},
+			bson.M{"_id": int32(3), "attrs": bson.M{"color": "green"}, "secret": "red"},
+		})
+		assert.NoError(t, err)
+
+		// equality
+		csr, err = c.Find(nil, bson.M{"attrs.color": "red"})
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": int32(1), "attrs": bson.M{"color": "red", "size": int32(3)}},
+			{"_id": int32(2), "attrs": bson.A{bson.M{"color": "blue"}, bson.M{"color": "red"}}},
+		}, readAll(csr))
+
+		// in
+		csr, err = c.Find(nil, bson.M{"attrs.color": bson.M{"$in": bson.A{"green", "blue"}}}, options.Find().SetSkip(1))
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": int32(3), "attrs": bson.M{"color": "green"}, "secret": "red"},
+		}, readAll(csr))
+
+		// excluded field
+		csr, err = c.Find(nil, bson.M{"secret": "red"})
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": int32(3), "attrs": bson.M{"color": "green"}, "secret": "red"},
+		}, readAll(csr))
+
+		// update
+		res, err := c.UpdateMany(nil, bson.M{"attrs.size": int32(3)}, bson.M{"$set": bson.M{"attrs.size": int32(4)}})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), res.ModifiedCount)
+
+		num, err := c.CountDocuments(nil, bson.M{"attrs.size": int32(4)})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), num)
+
+		// delete
+		res2, err := c.DeleteMany(nil, bson.M{"attrs.color": "red"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), res2.DeletedCount)
+
+		num, err = c.CountDocuments(nil, bson.M{"attrs.color": "red"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), num)
+
+		// invalid
+		_, err = c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{
+				"$**": 1,
+			},
+			Options: options.Index().SetName("foo").SetUnique(true),
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestIndexHashedAndHidden(t *testing.T) {
+	collectionTest(t, func(t *testing.T, c ICollection) {
+		name, err := c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{
+				"a": "hashed",
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "a_hashed", name)
+
+		name, err = c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{
+				"b": 1,
+			},
+			Options: options.Index().SetUnique(true).SetHidden(true),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "b_1", name)
+
+		// list
+		csr, err := c.Indexes().List(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{
+				"key": bson.M{
+					"_id": int32(1),
+				},
+				"name": "_id_",
+				"v":    int32(2),
+			},
+			{
+				"key": bson.M{
+					"a": "hashed",
+				},
+				"name": "a_hashed",
+				"v":    int32(2),
+			},
+			{
+				"hidden": true,
+				"key": bson.M{
+					"b": int32(1),
+				},
+				"name":   "b_1",
+				"unique": true,
+				"v":      int32(2),
+			},
+		}, readAll(csr))
+
+		_, err = c.InsertMany(nil, []interface{}{
+			bson.M{"_id": int32(1), "a": "foo", "b": int32(1)},
+			bson.M{"_id": int32(2), "a": int32(7), "b": int32(2)},
+		})
+		assert.NoError(t, err)
+
+		// array value
+		_, err = c.InsertOne(nil, bson.M{"_id": int32(3), "a": bson.A{"bar"}})
+		assert.Error(t, err)
+
+		// hidden unique
+		_, err = c.InsertOne(nil, bson.M{"_id": int32(4), "b": int32(1)})
+		assert.Error(t, err)
+
+		// find
+		csr, err = c.Find(nil, bson.M{"a": int32(7), "b": int32(2)})
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{"_id": int32(2), "a": int32(7), "b": int32(2)},
+		}, readAll(csr))
+
+		// unique hashed
+		_, err = c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{
+				"c": "hashed",
+			},
+			Options: options.Index().SetUnique(true),
+		})
+		assert.Error(t, err)
+
+		// hidden id
+		_, err = c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{
+				"_id": 1,
+			},
+			Options: options.Index().SetHidden(true),
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestIndexSparse(t *testing.T) {
+	collectionTest(t, func(t *testing.T, c ICollection) {
+		name, err := c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{
+				"a": 1,
+			},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "a_1", name)
+
+		// list
+		csr, err := c.Indexes().List(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []bson.M{
+			{
+				"key": bson.M{
+					"_id": int32(1),
+				},
+				"name": "_id_",
+				"v":    int32(2),
+			},
+			{
+				"key": bson.M{
+					"a": int32(1),
+				},
+				"name":   "a_1",
+				"sparse": true,
+				"unique": true,
+				"v":      int32(2),
+			},
+		}, readAll(csr))
+
+		// missing fields
+		_, err = c.InsertMany(nil, []interface{}{
+			bson.M{"_id": int32(1), "b": int32(1)},
+			bson.M{"_id": int32(2), "b": int32(2)},
+			bson.M{"_id": int32(3), "a": int32(1)},
+		})
+		assert.NoError(t, err)
+
+		// duplicate value
+		_, err = c.InsertOne(nil, bson.M{"_id": int32(4), "a": int32(1)})
+		assert.Error(t, err)
+
+		// null is indexed
+		_, err = c.InsertOne(nil, bson.M{"_id": int32(5), "a": nil})
+		assert.NoError(t, err)
+		_, err = c.InsertOne(nil, bson.M{"_id": int32(6), "a": nil})
+		assert.Error(t, err)
+
+		// unsupported partial expressions
+		_, err = c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{
+				"b": 1,
+			},
+			Options: options.Index().SetPartialFilterExpression(bson.M{
+				"b": bson.M{"$exists": false},
+			}),
+		})
+		assert.Error(t, err)
+
+		_, err = c.Indexes().CreateOne(nil, mongo.IndexModel{
+			Keys: bson.M{
+				"b": 1,
+			},
+			Options: options.Index().SetPartialFilterExpression(bson.M{
+				"b": bson.M{"$ne": int32(1)},
+			}),
+		})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,754 @@
+package lungo
+
+import (
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+)
+
+// the stages that may precede the final group stage of a materialized view
+var materializedStages = map[string]bool{
+	"$match":       true,
+	"$project":     true,
+	"$addFields":   true,
+	"$set":         true,
+	"$unset":       true,
+	"$unwind":      true,
+	"$replaceRoot": true,
+	"$replaceWith": true,
+}
+
+// CreateMaterializedView will create a materialized view in the specified
+// namespace that stores the result of applying the pipeline to the documents
+// of the source collection. The pipeline must consist of document stages
+// followed by a final $group stage. The view is kept up to date by Refresh.
+// A collation may be supplied to set the view default collation.
+func (t *Transaction) CreateMaterializedView(handle Handle, viewOn string, pipeline bsonkit.List, collation bsonkit.Doc) error {
+	// acquire write lock
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// validate handle
+	err := handle.Validate(true)
+	if err != nil {
+		return err
+	}
+
+	// check access
+	if handle[0] == Local {
+		return fmt.Errorf("namespace local.* is read only")
+	}
+
+	// check source
+	if viewOn == "" {
+		return fmt.Errorf("missing view source")
+	}
+
+	// check catalog
+	if t.catalog.Namespaces[handle] != nil {
+		return fmt.Errorf("namespace %q already exists", handle.String())
+	}
+
+	// check source namespace
+	source := t.catalog.Namespaces[Handle{handle[0], viewOn}]
+	if source != nil && (source.IsView() || source.IsMaterialized() || source.IsTimeSeries()) {
+		return fmt.Errorf("materialized views must be defined on a collection")
+	}
+
+	// check pipeline
+	_, _, err = splitMaterialized(pipeline)
+	if err != nil {
+		return err
+	}
+	_, err = mongokit.Aggregate(nil, pipeline, mongokit.PipelineContext{})
+	if err != nil {
+		return err
+	}
+
+	// create view
+	namespace, err := mongokit.CreateCollection(mongokit.CollectionConfig{
+		Collation:    collation,
+		ViewOn:       viewOn,
+		Pipeline:     pipeline,
+		Materialized: true,
+	}, true)
+	if err != nil {
+		return err
+	}
+
+	// compute result
+	state, err := materialize(namespace, source)
+	if err != nil {
+		return err
+	}
+
+	// add view
+	t.catalog = t.catalog.Clone()
+	t.catalog.Namespaces[handle] = namespace
+	t.catalog.setState(namespace, state)
+	t.dirty = true
+
+	return nil
+}
+
+// Refresh will update the materialized views whose source collections have
+// been changed by the transaction. The changed documents are determined from
+// the oplog events appended by the transaction and the accumulators of the
+// affected groups are updated from them.
+func (t *Transaction) Refresh() error {
+	// acquire write lock
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// check catalogs
+	if t.base == nil || t.base == t.catalog {
+		return nil
+	}
+
+	// get oplogs
+	oplog := t.catalog.Namespaces[Oplog]
+	baseOplog := t.base.Namespaces[Oplog]
+	if oplog == nil || baseOplog == nil {
+		return nil
+	}
+
+	// collect changes from new events
+	type change struct {
+		ids     bson.A
+		dropped bool
+	}
+	changes := map[Handle]*change{}
+	getChange := func(handle Handle) *change {
+		chg := changes[handle]
+		if chg == nil {
+			chg = &change{}
+			changes[handle] = chg
+		}
+		return chg
+	}
+	for i := len(oplog.Documents.List) - 1; i >= 0; i-- {
+		// get event
+		event := oplog.Documents.List[i]
+
+		// stop at existing events, new events are always appended
+		if _, ok := baseOplog.Documents.Index[event]; ok {
+			break
+		}
+
+		// get namespace
+		db, _ := bsonkit.Get(event, "ns.db").(string)
+		coll, _ := bsonkit.Get(event, "ns.coll").(string)
+		if coll == "" {
+			continue
+		}
+
+		// record change, renames recompute both the source and the target
+		switch bsonkit.Get(event, "operationType") {
+		case "drop":
+			getChange(Handle{db, coll}).dropped = true
+		case "rename":
+			getChange(Handle{db, coll}).dropped = true
+			toDB, _ := bsonkit.Get(event, "to.db").(string)
+			toColl, _ := bsonkit.Get(event, "to.coll").(string)
+			getChange(Handle{toDB, toColl}).dropped = true
+		default:
+			chg := getChange(Handle{db, coll})
+			chg.ids = append(chg.ids, bsonkit.Get(event, "documentKey._id"))
+		}
+	}
+
+	// check changes
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// prepare clone
+	var clone *Catalog
+
+	// update affected views
+	for handle, namespace := range t.catalog.Namespaces {
+		// check view
+		if !namespace.IsMaterialized() {
+			continue
+		}
+
+		// get change
+		source := Handle{handle[0], namespace.Config().ViewOn}
+		chg := changes[source]
+		if chg == nil {
+			continue
+		}
+
+		// clone catalog
+		if clone == nil {
+			clone = t.catalog.Clone()
+		}
+
+		// clone view
+		view := namespace.Clone()
+		clone.Namespaces[handle] = view
+
+		// get state, which is only valid if the view is unchanged since the
+		// transaction began
+		state := t.base.states[namespace]
+		delete(clone.states, namespace)
+
+		// recompute view if the source has been dropped or the state is
+		// missing, otherwise update the affected groups only
+		var err error
+		if chg.dropped || state == nil {
+			state, err = materialize(view, t.catalog.Namespaces[source])
+		} else {
+			state, err = maintain(view, t.base.Namespaces[source], t.catalog.Namespaces[source], chg.ids, state)
+		}
+		if err != nil {
+			return err
+		}
+
+		// set state
+		clone.setState(view, state)
+	}
+
+	// set catalog and flag
+	if clone != nil {
+		clone.pruneStates()
+		t.catalog = clone
+		t.dirty = true
+	}
+
+	return nil
+}
+
+// materializedGroup is the accumulator state of a materialized view group.
+// It allows groups to be updated from the changed documents only.
+type materializedGroup struct {
+	count  int
+	sums   []interface{}
+	counts []int
+	values []interface{}
+}
+
+func newMaterializedGroup(fields bson.D) *materializedGroup {
+	// prepare group
+	group := &materializedGroup{
+		sums:   make([]interface{}, len(fields)),
+		counts: make([]int, len(fields)),
+		values: make([]interface{}, len(fields)),
+	}
+
+	// initialize sums
+	for i := range fields {
+		group.sums[i] = int32(0)
+	}
+
+	return group
+}
+
+func (g *materializedGroup) clone() *materializedGroup {
+	return &materializedGroup{
+		count:  g.count,
+		sums:   append([]interface{}{}, g.sums...),
+		counts: append([]int{}, g.counts...),
+		values: append([]interface{}{}, g.values...),
+	}
+}
+
+// fold will add or remove the specified document from the group. It returns
+// false if the group must be recomputed from the source.
+func (g *materializedGroup) fold(doc bsonkit.Doc, fields bson.D, sign int) (bool, error) {
+	// update count
+	g.count += sign
+
+	// update accumulators
+	ok := true
+	for i, field := range fields {
+		// get accumulator
+		acc := field.Value.(bson.D)[0]
+
+		// order dependent accumulators cannot be updated
+		if acc.Key != "$sum" && acc.Key != "$avg" && acc.Key != "$min" && acc.Key != "$max" {
+			ok = false
+			continue
+		}
+
+		// evaluate value
+		value, err := mongokit.Evaluate(doc, acc.Value)
+		if err != nil {
+			return false, err
+		}
+
+		// get class
+		class, _ := bsonkit.Inspect(value)
+
+		// update sums and counts
+		if acc.Key == "$sum" || acc.Key == "$avg" {
+			if class == bsonkit.Number {
+				if sign < 0 {
+					value = bsonkit.Mul(value, int32(-1))
+				}
+				g.sums[i] = bsonkit.Add(g.sums[i], value)
+				g.counts[i] += sign
+			}
+			continue
+		}
+
+		// ignore nullish values
+		if class == bsonkit.Null {
+			continue
+		}
+
+		// a removed extreme value requires a recompute
+		if sign < 0 {
+			if g.values[i] != nil && bsonkit.Compare(value, g.values[i]) == 0 {
+				ok = false
+			}
+			continue
+		}
+
+		// update extreme value
+		if g.values[i] == nil {
+			g.values[i] = value
+		} else if cmp := bsonkit.Compare(value, g.values[i]); acc.Key == "$min" && cmp < 0 || acc.Key == "$max" && cmp > 0 {
+			g.values[i] = value
+		}
+	}
+
+	return ok, nil
+}
+
+func (g *materializedGroup) render(key interface{}, fields bson.D) bsonkit.Doc {
+	// prepare document
+	doc := bson.D{bson.E{Key: "_id", Value: key}}
+
+	// add accumulators
+	for i, field := range fields {
+		var value interface{}
+		switch field.Value.(bson.D)[0].Key {
+		case "$sum":
+			value = g.sums[i]
+		case "$avg":
+			if g.counts[i] > 0 {
+				value = mongokit.ToFloat64(g.sums[i]) / float64(g.counts[i])
+			}
+		default:
+			value = g.values[i]
+		}
+		doc = append(doc, bson.E{Key: field.Key, Value: value})
+	}
+
+	return &doc
+}
+
+// materializedState maps the documents of a materialized view to the state of
+// their groups.
+type materializedState map[bsonkit.Doc]*materializedGroup
+
+func (s materializedState) clone() materializedState {
+	// copy groups
+	clone := make(materializedState, len(s))
+	for doc, group := range s {
+		clone[doc] = group
+	}
+
+	return clone
+}
+
+func (d *Catalog) setState(view *mongokit.Collection, state materializedState) {
+	// ensure map
+	if d.states == nil {
+		d.states = map[*mongokit.Collection]materializedState{}
+	}
+
+	// set state
+	d.states[view] = state
+}
+
+func (d *Catalog) pruneStates() {
+	// collect views
+	views := map[*mongokit.Collection]bool{}
+	for _, namespace := range d.Namespaces {
+		if namespace.IsMaterialized() {
+			views[namespace] = true
+		}
+	}
+
+	// remove states of removed views
+	for view := range d.states {
+		if !views[view] {
+			delete(d.states, view)
+		}
+	}
+}
+
+func materialize(view, source *mongokit.Collection) (materializedState, error) {
+	// get config
+	config := view.Config()
+
+	// split pipeline
+	stages, group, err := splitMaterialized(config.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	// get collator
+	collator, err := bsonkit.NewCollator(config.Collation)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare context
+	ctx := mongokit.PipelineContext{
+		Collator: collator,
+	}
+
+	// clear view
+	for _, doc := range append(bsonkit.List{}, view.Documents.List...) {
+		err = view.Remove(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// prepare state
+	state := materializedState{}
+
+	// check source
+	if source == nil {
+		return state, nil
+	}
+
+	// run stages
+	members, err := mongokit.Aggregate(source.Documents.List, stages, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// compute groups
+	groups, err := mongokit.Aggregate(members, bsonkit.List{
+		&bson.D{bson.E{Key: "$group", Value: group}},
+	}, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// add groups
+	for _, doc := range groups {
+		_, err = view.Insert(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// compute state
+	fields := groupFields(group)
+	for _, member := range members {
+		// get group document, the default index of the view compares the keys
+		// using the view collation
+		key, err := groupKey(member, group)
+		if err != nil {
+			return nil, err
+		}
+		doc := view.Get(key)
+
+		// fold member
+		grp := state[doc]
+		if grp == nil {
+			grp = newMaterializedGroup(fields)
+			state[doc] = grp
+		}
+		_, err = grp.fold(member, fields, 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+func maintain(view, before, after *mongokit.Collection, ids bson.A, state materializedState) (materializedState, error) {
+	// get config
+	config := view.Config()
+
+	// split pipeline
+	stages, group, err := splitMaterialized(config.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	// get collator
+	collator, err := bsonkit.NewCollator(config.Collation)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare context
+	ctx := mongokit.PipelineContext{
+		Collator: collator,
+	}
+
+	// sort ids to skip duplicates
+	sort.Slice(ids, func(i, j int) bool {
+		return bsonkit.Compare(ids[i], ids[j]) < 0
+	})
+
+	// collect previous and current versions of changed documents
+	var removed, inserted bsonkit.List
+	for i, id := range ids {
+		// skip duplicates
+		if i > 0 && bsonkit.Compare(ids[i-1], id) == 0 {
+			continue
+		}
+
+		// get versions
+		var prev, next bsonkit.Doc
+		if before != nil && !before.IsView() {
+			prev = before.Get(id)
+		}
+		if after != nil {
+			next = after.Get(id)
+		}
+
+		// skip unchanged documents
+		if prev == next {
+			continue
+		}
+
+		// add versions
+		if prev != nil {
+			removed = append(removed, prev)
+		}
+		if next != nil {
+			inserted = append(inserted, next)
+		}
+	}
+
+	// run stages
+	removed, err = mongokit.Aggregate(removed, stages, ctx)
+	if err != nil {
+		return nil, err
+	}
+	inserted, err = mongokit.Aggregate(inserted, stages, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// copy state
+	state = state.clone()
+
+	// update groups
+	fields := groupFields(group)
+	groups := map[bsonkit.Doc]*materializedGroup{}
+	stale := map[bsonkit.Doc]bool{}
+	var touched bsonkit.List
+	var missing bool
+	update := func(list bsonkit.List, sign int) error {
+		for _, member := range list {
+			// get group document
+			key, err := groupKey(member, group)
+			if err != nil {
+				return err
+			}
+			doc := view.Get(key)
+
+			// handle missing group
+			if doc == nil {
+				// removed members must belong to an existing group
+				if sign < 0 {
+					missing = true
+					return nil
+				}
+
+				// add placeholder
+				doc = &bson.D{bson.E{Key: "_id", Value: key}}
+				_, err = view.Insert(doc)
+				if err != nil {
+					return err
+				}
+			}
+
+			// get group
+			grp := groups[doc]
+			if grp == nil {
+				if state[doc] != nil {
+					grp = state[doc].clone()
+				} else {
+					grp = newMaterializedGroup(fields)
+				}
+				groups[doc] = grp
+				touched = append(touched, doc)
+			}
+
+			// fold member
+			ok, err := grp.fold(member, fields, sign)
+			if err != nil {
+				return err
+			} else if !ok {
+				stale[doc] = true
+			}
+		}
+
+		return nil
+	}
+	err = update(removed, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// recompute the view if the state is inconsistent
+	if missing {
+		return materialize(view, after)
+	}
+
+	// add inserted members
+	err = update(inserted, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// recompute stale groups from the source
+	recomputed := map[bsonkit.Doc]bsonkit.Doc{}
+	for doc := range stale {
+		groups[doc] = newMaterializedGroup(fields)
+	}
+	if len(stale) > 0 && after != nil {
+		// run stages
+		list, err := mongokit.Aggregate(after.Documents.List, stages, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// collect members of stale groups
+		var members bsonkit.List
+		for _, member := range list {
+			// get group document
+			key, err := groupKey(member, group)
+			if err != nil {
+				return nil, err
+			}
+			doc := view.Get(key)
+			if !stale[doc] {
+				continue
+			}
+
+			// fold member
+			members = append(members, member)
+			_, err = groups[doc].fold(member, fields, 1)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// compute groups
+		list, err = mongokit.Aggregate(members, bsonkit.List{
+			&bson.D{bson.E{Key: "$group", Value: group}},
+		}, ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range list {
+			recomputed[view.Get(bsonkit.Get(doc, "_id"))] = doc
+		}
+	}
+
+	// replace groups
+	for _, doc := range touched {
+		// remove previous group
+		err = view.Remove(doc)
+		if err != nil {
+			return nil, err
+		}
+		delete(state, doc)
+
+		// skip empty groups
+		grp := groups[doc]
+		if grp.count <= 0 {
+			continue
+		}
+
+		// get current group
+		current := recomputed[doc]
+		if !stale[doc] {
+			current = grp.render(bsonkit.Get(doc, "_id"), fields)
+		}
+
+		// add current group
+		_, err = view.Insert(current)
+		if err != nil {
+			return nil, err
+		}
+		state[current] = grp
+	}
+
+	return state, nil
+}
+
+func splitMaterialized(pipeline bsonkit.List) (bsonkit.List, bson.D, error) {
+	// check length
+	if len(pipeline) == 0 {
+		return nil, nil, fmt.Errorf("materialized view pipeline must end with a $group stage")
+	}
+
+	// check stages
+	for i, stage := range pipeline {
+		// check stage
+		if len(*stage) != 1 {
+			return nil, nil, fmt.Errorf("a pipeline stage specification object must contain exactly one field")
+		}
+
+		// get name
+		name := (*stage)[0].Key
+
+		// check last stage
+		if i == len(pipeline)-1 {
+			group, ok := (*stage)[0].Value.(bson.D)
+			if name != "$group" || !ok {
+				return nil, nil, fmt.Errorf("materialized view pipeline must end with a $group stage")
+			}
+
+			return pipeline[:i], group, nil
+		}
+
+		// check other stages
+		if !materializedStages[name] {
+			return nil, nil, fmt.Errorf("unsupported stage in materialized view pipeline: %s", name)
+		}
+	}
+
+	return nil, nil, nil
+}
+
+func groupKey(doc bsonkit.Doc, group bson.D) (interface{}, error) {
+	// get id expression
+	var id interface{}
+	for _, field := range group {
+		if field.Key == "_id" {
+			id = field.Value
+		}
+	}
+
+	// evaluate key
+	key, err := mongokit.Evaluate(doc, id)
+	if err != nil {
+		return nil, err
+	} else if key == bsonkit.Missing {
+		key = nil
+	}
+
+	return key, nil
+}
+
+func groupFields(group bson.D) bson.D {
+	// collect accumulator fields
+	var fields bson.D
+	for _, field := range group {
+		if field.Key != "_id" {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
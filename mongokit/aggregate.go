@@ -0,0 +1,562 @@
+package mongokit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+// https://www.mongodb.com/docs/manual/reference/operator/aggregation-pipeline/
+
+// StageOperator is an aggregation pipeline stage operator.
+type StageOperator func(ctx PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error)
+
+// AccumulatorOperator is an aggregation accumulator operator that computes a
+// result from the evaluated values of a group.
+type AccumulatorOperator func(op string, values bson.A) (interface{}, error)
+
+// PipelineContext is the context of an aggregation pipeline.
+type PipelineContext struct {
+	// The collator used to compare strings.
+	Collator *bsonkit.Collator
+
+	// Additional stages that override or extend the default stages.
+	Stages map[string]StageOperator
+}
+
+// PipelineStageOperators defines the aggregation pipeline stage operators.
+var PipelineStageOperators = map[string]StageOperator{}
+
+// AccumulatorOperators defines the aggregation accumulator operators.
+var AccumulatorOperators = map[string]AccumulatorOperator{}
+
+func init() {
+	// register stage operators
+	PipelineStageOperators["$match"] = stageMatch
+	PipelineStageOperators["$project"] = stageProject
+	PipelineStageOperators["$addFields"] = stageAddFields
+	PipelineStageOperators["$set"] = stageAddFields
+	PipelineStageOperators["$unset"] = stageUnset
+	PipelineStageOperators["$sort"] = stageSort
+	PipelineStageOperators["$skip"] = stageSkip
+	PipelineStageOperators["$limit"] = stageLimit
+	PipelineStageOperators["$count"] = stageCount
+	PipelineStageOperators["$group"] = stageGroup
+	PipelineStageOperators["$unwind"] = stageUnwind
+	PipelineStageOperators["$replaceRoot"] = stageReplaceRoot
+	PipelineStageOperators["$replaceWith"] = stageReplaceRoot
+
+	// register accumulator operators
+	AccumulatorOperators["$sum"] = accumulateSum
+	AccumulatorOperators["$avg"] = accumulateAvg
+	AccumulatorOperators["$min"] = accumulateMinMax
+	AccumulatorOperators["$max"] = accumulateMinMax
+	AccumulatorOperators["$first"] = accumulateFirstLast
+	AccumulatorOperators["$last"] = accumulateFirstLast
+	AccumulatorOperators["$push"] = accumulatePush
+	AccumulatorOperators["$addToSet"] = accumulateAddToSet
+}
+
+// Aggregate will run the specified aggregation pipeline on the list of
+// documents and return the resulting list. The input documents are not
+// modified, but documents may be shared between the input and the output.
+func Aggregate(list bsonkit.List, pipeline bsonkit.List, ctx PipelineContext) (bsonkit.List, error) {
+	for _, stage := range pipeline {
+		// check stage
+		if len(*stage) != 1 {
+			return nil, fmt.Errorf("a pipeline stage specification object must contain exactly one field")
+		}
+
+		// get name
+		name := (*stage)[0].Key
+
+		// lookup operator
+		operator := ctx.Stages[name]
+		if operator == nil {
+			operator = PipelineStageOperators[name]
+		}
+		if operator == nil {
+			return nil, fmt.Errorf("unrecognized pipeline stage name: %q", name)
+		}
+
+		// run stage
+		var err error
+		list, err = operator(ctx, list, name, (*stage)[0].Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+func stageMatch(ctx PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+	// get query
+	query, ok := v.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected document", stage)
+	}
+
+	return Filter(list, &query, 0, ctx.Collator)
+}
+
+func stageProject(_ PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+	// get projection
+	projection, ok := v.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected document", stage)
+	} else if len(projection) == 0 {
+		return nil, fmt.Errorf("%s: specification must have at least one field", stage)
+	}
+
+	return ProjectList(list, &projection)
+}
+
+func stageAddFields(_ PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+	// get fields
+	fields, ok := v.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected document", stage)
+	}
+
+	// add fields
+	result := make(bsonkit.List, 0, len(list))
+	for _, doc := range list {
+		// clone document
+		res := bsonkit.Clone(doc)
+
+		// set fields
+		for _, field := range fields {
+			// evaluate expression
+			value, err := Evaluate(doc, field.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			// remove or set field
+			if value == bsonkit.Missing {
+				bsonkit.Unset(res, field.Key)
+			} else {
+				_, err = bsonkit.Put(res, field.Key, value, false)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		result = append(result, res)
+	}
+
+	return result, nil
+}
+
+func stageUnset(_ PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+	// get fields
+	var fields bson.A
+	switch value := v.(type) {
+	case string:
+		fields = bson.A{value}
+	case bson.A:
+		fields = value
+	default:
+		return nil, fmt.Errorf("%s: expected string or array of strings", stage)
+	}
+
+	// prepare projection
+	projection := bson.D{}
+	for _, field := range fields {
+		name, ok := field.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected string or array of strings", stage)
+		}
+		projection = append(projection, bson.E{Key: name, Value: int32(0)})
+	}
+
+	return ProjectList(list, &projection)
+}
+
+func stageSort(ctx PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+	// get sort
+	doc, ok := v.(bson.D)
+	if !ok || len(doc) == 0 {
+		return nil, fmt.Errorf("%s: the sort key specification must be a non-empty object", stage)
+	}
+
+	return Sort(list, &doc, ctx.Collator)
+}
+
+func stageSkip(_ PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+	// get skip
+	skip, ok := stageInteger(v)
+	if !ok || skip < 0 {
+		return nil, fmt.Errorf("%s: expected non-negative integer", stage)
+	}
+
+	// apply skip
+	if int(skip) >= len(list) {
+		return bsonkit.List{}, nil
+	}
+
+	return list[skip:], nil
+}
+
+func stageLimit(_ PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+	// get limit
+	limit, ok := stageInteger(v)
+	if !ok || limit <= 0 {
+		return nil, fmt.Errorf("%s: expected positive integer", stage)
+	}
+
+	// apply limit
+	if int(limit) < len(list) {
+		return list[:limit], nil
+	}
+
+	return list, nil
+}
+
+func stageCount(_ PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+	// get name
+	name, ok := v.(string)
+	if !ok || name == "" || strings.HasPrefix(name, "$") || strings.Contains(name, ".") {
+		return nil, fmt.Errorf("%s: expected non-empty field name without '$' or '.'", stage)
+	}
+
+	// handle empty list
+	if len(list) == 0 {
+		return bsonkit.List{}, nil
+	}
+
+	return bsonkit.List{
+		&bson.D{bson.E{Key: name, Value: int32(len(list))}},
+	}, nil
+}
+
+func stageGroup(ctx PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+	// get specification
+	spec, ok := v.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected document", stage)
+	}
+
+	// get id and accumulators
+	var id interface{} = bsonkit.Missing
+	var fields bson.D
+	for _, field := range spec {
+		if field.Key == "_id" {
+			id = field.Value
+		} else {
+			fields = append(fields, field)
+		}
+	}
+
+	// check id
+	if id == bsonkit.Missing {
+		return nil, fmt.Errorf("%s: a group specification must include an _id", stage)
+	}
+
+	// check accumulators
+	for _, field := range fields {
+		if strings.Contains(field.Key, ".") {
+			return nil, fmt.Errorf("%s: the group aggregate field name %q cannot contain '.'", stage, field.Key)
+		}
+		acc, ok := field.Value.(bson.D)
+		if !ok || len(acc) != 1 {
+			return nil, fmt.Errorf("%s: the field %q must be an accumulator object", stage, field.Key)
+		} else if AccumulatorOperators[acc[0].Key] == nil {
+			return nil, fmt.Errorf("%s: unknown group operator %q", stage, acc[0].Key)
+		}
+	}
+
+	// compute keys
+	type item struct {
+		key interface{}
+		doc bsonkit.Doc
+	}
+	items := make([]item, 0, len(list))
+	for _, doc := range list {
+		key, err := Evaluate(doc, id)
+		if err != nil {
+			return nil, err
+		}
+		if key == bsonkit.Missing {
+			key = nil
+		}
+		items = append(items, item{key: key, doc: doc})
+	}
+
+	// sort items by key
+	sort.SliceStable(items, func(i, j int) bool {
+		return ctx.Collator.Compare(items[i].key, items[j].key) < 0
+	})
+
+	// prepare result
+	var result bsonkit.List
+
+	// process groups
+	for start := 0; start < len(items); {
+		// find end of group
+		end := start + 1
+		for end < len(items) && ctx.Collator.Compare(items[start].key, items[end].key) == 0 {
+			end++
+		}
+
+		// prepare group document
+		group := bson.D{bson.E{Key: "_id", Value: items[start].key}}
+
+		// compute accumulators
+		for _, field := range fields {
+			// get accumulator
+			acc := field.Value.(bson.D)[0]
+
+			// evaluate values
+			values := make(bson.A, 0, end-start)
+			for _, item := range items[start:end] {
+				value, err := Evaluate(item.doc, acc.Value)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, value)
+			}
+
+			// accumulate values
+			res, err := AccumulatorOperators[acc.Key](acc.Key, values)
+			if err != nil {
+				return nil, err
+			}
+
+			group = append(group, bson.E{Key: field.Key, Value: res})
+		}
+
+		// add group
+		result = append(result, &group)
+
+		start = end
+	}
+
+	return result, nil
+}
+
+func stageUnwind(_ PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+	// get options
+	var path, indexField string
+	var preserve bool
+	switch value := v.(type) {
+	case string:
+		path = value
+	case bson.D:
+		for _, opt := range value {
+			switch opt.Key {
+			case "path":
+				path, _ = opt.Value.(string)
+			case "includeArrayIndex":
+				indexField, _ = opt.Value.(string)
+			case "preserveNullAndEmptyArrays":
+				preserve, _ = opt.Value.(bool)
+			default:
+				return nil, fmt.Errorf("%s: unrecognized option %q", stage, opt.Key)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: expected string or document", stage)
+	}
+
+	// check path
+	if !strings.HasPrefix(path, "$") || len(path) < 2 {
+		return nil, fmt.Errorf("%s: path option must be prefixed with a '$'", stage)
+	}
+	path = path[1:]
+
+	// unwind documents
+	var result bsonkit.List
+	for _, doc := range list {
+		// get value
+		value := bsonkit.Get(doc, path)
+
+		// handle non arrays and empty arrays, null, missing and empty arrays
+		// are only kept if preserved
+		array, ok := value.(bson.A)
+		if !ok || len(array) == 0 {
+			if (ok || isNullish(value)) && !preserve {
+				continue
+			}
+			res := bsonkit.Clone(doc)
+			if ok {
+				bsonkit.Unset(res, path)
+			}
+			if indexField != "" {
+				_, err := bsonkit.Put(res, indexField, nil, false)
+				if err != nil {
+					return nil, err
+				}
+			}
+			result = append(result, res)
+			continue
+		}
+
+		// add elements
+		for i, item := range array {
+			res := bsonkit.Clone(doc)
+			_, err := bsonkit.Put(res, path, item, false)
+			if err != nil {
+				return nil, err
+			}
+			if indexField != "" {
+				_, err = bsonkit.Put(res, indexField, int64(i), false)
+				if err != nil {
+					return nil, err
+				}
+			}
+			result = append(result, res)
+		}
+	}
+
+	return result, nil
+}
+
+func stageReplaceRoot(_ PipelineContext, list bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+	// get expression
+	expr := v
+	if stage == "$replaceRoot" {
+		spec, ok := v.(bson.D)
+		if !ok || len(spec) != 1 || spec[0].Key != "newRoot" {
+			return nil, fmt.Errorf("%s: expected document with 'newRoot'", stage)
+		}
+		expr = spec[0].Value
+	}
+
+	// replace documents
+	result := make(bsonkit.List, 0, len(list))
+	for _, doc := range list {
+		value, err := Evaluate(doc, expr)
+		if err != nil {
+			return nil, err
+		}
+		root, ok := value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("%s: 'newRoot' expression must evaluate to an object", stage)
+		}
+		result = append(result, bsonkit.Clone(&root))
+	}
+
+	return result, nil
+}
+
+func stageInteger(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), float64(int64(v)) == v
+	default:
+		return 0, false
+	}
+}
+
+func accumulateSum(_ string, values bson.A) (interface{}, error) {
+	// sum numbers
+	var sum interface{} = int32(0)
+	for _, value := range values {
+		if class, _ := bsonkit.Inspect(value); class == bsonkit.Number {
+			sum = bsonkit.Add(sum, value)
+		}
+	}
+
+	return sum, nil
+}
+
+func accumulateAvg(_ string, values bson.A) (interface{}, error) {
+	// sum numbers
+	var sum interface{} = int32(0)
+	var count int
+	for _, value := range values {
+		if class, _ := bsonkit.Inspect(value); class == bsonkit.Number {
+			sum = bsonkit.Add(sum, value)
+			count++
+		}
+	}
+
+	// handle no numbers
+	if count == 0 {
+		return nil, nil
+	}
+
+	return ToFloat64(sum) / float64(count), nil
+}
+
+func accumulateMinMax(op string, values bson.A) (interface{}, error) {
+	// find extreme value
+	var res interface{}
+	for _, value := range values {
+		if isNullish(value) {
+			continue
+		}
+		if res == nil {
+			res = value
+		} else if cmp := bsonkit.Compare(value, res); op == "$min" && cmp < 0 || op == "$max" && cmp > 0 {
+			res = value
+		}
+	}
+
+	return res, nil
+}
+
+func accumulateFirstLast(op string, values bson.A) (interface{}, error) {
+	// handle empty
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	// get value
+	value := values[0]
+	if op == "$last" {
+		value = values[len(values)-1]
+	}
+
+	// convert missing
+	if value == bsonkit.Missing {
+		return nil, nil
+	}
+
+	return value, nil
+}
+
+func accumulatePush(_ string, values bson.A) (interface{}, error) {
+	// collect values
+	res := make(bson.A, 0, len(values))
+	for _, value := range values {
+		if value != bsonkit.Missing {
+			res = append(res, value)
+		}
+	}
+
+	return res, nil
+}
+
+func accumulateAddToSet(_ string, values bson.A) (interface{}, error) {
+	// collect unique values
+	res := make(bson.A, 0, len(values))
+	for _, value := range values {
+		if value == bsonkit.Missing {
+			continue
+		}
+		var found bool
+		for _, item := range res {
+			if bsonkit.Compare(item, value) == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, value)
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,155 @@
+package mongokit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+func aggregateTest(t *testing.T, docs bson.A, fn func(fn func(bson.A, interface{}))) {
+	t.Run("Mongo", func(t *testing.T) {
+		coll := testCollection()
+		if len(docs) > 0 {
+			_, err := coll.InsertMany(nil, docs)
+			assert.NoError(t, err)
+		}
+
+		fn(func(pipeline bson.A, result interface{}) {
+			var out []bson.D
+			csr, err := coll.Aggregate(nil, pipeline)
+			if err == nil {
+				err = csr.All(nil, &out)
+			}
+			if _, ok := result.(string); ok {
+				assert.Error(t, err, pipeline)
+			} else {
+				assert.NoError(t, err, pipeline)
+				assert.Equal(t, result, bsonkit.MustConvertList(out), pipeline)
+			}
+		})
+	})
+
+	t.Run("Lungo", func(t *testing.T) {
+		list := bsonkit.MustConvertList(docs)
+
+		fn(func(pipeline bson.A, result interface{}) {
+			res, err := Aggregate(bsonkit.CloneList(list), bsonkit.MustConvertList(pipeline), PipelineContext{})
+			if str, ok := result.(string); ok {
+				assert.Error(t, err, pipeline)
+				assert.Equal(t, str, err.Error(), pipeline)
+			} else {
+				assert.NoError(t, err, pipeline)
+				assert.Equal(t, result, res, pipeline)
+			}
+		})
+	})
+}
+
+func TestAggregate(t *testing.T) {
+	aggregateTest(t, bson.A{
+		bson.D{{Key: "_id", Value: int32(1)}, {Key: "a", Value: "x"}, {Key: "b", Value: int32(3)}},
+		bson.D{{Key: "_id", Value: int32(2)}, {Key: "a", Value: "y"}, {Key: "b", Value: int32(1)}},
+		bson.D{{Key: "_id", Value: int32(3)}, {Key: "a", Value: "x"}, {Key: "b", Value: int32(2)}},
+	}, func(fn func(bson.A, interface{})) {
+		// match, sort, skip and limit
+		fn(bson.A{
+			bson.M{"$match": bson.M{"a": "x"}},
+			bson.M{"$sort": bson.M{"b": 1}},
+			bson.M{"$skip": 1},
+			bson.M{"$limit": 1},
+		}, bsonkit.List{
+			{{Key: "_id", Value: int32(1)}, {Key: "a", Value: "x"}, {Key: "b", Value: int32(3)}},
+		})
+
+		// project and add fields
+		fn(bson.A{
+			bson.M{"$project": bson.M{"a": 1}},
+			bson.M{"$addFields": bson.M{"c": bson.M{"$concat": bson.A{"$a", "!"}}}},
+			bson.M{"$limit": 1},
+		}, bsonkit.List{
+			{{Key: "_id", Value: int32(1)}, {Key: "a", Value: "x"}, {Key: "c", Value: "x!"}},
+		})
+
+		// unset
+		fn(bson.A{
+			bson.M{"$unset": bson.A{"a", "b"}},
+			bson.M{"$limit": 1},
+		}, bsonkit.List{
+			{{Key: "_id", Value: int32(1)}},
+		})
+
+		// group
+		fn(bson.A{
+			bson.M{"$group": bson.D{
+				{Key: "_id", Value: "$a"},
+				{Key: "sum", Value: bson.M{"$sum": "$b"}},
+				{Key: "count", Value: bson.M{"$sum": int32(1)}},
+				{Key: "avg", Value: bson.M{"$avg": "$b"}},
+				{Key: "max", Value: bson.M{"$max": "$b"}},
+				{Key: "ids", Value: bson.M{"$push": "$_id"}},
+			}},
+			bson.M{"$sort": bson.M{"_id": 1}},
+		}, bsonkit.List{
+			{{Key: "_id", Value: "x"}, {Key: "sum", Value: int32(5)}, {Key: "count", Value: int32(2)}, {Key: "avg", Value: 2.5}, {Key: "max", Value: int32(3)}, {Key: "ids", Value: bson.A{int32(1), int32(3)}}},
+			{{Key: "_id", Value: "y"}, {Key: "sum", Value: int32(1)}, {Key: "count", Value: int32(1)}, {Key: "avg", Value: 1.0}, {Key: "max", Value: int32(1)}, {Key: "ids", Value: bson.A{int32(2)}}},
+		})
+
+		// count
+		fn(bson.A{
+			bson.M{"$match": bson.M{"b": bson.M{"$gt": 1}}},
+			bson.M{"$count": "n"},
+		}, bsonkit.List{
+			{{Key: "n", Value: int32(2)}},
+		})
+
+		// replace root
+		fn(bson.A{
+			bson.M{"$replaceRoot": bson.M{"newRoot": bson.M{"v": "$b"}}},
+			bson.M{"$limit": 1},
+		}, bsonkit.List{
+			{{Key: "v", Value: int32(3)}},
+		})
+
+		// errors
+		fn(bson.A{
+			bson.M{"$foo": 1},
+		}, `unrecognized pipeline stage name: "$foo"`)
+		fn(bson.A{
+			bson.M{"$group": bson.M{"a": bson.M{"$sum": int32(1)}}},
+		}, "$group: a group specification must include an _id")
+	})
+}
+
+func TestAggregateUnwind(t *testing.T) {
+	aggregateTest(t, bson.A{
+		bson.D{{Key: "_id", Value: int32(1)}, {Key: "a", Value: bson.A{"x", "y"}}},
+		bson.D{{Key: "_id", Value: int32(2)}, {Key: "a", Value: bson.A{}}},
+		bson.D{{Key: "_id", Value: int32(3)}, {Key: "a", Value: "z"}},
+		bson.D{{Key: "_id", Value: int32(4)}},
+	}, func(fn func(bson.A, interface{})) {
+		fn(bson.A{
+			bson.M{"$unwind": "$a"},
+		}, bsonkit.List{
+			{{Key: "_id", Value: int32(1)}, {Key: "a", Value: "x"}},
+			{{Key: "_id", Value: int32(1)}, {Key: "a", Value: "y"}},
+			{{Key: "_id", Value: int32(3)}, {Key: "a", Value: "z"}},
+		})
+
+		fn(bson.A{
+			bson.M{"$unwind": bson.M{
+				"path":                       "$a",
+				"includeArrayIndex":          "i",
+				"preserveNullAndEmptyArrays": true,
+			}},
+		}, bsonkit.List{
+			{{Key: "_id", Value: int32(1)}, {Key: "a", Value: "x"}, {Key: "i", Value: int64(0)}},
+			{{Key: "_id", Value: int32(1)}, {Key: "a", Value: "y"}, {Key: "i", Value: int64(1)}},
+			{{Key: "_id", Value: int32(2)}, {Key: "i", Value: nil}},
+			{{Key: "_id", Value: int32(3)}, {Key: "a", Value: "z"}, {Key: "i", Value: nil}},
+			{{Key: "_id", Value: int32(4)}, {Key: "i", Value: nil}},
+		})
+	})
+}
@@ -2,6 +2,9 @@ package mongokit
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -24,6 +27,71 @@ type Result struct {
 	Changes []*Changes
 }
 
+// CollectionConfig defines a collection configuration.
+type CollectionConfig struct {
+	// The default collation used to compare strings.
+	Collation bsonkit.Doc
+
+	// The source collection of a read-only view.
+	ViewOn string
+
+	// The aggregation pipeline of a read-only view.
+	Pipeline bsonkit.List
+
+	// Whether the view stores the pipeline result.
+	Materialized bool
+
+	// The query that inserted and updated documents must match.
+	Validator bsonkit.Doc
+
+	// The validation level: "strict" (default), "moderate" or "off".
+	ValidationLevel string
+
+	// The validation action: "error" (default) or "warn".
+	ValidationAction string
+
+	// Whether the collection is capped to a maximum size in bytes and an
+	// optional maximum number of documents.
+	Capped       bool
+	SizeInBytes  int64
+	MaxDocuments int64
+
+	// Whether the documents are stored ordered by _id instead of being
+	// indexed by a separate default index.
+	Clustered bool
+
+	// The time and optional meta field of a time-series collection and the
+	// granularity: "seconds" (default), "minutes" or "hours". The measurements
+	// are stored in buckets in a separate collection.
+	TimeField   string
+	MetaField   string
+	Granularity string
+
+	// The number of seconds after which the buckets of a time-series
+	// collection or the documents of a clustered collection expire.
+	ExpireAfterSeconds int64
+}
+
+// DocumentChange describes a document that has been added, replaced or removed.
+// The removed document is nil for added documents and the added document is
+// nil for removed documents.
+type DocumentChange struct {
+	Removed bsonkit.Doc
+	Added   bsonkit.Doc
+}
+
+// Verification is the result of a collection verification.
+type Verification struct {
+	// The detected inconsistencies of the documents.
+	Errors []string
+
+	// The detected inconsistencies per index.
+	Indexes map[string][]string
+
+	// The number of documents that do not comply with the validator.
+	NonCompliant int
+}
+
 // Collection combines a set and multiple indexes to form a basic MongoDB like
 // collection that offers basic CRUD capabilities. The collection is not safe
 // from concurrent access and does not roll back changes on errors. Therefore,
@@ -31,47 +99,307 @@ type Result struct {
 type Collection struct {
 	Documents *bsonkit.Set
 	Indexes   map[string]*Index
+
+	config   CollectionConfig
+	collator *bsonkit.Collator
+
+	// the running BSON size of capped collections and the document set it has
+	// been computed for
+	size  int64
+	sized *bsonkit.Set
+
+	// the collection the document changes are tracked against and the
+	// changes applied since
+	origin  *Collection
+	changes []DocumentChange
 }
 
 // NewCollection will create and return a new collection.
 func NewCollection(idIndex bool) *Collection {
+	// create collection
+	coll, err := CreateCollection(CollectionConfig{}, idIndex)
+	if err != nil {
+		panic(err)
+	}
+
+	return coll
+}
+
+// CreateCollection will create and return a new collection using the specified
+// configuration. The default index will inherit the collation.
+func CreateCollection(config CollectionConfig, idIndex bool) (*Collection, error) {
+	// create collator
+	collator, err := bsonkit.NewCollator(config.Collation)
+	if err != nil {
+		return nil, err
+	}
+
+	// clone or clear collation
+	if collator != nil {
+		config.Collation = bsonkit.Clone(config.Collation)
+	} else {
+		config.Collation = nil
+	}
+
+	// clone pipeline
+	config.Pipeline = bsonkit.CloneList(config.Pipeline)
+
+	// check validation level and action
+	switch config.ValidationLevel {
+	case "", "strict", "moderate", "off":
+	default:
+		return nil, fmt.Errorf("invalid validation level %q", config.ValidationLevel)
+	}
+	switch config.ValidationAction {
+	case "", "error", "warn":
+	default:
+		return nil, fmt.Errorf("invalid validation action %q", config.ValidationAction)
+	}
+
+	// check capped options
+	if config.Capped {
+		if config.SizeInBytes <= 0 {
+			return nil, fmt.Errorf("the 'size' field is required when 'capped' is true")
+		} else if config.MaxDocuments < 0 {
+			return nil, fmt.Errorf("the 'max' field must not be negative")
+		}
+
+		// round size like MongoDB
+		if config.SizeInBytes <= 4096 {
+			config.SizeInBytes = 4096
+		} else if rest := config.SizeInBytes % 256; rest != 0 {
+			config.SizeInBytes += 256 - rest
+		}
+	} else {
+		config.SizeInBytes = 0
+		config.MaxDocuments = 0
+	}
+
+	// check time-series options
+	if config.TimeField != "" {
+		switch config.Granularity {
+		case "":
+			config.Granularity = "seconds"
+		case "seconds", "minutes", "hours":
+		default:
+			return nil, fmt.Errorf("invalid time-series granularity %q", config.Granularity)
+		}
+		if config.TimeField == "_id" || config.MetaField == "_id" {
+			return nil, fmt.Errorf("time-series fields must not be '_id'")
+		} else if config.MetaField == config.TimeField {
+			return nil, fmt.Errorf("the 'metaField' must be different from the 'timeField'")
+		}
+	} else if config.MetaField != "" || config.Granularity != "" {
+		return nil, fmt.Errorf("the 'timeField' is required for time-series collections")
+	}
+
+	// check clustered options
+	if config.Clustered && (config.Capped || config.TimeField != "" || config.ViewOn != "") {
+		return nil, fmt.Errorf("clustered collections must not be capped, time-series collections or views")
+	}
+
+	// check expiry
+	if config.ExpireAfterSeconds < 0 {
+		return nil, fmt.Errorf("the 'expireAfterSeconds' field must not be negative")
+	} else if config.ExpireAfterSeconds != 0 && config.TimeField == "" && !config.Clustered {
+		return nil, fmt.Errorf("the 'expireAfterSeconds' field is only supported on time-series and clustered collections")
+	}
+
+	// check and clone validator
+	if config.Validator != nil {
+		_, err = Match(&bson.D{}, config.Validator, collator)
+		if err != nil {
+			return nil, err
+		}
+		config.Validator = bsonkit.Clone(config.Validator)
+	}
+
 	// create collection
 	coll := &Collection{
 		Documents: bsonkit.NewSet(nil),
 		Indexes:   map[string]*Index{},
+		config:    config,
+		collator:  collator,
 	}
+	coll.sized = coll.Documents
 
-	// add default index if requested
-	if idIndex {
-		var err error
+	// add default index if requested, clustered collections are ordered by
+	// _id instead
+	if idIndex && !config.Clustered {
 		coll.Indexes["_id_"], err = CreateIndex(IndexConfig{
 			Key: bsonkit.MustConvert(bson.M{
 				"_id": int32(1),
 			}),
-			Unique: true,
+			Unique:    true,
+			Collation: config.Collation,
 		})
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 	}
 
-	return coll
+	return coll, nil
 }
 
-// Find will look up the documents that match the specified query.
-func (c *Collection) Find(query, sort bsonkit.Doc, skip, limit int) (*Result, error) {
-	// get documents
-	list := c.Documents.List
+// Config will return the collection configuration.
+func (c *Collection) Config() CollectionConfig {
+	return CollectionConfig{
+		Collation:          bsonkit.Clone(c.config.Collation),
+		ViewOn:             c.config.ViewOn,
+		Pipeline:           bsonkit.CloneList(c.config.Pipeline),
+		Materialized:       c.config.Materialized,
+		Validator:          bsonkit.Clone(c.config.Validator),
+		ValidationLevel:    c.config.ValidationLevel,
+		ValidationAction:   c.config.ValidationAction,
+		Capped:             c.config.Capped,
+		SizeInBytes:        c.config.SizeInBytes,
+		MaxDocuments:       c.config.MaxDocuments,
+		Clustered:          c.config.Clustered,
+		TimeField:          c.config.TimeField,
+		MetaField:          c.config.MetaField,
+		Granularity:        c.config.Granularity,
+		ExpireAfterSeconds: c.config.ExpireAfterSeconds,
+	}
+}
+
+// IsClustered will return whether the collection stores its documents ordered
+// by _id.
+func (c *Collection) IsClustered() bool {
+	return c.config.Clustered
+}
+
+// IsCapped will return whether the collection is a capped collection.
+func (c *Collection) IsCapped() bool {
+	return c.config.Capped
+}
+
+// Evict will remove the oldest documents in insertion order while a capped
+// collection exceeds its size or document limit. The newest document is never
+// removed. The removed documents are returned.
+func (c *Collection) Evict() (bsonkit.List, error) {
+	// check capped
+	if !c.config.Capped {
+		return nil, nil
+	}
+
+	// compute size if the documents have been replaced
+	if c.sized != c.Documents {
+		c.size = 0
+		for _, doc := range c.Documents.List {
+			size, err := docSize(doc)
+			if err != nil {
+				return nil, err
+			}
+			c.size += size
+		}
+		c.sized = c.Documents
+	}
+
+	// remove oldest documents while a limit is exceeded
+	var evicted bsonkit.List
+	for len(c.Documents.List) > 1 {
+		// check limits
+		if c.size <= c.config.SizeInBytes && (c.config.MaxDocuments <= 0 || int64(len(c.Documents.List)) <= c.config.MaxDocuments) {
+			break
+		}
+
+		// remove document
+		doc := c.Documents.List[0]
+		err := c.Remove(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		evicted = append(evicted, doc)
+	}
+
+	return evicted, nil
+}
+
+// Validate will check the document against the collection validator using the
+// default collation. It returns the details of the failure or nil if the
+// document is valid or no validator is configured.
+func (c *Collection) Validate(doc bsonkit.Doc) (bson.D, error) {
+	// check validator
+	if c.config.Validator == nil {
+		return nil, nil
+	}
+
+	return Explain(doc, c.config.Validator, c.collator)
+}
+
+// IsView will return whether the collection is a read-only view that is
+// resolved on every read.
+func (c *Collection) IsView() bool {
+	return c.config.ViewOn != "" && !c.config.Materialized
+}
+
+// IsMaterialized will return whether the collection is a materialized view
+// that stores the pipeline result.
+func (c *Collection) IsMaterialized() bool {
+	return c.config.ViewOn != "" && c.config.Materialized
+}
+
+// Get will return the document with the specified _id or nil if it does not
+// exist. The default index is used if available.
+func (c *Collection) Get(id interface{}) bsonkit.Doc {
+	// prepare query
+	query := &bson.D{bson.E{Key: "_id", Value: id}}
+
+	// lookup using clustered order
+	if c.config.Clustered {
+		i := c.search(id, false)
+		if i < len(c.Documents.List) && bsonkit.Compare(bsonkit.Get(c.Documents.List[i], "_id"), id) == 0 {
+			return c.Documents.List[i]
+		}
+		return nil
+	}
+
+	// lookup using default index
+	if index := c.Indexes["_id_"]; index != nil && index.wildcard == nil {
+		list := index.base.Lookup(query)
+		if len(list) > 0 {
+			return list[0]
+		}
+		return nil
+	}
+
+	// otherwise, scan documents
+	for _, doc := range c.Documents.List {
+		if c.collator.Compare(bsonkit.Get(doc, "_id"), id) == 0 {
+			return doc
+		}
+	}
+
+	return nil
+}
+
+// Find will look up the documents that match the specified query. If no
+// collation is provided, the collection default collation is used.
+func (c *Collection) Find(query, sort bsonkit.Doc, skip, limit int, collation bsonkit.Doc) (*Result, error) {
+	// get collator
+	collator, err := c.useCollator(collation)
+	if err != nil {
+		return nil, err
+	}
+
+	// get candidate documents
+	list := c.plan(query, collator)
 
 	// sort documents
-	var err error
 	if sort != nil && len(*sort) > 0 {
-		list, err = Sort(list, sort)
+		list, err = Sort(list, sort, collator)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	// filter documents
+	list, err = Filter(list, query, skipLimit(skip, limit), collator)
+	if err != nil {
+		return nil, err
+	}
+
 	// apply skip
 	if skip > len(list) {
 		list = nil
@@ -79,15 +407,53 @@ func (c *Collection) Find(query, sort bsonkit.Doc, skip, limit int) (*Result, er
 		list = list[skip:]
 	}
 
+	return &Result{
+		Matched: list,
+	}, nil
+}
+
+// Distinct will return the distinct values of the field in the documents that
+// match the query. If no collation is provided, the collection default
+// collation is used.
+func (c *Collection) Distinct(field string, query, collation bsonkit.Doc) (bson.A, error) {
+	// get collator
+	collator, err := c.useCollator(collation)
+	if err != nil {
+		return nil, err
+	}
+
 	// filter documents
-	list, err = Filter(list, query, limit)
+	list, err := Filter(c.plan(query, collator), query, 0, collator)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Result{
-		Matched: list,
-	}, nil
+	// collect distinct values
+	values := Distinct(list, field, collator)
+
+	return values, nil
+}
+
+// Aggregate will run the aggregation pipeline on the documents of the
+// collection. Additional stages may be provided to extend the default stages.
+// If no collation is provided, the collection default collation is used.
+func (c *Collection) Aggregate(pipeline bsonkit.List, stages map[string]StageOperator, collation bsonkit.Doc) (bsonkit.List, error) {
+	// get collator
+	collator, err := c.useCollator(collation)
+	if err != nil {
+		return nil, err
+	}
+
+	// run pipeline
+	list, err := Aggregate(c.Documents.List, pipeline, PipelineContext{
+		Collator: collator,
+		Stages:   stages,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
 }
 
 // Insert will add the specified document to the collection.
@@ -100,6 +466,11 @@ func (c *Collection) Insert(doc bsonkit.Doc) (*Result, error) {
 		}
 	}
 
+	// check clustered order
+	if c.config.Clustered && c.Get(bsonkit.Get(doc, "_id")) != nil {
+		return nil, fmt.Errorf("duplicate document for index %q", "_id_")
+	}
+
 	// add document to all indexes
 	for name, index := range c.Indexes {
 		ok, err := index.Add(doc)
@@ -111,7 +482,7 @@ func (c *Collection) Insert(doc bsonkit.Doc) (*Result, error) {
 	}
 
 	// add document
-	if !c.Documents.Add(doc) {
+	if !c.add(doc) {
 		return nil, fmt.Errorf("unable to add document to collection")
 	}
 
@@ -121,22 +492,28 @@ func (c *Collection) Insert(doc bsonkit.Doc) (*Result, error) {
 }
 
 // Replace will look up the first document that matches the query and if found
-// replace it with the specified document.
-func (c *Collection) Replace(query, repl, sort bsonkit.Doc) (*Result, error) {
-	// get documents
-	list := c.Documents.List
+// replace it with the specified document. If no collation is provided, the
+// collection default collation is used.
+func (c *Collection) Replace(query, repl, sort bsonkit.Doc, collation bsonkit.Doc) (*Result, error) {
+	// get collator
+	collator, err := c.useCollator(collation)
+	if err != nil {
+		return nil, err
+	}
+
+	// get candidate documents
+	list := c.plan(query, collator)
 
 	// sort documents
-	var err error
 	if sort != nil && len(*sort) > 0 {
-		list, err = Sort(list, sort)
+		list, err = Sort(list, sort, collator)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// filter documents
-	list, err = Filter(list, query, 1)
+	list, err = Filter(list, query, 1, collator)
 	if err != nil {
 		return nil, err
 	}
@@ -181,6 +558,10 @@ func (c *Collection) Replace(query, repl, sort bsonkit.Doc) (*Result, error) {
 		return nil, fmt.Errorf("unable to replace document in collection")
 	}
 
+	// update size and changes
+	c.resize(list[0], repl)
+	c.track(list[0], repl)
+
 	return &Result{
 		Matched:  list,
 		Modified: bsonkit.List{repl},
@@ -188,20 +569,32 @@ func (c *Collection) Replace(query, repl, sort bsonkit.Doc) (*Result, error) {
 }
 
 // Update will look up all documents that match the specified query and update
-// them according to the update document.
-func (c *Collection) Update(query, update, sort bsonkit.Doc, skip, limit int, arrayFilters bsonkit.List) (*Result, error) {
-	// get documents
-	list := c.Documents.List
+// them according to the update document. If no collation is provided, the
+// collection default collation is used.
+func (c *Collection) Update(query, update, sort bsonkit.Doc, skip, limit int, arrayFilters bsonkit.List, collation bsonkit.Doc) (*Result, error) {
+	// get collator
+	collator, err := c.useCollator(collation)
+	if err != nil {
+		return nil, err
+	}
+
+	// get candidate documents
+	list := c.plan(query, collator)
 
 	// sort documents
-	var err error
 	if sort != nil && len(*sort) > 0 {
-		list, err = Sort(list, sort)
+		list, err = Sort(list, sort, collator)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	// filter documents
+	list, err = Filter(list, query, skipLimit(skip, limit), collator)
+	if err != nil {
+		return nil, err
+	}
+
 	// apply skip
 	if skip > len(list) {
 		list = nil
@@ -209,12 +602,6 @@ func (c *Collection) Update(query, update, sort bsonkit.Doc, skip, limit int, ar
 		list = list[skip:]
 	}
 
-	// filter documents
-	list, err = Filter(list, query, limit)
-	if err != nil {
-		return nil, err
-	}
-
 	// check list
 	if len(list) == 0 {
 		return &Result{}, nil
@@ -265,6 +652,8 @@ func (c *Collection) Update(query, update, sort bsonkit.Doc, skip, limit int, ar
 		if !c.Documents.Replace(list[i], doc) {
 			return nil, fmt.Errorf("unable to replace document in collection")
 		}
+		c.resize(list[i], doc)
+		c.track(list[i], doc)
 	}
 
 	return &Result{
@@ -334,6 +723,11 @@ func (c *Collection) Upsert(query, repl, update bsonkit.Doc, arrayFilters bsonki
 		}
 	}
 
+	// check clustered order
+	if c.config.Clustered && c.Get(bsonkit.Get(doc, "_id")) != nil {
+		return nil, fmt.Errorf("duplicate document for index %q", "_id_")
+	}
+
 	// add document to indexes
 	for name, index := range c.Indexes {
 		ok, err := index.Add(doc)
@@ -345,7 +739,7 @@ func (c *Collection) Upsert(query, repl, update bsonkit.Doc, arrayFilters bsonki
 	}
 
 	// add document
-	if !c.Documents.Add(doc) {
+	if !c.add(doc) {
 		return nil, fmt.Errorf("unable to add document to collection")
 	}
 
@@ -354,20 +748,32 @@ func (c *Collection) Upsert(query, repl, update bsonkit.Doc, arrayFilters bsonki
 	}, nil
 }
 
-// Delete will remove all documents that match the specified query.
-func (c *Collection) Delete(query, sort bsonkit.Doc, skip, limit int) (*Result, error) {
-	// get documents
-	list := c.Documents.List
+// Delete will remove all documents that match the specified query. If no
+// collation is provided, the collection default collation is used.
+func (c *Collection) Delete(query, sort bsonkit.Doc, skip, limit int, collation bsonkit.Doc) (*Result, error) {
+	// get collator
+	collator, err := c.useCollator(collation)
+	if err != nil {
+		return nil, err
+	}
+
+	// get candidate documents
+	list := c.plan(query, collator)
 
 	// sort documents
-	var err error
 	if sort != nil && len(*sort) > 0 {
-		list, err = Sort(list, sort)
+		list, err = Sort(list, sort, collator)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	// filter documents
+	list, err = Filter(list, query, skipLimit(skip, limit), collator)
+	if err != nil {
+		return nil, err
+	}
+
 	// apply skip
 	if skip > len(list) {
 		list = nil
@@ -375,12 +781,6 @@ func (c *Collection) Delete(query, sort bsonkit.Doc, skip, limit int) (*Result,
 		list = list[skip:]
 	}
 
-	// filter documents
-	list, err = Filter(list, query, limit)
-	if err != nil {
-		return nil, err
-	}
-
 	// update indexes
 	for _, doc := range list {
 		for name, index := range c.Indexes {
@@ -398,6 +798,8 @@ func (c *Collection) Delete(query, sort bsonkit.Doc, skip, limit int) (*Result,
 		if !c.Documents.Remove(doc) {
 			return nil, fmt.Errorf("unable to remove document from collection")
 		}
+		c.resize(doc, nil)
+		c.track(doc, nil)
 	}
 
 	return &Result{
@@ -405,6 +807,31 @@ func (c *Collection) Delete(query, sort bsonkit.Doc, skip, limit int) (*Result,
 	}, nil
 }
 
+// Remove will remove the specified document from the collection and all
+// indexes.
+func (c *Collection) Remove(doc bsonkit.Doc) error {
+	// update indexes
+	for name, index := range c.Indexes {
+		ok, err := index.Remove(doc)
+		if err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("unable to remove document from index %q", name)
+		}
+	}
+
+	// remove document
+	if !c.Documents.Remove(doc) {
+		return fmt.Errorf("unable to remove document from collection")
+	}
+
+	// update size and changes
+	c.resize(doc, nil)
+	c.track(doc, nil)
+
+	return nil
+}
+
 // CreateIndex will create and build an index based on the specified
 // configuration. If the index name is missing, it will be generated from the
 // config and returned.
@@ -412,6 +839,11 @@ func (c *Collection) CreateIndex(name string, config IndexConfig) (string, error
 	// prepare error
 	var err error
 
+	// inherit default collation
+	if config.Collation == nil {
+		config.Collation = c.config.Collation
+	}
+
 	// compute name if missing
 	if name == "" {
 		name, err = config.Name()
@@ -420,6 +852,11 @@ func (c *Collection) CreateIndex(name string, config IndexConfig) (string, error
 		}
 	}
 
+	// check hidden id index
+	if config.Hidden && (name == "_id_" || bsonkit.Compare(*config.Key, bson.D{{Key: "_id", Value: int32(1)}}) == 0) {
+		return "", fmt.Errorf("cannot hide _id index")
+	}
+
 	// return if existing index is equal
 	if index, ok := c.Indexes[name]; ok {
 		if config.Equal(index.Config()) {
@@ -427,19 +864,19 @@ func (c *Collection) CreateIndex(name string, config IndexConfig) (string, error
 		}
 	}
 
-	// check duplicate
-	for name, index := range c.Indexes {
-		if bsonkit.Compare(*config.Key, *index.Config().Key) == 0 {
-			return "", fmt.Errorf("existing index %q has same key", name)
-		}
-	}
-
 	// create index
 	index, err := CreateIndex(config)
 	if err != nil {
 		return "", err
 	}
 
+	// check duplicate
+	for name, existing := range c.Indexes {
+		if bsonkit.Compare(*index.config.Key, *existing.config.Key) == 0 && equalDocs(index.config.Collation, existing.config.Collation) {
+			return "", fmt.Errorf("existing index %q has same key", name)
+		}
+	}
+
 	// add index
 	c.Indexes[name] = index
 
@@ -490,12 +927,167 @@ func (c *Collection) DropIndex(name string) ([]string, error) {
 	return dropped, nil
 }
 
+// HideIndex will hide or unhide the specified index from the query planner.
+func (c *Collection) HideIndex(name string, hidden bool) error {
+	// check existence
+	index, ok := c.Indexes[name]
+	if !ok {
+		return fmt.Errorf("missing index %q", name)
+	}
+
+	// check id index
+	if name == "_id_" && hidden {
+		return fmt.Errorf("cannot hide _id index")
+	}
+
+	// set hidden
+	index.Hide(hidden)
+
+	return nil
+}
+
+// ExpireIndex will change the expiry of the specified TTL index.
+func (c *Collection) ExpireIndex(name string, expiry time.Duration) error {
+	// check existence
+	index, ok := c.Indexes[name]
+	if !ok {
+		return fmt.Errorf("missing index %q", name)
+	}
+
+	// check expiry
+	if index.config.Expiry <= 0 {
+		return fmt.Errorf("no expireAfterSeconds field to update")
+	} else if expiry <= 0 {
+		return fmt.Errorf("expireAfterSeconds must be positive")
+	}
+
+	// set expiry
+	index.config.Expiry = expiry
+
+	return nil
+}
+
+// ConvertIndex will convert the specified index to a unique index. The index
+// is left unchanged if the existing documents violate the unique constraint.
+func (c *Collection) ConvertIndex(name string) error {
+	// check existence
+	index, ok := c.Indexes[name]
+	if !ok {
+		return fmt.Errorf("missing index %q", name)
+	}
+
+	// check unique
+	if index.config.Unique {
+		return nil
+	}
+
+	// create unique index
+	config := index.Config()
+	config.Unique = true
+	unique, err := CreateIndex(config)
+	if err != nil {
+		return err
+	}
+
+	// build index
+	ok, err = unique.Build(c.Documents.List)
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("duplicate document for index %q", name)
+	}
+
+	// replace index
+	c.Indexes[name] = unique
+
+	return nil
+}
+
+// Modify will update the validator, validation level, validation action and
+// collection expiry using the specified configuration. Other options cannot
+// be changed and are ignored.
+func (c *Collection) Modify(config CollectionConfig) error {
+	// prepare config
+	next := c.Config()
+	next.Validator = config.Validator
+	next.ValidationLevel = config.ValidationLevel
+	next.ValidationAction = config.ValidationAction
+	next.ExpireAfterSeconds = config.ExpireAfterSeconds
+
+	// check config
+	coll, err := CreateCollection(next, false)
+	if err != nil {
+		return err
+	}
+
+	// set config
+	c.config = coll.config
+
+	return nil
+}
+
+// Verify will check the consistency of the documents and indexes of the
+// collection and return the detected inconsistencies.
+func (c *Collection) Verify() (*Verification, error) {
+	// prepare verification
+	verification := &Verification{
+		Indexes: map[string][]string{},
+	}
+
+	// check set
+	err := c.Documents.Verify()
+	if err != nil {
+		verification.Errors = append(verification.Errors, err.Error())
+	}
+
+	// check ids and clustered order
+	var missing, unordered int
+	for i, doc := range c.Documents.List {
+		if bsonkit.Get(doc, "_id") == bsonkit.Missing {
+			missing++
+		} else if c.config.Clustered && i > 0 && bsonkit.Compare(bsonkit.Get(c.Documents.List[i-1], "_id"), bsonkit.Get(doc, "_id")) >= 0 {
+			unordered++
+		}
+	}
+	if missing > 0 {
+		verification.Errors = append(verification.Errors, fmt.Sprintf("%d documents are missing an _id", missing))
+	}
+	if unordered > 0 {
+		verification.Errors = append(verification.Errors, fmt.Sprintf("%d documents are not ordered by _id", unordered))
+	}
+
+	// check validator
+	if c.config.Validator != nil && c.config.ValidationLevel != "off" {
+		for _, doc := range c.Documents.List {
+			ok, err := Match(doc, c.config.Validator, c.collator)
+			if err != nil {
+				return nil, err
+			} else if !ok {
+				verification.NonCompliant++
+			}
+		}
+	}
+
+	// check indexes
+	for name, index := range c.Indexes {
+		problems, err := index.Verify(c.Documents)
+		if err != nil {
+			return nil, err
+		}
+		verification.Indexes[name] = problems
+	}
+
+	return verification, nil
+}
+
 // Clone will clone the collection.
 func (c *Collection) Clone() *Collection {
 	// create new collection
 	clone := &Collection{
 		Documents: c.Documents.Clone(),
 		Indexes:   map[string]*Index{},
+		config:    c.config,
+		collator:  c.collator,
 	}
 
 	// clone indexes
@@ -503,5 +1095,337 @@ func (c *Collection) Clone() *Collection {
 		clone.Indexes[name] = index.Clone()
 	}
 
+	// copy size
+	if c.sized == c.Documents {
+		clone.size = c.size
+		clone.sized = clone.Documents
+	}
+
+	// track changes against the collection or its origin
+	if c.origin == nil {
+		clone.origin = c
+	} else {
+		clone.origin = c.origin
+		clone.changes = append([]DocumentChange(nil), c.changes...)
+	}
+
 	return clone
 }
+
+// TrackedChanges will return the document changes applied since the collection
+// has been cloned from the specified collection. Clones track their changes
+// against the origin of the cloned collection or the cloned collection itself
+// if it has no origin. False is returned if the changes are not tracked against
+// the specified collection.
+func (c *Collection) TrackedChanges(origin *Collection) ([]DocumentChange, bool) {
+	// check origin
+	if origin == nil || c.origin != origin {
+		return nil, false
+	}
+
+	return c.changes, true
+}
+
+// ResetChanges will discard the tracked changes and the origin. Clones of the
+// collection will then track their changes against it. Collections without an
+// origin do not track their changes.
+func (c *Collection) ResetChanges() {
+	c.origin = nil
+	c.changes = nil
+}
+
+func (c *Collection) useCollator(collation bsonkit.Doc) (*bsonkit.Collator, error) {
+	// use default collator if missing
+	if collation == nil {
+		return c.collator, nil
+	}
+
+	return bsonkit.NewCollator(collation)
+}
+
+func (c *Collection) add(doc bsonkit.Doc) bool {
+	// insert at position if clustered
+	if c.config.Clustered {
+		if !c.Documents.Insert(doc, c.search(bsonkit.Get(doc, "_id"), true)) {
+			return false
+		}
+		c.track(nil, doc)
+		return true
+	}
+
+	// add document
+	if !c.Documents.Add(doc) {
+		return false
+	}
+
+	// update size and changes
+	c.resize(nil, doc)
+	c.track(nil, doc)
+
+	return true
+}
+
+func (c *Collection) track(removed, added bsonkit.Doc) {
+	// check origin
+	if c.origin == nil {
+		return
+	}
+
+	// add change
+	c.changes = append(c.changes, DocumentChange{
+		Removed: removed,
+		Added:   added,
+	})
+}
+
+func (c *Collection) resize(removed, added bsonkit.Doc) {
+	// check tracking
+	if !c.config.Capped || c.sized != c.Documents {
+		return
+	}
+
+	// update size, invalidate it on errors
+	for i, doc := range []bsonkit.Doc{removed, added} {
+		if doc == nil {
+			continue
+		}
+		size, err := docSize(doc)
+		if err != nil {
+			c.sized = nil
+			return
+		}
+		if i == 0 {
+			c.size -= size
+		} else {
+			c.size += size
+		}
+	}
+}
+
+func docSize(doc bsonkit.Doc) (int64, error) {
+	// marshal document
+	bytes, err := bson.Marshal(doc)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(bytes)), nil
+}
+
+func (c *Collection) search(id interface{}, after bool) int {
+	// find first document with an equal or greater, or only greater _id
+	list := c.Documents.List
+	return sort.Search(len(list), func(i int) bool {
+		res := bsonkit.Compare(bsonkit.Get(list[i], "_id"), id)
+		return res > 0 || res == 0 && !after
+	})
+}
+
+func (c *Collection) cluster(query bsonkit.Doc) (bsonkit.List, bool) {
+	// find _id condition
+	for _, exp := range *query {
+		// check key
+		if exp.Key != "_id" {
+			continue
+		}
+
+		// get operators, equality is treated as $eq
+		ops := bson.D{bson.E{Key: "$eq", Value: exp.Value}}
+		if d, ok := exp.Value.(bson.D); ok && len(d) > 0 && strings.HasPrefix(d[0].Key, "$") {
+			ops = d
+		}
+
+		// narrow range
+		lo, hi := 0, len(c.Documents.List)
+		var values bson.A
+		for _, op := range ops {
+			switch op.Key {
+			case "$eq", "$gt", "$gte", "$lt", "$lte":
+				// regular expressions are matched, not compared
+				if _, ok := op.Value.(primitive.Regex); ok {
+					return nil, false
+				}
+			}
+			switch op.Key {
+			case "$eq":
+				values = append(values, op.Value)
+			case "$gt", "$gte":
+				if i := c.search(op.Value, false); i > lo {
+					lo = i
+				}
+			case "$lt", "$lte":
+				if i := c.search(op.Value, true); i < hi {
+					hi = i
+				}
+			case "$in":
+				arr, ok := op.Value.(bson.A)
+				if !ok {
+					return nil, false
+				}
+				for _, value := range arr {
+					if _, ok := value.(primitive.Regex); ok {
+						return nil, false
+					}
+				}
+				values = append(values, arr...)
+			}
+		}
+
+		// check range
+		if lo >= hi {
+			return bsonkit.List{}, true
+		}
+
+		// return range if no values
+		if values == nil {
+			if lo == 0 && hi == len(c.Documents.List) {
+				return nil, false
+			}
+			return c.Documents.List[lo:hi], true
+		}
+
+		// collect value ranges in order
+		var ranges [][2]int
+		for _, value := range values {
+			start := c.search(value, false)
+			end := c.search(value, true)
+			if start < lo {
+				start = lo
+			}
+			if end > hi {
+				end = hi
+			}
+			if start < end {
+				ranges = append(ranges, [2]int{start, end})
+			}
+		}
+		sort.Slice(ranges, func(i, j int) bool {
+			return ranges[i][0] < ranges[j][0]
+		})
+
+		// collect documents
+		list := make(bsonkit.List, 0, len(ranges))
+		last := -1
+		for _, r := range ranges {
+			for i := r[0]; i < r[1]; i++ {
+				if i > last {
+					list = append(list, c.Documents.List[i])
+					last = i
+				}
+			}
+		}
+
+		return list, true
+	}
+
+	return nil, false
+}
+
+func (c *Collection) plan(query bsonkit.Doc, collator *bsonkit.Collator) bsonkit.List {
+	// use clustered order with the simple collation
+	if c.config.Clustered && collator == nil {
+		if list, ok := c.cluster(query); ok {
+			return list
+		}
+	}
+
+	// get sorted index names
+	names := make([]string, 0, len(c.Indexes))
+	for name := range c.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// find an index that can serve an equality condition
+	for _, exp := range *query {
+		// skip top level operators
+		if strings.HasPrefix(exp.Key, "$") {
+			continue
+		}
+
+		// get values
+		values := equalityValues(exp.Value)
+		if values == nil {
+			continue
+		}
+
+		// lookup values
+		for _, name := range names {
+			var list bsonkit.List
+			var ok bool
+			for _, value := range values {
+				var docs bsonkit.List
+				docs, ok = c.Indexes[name].Lookup(exp.Key, value, collator)
+				if !ok {
+					break
+				}
+				list = append(list, docs...)
+			}
+			if !ok {
+				continue
+			}
+
+			// count access
+			c.Indexes[name].access()
+
+			// restore natural order and remove duplicates
+			sort.Slice(list, func(i, j int) bool {
+				return c.Documents.Index[list[i]] < c.Documents.Index[list[j]]
+			})
+			result := make(bsonkit.List, 0, len(list))
+			for i, doc := range list {
+				if i == 0 || list[i-1] != doc {
+					result = append(result, doc)
+				}
+			}
+
+			return result
+		}
+	}
+
+	// otherwise, count the access of the first regular index that serves a
+	// condition, the documents are scanned as regular indexes do not index the
+	// elements of array values
+	for _, exp := range *query {
+		// skip top level operators
+		if strings.HasPrefix(exp.Key, "$") {
+			continue
+		}
+
+		// find index
+		equality := equalityValues(exp.Value) != nil
+		for _, name := range names {
+			if c.Indexes[name].serves(exp.Key, equality, collator) {
+				c.Indexes[name].access()
+				return c.Documents.List
+			}
+		}
+	}
+
+	return c.Documents.List
+}
+
+func equalityValues(value interface{}) bson.A {
+	// check operators
+	if doc, ok := value.(bson.D); ok && len(doc) > 0 && strings.HasPrefix(doc[0].Key, "$") {
+		for _, op := range doc {
+			if op.Key == "$eq" {
+				return bson.A{op.Value}
+			} else if arr, ok := op.Value.(bson.A); ok && op.Key == "$in" {
+				return arr
+			}
+		}
+		return nil
+	}
+
+	return bson.A{value}
+}
+
+func skipLimit(skip, limit int) int {
+	// extend limit by skip
+	if limit > 0 {
+		return skip + limit
+	}
+
+	return 0
+}
@@ -0,0 +1,226 @@
+package mongokit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+func TestCollectionEvict(t *testing.T) {
+	coll, err := CreateCollection(CollectionConfig{
+		Capped:       true,
+		SizeInBytes:  4096,
+		MaxDocuments: 10,
+	}, true)
+	assert.NoError(t, err)
+
+	data := strings.Repeat("x", 1000)
+	for i := 0; i < 5; i++ {
+		_, err = coll.Insert(bsonkit.MustConvert(bson.M{"_id": i, "data": data}))
+		assert.NoError(t, err)
+	}
+
+	evicted, err := coll.Evict()
+	assert.NoError(t, err)
+	assert.Len(t, evicted, 2)
+	assert.Len(t, coll.Documents.List, 3)
+	assert.Equal(t, int64(2), bsonkit.Get(coll.Documents.List[0], "_id"))
+
+	/* replace */
+
+	_, err = coll.Replace(bsonkit.MustConvert(bson.M{"_id": 2}), bsonkit.MustConvert(bson.M{"_id": 2}), nil, nil)
+	assert.NoError(t, err)
+
+	_, err = coll.Insert(bsonkit.MustConvert(bson.M{"_id": 5, "data": data}))
+	assert.NoError(t, err)
+
+	evicted, err = coll.Evict()
+	assert.NoError(t, err)
+	assert.Empty(t, evicted)
+	assert.Len(t, coll.Documents.List, 4)
+
+	/* clone */
+
+	clone := coll.Clone()
+	_, err = clone.Insert(bsonkit.MustConvert(bson.M{"_id": 6, "data": data}))
+	assert.NoError(t, err)
+
+	evicted, err = clone.Evict()
+	assert.NoError(t, err)
+	assert.Len(t, evicted, 2)
+	assert.Len(t, clone.Documents.List, 3)
+	assert.Len(t, coll.Documents.List, 4)
+
+	/* documents */
+
+	coll.Documents = bsonkit.NewSet(coll.Documents.List[1:])
+
+	_, err = coll.Insert(bsonkit.MustConvert(bson.M{"_id": 7, "data": data}))
+	assert.NoError(t, err)
+
+	evicted, err = coll.Evict()
+	assert.NoError(t, err)
+	assert.Len(t, evicted, 1)
+	assert.Len(t, coll.Documents.List, 3)
+
+	/* count */
+
+	coll, err = CreateCollection(CollectionConfig{
+		Capped:       true,
+		SizeInBytes:  4096,
+		MaxDocuments: 2,
+	}, true)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = coll.Insert(bsonkit.MustConvert(bson.M{"_id": i}))
+		assert.NoError(t, err)
+	}
+
+	evicted, err = coll.Evict()
+	assert.NoError(t, err)
+	assert.Len(t, evicted, 1)
+	assert.Len(t, coll.Documents.List, 2)
+}
+
+func TestCollectionTrackedChanges(t *testing.T) {
+	coll := NewCollection(true)
+
+	a := bsonkit.MustConvert(bson.M{"_id": "a"})
+	_, err := coll.Insert(a)
+	assert.NoError(t, err)
+
+	changes, ok := coll.TrackedChanges(nil)
+	assert.False(t, ok)
+	assert.Empty(t, changes)
+
+	clone1 := coll.Clone()
+	b := bsonkit.MustConvert(bson.M{"_id": "b"})
+	_, err = clone1.Insert(b)
+	assert.NoError(t, err)
+
+	clone2 := clone1.Clone()
+	res, err := clone2.Update(bsonkit.MustConvert(bson.M{"_id": "a"}), bsonkit.MustConvert(bson.M{
+		"$set": bson.M{"n": int32(1)},
+	}), nil, 0, 0, nil, nil)
+	assert.NoError(t, err)
+	err = clone2.Remove(b)
+	assert.NoError(t, err)
+
+	changes, ok = clone2.TrackedChanges(coll)
+	assert.True(t, ok)
+	assert.Equal(t, []DocumentChange{
+		{Added: b},
+		{Removed: a, Added: res.Modified[0]},
+		{Removed: b},
+	}, changes)
+
+	changes, ok = clone1.TrackedChanges(coll)
+	assert.True(t, ok)
+	assert.Equal(t, []DocumentChange{
+		{Added: b},
+	}, changes)
+
+	_, ok = clone2.TrackedChanges(clone1)
+	assert.False(t, ok)
+
+	clone2.ResetChanges()
+	clone3 := clone2.Clone()
+	_, ok = clone3.TrackedChanges(clone2)
+	assert.True(t, ok)
+	_, ok = clone3.TrackedChanges(coll)
+	assert.False(t, ok)
+}
+
+func TestCollectionIndexAccesses(t *testing.T) {
+	coll := NewCollection(true)
+
+	_, err := coll.CreateIndex("a_1", IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{"a": int32(1)}),
+	})
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = coll.Insert(bsonkit.MustConvert(bson.M{"_id": i, "a": i}))
+		assert.NoError(t, err)
+	}
+
+	ops := func(name string) int64 {
+		ops, _ := coll.Indexes[name].Accesses()
+		return ops
+	}
+
+	res, err := coll.Find(bsonkit.MustConvert(bson.M{"a": 1}), nil, 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Len(t, res.Matched, 1)
+	assert.Equal(t, int64(1), ops("a_1"))
+
+	_, err = coll.Find(bsonkit.MustConvert(bson.M{"a": bson.M{"$gt": 0}}), nil, 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), ops("a_1"))
+
+	_, err = coll.Update(bsonkit.MustConvert(bson.M{"a": 2}), bsonkit.MustConvert(bson.M{
+		"$set": bson.M{"b": true},
+	}), nil, 0, 0, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), ops("a_1"))
+
+	_, err = coll.Delete(bsonkit.MustConvert(bson.M{"a": 0}), nil, 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), ops("a_1"))
+
+	_, err = coll.Find(bsonkit.MustConvert(bson.M{"b": true}), nil, 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), ops("a_1"))
+	assert.Equal(t, int64(0), ops("_id_"))
+
+	_, err = coll.Find(bsonkit.MustConvert(bson.M{"_id": 1}), nil, 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), ops("_id_"))
+
+	/* hidden */
+
+	coll.Indexes["a_1"].Hide(true)
+
+	_, err = coll.Find(bsonkit.MustConvert(bson.M{"a": 1}), nil, 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), ops("a_1"))
+}
+
+func TestCollectionSkip(t *testing.T) {
+	coll := NewCollection(true)
+
+	for i := 0; i < 6; i++ {
+		_, err := coll.Insert(bsonkit.MustConvert(bson.M{"_id": i, "a": i % 2}))
+		assert.NoError(t, err)
+	}
+
+	ids := func(list bsonkit.List) []int64 {
+		var ids []int64
+		for _, doc := range list {
+			ids = append(ids, bsonkit.Get(doc, "_id").(int64))
+		}
+		return ids
+	}
+
+	res, err := coll.Find(bsonkit.MustConvert(bson.M{"a": 1}), nil, 1, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 5}, ids(res.Matched))
+
+	res, err = coll.Find(bsonkit.MustConvert(bson.M{"a": 1}), nil, 1, 1, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3}, ids(res.Matched))
+
+	res, err = coll.Find(bsonkit.MustConvert(bson.M{"a": 1}), nil, 3, 0, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, res.Matched)
+
+	res, err = coll.Delete(bsonkit.MustConvert(bson.M{"a": 0}), nil, 2, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{4}, ids(res.Matched))
+	assert.Len(t, coll.Documents.List, 5)
+}
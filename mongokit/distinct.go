@@ -1,13 +1,43 @@
 package mongokit
 
 import (
+	"sort"
+
 	"go.mongodb.org/mongo-driver/bson"
 
 	"github.com/256dpi/lungo/bsonkit"
 )
 
 // Distinct will perform a MongoDB distinct value search on the list of documents
-// and return an array with the results.
-func Distinct(list bsonkit.List, path string) bson.A {
-	return bsonkit.Collect(list, path, true, true, true, true)
+// and return an array with the results. An optional collator may be provided
+// to compare strings.
+func Distinct(list bsonkit.List, path string, collator *bsonkit.Collator) bson.A {
+	// collect values without a collator
+	if collator == nil {
+		return bsonkit.Collect(list, path, true, true, true, true)
+	}
+
+	// collect values
+	result := bsonkit.Collect(list, path, true, true, true, false)
+
+	// sort results
+	sort.SliceStable(result, func(i, j int) bool {
+		return collator.Compare(result[i], result[j]) < 0
+	})
+
+	// prepare distincts
+	distincts := make(bson.A, 0, len(result))
+
+	// add distinct values
+	for _, value := range result {
+		// check if same as previous value
+		if len(distincts) > 0 && collator.Compare(distincts[len(distincts)-1], value) == 0 {
+			continue
+		}
+
+		// add value
+		distincts = append(distincts, value)
+	}
+
+	return distincts
 }
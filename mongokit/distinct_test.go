@@ -34,7 +34,7 @@ func distinctTest(t *testing.T, list bsonkit.List, fn func(fn func(string, bson.
 
 	t.Run("Lungo", func(t *testing.T) {
 		fn(func(path string, result bson.A) {
-			values := Distinct(list, path)
+			values := Distinct(list, path, nil)
 			assert.Equal(t, result, values)
 		})
 	})
@@ -0,0 +1,424 @@
+package mongokit
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+// https://www.mongodb.com/docs/manual/core/schema-validation/#view-the-validation-failure-details
+
+// the failure reasons reported for expression query operators
+var explainReasons = map[string]string{
+	"$eq":   "comparison failed",
+	"$ne":   "comparison failed",
+	"$gt":   "comparison failed",
+	"$gte":  "comparison failed",
+	"$lt":   "comparison failed",
+	"$lte":  "comparison failed",
+	"$in":   "no matching value found in array",
+	"$nin":  "matching value found in array",
+	"$type": "type did not match",
+	"$all":  "array did not contain all specified values",
+	"$size": "array length was not equal to given size",
+}
+
+// the failure reasons reported for JSON schema keywords
+var explainSchemaReasons = map[string]string{
+	"type":          "type did not match",
+	"bsonType":      "type did not match",
+	"enum":          "value was not found in enum",
+	"minimum":       "comparison failed",
+	"maximum":       "comparison failed",
+	"multipleOf":    "considered value was not a multiple",
+	"minLength":     "specified string length was not satisfied",
+	"maxLength":     "specified string length was not satisfied",
+	"pattern":       "regular expression did not match",
+	"minItems":      "array did not match specified length",
+	"maxItems":      "array did not match specified length",
+	"uniqueItems":   "found a duplicate item",
+	"minProperties": "specified number of properties was not satisfied",
+	"maxProperties": "specified number of properties was not satisfied",
+}
+
+// Explain will explain why the specified document does not match the supplied
+// MongoDB query document. It returns nil if the document matches. Otherwise,
+// the returned details are formatted like the details of MongoDB document
+// validation errors. An optional collator may be provided to compare strings.
+func Explain(doc, query bsonkit.Doc, collator *bsonkit.Collator) (bson.D, error) {
+	// match document
+	ok, err := Match(doc, query, collator)
+	if err != nil {
+		return nil, err
+	} else if ok {
+		return nil, nil
+	}
+
+	return explainQuery(doc, *query, collator)
+}
+
+func explainQuery(doc bsonkit.Doc, query bson.D, collator *bsonkit.Collator) (bson.D, error) {
+	// collect failed clauses
+	var failed bson.A
+	var last bson.D
+	for i, exp := range query {
+		// match clause
+		ok, err := Match(doc, &bson.D{exp}, collator)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			continue
+		}
+
+		// explain clause
+		details, err := explainClause(doc, exp, collator)
+		if err != nil {
+			return nil, err
+		}
+
+		// add clause
+		failed = append(failed, bson.D{
+			bson.E{Key: "index", Value: int32(i)},
+			bson.E{Key: "details", Value: details},
+		})
+		last = details
+	}
+
+	// return single clause directly
+	if len(query) == 1 {
+		return last, nil
+	}
+
+	return bson.D{
+		bson.E{Key: "operatorName", Value: "$and"},
+		bson.E{Key: "clausesNotSatisfied", Value: failed},
+	}, nil
+}
+
+func explainClause(doc bsonkit.Doc, exp bson.E, collator *bsonkit.Collator) (bson.D, error) {
+	// handle top level operators
+	switch exp.Key {
+	case "$and", "$or", "$nor":
+		// get clauses
+		list, _ := exp.Value.(bson.A)
+
+		// collect failed or, for $nor, matched clauses
+		var failed bson.A
+		for i, item := range list {
+			// get query
+			query, ok := item.(bson.D)
+			if !ok {
+				continue
+			}
+
+			// match query
+			ok, err := Match(doc, &query, collator)
+			if err != nil {
+				return nil, err
+			}
+
+			// check result
+			if exp.Key == "$nor" {
+				if ok {
+					failed = append(failed, bson.D{
+						bson.E{Key: "index", Value: int32(i)},
+						bson.E{Key: "details", Value: bson.D{
+							bson.E{Key: "operatorName", Value: "$nor"},
+							bson.E{Key: "specifiedAs", Value: query},
+							bson.E{Key: "reason", Value: "clause matched"},
+						}},
+					})
+				}
+				continue
+			} else if ok {
+				continue
+			}
+
+			// explain query
+			details, err := explainQuery(doc, query, collator)
+			if err != nil {
+				return nil, err
+			}
+
+			// add clause
+			failed = append(failed, bson.D{
+				bson.E{Key: "index", Value: int32(i)},
+				bson.E{Key: "details", Value: details},
+			})
+		}
+
+		return bson.D{
+			bson.E{Key: "operatorName", Value: exp.Key},
+			bson.E{Key: "clausesNotSatisfied", Value: failed},
+		}, nil
+	case "$jsonSchema":
+		// get schema
+		schema, _ := exp.Value.(bson.D)
+
+		// explain schema
+		rules, err := explainSchema(schema, *doc)
+		if err != nil {
+			return nil, err
+		}
+
+		return bson.D{
+			bson.E{Key: "operatorName", Value: "$jsonSchema"},
+			bson.E{Key: "schemaRulesNotSatisfied", Value: rules},
+		}, nil
+	}
+
+	// handle other top level operators
+	if strings.HasPrefix(exp.Key, "$") {
+		return bson.D{
+			bson.E{Key: "operatorName", Value: exp.Key},
+			bson.E{Key: "specifiedAs", Value: bson.D{exp}},
+			bson.E{Key: "reason", Value: "expression did not match"},
+		}, nil
+	}
+
+	// get operators, equality is treated as $eq
+	ops := bson.D{bson.E{Key: "$eq", Value: exp.Value}}
+	if d, ok := exp.Value.(bson.D); ok && len(d) > 0 && strings.HasPrefix(d[0].Key, "$") {
+		ops = d
+	}
+
+	// get value
+	value := bsonkit.Get(doc, exp.Key)
+
+	// collect failed operators
+	var failed []bson.D
+	var indexes []int
+	for i, op := range ops {
+		// prepare clause
+		clause := bson.D{bson.E{Key: exp.Key, Value: bson.D{op}}}
+		if op.Key == "$eq" && len(ops) == 1 {
+			clause = bson.D{exp}
+		}
+
+		// match clause
+		ok, err := Match(doc, &clause, collator)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			continue
+		}
+
+		// prepare details
+		details := bson.D{
+			bson.E{Key: "operatorName", Value: op.Key},
+			bson.E{Key: "specifiedAs", Value: clause},
+		}
+
+		// add reason and considered value
+		if value == bsonkit.Missing {
+			if op.Key == "$exists" {
+				details = append(details, bson.E{Key: "reason", Value: "path does not exist"})
+			} else {
+				details = append(details, bson.E{Key: "reason", Value: "field was missing"})
+			}
+		} else {
+			// get reason
+			reason := explainReasons[op.Key]
+			if op.Key == "$exists" {
+				reason = "path does exist"
+			} else if reason == "" {
+				reason = "expression did not match"
+			}
+
+			// add reason and value
+			details = append(details, bson.E{Key: "reason", Value: reason})
+			details = append(details, bson.E{Key: "consideredValue", Value: value})
+			if op.Key == "$type" {
+				details = append(details, bson.E{Key: "consideredType", Value: typeAlias(value)})
+			}
+		}
+
+		failed = append(failed, details)
+		indexes = append(indexes, i)
+	}
+
+	// return single operator directly
+	if len(failed) == 1 {
+		return failed[0], nil
+	}
+
+	// otherwise, wrap in $and
+	clauses := make(bson.A, 0, len(failed))
+	for i, details := range failed {
+		clauses = append(clauses, bson.D{
+			bson.E{Key: "index", Value: int32(indexes[i])},
+			bson.E{Key: "details", Value: details},
+		})
+	}
+
+	return bson.D{
+		bson.E{Key: "operatorName", Value: "$and"},
+		bson.E{Key: "clausesNotSatisfied", Value: clauses},
+	}, nil
+}
+
+func explainSchema(schema bson.D, value interface{}) (bson.A, error) {
+	// get document
+	doc, isDoc := value.(bson.D)
+
+	// collect failed rules
+	rules := bson.A{}
+	for _, keyword := range schema {
+		switch keyword.Key {
+		case "exclusiveMinimum", "exclusiveMaximum", "patternProperties":
+			// evaluated with minimum, maximum and additionalProperties
+		case "properties":
+			// check document
+			if !isDoc {
+				continue
+			}
+
+			// collect failed properties
+			var failed bson.A
+			properties, _ := keyword.Value.(bson.D)
+			for _, property := range properties {
+				// get value
+				val := bsonkit.Get(&doc, property.Key)
+				if val == bsonkit.Missing {
+					continue
+				}
+
+				// evaluate schema
+				sub, _ := property.Value.(bson.D)
+				err := bsonkit.NewSchema(sub).Evaluate(val)
+				if err == bsonkit.ErrValidationFailed {
+					details, err := explainSchema(sub, val)
+					if err != nil {
+						return nil, err
+					}
+					failed = append(failed, bson.D{
+						bson.E{Key: "propertyName", Value: property.Key},
+						bson.E{Key: "details", Value: details},
+					})
+				} else if err != nil {
+					return nil, err
+				}
+			}
+
+			// add rule
+			if len(failed) > 0 {
+				rules = append(rules, bson.D{
+					bson.E{Key: "operatorName", Value: "properties"},
+					bson.E{Key: "propertiesNotSatisfied", Value: failed},
+				})
+			}
+		case "required":
+			// check document
+			if !isDoc {
+				continue
+			}
+
+			// collect missing properties
+			var missing bson.A
+			list, _ := keyword.Value.(bson.A)
+			for _, item := range list {
+				name, _ := item.(string)
+				if bsonkit.Get(&doc, name) == bsonkit.Missing {
+					missing = append(missing, name)
+				}
+			}
+
+			// add rule
+			if len(missing) > 0 {
+				rules = append(rules, bson.D{
+					bson.E{Key: "operatorName", Value: "required"},
+					bson.E{Key: "specifiedAs", Value: bson.D{keyword}},
+					bson.E{Key: "missingProperties", Value: missing},
+				})
+			}
+		case "additionalProperties":
+			// check document
+			if !isDoc {
+				continue
+			}
+
+			// prepare schema that only checks the additional properties
+			check := bson.D{keyword}
+			for _, name := range []string{"properties", "patternProperties"} {
+				if list, ok := bsonkit.Get(&schema, name).(bson.D); ok {
+					empty := bson.D{}
+					for _, item := range list {
+						empty = append(empty, bson.E{Key: item.Key, Value: bson.D{}})
+					}
+					check = append(check, bson.E{Key: name, Value: empty})
+				}
+			}
+
+			// evaluate schema
+			err := bsonkit.NewSchema(check).Evaluate(value)
+			if err == nil {
+				continue
+			} else if err != bsonkit.ErrValidationFailed {
+				return nil, err
+			}
+
+			// collect additional properties
+			var additional bson.A
+			for _, member := range doc {
+				err = bsonkit.NewSchema(check).Evaluate(bson.D{member})
+				if err == bsonkit.ErrValidationFailed {
+					additional = append(additional, member.Key)
+				} else if err != nil {
+					return nil, err
+				}
+			}
+
+			// add rule
+			rules = append(rules, bson.D{
+				bson.E{Key: "operatorName", Value: "additionalProperties"},
+				bson.E{Key: "specifiedAs", Value: bson.D{keyword}},
+				bson.E{Key: "additionalProperties", Value: additional},
+			})
+		default:
+			// prepare sub schema
+			sub := bson.D{keyword}
+			if keyword.Key == "minimum" || keyword.Key == "maximum" {
+				name := "exclusive" + strings.ToUpper(keyword.Key[:1]) + keyword.Key[1:]
+				if val := bsonkit.Get(&schema, name); val != bsonkit.Missing {
+					sub = append(sub, bson.E{Key: name, Value: val})
+				}
+			}
+
+			// evaluate sub schema
+			err := bsonkit.NewSchema(sub).Evaluate(value)
+			if err == nil {
+				continue
+			} else if err != bsonkit.ErrValidationFailed {
+				return nil, err
+			}
+
+			// get reason
+			reason := explainSchemaReasons[keyword.Key]
+			if reason == "" {
+				reason = "value did not match"
+			}
+
+			// prepare rule
+			rule := bson.D{
+				bson.E{Key: "operatorName", Value: keyword.Key},
+				bson.E{Key: "specifiedAs", Value: sub},
+				bson.E{Key: "reason", Value: reason},
+				bson.E{Key: "consideredValue", Value: value},
+			}
+			if keyword.Key == "type" || keyword.Key == "bsonType" {
+				rule = append(rule, bson.E{Key: "consideredType", Value: typeAlias(value)})
+			}
+
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+func typeAlias(v interface{}) string {
+	_, typ := bsonkit.Inspect(v)
+	return bsonkit.Type2Alias[typ]
+}
@@ -0,0 +1,149 @@
+package mongokit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+func TestExplain(t *testing.T) {
+	doc := bsonkit.MustConvert(bson.M{
+		"name": "foo",
+		"age":  int32(17),
+		"tag":  "x",
+	})
+
+	/* matched */
+
+	details, err := Explain(doc, bsonkit.MustConvert(bson.M{
+		"name": "foo",
+	}), nil)
+	assert.NoError(t, err)
+	assert.Nil(t, details)
+
+	/* single clause */
+
+	details, err = Explain(doc, bsonkit.MustConvert(bson.M{
+		"age": bson.M{"$gte": int32(18)},
+	}), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "operatorName", Value: "$gte"},
+		{Key: "specifiedAs", Value: bson.D{
+			{Key: "age", Value: bson.D{{Key: "$gte", Value: int32(18)}}},
+		}},
+		{Key: "reason", Value: "comparison failed"},
+		{Key: "consideredValue", Value: int32(17)},
+	}, details)
+
+	/* multiple clauses */
+
+	details, err = Explain(doc, bsonkit.MustConvert(bson.D{
+		{Key: "name", Value: "foo"},
+		{Key: "email", Value: bson.M{"$exists": true}},
+		{Key: "age", Value: bson.M{"$type": "string"}},
+	}), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "operatorName", Value: "$and"},
+		{Key: "clausesNotSatisfied", Value: bson.A{
+			bson.D{
+				{Key: "index", Value: int32(1)},
+				{Key: "details", Value: bson.D{
+					{Key: "operatorName", Value: "$exists"},
+					{Key: "specifiedAs", Value: bson.D{
+						{Key: "email", Value: bson.D{{Key: "$exists", Value: true}}},
+					}},
+					{Key: "reason", Value: "path does not exist"},
+				}},
+			},
+			bson.D{
+				{Key: "index", Value: int32(2)},
+				{Key: "details", Value: bson.D{
+					{Key: "operatorName", Value: "$type"},
+					{Key: "specifiedAs", Value: bson.D{
+						{Key: "age", Value: bson.D{{Key: "$type", Value: "string"}}},
+					}},
+					{Key: "reason", Value: "type did not match"},
+					{Key: "consideredValue", Value: int32(17)},
+					{Key: "consideredType", Value: "int"},
+				}},
+			},
+		}},
+	}, details)
+
+	/* or */
+
+	details, err = Explain(doc, bsonkit.MustConvert(bson.M{
+		"$or": bson.A{
+			bson.M{"name": "bar"},
+		},
+	}), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "operatorName", Value: "$or"},
+		{Key: "clausesNotSatisfied", Value: bson.A{
+			bson.D{
+				{Key: "index", Value: int32(0)},
+				{Key: "details", Value: bson.D{
+					{Key: "operatorName", Value: "$eq"},
+					{Key: "specifiedAs", Value: bson.D{
+						{Key: "name", Value: "bar"},
+					}},
+					{Key: "reason", Value: "comparison failed"},
+					{Key: "consideredValue", Value: "foo"},
+				}},
+			},
+		}},
+	}, details)
+
+	/* json schema */
+
+	details, err = Explain(doc, bsonkit.MustConvert(bson.M{
+		"$jsonSchema": bson.D{
+			{Key: "required", Value: bson.A{"name", "email"}},
+			{Key: "properties", Value: bson.D{
+				{Key: "name", Value: bson.M{"bsonType": "string"}},
+				{Key: "age", Value: bson.M{"minimum": int32(18)}},
+			}},
+			{Key: "additionalProperties", Value: false},
+		},
+	}), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "operatorName", Value: "$jsonSchema"},
+		{Key: "schemaRulesNotSatisfied", Value: bson.A{
+			bson.D{
+				{Key: "operatorName", Value: "required"},
+				{Key: "specifiedAs", Value: bson.D{
+					{Key: "required", Value: bson.A{"name", "email"}},
+				}},
+				{Key: "missingProperties", Value: bson.A{"email"}},
+			},
+			bson.D{
+				{Key: "operatorName", Value: "properties"},
+				{Key: "propertiesNotSatisfied", Value: bson.A{
+					bson.D{
+						{Key: "propertyName", Value: "age"},
+						{Key: "details", Value: bson.A{
+							bson.D{
+								{Key: "operatorName", Value: "minimum"},
+								{Key: "specifiedAs", Value: bson.D{{Key: "minimum", Value: int32(18)}}},
+								{Key: "reason", Value: "comparison failed"},
+								{Key: "consideredValue", Value: int32(17)},
+							},
+						}},
+					},
+				}},
+			},
+			bson.D{
+				{Key: "operatorName", Value: "additionalProperties"},
+				{Key: "specifiedAs", Value: bson.D{{Key: "additionalProperties", Value: false}}},
+				{Key: "additionalProperties", Value: bson.A{"tag"}},
+			},
+		}},
+	}, details)
+}
@@ -0,0 +1,716 @@
+package mongokit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+// https://www.mongodb.com/docs/manual/reference/operator/aggregation/
+
+// ExpressionOperator is an aggregation expression operator.
+type ExpressionOperator func(doc bsonkit.Doc, op string, v interface{}) (interface{}, error)
+
+// AggregationExpressionOperators defines the aggregation expression operators.
+var AggregationExpressionOperators = map[string]ExpressionOperator{}
+
+func init() {
+	// register literal operator
+	AggregationExpressionOperators["$literal"] = evaluateLiteral
+
+	// register arithmetic operators
+	AggregationExpressionOperators["$add"] = evaluateAdd
+	AggregationExpressionOperators["$subtract"] = evaluateSubtract
+	AggregationExpressionOperators["$multiply"] = evaluateMultiply
+	AggregationExpressionOperators["$divide"] = evaluateDivide
+	AggregationExpressionOperators["$mod"] = evaluateMod
+
+	// register string operators
+	AggregationExpressionOperators["$concat"] = evaluateConcat
+	AggregationExpressionOperators["$toUpper"] = evaluateCase
+	AggregationExpressionOperators["$toLower"] = evaluateCase
+
+	// register comparison operators
+	AggregationExpressionOperators["$cmp"] = evaluateComp
+	AggregationExpressionOperators["$eq"] = evaluateComp
+	AggregationExpressionOperators["$ne"] = evaluateComp
+	AggregationExpressionOperators["$gt"] = evaluateComp
+	AggregationExpressionOperators["$gte"] = evaluateComp
+	AggregationExpressionOperators["$lt"] = evaluateComp
+	AggregationExpressionOperators["$lte"] = evaluateComp
+
+	// register boolean operators
+	AggregationExpressionOperators["$and"] = evaluateAnd
+	AggregationExpressionOperators["$or"] = evaluateOr
+	AggregationExpressionOperators["$not"] = evaluateNot
+
+	// register conditional operators
+	AggregationExpressionOperators["$cond"] = evaluateCond
+	AggregationExpressionOperators["$ifNull"] = evaluateIfNull
+
+	// register array operators
+	AggregationExpressionOperators["$size"] = evaluateSize
+	AggregationExpressionOperators["$arrayElemAt"] = evaluateArrayElemAt
+	AggregationExpressionOperators["$concatArrays"] = evaluateConcatArrays
+	AggregationExpressionOperators["$in"] = evaluateIn
+
+	// register type operators
+	AggregationExpressionOperators["$type"] = evaluateType
+}
+
+// Evaluate will evaluate the specified aggregation expression against the
+// document. Field paths (e.g. "$foo.bar") are resolved from the document,
+// documents with a single operator key are evaluated using the registered
+// operators, other documents and arrays are evaluated recursively and all
+// remaining values are returned as is. The result may be bsonkit.Missing if
+// the expression resolves to a missing field.
+func Evaluate(doc bsonkit.Doc, expr interface{}) (interface{}, error) {
+	switch value := expr.(type) {
+	case string:
+		// handle variables
+		if strings.HasPrefix(value, "$$") {
+			return evaluateVariable(doc, value[2:])
+		}
+
+		// handle field paths
+		if strings.HasPrefix(value, "$") {
+			if len(value) == 1 {
+				return nil, fmt.Errorf("invalid field path %q", value)
+			}
+			res, _ := bsonkit.All(doc, value[1:], true, false)
+			return res, nil
+		}
+
+		return value, nil
+	case bson.D:
+		// handle operators
+		if len(value) > 0 && strings.HasPrefix(value[0].Key, "$") {
+			// check length
+			if len(value) > 1 {
+				return nil, fmt.Errorf("an expression specification must contain exactly one field")
+			}
+
+			// lookup operator
+			operator := AggregationExpressionOperators[value[0].Key]
+			if operator == nil {
+				return nil, fmt.Errorf("unknown aggregation expression operator %q", value[0].Key)
+			}
+
+			return operator(doc, value[0].Key, value[0].Value)
+		}
+
+		// evaluate document
+		res := make(bson.D, 0, len(value))
+		for _, el := range value {
+			val, err := Evaluate(doc, el.Value)
+			if err != nil {
+				return nil, err
+			}
+			if val != bsonkit.Missing {
+				res = append(res, bson.E{Key: el.Key, Value: val})
+			}
+		}
+
+		return res, nil
+	case bson.A:
+		// evaluate array
+		res := make(bson.A, 0, len(value))
+		for _, item := range value {
+			val, err := Evaluate(doc, item)
+			if err != nil {
+				return nil, err
+			}
+			if val == bsonkit.Missing {
+				val = nil
+			}
+			res = append(res, val)
+		}
+
+		return res, nil
+	default:
+		return expr, nil
+	}
+}
+
+// truthy returns whether the provided value is considered true by the
+// aggregation expression operators.
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil, bsonkit.MissingType, primitive.Null, primitive.Undefined:
+		return false
+	case bool:
+		return v
+	case int32, int64, float64, primitive.Decimal128:
+		return bsonkit.Compare(v, int64(0)) != 0
+	default:
+		return true
+	}
+}
+
+func evaluateVariable(doc bsonkit.Doc, name string) (interface{}, error) {
+	// split path
+	path := ""
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name, path = name[:i], name[i+1:]
+	}
+
+	// get value
+	switch name {
+	case "ROOT", "CURRENT":
+		if path == "" {
+			return *doc, nil
+		}
+		res, _ := bsonkit.All(doc, path, true, false)
+		return res, nil
+	case "REMOVE":
+		return bsonkit.Missing, nil
+	default:
+		return nil, fmt.Errorf("use of undefined variable %q", name)
+	}
+}
+
+func evaluateArgs(doc bsonkit.Doc, op string, v interface{}, min, max int) (bson.A, error) {
+	// coerce arguments
+	args, ok := v.(bson.A)
+	if !ok {
+		args = bson.A{v}
+	}
+
+	// check count
+	if len(args) < min || max >= 0 && len(args) > max {
+		if min == max {
+			return nil, fmt.Errorf("%s: expected %d arguments", op, min)
+		}
+		return nil, fmt.Errorf("%s: invalid number of arguments", op)
+	}
+
+	// evaluate arguments
+	list := make(bson.A, 0, len(args))
+	for _, arg := range args {
+		val, err := Evaluate(doc, arg)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, val)
+	}
+
+	return list, nil
+}
+
+func isNullish(v interface{}) bool {
+	class, _ := bsonkit.Inspect(v)
+	return class == bsonkit.Null
+}
+
+func evaluateLiteral(_ bsonkit.Doc, _ string, v interface{}) (interface{}, error) {
+	return v, nil
+}
+
+func evaluateAdd(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// sum values
+	var sum interface{} = int32(0)
+	var date bool
+	for _, arg := range args {
+		// handle null
+		if isNullish(arg) {
+			return nil, nil
+		}
+
+		// handle date
+		if dt, ok := arg.(primitive.DateTime); ok {
+			if date {
+				return nil, fmt.Errorf("%s: only one date allowed", op)
+			}
+			date = true
+			arg = int64(dt)
+		}
+
+		// add value
+		res := bsonkit.Add(sum, arg)
+		if res == bsonkit.Missing {
+			return nil, fmt.Errorf("%s: only supports numeric or date types", op)
+		}
+		sum = res
+	}
+
+	// convert date
+	if date {
+		return primitive.DateTime(toInt64(sum)), nil
+	}
+
+	return sum, nil
+}
+
+func evaluateSubtract(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	// handle null
+	if isNullish(args[0]) || isNullish(args[1]) {
+		return nil, nil
+	}
+
+	// handle dates
+	ld, lok := args[0].(primitive.DateTime)
+	rd, rok := args[1].(primitive.DateTime)
+	if lok && rok {
+		return int64(ld) - int64(rd), nil
+	} else if lok {
+		res := bsonkit.Mul(args[1], int32(-1))
+		if res == bsonkit.Missing {
+			return nil, fmt.Errorf("%s: only supports numeric or date types", op)
+		}
+		return primitive.DateTime(int64(ld) + toInt64(res)), nil
+	}
+
+	// subtract numbers
+	res := bsonkit.Mul(args[1], int32(-1))
+	if res != bsonkit.Missing {
+		res = bsonkit.Add(args[0], res)
+	}
+	if res == bsonkit.Missing {
+		return nil, fmt.Errorf("%s: only supports numeric or date types", op)
+	}
+
+	return res, nil
+}
+
+func evaluateMultiply(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// multiply values
+	var product interface{} = int32(1)
+	for _, arg := range args {
+		// handle null
+		if isNullish(arg) {
+			return nil, nil
+		}
+
+		// multiply value
+		res := bsonkit.Mul(product, arg)
+		if res == bsonkit.Missing {
+			return nil, fmt.Errorf("%s: only supports numeric types", op)
+		}
+		product = res
+	}
+
+	return product, nil
+}
+
+func evaluateDivide(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	// handle null
+	if isNullish(args[0]) || isNullish(args[1]) {
+		return nil, nil
+	}
+
+	// check types
+	lc, _ := bsonkit.Inspect(args[0])
+	rc, _ := bsonkit.Inspect(args[1])
+	if lc != bsonkit.Number || rc != bsonkit.Number {
+		return nil, fmt.Errorf("%s: only supports numeric types", op)
+	}
+
+	// check divisor
+	if bsonkit.Compare(args[1], int64(0)) == 0 {
+		return nil, fmt.Errorf("%s: can't divide by zero", op)
+	}
+
+	return ToFloat64(args[0]) / ToFloat64(args[1]), nil
+}
+
+func evaluateMod(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	// handle null
+	if isNullish(args[0]) || isNullish(args[1]) {
+		return nil, nil
+	}
+
+	// check divisor
+	rc, _ := bsonkit.Inspect(args[1])
+	if rc == bsonkit.Number && bsonkit.Compare(args[1], int64(0)) == 0 {
+		return nil, fmt.Errorf("%s: can't mod by zero", op)
+	}
+
+	// compute modulo
+	res := bsonkit.Mod(args[0], args[1])
+	if res == bsonkit.Missing {
+		return nil, fmt.Errorf("%s: only supports numeric types", op)
+	}
+
+	return res, nil
+}
+
+func evaluateConcat(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// concat strings
+	var builder strings.Builder
+	for _, arg := range args {
+		// handle null
+		if isNullish(arg) {
+			return nil, nil
+		}
+
+		// check string
+		str, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: only supports strings", op)
+		}
+
+		// add string
+		builder.WriteString(str)
+	}
+
+	return builder.String(), nil
+}
+
+func evaluateCase(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// handle null
+	if isNullish(args[0]) {
+		return "", nil
+	}
+
+	// check string
+	str, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected string", op)
+	}
+
+	// change case
+	if op == "$toUpper" {
+		return strings.ToUpper(str), nil
+	}
+
+	return strings.ToLower(str), nil
+}
+
+func evaluateComp(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	// compare values (missing values compare as null)
+	res := bsonkit.Compare(args[0], args[1])
+
+	// check operator
+	switch op {
+	case "$cmp":
+		return int32(res), nil
+	case "$eq":
+		return res == 0, nil
+	case "$ne":
+		return res != 0, nil
+	case "$gt":
+		return res > 0, nil
+	case "$gte":
+		return res >= 0, nil
+	case "$lt":
+		return res < 0, nil
+	case "$lte":
+		return res <= 0, nil
+	default:
+		return nil, fmt.Errorf("unknown comparison operator %q", op)
+	}
+}
+
+func evaluateAnd(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// check values
+	for _, arg := range args {
+		if !truthy(arg) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func evaluateOr(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// check values
+	for _, arg := range args {
+		if truthy(arg) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func evaluateNot(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return !truthy(args[0]), nil
+}
+
+func evaluateCond(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// get branches
+	var cond, then, els interface{}
+	switch value := v.(type) {
+	case bson.A:
+		if len(value) != 3 {
+			return nil, fmt.Errorf("%s: expected 3 arguments", op)
+		}
+		cond, then, els = value[0], value[1], value[2]
+	case bson.D:
+		var ok [3]bool
+		for _, el := range value {
+			switch el.Key {
+			case "if":
+				cond, ok[0] = el.Value, true
+			case "then":
+				then, ok[1] = el.Value, true
+			case "else":
+				els, ok[2] = el.Value, true
+			default:
+				return nil, fmt.Errorf("%s: unrecognized parameter %q", op, el.Key)
+			}
+		}
+		if !ok[0] || !ok[1] || !ok[2] {
+			return nil, fmt.Errorf("%s: missing 'if', 'then' or 'else' parameter", op)
+		}
+	default:
+		return nil, fmt.Errorf("%s: expected array or document", op)
+	}
+
+	// evaluate condition
+	res, err := Evaluate(doc, cond)
+	if err != nil {
+		return nil, err
+	}
+
+	// evaluate branch
+	if truthy(res) {
+		return Evaluate(doc, then)
+	}
+
+	return Evaluate(doc, els)
+}
+
+func evaluateIfNull(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 2, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// return first non-null value
+	for _, arg := range args[:len(args)-1] {
+		if !isNullish(arg) {
+			return arg, nil
+		}
+	}
+
+	return args[len(args)-1], nil
+}
+
+func evaluateSize(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// check array
+	array, ok := args[0].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected array", op)
+	}
+
+	return int32(len(array)), nil
+}
+
+func evaluateArrayElemAt(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	// handle null
+	if isNullish(args[0]) || isNullish(args[1]) {
+		return nil, nil
+	}
+
+	// check array
+	array, ok := args[0].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected array", op)
+	}
+
+	// check index
+	class, _ := bsonkit.Inspect(args[1])
+	if class != bsonkit.Number {
+		return nil, fmt.Errorf("%s: expected numeric index", op)
+	}
+
+	// get index
+	index := int(toInt64(args[1]))
+	if index < 0 {
+		index += len(array)
+	}
+
+	// check bounds
+	if index < 0 || index >= len(array) {
+		return bsonkit.Missing, nil
+	}
+
+	return array[index], nil
+}
+
+func evaluateConcatArrays(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// concat arrays
+	res := bson.A{}
+	for _, arg := range args {
+		// handle null
+		if isNullish(arg) {
+			return nil, nil
+		}
+
+		// check array
+		array, ok := arg.(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("%s: only supports arrays", op)
+		}
+
+		// add items
+		res = append(res, array...)
+	}
+
+	return res, nil
+}
+
+func evaluateIn(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	// check array
+	array, ok := args[1].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected array", op)
+	}
+
+	// find value
+	for _, item := range array {
+		if bsonkit.Compare(args[0], item) == 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func evaluateType(doc bsonkit.Doc, op string, v interface{}) (interface{}, error) {
+	// evaluate arguments
+	args, err := evaluateArgs(doc, op, v, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// handle missing
+	if args[0] == bsonkit.Missing {
+		return "missing", nil
+	}
+
+	// get type
+	_, typ := bsonkit.Inspect(args[0])
+
+	return bsonkit.Type2Alias[typ], nil
+}
+
+func toInt64(v interface{}) int64 {
+	switch v := v.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case primitive.Decimal128:
+		return int64(ToFloat64(v))
+	default:
+		return 0
+	}
+}
+
+// ToFloat64 will convert a number to a float64. Other values are converted to
+// zero.
+func ToFloat64(v interface{}) float64 {
+	switch v := v.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case primitive.Decimal128:
+		f, _ := strconv.ParseFloat(v.String(), 64)
+		return f
+	default:
+		return 0
+	}
+}
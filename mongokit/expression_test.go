@@ -0,0 +1,147 @@
+package mongokit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+func evaluateTest(t *testing.T, doc bson.M, fn func(fn func(interface{}, interface{}))) {
+	t.Run("Mongo", func(t *testing.T) {
+		coll := testCollection()
+		res, err := coll.InsertOne(nil, doc)
+		assert.NoError(t, err)
+
+		fn(func(expr interface{}, result interface{}) {
+			var out []bson.M
+			csr, err := coll.Aggregate(nil, bson.A{
+				bson.M{"$match": bson.M{"_id": res.InsertedID}},
+				bson.M{"$project": bson.M{"_id": 0, "v": expr}},
+			})
+			if err == nil {
+				err = csr.All(nil, &out)
+			}
+			if _, ok := result.(error); ok {
+				assert.Error(t, err, expr)
+			} else if result == bsonkit.Missing {
+				assert.NoError(t, err, expr)
+				assert.Equal(t, []bson.M{{}}, out, expr)
+			} else {
+				assert.NoError(t, err, expr)
+				assert.Equal(t, []bson.M{{"v": result}}, out, expr)
+			}
+		})
+	})
+
+	t.Run("Lungo", func(t *testing.T) {
+		fn(func(expr interface{}, result interface{}) {
+			res, err := Evaluate(bsonkit.MustConvert(doc), bsonkit.MustConvertValue(expr))
+			if e, ok := result.(error); ok {
+				assert.Error(t, err, expr)
+				assert.Equal(t, e.Error(), err.Error(), expr)
+			} else if result == bsonkit.Missing {
+				assert.NoError(t, err, expr)
+				assert.Equal(t, bsonkit.Missing, res, expr)
+			} else {
+				assert.NoError(t, err, expr)
+				assert.Equal(t, bsonkit.MustConvertValue(result), res, expr)
+			}
+		})
+	})
+}
+
+func TestEvaluate(t *testing.T) {
+	evaluateTest(t, bson.M{
+		"a": "foo",
+		"b": int32(7),
+		"c": bson.M{
+			"d": "bar",
+		},
+	}, func(fn func(interface{}, interface{})) {
+		// field paths
+		fn("$a", "foo")
+		fn("$c.d", "bar")
+		fn("$x", bsonkit.Missing)
+		fn("$$ROOT.b", int32(7))
+
+		// literals
+		fn(bson.M{"$literal": "$a"}, "$a")
+		fn(bson.M{"$literal": int32(1)}, int32(1))
+
+		// documents and arrays
+		fn(bson.M{"x": "$a", "y": "$b"}, bson.M{"x": "foo", "y": int32(7)})
+		fn(bson.A{"$a", "$x"}, bson.A{"foo", nil})
+
+		// unknown operator
+		fn(bson.M{"$foo": "bar"}, errors.New(`unknown aggregation expression operator "$foo"`))
+	})
+}
+
+func TestEvaluateArithmetic(t *testing.T) {
+	evaluateTest(t, bson.M{
+		"a": int32(7),
+		"b": int64(2),
+		"c": 1.5,
+	}, func(fn func(interface{}, interface{})) {
+		fn(bson.M{"$add": bson.A{"$a", "$b"}}, int64(9))
+		fn(bson.M{"$add": bson.A{"$a", "$c"}}, 8.5)
+		fn(bson.M{"$add": bson.A{"$a", "$x"}}, nil)
+		fn(bson.M{"$subtract": bson.A{"$a", "$b"}}, int64(5))
+		fn(bson.M{"$multiply": bson.A{"$a", "$b"}}, int64(14))
+		fn(bson.M{"$divide": bson.A{"$a", "$b"}}, 3.5)
+		fn(bson.M{"$divide": bson.A{"$a", 0}}, errors.New("$divide: can't divide by zero"))
+		fn(bson.M{"$mod": bson.A{"$a", "$b"}}, int64(1))
+		fn(bson.M{"$add": bson.A{"$a", "foo"}}, errors.New("$add: only supports numeric or date types"))
+	})
+}
+
+func TestEvaluateString(t *testing.T) {
+	evaluateTest(t, bson.M{
+		"first": "John",
+		"last":  "Doe",
+	}, func(fn func(interface{}, interface{})) {
+		fn(bson.M{"$concat": bson.A{"$first", " ", "$last"}}, "John Doe")
+		fn(bson.M{"$concat": bson.A{"$first", "$x"}}, nil)
+		fn(bson.M{"$toUpper": "$first"}, "JOHN")
+		fn(bson.M{"$toLower": "$last"}, "doe")
+		fn(bson.M{"$toLower": "$x"}, "")
+	})
+}
+
+func TestEvaluateLogic(t *testing.T) {
+	evaluateTest(t, bson.M{
+		"a": int32(5),
+		"b": bson.A{int32(1), int32(2), int32(3)},
+		"n": nil,
+	}, func(fn func(interface{}, interface{})) {
+		// comparison
+		fn(bson.M{"$eq": bson.A{"$a", int32(5)}}, true)
+		fn(bson.M{"$gt": bson.A{"$a", int32(5)}}, false)
+		fn(bson.M{"$lte": bson.A{"$a", int32(5)}}, true)
+		fn(bson.M{"$cmp": bson.A{"$a", int32(6)}}, int32(-1))
+
+		// boolean
+		fn(bson.M{"$and": bson.A{"$a", true}}, true)
+		fn(bson.M{"$or": bson.A{"$n", false}}, false)
+		fn(bson.M{"$not": bson.A{"$a"}}, false)
+
+		// conditional
+		fn(bson.M{"$cond": bson.A{"$a", "yes", "no"}}, "yes")
+		fn(bson.M{"$cond": bson.M{"if": "$n", "then": "yes", "else": "no"}}, "no")
+		fn(bson.M{"$ifNull": bson.A{"$n", "$x", "default"}}, "default")
+
+		// arrays
+		fn(bson.M{"$size": "$b"}, int32(3))
+		fn(bson.M{"$arrayElemAt": bson.A{"$b", -1}}, int32(3))
+		fn(bson.M{"$concatArrays": bson.A{"$b", bson.A{int32(4)}}}, bson.A{int32(1), int32(2), int32(3), int32(4)})
+		fn(bson.M{"$in": bson.A{int32(2), "$b"}}, true)
+
+		// type
+		fn(bson.M{"$type": "$a"}, "int")
+		fn(bson.M{"$type": "$x"}, "missing")
+	})
+}
@@ -5,13 +5,14 @@ import (
 )
 
 // Filter will filter a list of documents based on the specified MongoDB query
-// document. A limit may be set to return early then the list is full.
-func Filter(list bsonkit.List, query bsonkit.Doc, limit int) (bsonkit.List, error) {
+// document. A limit may be set to return early then the list is full. An
+// optional collator may be provided to compare strings.
+func Filter(list bsonkit.List, query bsonkit.Doc, limit int, collator *bsonkit.Collator) (bsonkit.List, error) {
 	// select documents
 	var matchErr error
 	result := bsonkit.Select(list, limit, func(doc bsonkit.Doc) (bool, bool) {
 		// match based on query
-		res, err := Match(doc, query)
+		res, err := Match(doc, query, collator)
 		if err != nil {
 			matchErr = err
 			return false, true
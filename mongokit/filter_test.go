@@ -17,7 +17,7 @@ func TestFilter(t *testing.T) {
 	// field condition
 	list, err := Filter(bsonkit.List{a1, a2, a3}, bsonkit.MustConvert(bson.M{
 		"b": true,
-	}), 0)
+	}), 0, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, bsonkit.List{a1, a3}, list)
 
@@ -26,7 +26,7 @@ func TestFilter(t *testing.T) {
 		"a": bson.M{
 			"$gt": "1",
 		},
-	}), 0)
+	}), 0, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, bsonkit.List{a2, a3}, list)
 }
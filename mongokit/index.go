@@ -4,15 +4,15 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/256dpi/lungo/bsonkit"
 )
 
-// TODO: Whitelist supported query operators?
-
 // IndexConfig defines an index configuration.
 type IndexConfig struct {
 	// The index key.
@@ -21,11 +21,23 @@ type IndexConfig struct {
 	// Whether the index is unique.
 	Unique bool
 
+	// Whether the index skips documents that miss all indexed fields.
+	Sparse bool
+
 	// The partial index filter.
 	Partial bsonkit.Doc
 
 	// The time after documents expire.
 	Expiry time.Duration
+
+	// The collation used to compare strings.
+	Collation bsonkit.Doc
+
+	// The projection of a wildcard index.
+	WildcardProjection bsonkit.Doc
+
+	// Whether the index is hidden from the query planner.
+	Hidden bool
 }
 
 // Equal will compare to configurations and return whether they are equal.
@@ -40,15 +52,13 @@ func (c IndexConfig) Equal(d IndexConfig) bool {
 		return false
 	}
 
-	// check partials
-	var p1, p2 bson.D
-	if c.Partial != nil {
-		p1 = *c.Partial
-	}
-	if d.Partial != nil {
-		p2 = *d.Partial
+	// check sparse
+	if c.Sparse != d.Sparse {
+		return false
 	}
-	if bsonkit.Compare(p1, p2) != 0 {
+
+	// check partials
+	if !equalDocs(c.Partial, d.Partial) {
 		return false
 	}
 
@@ -57,13 +67,28 @@ func (c IndexConfig) Equal(d IndexConfig) bool {
 		return false
 	}
 
+	// check collations
+	if !equalDocs(c.Collation, d.Collation) {
+		return false
+	}
+
+	// check wildcard projections
+	if !equalDocs(c.WildcardProjection, d.WildcardProjection) {
+		return false
+	}
+
+	// check hidden
+	if c.Hidden != d.Hidden {
+		return false
+	}
+
 	return true
 }
 
 // Name will return the computed index name.
 func (c IndexConfig) Name() (string, error) {
 	// get columns
-	columns, err := Columns(c.Key)
+	columns, err := Columns(c.Key, nil)
 	if err != nil {
 		return "", err
 	}
@@ -71,6 +96,10 @@ func (c IndexConfig) Name() (string, error) {
 	// generate name
 	segments := make([]string, 0, len(columns)*2)
 	for _, column := range columns {
+		if column.Hashed {
+			segments = append(segments, column.Path, "hashed")
+			continue
+		}
 		var dir = 1
 		if column.Reverse {
 			dir = -1
@@ -88,9 +117,18 @@ func (c IndexConfig) Name() (string, error) {
 // not safe from concurrent access and does not roll back changes on errors.
 // Therefore, the recommended approach is to clone the index before making changes.
 type Index struct {
-	config  IndexConfig
-	columns []bsonkit.Column
-	base    *bsonkit.Index
+	config   IndexConfig
+	collator *bsonkit.Collator
+	columns  []bsonkit.Column
+	wildcard *wildcard
+	base     *bsonkit.Index
+	usage    *usage
+}
+
+// the usage counters are shared between clones of an index
+type usage struct {
+	ops   int64
+	since time.Time
 }
 
 // CreateIndex will create and return a new index.
@@ -100,26 +138,99 @@ func CreateIndex(config IndexConfig) (*Index, error) {
 		return nil, fmt.Errorf("empty index key")
 	}
 
-	// clone key and partial
+	// clone key, partial, collation and wildcard projection
 	config.Key = bsonkit.Clone(config.Key)
 	config.Partial = bsonkit.Clone(config.Partial)
+	config.Collation = bsonkit.Clone(config.Collation)
+	config.WildcardProjection = bsonkit.Clone(config.WildcardProjection)
+
+	// create collator
+	collator, err := bsonkit.NewCollator(config.Collation)
+	if err != nil {
+		return nil, err
+	} else if collator == nil {
+		config.Collation = nil
+	}
 
 	// parse columns
-	columns, err := Columns(config.Key)
+	columns, err := Columns(config.Key, collator)
 	if err != nil {
 		return nil, err
 	}
 
+	// check partial
+	if config.Partial != nil {
+		if config.Sparse {
+			return nil, fmt.Errorf("cannot mix \"partialFilterExpression\" and \"sparse\" options")
+		}
+		err = validatePartial(*config.Partial)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// enforce single field ttl index
 	if config.Expiry > 0 && len(*config.Key) > 1 {
 		return nil, fmt.Errorf("invalid expiring compound index")
 	}
 
+	// check hashed columns
+	var hashed int
+	for _, column := range columns {
+		if column.Hashed {
+			hashed++
+		}
+	}
+	if hashed > 1 {
+		return nil, fmt.Errorf("a maximum of one index field is allowed to be hashed")
+	} else if hashed > 0 && config.Unique {
+		return nil, fmt.Errorf("hashed indexes cannot guarantee uniqueness")
+	}
+
+	// check wildcard
+	var wc *wildcard
+	for _, column := range columns {
+		if IsWildcard(column.Path) {
+			// check options
+			if column.Hashed {
+				return nil, fmt.Errorf("wildcard indexes do not support hashed keys")
+			} else if len(columns) > 1 {
+				return nil, fmt.Errorf("wildcard indexes do not allow compounding")
+			} else if config.Unique {
+				return nil, fmt.Errorf("wildcard indexes do not support the unique option")
+			} else if config.Expiry > 0 {
+				return nil, fmt.Errorf("wildcard indexes do not support the expiry option")
+			} else if config.Sparse {
+				return nil, fmt.Errorf("wildcard indexes do not support the sparse option")
+			}
+
+			// create wildcard
+			wc, err = newWildcard(column.Path, config.WildcardProjection)
+			if err != nil {
+				return nil, err
+			}
+
+			// index entries by path and value
+			columns = []bsonkit.Column{
+				{Path: "path"},
+				{Path: "value", Collator: collator},
+			}
+		}
+	}
+
+	// check wildcard projection
+	if wc == nil && config.WildcardProjection != nil {
+		return nil, fmt.Errorf("the field 'wildcardProjection' is only allowed in a wildcard index")
+	}
+
 	// create index
 	index := &Index{
-		config:  config,
-		columns: columns,
-		base:    bsonkit.NewIndex(config.Unique, columns),
+		config:   config,
+		collator: collator,
+		columns:  columns,
+		wildcard: wc,
+		base:     bsonkit.NewIndex(config.Unique, columns),
+		usage:    &usage{since: time.Now()},
 	}
 
 	return index, nil
@@ -147,7 +258,7 @@ func (i *Index) Build(list bsonkit.List) (bool, error) {
 func (i *Index) Add(doc bsonkit.Doc) (bool, error) {
 	// skip documents that do not match partial expression
 	if i.config.Partial != nil {
-		ok, err := Match(doc, i.config.Partial)
+		ok, err := Match(doc, i.config.Partial, i.collator)
 		if err != nil {
 			return false, err
 		} else if !ok {
@@ -155,6 +266,26 @@ func (i *Index) Add(doc bsonkit.Doc) (bool, error) {
 		}
 	}
 
+	// skip documents that miss all indexed fields
+	if i.config.Sparse && !i.covers(doc) {
+		return true, nil
+	}
+
+	// add wildcard entries
+	if i.wildcard != nil {
+		for _, entry := range i.wildcard.entries(doc) {
+			i.base.Add(entry)
+		}
+		return true, nil
+	}
+
+	// check hashed values
+	for _, column := range i.columns {
+		if _, ok := bsonkit.Get(doc, column.Path).(bson.A); ok && column.Hashed {
+			return false, fmt.Errorf("hashed indexes do not support array values")
+		}
+	}
+
 	return i.base.Add(doc), nil
 }
 
@@ -162,7 +293,7 @@ func (i *Index) Add(doc bsonkit.Doc) (bool, error) {
 func (i *Index) Has(doc bsonkit.Doc) (bool, error) {
 	// skip documents that do not match partial expression
 	if i.config.Partial != nil {
-		ok, err := Match(doc, i.config.Partial)
+		ok, err := Match(doc, i.config.Partial, i.collator)
 		if err != nil {
 			return false, err
 		} else if !ok {
@@ -170,6 +301,22 @@ func (i *Index) Has(doc bsonkit.Doc) (bool, error) {
 		}
 	}
 
+	// skip documents that miss all indexed fields
+	if i.config.Sparse && !i.covers(doc) {
+		return false, nil
+	}
+
+	// check wildcard entries
+	if i.wildcard != nil {
+		entries := i.wildcard.entries(doc)
+		for _, entry := range entries {
+			if i.findEntry(entry) == nil {
+				return false, nil
+			}
+		}
+		return len(entries) > 0, nil
+	}
+
 	return i.base.Has(doc), nil
 }
 
@@ -178,7 +325,7 @@ func (i *Index) Has(doc bsonkit.Doc) (bool, error) {
 func (i *Index) Remove(doc bsonkit.Doc) (bool, error) {
 	// skip documents that do not match partial expression
 	if i.config.Partial != nil {
-		ok, err := Match(doc, i.config.Partial)
+		ok, err := Match(doc, i.config.Partial, i.collator)
 		if err != nil {
 			return false, err
 		} else if !ok {
@@ -186,30 +333,353 @@ func (i *Index) Remove(doc bsonkit.Doc) (bool, error) {
 		}
 	}
 
+	// skip documents that miss all indexed fields
+	if i.config.Sparse && !i.covers(doc) {
+		return true, nil
+	}
+
+	// remove wildcard entries
+	if i.wildcard != nil {
+		ok := true
+		for _, entry := range i.wildcard.entries(doc) {
+			existing := i.findEntry(entry)
+			if existing == nil {
+				ok = false
+				continue
+			}
+			i.base.Remove(existing)
+		}
+		return ok, nil
+	}
+
 	return i.base.Remove(doc), nil
 }
 
+// Lookup will return the documents that may match an equality condition on
+// the specified path using the specified collator. The returned list is a
+// superset of the matching documents and must still be filtered. False is
+// returned if the index cannot serve the condition.
+func (i *Index) Lookup(path string, value interface{}, collator *bsonkit.Collator) (bsonkit.List, bool) {
+	// check index
+	if i.wildcard == nil || i.config.Partial != nil || i.config.Hidden {
+		return nil, false
+	}
+
+	// check collation
+	if !equalDocs(collator.Spec(), i.config.Collation) {
+		return nil, false
+	}
+
+	// check path
+	if strings.HasPrefix(path, "$") || bsonkit.IndexedPath(path) || !i.wildcard.includes(path) {
+		return nil, false
+	}
+
+	// check value
+	switch class, _ := bsonkit.Inspect(value); class {
+	case bsonkit.Null, bsonkit.Document, bsonkit.Array, bsonkit.Regex:
+		return nil, false
+	}
+
+	// lookup entries
+	entries := i.base.Lookup(&bson.D{
+		bson.E{Key: "path", Value: path},
+		bson.E{Key: "value", Value: value},
+	})
+
+	// collect documents
+	list := make(bsonkit.List, 0, len(entries))
+	seen := make(map[bsonkit.Doc]bool, len(entries))
+	for _, entry := range entries {
+		doc := (*entry)[2].Value.(bsonkit.Doc)
+		if !seen[doc] {
+			seen[doc] = true
+			list = append(list, doc)
+		}
+	}
+
+	return list, true
+}
+
+func (i *Index) serves(path string, equality bool, collator *bsonkit.Collator) bool {
+	// check index
+	if i.wildcard != nil || i.config.Partial != nil || i.config.Hidden {
+		return false
+	}
+
+	// check collation
+	if !equalDocs(collator.Spec(), i.config.Collation) {
+		return false
+	}
+
+	// check leading column, hashed columns only serve equality conditions
+	column := i.columns[0]
+
+	return column.Path == path && (equality || !column.Hashed)
+}
+
 // List will return an ascending list of all documents in the index.
 func (i *Index) List() bsonkit.List {
+	// collect wildcard documents
+	if i.wildcard != nil {
+		var list bsonkit.List
+		seen := map[bsonkit.Doc]bool{}
+		for _, entry := range i.base.List() {
+			doc := (*entry)[2].Value.(bsonkit.Doc)
+			if !seen[doc] {
+				seen[doc] = true
+				list = append(list, doc)
+			}
+		}
+		return list
+	}
+
 	return i.base.List()
 }
 
 // Config will return the index configuration.
 func (i *Index) Config() IndexConfig {
 	return IndexConfig{
-		Key:     bsonkit.Clone(i.config.Key),
-		Unique:  i.config.Unique,
-		Partial: bsonkit.Clone(i.config.Partial),
-		Expiry:  i.config.Expiry,
+		Key:                bsonkit.Clone(i.config.Key),
+		Unique:             i.config.Unique,
+		Sparse:             i.config.Sparse,
+		Partial:            bsonkit.Clone(i.config.Partial),
+		Expiry:             i.config.Expiry,
+		Collation:          bsonkit.Clone(i.config.Collation),
+		WildcardProjection: bsonkit.Clone(i.config.WildcardProjection),
+		Hidden:             i.config.Hidden,
+	}
+}
+
+// Hide will hide or unhide the index from the query planner. A hidden index is
+// still maintained and enforces its unique constraint.
+func (i *Index) Hide(hidden bool) {
+	i.config.Hidden = hidden
+}
+
+// Len will return the number of entries in the index.
+func (i *Index) Len() int {
+	return i.base.Len()
+}
+
+// Size will return the estimated size of the index in bytes. The size is
+// computed from the BSON encoded size of the indexed values.
+func (i *Index) Size() int64 {
+	// sum up value sizes
+	var size int64
+	for _, entry := range i.base.List() {
+		for _, column := range i.columns {
+			_, raw, err := bson.MarshalValue(bsonkit.Get(entry, column.Path))
+			if err == nil {
+				size += int64(len(raw))
+			}
+		}
+	}
+
+	return size
+}
+
+// Accesses will return the number of operations that used the index and the
+// time the counting started. The counter is shared by all clones of the index.
+func (i *Index) Accesses() (int64, time.Time) {
+	return atomic.LoadInt64(&i.usage.ops), i.usage.since
+}
+
+func (i *Index) access() {
+	atomic.AddInt64(&i.usage.ops, 1)
+}
+
+// Verify will check the index against the specified documents. It returns a
+// description of every detected inconsistency.
+func (i *Index) Verify(docs *bsonkit.Set) ([]string, error) {
+	// prepare problems
+	var problems []string
+
+	// rebuild index
+	expected, err := CreateIndex(i.config)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := expected.Build(docs.List)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		problems = append(problems, "documents violate the unique constraint")
+	} else if i.base.Len() != expected.base.Len() {
+		problems = append(problems, fmt.Sprintf("index has %d entries, expected %d", i.base.Len(), expected.base.Len()))
+	}
+
+	// check indexed documents
+	var extra, excluded int
+	for _, entry := range i.base.List() {
+		// get document
+		doc := entry
+		if i.wildcard != nil {
+			doc, _ = (*entry)[2].Value.(bsonkit.Doc)
+		}
+
+		// check presence
+		if _, ok := docs.Index[doc]; !ok {
+			extra++
+			continue
+		}
+
+		// check partial filter and sparse
+		ok, err := i.includes(doc)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			excluded++
+		}
+	}
+	if extra > 0 {
+		problems = append(problems, fmt.Sprintf("index has %d entries for missing documents", extra))
+	}
+	if excluded > 0 {
+		problems = append(problems, fmt.Sprintf("index has %d entries for documents excluded by the partial filter or sparse option", excluded))
+	}
+
+	// check missing documents
+	var missing int
+	for _, doc := range docs.List {
+		// check inclusion
+		ok, err := i.includes(doc)
+		if err != nil {
+			return nil, err
+		} else if !ok || i.wildcard != nil && len(i.wildcard.entries(doc)) == 0 {
+			continue
+		}
+
+		// check entry
+		ok, err = i.Has(doc)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			missing++
+		}
 	}
+	if missing > 0 {
+		problems = append(problems, fmt.Sprintf("index misses %d documents", missing))
+	}
+
+	return problems, nil
 }
 
 // Clone will clone the index. Mutating the new index will not mutate the
 // original index.
 func (i *Index) Clone() *Index {
 	return &Index{
-		config:  i.config,
-		columns: i.columns,
-		base:    i.base.Clone(),
+		config:   i.config,
+		collator: i.collator,
+		columns:  i.columns,
+		wildcard: i.wildcard,
+		base:     i.base.Clone(),
+		usage:    i.usage,
+	}
+}
+
+func (i *Index) includes(doc bsonkit.Doc) (bool, error) {
+	// check partial expression
+	if i.config.Partial != nil {
+		ok, err := Match(doc, i.config.Partial, i.collator)
+		if err != nil || !ok {
+			return false, err
+		}
 	}
+
+	// check indexed fields
+	if i.config.Sparse && !i.covers(doc) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (i *Index) covers(doc bsonkit.Doc) bool {
+	// check if any indexed field is present
+	for _, column := range i.columns {
+		value, nested := bsonkit.All(doc, column.Path, true, false)
+		if array, ok := value.(bson.A); ok && nested && len(array) == 0 {
+			continue
+		} else if value != bsonkit.Missing {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (i *Index) findEntry(entry bsonkit.Doc) bsonkit.Doc {
+	// find entry of the same document
+	doc := (*entry)[2].Value
+	for _, existing := range i.base.Lookup(entry) {
+		if (*existing)[2].Value == doc {
+			return existing
+		}
+	}
+
+	return nil
+}
+
+func equalDocs(a, b bsonkit.Doc) bool {
+	// get documents
+	var d1, d2 bson.D
+	if a != nil {
+		d1 = *a
+	}
+	if b != nil {
+		d2 = *b
+	}
+
+	return bsonkit.Compare(d1, d2) == 0
+}
+
+func validatePartial(query bson.D) error {
+	for _, exp := range query {
+		// handle logical operators
+		if exp.Key == "$and" || exp.Key == "$or" {
+			list, ok := exp.Value.(bson.A)
+			if !ok {
+				return fmt.Errorf("%s: expected list", exp.Key)
+			}
+			for _, item := range list {
+				doc, ok := item.(bson.D)
+				if !ok {
+					return fmt.Errorf("%s: expected list of documents", exp.Key)
+				}
+				err := validatePartial(doc)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		// check other top level operators
+		if strings.HasPrefix(exp.Key, "$") {
+			return fmt.Errorf("unsupported expression in partial index: %s", exp.Key)
+		}
+
+		// check regular expressions
+		if _, ok := exp.Value.(primitive.Regex); ok {
+			return fmt.Errorf("unsupported expression in partial index: %s $regex", exp.Key)
+		}
+
+		// check query operators
+		if doc, ok := exp.Value.(bson.D); ok && len(doc) > 0 && strings.HasPrefix(doc[0].Key, "$") {
+			for _, op := range doc {
+				switch op.Key {
+				case "$eq", "$gt", "$gte", "$lt", "$lte", "$type", "$in":
+				case "$exists":
+					if !truthy(op.Value) {
+						return fmt.Errorf("unsupported expression in partial index: %s does not exist", exp.Key)
+					}
+				default:
+					return fmt.Errorf("unsupported expression in partial index: %s %s", exp.Key, op.Key)
+				}
+			}
+		}
+	}
+
+	return nil
 }
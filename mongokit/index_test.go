@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/256dpi/lungo/bsonkit"
 )
@@ -335,3 +336,389 @@ func TestIndexPartial(t *testing.T) {
 	assert.False(t, mustHas(index.Has(d1)))
 	assert.False(t, mustHas(index.Has(d2)))
 }
+
+func TestIndexWildcard(t *testing.T) {
+	d1 := bsonkit.MustConvert(bson.M{"_id": "1", "a": bson.M{"b": "x", "c": int32(1)}})
+	d2 := bsonkit.MustConvert(bson.M{"_id": "2", "a": bson.A{bson.M{"b": "x"}, bson.M{"b": "y"}}})
+	d3 := bsonkit.MustConvert(bson.M{"_id": "3", "d": bson.A{"x", "z"}})
+
+	index, err := CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{
+			"$**": int32(1),
+		}),
+	})
+	assert.NoError(t, err)
+
+	ok, err := index.Build(bsonkit.List{d1, d2, d3})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, mustHas(index.Has(d1)))
+	assert.Len(t, index.List(), 3)
+
+	list, ok := index.Lookup("a.b", "x", nil)
+	assert.True(t, ok)
+	assert.ElementsMatch(t, bsonkit.List{d1, d2}, list)
+
+	list, ok = index.Lookup("d", "z", nil)
+	assert.True(t, ok)
+	assert.Equal(t, bsonkit.List{d3}, list)
+
+	list, ok = index.Lookup("a.c", int64(1), nil)
+	assert.True(t, ok)
+	assert.Equal(t, bsonkit.List{d1}, list)
+
+	list, ok = index.Lookup("a.c", int32(2), nil)
+	assert.True(t, ok)
+	assert.Empty(t, list)
+
+	_, ok = index.Lookup("_id", "1", nil)
+	assert.False(t, ok)
+
+	_, ok = index.Lookup("a.0.b", "x", nil)
+	assert.False(t, ok)
+
+	_, ok = index.Lookup("a.b", nil, nil)
+	assert.False(t, ok)
+
+	ok, err = index.Remove(d2)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, mustHas(index.Has(d2)))
+
+	list, ok = index.Lookup("a.b", "x", nil)
+	assert.True(t, ok)
+	assert.Equal(t, bsonkit.List{d1}, list)
+
+	// sub path
+	index, err = CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{
+			"a.$**": int32(1),
+		}),
+	})
+	assert.NoError(t, err)
+
+	ok, err = index.Build(bsonkit.List{d1, d2, d3})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	list, ok = index.Lookup("a.b", "y", nil)
+	assert.True(t, ok)
+	assert.Equal(t, bsonkit.List{d2}, list)
+
+	_, ok = index.Lookup("d", "x", nil)
+	assert.False(t, ok)
+
+	// projection
+	index, err = CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{
+			"$**": int32(1),
+		}),
+		WildcardProjection: bsonkit.MustConvert(bson.M{
+			"a.b": int32(0),
+		}),
+	})
+	assert.NoError(t, err)
+
+	ok, err = index.Build(bsonkit.List{d1, d2, d3})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, ok = index.Lookup("a.b", "x", nil)
+	assert.False(t, ok)
+
+	list, ok = index.Lookup("a.c", int32(1), nil)
+	assert.True(t, ok)
+	assert.Equal(t, bsonkit.List{d1}, list)
+
+	// invalid
+	_, err = CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{
+			"$**": int32(1),
+		}),
+		Unique: true,
+	})
+	assert.Error(t, err)
+
+	_, err = CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{
+			"a.$**": int32(1),
+		}),
+		WildcardProjection: bsonkit.MustConvert(bson.M{
+			"b": int32(1),
+		}),
+	})
+	assert.Error(t, err)
+
+	_, err = CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{
+			"a": int32(1),
+		}),
+		WildcardProjection: bsonkit.MustConvert(bson.M{
+			"b": int32(1),
+		}),
+	})
+	assert.Error(t, err)
+
+	_, err = CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{
+			"$**": int32(1),
+		}),
+		WildcardProjection: bsonkit.MustConvert(bson.M{
+			"a": int32(1),
+			"b": int32(0),
+		}),
+	})
+	assert.Error(t, err)
+}
+
+func TestIndexHashed(t *testing.T) {
+	d1 := bsonkit.MustConvert(bson.M{"a": int32(1)})
+	d2 := bsonkit.MustConvert(bson.M{"a": 1.5})
+	d3 := bsonkit.MustConvert(bson.M{"a": bson.A{int32(1)}})
+
+	config := IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{
+			"a": "hashed",
+		}),
+	}
+
+	name, err := config.Name()
+	assert.NoError(t, err)
+	assert.Equal(t, "a_hashed", name)
+
+	index, err := CreateIndex(config)
+	assert.NoError(t, err)
+
+	ok, err := index.Add(d1)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, mustHas(index.Has(d1)))
+	assert.False(t, mustHas(index.Has(d2)))
+
+	ok, err = index.Add(d3)
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	_, err = CreateIndex(IndexConfig{
+		Key:    bsonkit.MustConvert(bson.M{"a": "hashed"}),
+		Unique: true,
+	})
+	assert.Error(t, err)
+
+	_, err = CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.D{
+			{Key: "a", Value: "hashed"},
+			{Key: "b", Value: "hashed"},
+		}),
+	})
+	assert.Error(t, err)
+}
+
+func TestIndexHidden(t *testing.T) {
+	d1 := bsonkit.MustConvert(bson.M{"a": "foo"})
+
+	index, err := CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{
+			"$**": int32(1),
+		}),
+		Hidden: true,
+	})
+	assert.NoError(t, err)
+	assert.True(t, index.Config().Hidden)
+
+	ok, err := index.Add(d1)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, mustHas(index.Has(d1)))
+
+	_, ok = index.Lookup("a", "foo", nil)
+	assert.False(t, ok)
+
+	index.Hide(false)
+	assert.False(t, index.Config().Hidden)
+
+	list, ok := index.Lookup("a", "foo", nil)
+	assert.True(t, ok)
+	assert.Equal(t, bsonkit.List{d1}, list)
+}
+
+func TestIndexStats(t *testing.T) {
+	d1 := bsonkit.MustConvert(bson.M{"a": "foo"})
+	d2 := bsonkit.MustConvert(bson.M{"a": "bar"})
+
+	index, err := CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{
+			"a": int32(1),
+		}),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index.Len())
+	assert.Equal(t, int64(0), index.Size())
+
+	ok, err := index.Build(bsonkit.List{d1, d2})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, index.Len())
+	assert.Equal(t, int64(16), index.Size())
+
+	ops, since := index.Accesses()
+	assert.Equal(t, int64(0), ops)
+	assert.False(t, since.IsZero())
+
+	clone := index.Clone()
+	clone.access()
+
+	ops, _ = index.Accesses()
+	assert.Equal(t, int64(1), ops)
+}
+
+func TestIndexVerify(t *testing.T) {
+	d1 := bsonkit.MustConvert(bson.M{"a": "foo", "b": true})
+	d2 := bsonkit.MustConvert(bson.M{"a": "bar", "b": false})
+	docs := bsonkit.NewSet(bsonkit.List{d1, d2})
+
+	index, err := CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.M{
+			"a": int32(1),
+		}),
+		Unique: true,
+		Partial: bsonkit.MustConvert(bson.M{
+			"b": true,
+		}),
+	})
+	assert.NoError(t, err)
+
+	ok, err := index.Build(docs.List)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	problems, err := index.Verify(docs)
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+
+	index.base.Add(d2)
+	problems, err = index.Verify(docs)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"index has 2 entries, expected 1",
+		"index has 1 entries for documents excluded by the partial filter or sparse option",
+	}, problems)
+
+	index.base.Remove(d1)
+	index.base.Remove(d2)
+	problems, err = index.Verify(docs)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"index has 0 entries, expected 1",
+		"index misses 1 documents",
+	}, problems)
+
+	d3 := bsonkit.MustConvert(bson.M{"a": "foo", "b": true})
+	index.base.Add(d1)
+	docs.Add(d3)
+	problems, err = index.Verify(docs)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"documents violate the unique constraint",
+	}, problems)
+}
+
+func TestIndexSparse(t *testing.T) {
+	d1 := bsonkit.MustConvert(bson.M{"a": "1"})
+	d2 := bsonkit.MustConvert(bson.M{"b": "1"})
+	d3 := bsonkit.MustConvert(bson.M{"c": "1"})
+	d4 := bsonkit.MustConvert(bson.M{"a": nil})
+
+	index, err := CreateIndex(IndexConfig{
+		Key: bsonkit.MustConvert(bson.D{
+			{Key: "a", Value: int32(1)},
+			{Key: "b", Value: int32(1)},
+		}),
+		Unique: true,
+		Sparse: true,
+	})
+	assert.NoError(t, err)
+
+	for _, doc := range []bsonkit.Doc{d1, d2, d3, d3, d4} {
+		ok, err := index.Add(doc)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	assert.True(t, mustHas(index.Has(d1)))
+	assert.True(t, mustHas(index.Has(d2)))
+	assert.False(t, mustHas(index.Has(d3)))
+	assert.True(t, mustHas(index.Has(d4)))
+	assert.Equal(t, bsonkit.List{d4, d2, d1}, index.List())
+
+	ok, err := index.Remove(d3)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = CreateIndex(IndexConfig{
+		Key:     bsonkit.MustConvert(bson.M{"a": int32(1)}),
+		Sparse:  true,
+		Partial: bsonkit.MustConvert(bson.M{"a": "1"}),
+	})
+	assert.Error(t, err)
+}
+
+func TestIndexPartialValidation(t *testing.T) {
+	for _, item := range []struct {
+		filter bson.M
+		err    string
+	}{
+		{
+			filter: bson.M{"a": int32(1)},
+		},
+		{
+			filter: bson.M{"a": bson.M{"b": int32(1)}},
+		},
+		{
+			filter: bson.M{"a": bson.M{"$gt": int32(1), "$lte": int32(7)}},
+		},
+		{
+			filter: bson.M{"a": bson.M{"$exists": true}, "b": bson.M{"$type": "string"}},
+		},
+		{
+			filter: bson.M{"$and": bson.A{
+				bson.M{"a": bson.M{"$eq": int32(1)}},
+				bson.M{"$or": bson.A{
+					bson.M{"b": bson.M{"$in": bson.A{"x", "y"}}},
+					bson.M{"c": bson.M{"$lt": int32(3)}},
+				}},
+			}},
+		},
+		{
+			filter: bson.M{"a": bson.M{"$exists": false}},
+			err:    "unsupported expression in partial index: a does not exist",
+		},
+		{
+			filter: bson.M{"a": bson.M{"$ne": int32(1)}},
+			err:    "unsupported expression in partial index: a $ne",
+		},
+		{
+			filter: bson.M{"$nor": bson.A{bson.M{"a": int32(1)}}},
+			err:    "unsupported expression in partial index: $nor",
+		},
+		{
+			filter: bson.M{"$or": bson.A{bson.M{"a": bson.M{"$nin": bson.A{int32(1)}}}}},
+			err:    "unsupported expression in partial index: a $nin",
+		},
+		{
+			filter: bson.M{"a": primitive.Regex{Pattern: "x"}},
+			err:    "unsupported expression in partial index: a $regex",
+		},
+	} {
+		_, err := CreateIndex(IndexConfig{
+			Key:     bsonkit.MustConvert(bson.M{"a": int32(1)}),
+			Partial: bsonkit.MustConvert(item.filter),
+		})
+		if item.err != "" {
+			assert.Error(t, err, item.filter)
+			assert.Equal(t, item.err, err.Error(), item.filter)
+		} else {
+			assert.NoError(t, err, item.filter)
+		}
+	}
+}
@@ -46,12 +46,13 @@ func init() {
 }
 
 // Match will test if the specified document matches the supplied MongoDB query
-// document.
-func Match(doc, query bsonkit.Doc) (bool, error) {
+// document. An optional collator may be provided to compare strings.
+func Match(doc, query bsonkit.Doc, collator *bsonkit.Collator) (bool, error) {
 	// match document to query
 	err := Process(Context{
 		TopLevel:   TopLevelQueryOperators,
 		Expression: ExpressionQueryOperators,
+		Collator:   collator,
 	}, doc, *query, "", true)
 	if err == ErrNotMatched {
 		return false, nil
@@ -132,7 +133,7 @@ func matchNor(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) er
 	})
 }
 
-func matchComp(_ Context, doc bsonkit.Doc, op, path string, v interface{}) error {
+func matchComp(ctx Context, doc bsonkit.Doc, op, path string, v interface{}) error {
 	return matchUnwind(doc, path, true, false, func(field interface{}) error {
 		// check classes (type bracketing)
 		lc, _ := bsonkit.Inspect(field)
@@ -142,7 +143,7 @@ func matchComp(_ Context, doc bsonkit.Doc, op, path string, v interface{}) error
 		}
 
 		// compare field with value
-		res := bsonkit.Compare(field, v)
+		res := ctx.Collator.Compare(field, v)
 
 		// check operator
 		var ok bool
@@ -197,7 +198,7 @@ func matchNot(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) er
 	return ErrNotMatched
 }
 
-func matchIn(_ Context, doc bsonkit.Doc, name, path string, v interface{}) error {
+func matchIn(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) error {
 	return matchUnwind(doc, path, true, false, func(field interface{}) error {
 		// get array
 		array, ok := v.(bson.A)
@@ -207,7 +208,7 @@ func matchIn(_ Context, doc bsonkit.Doc, name, path string, v interface{}) error
 
 		// check if field is in array
 		for _, item := range array {
-			if bsonkit.Compare(field, item) == 0 {
+			if ctx.Collator.Compare(field, item) == 0 {
 				return nil
 			}
 		}
@@ -323,7 +324,7 @@ func matchJSONSchema(_ Context, doc bsonkit.Doc, name, _ string, v interface{})
 	return nil
 }
 
-func matchAll(_ Context, doc bsonkit.Doc, name, path string, v interface{}) error {
+func matchAll(ctx Context, doc bsonkit.Doc, name, path string, v interface{}) error {
 	return matchUnwind(doc, path, false, true, func(field interface{}) error {
 		// get array
 		array, ok := v.(bson.A)
@@ -342,7 +343,7 @@ func matchAll(_ Context, doc bsonkit.Doc, name, path string, v interface{}) erro
 			for _, value := range array {
 				ok := false
 				for _, element := range arr {
-					if bsonkit.Compare(value, element) == 0 {
+					if ctx.Collator.Compare(value, element) == 0 {
 						ok = true
 					}
 				}
@@ -357,7 +358,7 @@ func matchAll(_ Context, doc bsonkit.Doc, name, path string, v interface{}) erro
 
 		// check if field is in array
 		for _, item := range array {
-			if bsonkit.Compare(field, item) != 0 {
+			if ctx.Collator.Compare(field, item) != 0 {
 				return ErrNotMatched
 			}
 		}
@@ -38,6 +38,9 @@ type Context struct {
 	// The array filters used to resolve positional operators in top level
 	// operator invocation paths.
 	TopLevelArrayFilters bsonkit.List
+
+	// The collator used to compare strings.
+	Collator *bsonkit.Collator
 }
 
 // Process will process a document with a query using the MongoDB operator
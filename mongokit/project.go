@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/256dpi/lungo/bsonkit"
 )
@@ -15,13 +16,22 @@ func init() {
 	// register expression projection operators
 	ProjectionExpressionOperators[""] = projectCondition
 	ProjectionExpressionOperators["$slice"] = projectSlice
+
+	// register aggregation expression operators
+	for name := range AggregationExpressionOperators {
+		if ProjectionExpressionOperators[name] == nil {
+			ProjectionExpressionOperators[name] = projectExpression
+		}
+	}
 }
 
 type projectState struct {
-	hideID  bool
-	include []string
-	exclude []string
-	merge   map[string]interface{}
+	hideID   bool
+	include  []string
+	exclude  []string
+	compute  bson.D
+	computed bool
+	merge    map[string]interface{}
 }
 
 // ProjectList will apply the provided projection to the specified list.
@@ -55,7 +65,7 @@ func Project(doc, projection bsonkit.Doc) (bsonkit.Doc, error) {
 	}
 
 	// validate
-	if len(state.include) > 0 && len(state.exclude) > 0 {
+	if len(state.exclude) > 0 && (len(state.include) > 0 || state.computed) {
 		return nil, fmt.Errorf("cannot have a mix of inclusion and exclusion")
 	}
 
@@ -63,7 +73,7 @@ func Project(doc, projection bsonkit.Doc) (bsonkit.Doc, error) {
 	var res bsonkit.Doc
 
 	// perform inclusion
-	if len(state.include) > 0 {
+	if len(state.include) > 0 || state.computed {
 		// set null document
 		res = &bson.D{}
 
@@ -96,6 +106,14 @@ func Project(doc, projection bsonkit.Doc) (bsonkit.Doc, error) {
 		}
 	}
 
+	// add computed fields
+	for _, field := range state.compute {
+		_, err := bsonkit.Put(res, field.Key, field.Value, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// merge fields
 	for path, value := range state.merge {
 		// check result
@@ -130,21 +148,58 @@ func Project(doc, projection bsonkit.Doc) (bsonkit.Doc, error) {
 	return res, nil
 }
 
-func projectCondition(ctx Context, _ bsonkit.Doc, _, path string, v interface{}) error {
+func projectCondition(ctx Context, doc bsonkit.Doc, _, path string, v interface{}) error {
 	// get state
 	state := ctx.Value.(*projectState)
 
+	// handle nested projections and computed fields
+	switch value := v.(type) {
+	case bool, int32, int64, float64, primitive.Decimal128:
+		// handled below
+	case bson.D:
+		// check document
+		if len(value) == 0 {
+			return fmt.Errorf("an empty sub-projection is not a valid value")
+		}
+
+		return Process(ctx, doc, value, path, false)
+	default:
+		return projectCompute(state, doc, path, v)
+	}
+
 	// handle inclusion or exclusion
-	if bsonkit.Compare(v, int64(1)) == 0 {
+	if truthy(v) {
 		state.include = append(state.include, path)
-	} else if bsonkit.Compare(v, int64(0)) == 0 {
-		if path == "_id" {
-			state.hideID = true
-		} else {
-			state.exclude = append(state.include, path)
-		}
+	} else if path == "_id" {
+		state.hideID = true
 	} else {
-		return fmt.Errorf("invalid projection argument %+v", v)
+		state.exclude = append(state.exclude, path)
+	}
+
+	return nil
+}
+
+func projectExpression(ctx Context, doc bsonkit.Doc, op, path string, v interface{}) error {
+	return projectCompute(ctx.Value.(*projectState), doc, path, bson.D{
+		bson.E{Key: op, Value: v},
+	})
+}
+
+func projectCompute(state *projectState, doc bsonkit.Doc, path string, expr interface{}) error {
+	// evaluate expression
+	value, err := Evaluate(doc, expr)
+	if err != nil {
+		return err
+	}
+
+	// set flag
+	state.computed = true
+
+	// add field if not missing
+	if value != bsonkit.Missing {
+		state.compute = append(state.compute, bson.E{Key: path, Value: value})
+	} else if path == "_id" {
+		state.hideID = true
 	}
 
 	return nil
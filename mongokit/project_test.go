@@ -134,6 +134,100 @@ func TestProject(t *testing.T) {
 	})
 }
 
+func TestProjectExpression(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	projectTest(t, bson.M{
+		"_id":   id,
+		"first": "John",
+		"last":  "Doe",
+		"age":   int32(42),
+	}, func(fn func(bson.M, interface{})) {
+		// computed field
+		fn(bson.M{
+			"name": bson.M{
+				"$concat": bson.A{"$first", " ", "$last"},
+			},
+		}, bson.M{
+			"_id":  id,
+			"name": "John Doe",
+		})
+
+		// computed field, hide id
+		fn(bson.M{
+			"_id":  0,
+			"name": "$first",
+		}, bson.M{
+			"name": "John",
+		})
+
+		// literal values
+		fn(bson.M{
+			"a": "foo",
+			"b": bson.M{
+				"$literal": int32(1),
+			},
+		}, bson.M{
+			"_id": id,
+			"a":   "foo",
+			"b":   int32(1),
+		})
+
+		// missing field
+		fn(bson.M{
+			"name": "$missing",
+		}, bson.M{
+			"_id": id,
+		})
+
+		// computed nested paths
+		fn(bson.M{
+			"person.name": "$first",
+		}, bson.M{
+			"_id": id,
+			"person": bson.M{
+				"name": "John",
+			},
+		})
+
+		// nested computed document
+		fn(bson.M{
+			"person": bson.M{
+				"age":  1,
+				"name": "$last",
+			},
+		}, bson.M{
+			"_id": id,
+			"person": bson.M{
+				"name": "Doe",
+			},
+		})
+
+		// computed id
+		fn(bson.M{
+			"_id": "$first",
+		}, bson.M{
+			"_id": "John",
+		})
+
+		// mixed inclusion and computed
+		fn(bson.M{
+			"age":   true,
+			"older": bson.M{"$add": bson.A{"$age", int32(1)}},
+		}, bson.M{
+			"_id":   id,
+			"age":   int32(42),
+			"older": int32(43),
+		})
+
+		// mixed exclusion and computed
+		fn(bson.M{
+			"age":  0,
+			"name": "$first",
+		}, "cannot have a mix of inclusion and exclusion")
+	})
+}
+
 func TestProjectSlice(t *testing.T) {
 	id := primitive.NewObjectID()
 
@@ -96,7 +96,7 @@ func resolve(path string, query bsonkit.Doc, doc bson.D, arrayFilters bsonkit.Li
 			// match item
 			ok, err := Match(&bson.D{
 				bson.E{Key: identifier, Value: item},
-			}, filter)
+			}, filter, nil)
 			if err != nil {
 				return err
 			}
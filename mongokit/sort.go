@@ -6,11 +6,22 @@ import (
 	"github.com/256dpi/lungo/bsonkit"
 )
 
-// Columns will return columns from a MongoDB sort document.
-func Columns(doc bsonkit.Doc) ([]bsonkit.Column, error) {
+// Columns will return columns from a MongoDB sort or index key document. An
+// optional collator may be provided to compare strings. A "hashed" direction
+// yields a column that orders by the MongoDB hash of the values.
+func Columns(doc bsonkit.Doc, collator *bsonkit.Collator) ([]bsonkit.Column, error) {
 	// prepare columns
 	columns := make([]bsonkit.Column, 0, len(*doc))
 	for _, exp := range *doc {
+		// handle hashed
+		if exp.Value == "hashed" {
+			columns = append(columns, bsonkit.Column{
+				Path:   exp.Key,
+				Hashed: true,
+			})
+			continue
+		}
+
 		// get direction
 		var direction int
 		switch value := exp.Value.(type) {
@@ -31,8 +42,9 @@ func Columns(doc bsonkit.Doc) ([]bsonkit.Column, error) {
 
 		// add column
 		columns = append(columns, bsonkit.Column{
-			Path:    exp.Key,
-			Reverse: direction == -1,
+			Path:     exp.Key,
+			Reverse:  direction == -1,
+			Collator: collator,
 		})
 	}
 
@@ -40,18 +52,26 @@ func Columns(doc bsonkit.Doc) ([]bsonkit.Column, error) {
 }
 
 // Sort will sort a list based on a MongoDB sort document and return a new
-// list with sorted documents.
-func Sort(list bsonkit.List, doc bsonkit.Doc) (bsonkit.List, error) {
+// list with sorted documents. An optional collator may be provided to compare
+// strings.
+func Sort(list bsonkit.List, doc bsonkit.Doc, collator *bsonkit.Collator) (bsonkit.List, error) {
 	// copy list
 	result := make(bsonkit.List, len(list))
 	copy(result, list)
 
 	// prepare columns
-	columns, err := Columns(doc)
+	columns, err := Columns(doc, collator)
 	if err != nil {
 		return nil, err
 	}
 
+	// check columns
+	for _, column := range columns {
+		if column.Hashed {
+			return nil, fmt.Errorf("expected 1 or -1 as direction")
+		}
+	}
+
 	// sort list
 	bsonkit.Sort(result, columns, true)
 
@@ -0,0 +1,251 @@
+package mongokit
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+// https://www.mongodb.com/docs/manual/core/timeseries/timeseries-bucketing/
+
+// BucketLimit is the maximum number of measurements stored in a bucket.
+const BucketLimit = 1000
+
+// the rounding of the bucket start and the maximum bucket span per granularity
+var bucketGranularities = map[string][2]time.Duration{
+	"seconds": {time.Minute, time.Hour},
+	"minutes": {time.Hour, 24 * time.Hour},
+	"hours":   {24 * time.Hour, 30 * 24 * time.Hour},
+}
+
+// IsTimeSeries will return whether the collection is a time-series collection
+// that stores its measurements in a separate bucket collection.
+func (c *Collection) IsTimeSeries() bool {
+	return c.config.TimeField != ""
+}
+
+// Bucket will add the specified measurement to the bucket collection of the
+// time-series collection. The measurement is added to the newest bucket with
+// the same meta value that covers its time and has space left, otherwise a new
+// bucket is created. The measurement receives an _id if missing. The previous
+// bucket (nil if created) and the current bucket are returned.
+func (c *Collection) Bucket(buckets *Collection, doc bsonkit.Doc) (bsonkit.Doc, bsonkit.Doc, error) {
+	// check collection
+	if !c.IsTimeSeries() {
+		return nil, nil, fmt.Errorf("collection is not a time-series collection")
+	}
+
+	// get time
+	date, ok := bsonkit.Get(doc, c.config.TimeField).(primitive.DateTime)
+	if !ok {
+		return nil, nil, fmt.Errorf("'%s' must be present and contain a valid BSON UTC datetime value", c.config.TimeField)
+	}
+
+	// ensure object id
+	if bsonkit.Get(doc, "_id") == bsonkit.Missing {
+		_, err := bsonkit.Put(doc, "_id", primitive.NewObjectID(), true)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// clone measurement
+	doc = bsonkit.Clone(doc)
+
+	// get meta
+	var meta interface{} = bsonkit.Missing
+	if c.config.MetaField != "" {
+		meta = bsonkit.Get(doc, c.config.MetaField)
+	}
+
+	// get bucket rounding and span
+	granularity := bucketGranularities[c.config.Granularity]
+
+	// find open bucket, newest first
+	var bucket bsonkit.Doc
+	for i := len(buckets.Documents.List) - 1; i >= 0; i-- {
+		// get candidate
+		candidate := buckets.Documents.List[i]
+
+		// check meta
+		if bsonkit.Compare(bsonkit.Get(candidate, "meta"), meta) != 0 {
+			continue
+		}
+
+		// check count
+		times, _ := bsonkit.Get(candidate, "data."+c.config.TimeField).(bson.D)
+		if len(times) >= BucketLimit {
+			continue
+		}
+
+		// check span
+		start, _ := bsonkit.Get(candidate, "control.min."+c.config.TimeField).(primitive.DateTime)
+		if date < start || date.Time().Sub(start.Time()) >= granularity[1] {
+			continue
+		}
+
+		bucket = candidate
+		break
+	}
+
+	// add to existing bucket
+	if bucket != nil {
+		// prepare bucket
+		next := bsonkit.Clone(bucket)
+		c.measure(next, doc)
+
+		// replace bucket
+		_, err := buckets.Replace(&bson.D{
+			bson.E{Key: "_id", Value: bsonkit.Get(bucket, "_id")},
+		}, next, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return bucket, next, nil
+	}
+
+	// get bucket start
+	start := date.Time().UTC().Truncate(granularity[0])
+
+	// prepare bucket
+	bucket = &bson.D{
+		bson.E{Key: "_id", Value: primitive.NewObjectIDFromTimestamp(start)},
+		bson.E{Key: "control", Value: bson.D{
+			bson.E{Key: "version", Value: int32(1)},
+			bson.E{Key: "min", Value: bson.D{
+				bson.E{Key: c.config.TimeField, Value: primitive.NewDateTimeFromTime(start)},
+			}},
+			bson.E{Key: "max", Value: bson.D{}},
+		}},
+	}
+	if meta != bsonkit.Missing {
+		*bucket = append(*bucket, bson.E{Key: "meta", Value: meta})
+	}
+	*bucket = append(*bucket, bson.E{Key: "data", Value: bson.D{}})
+
+	// add measurement
+	c.measure(bucket, doc)
+
+	// insert bucket
+	_, err := buckets.Insert(bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, bucket, nil
+}
+
+// Unpack will return the measurements stored in the specified buckets of the
+// time-series collection. The time and meta fields are placed first.
+func (c *Collection) Unpack(buckets bsonkit.List) bsonkit.List {
+	// prepare list
+	list := make(bsonkit.List, 0, len(buckets))
+
+	// unpack buckets
+	for _, bucket := range buckets {
+		// get data and meta
+		data, _ := bsonkit.Get(bucket, "data").(bson.D)
+		meta := bsonkit.Get(bucket, "meta")
+
+		// get count
+		times, _ := bsonkit.Get(bucket, "data."+c.config.TimeField).(bson.D)
+
+		// unpack measurements
+		for i, entry := range times {
+			// prepare measurement
+			doc := bson.D{bson.E{Key: c.config.TimeField, Value: entry.Value}}
+			if c.config.MetaField != "" && meta != bsonkit.Missing {
+				doc = append(doc, bson.E{Key: c.config.MetaField, Value: meta})
+			}
+
+			// add fields
+			key := strconv.Itoa(i)
+			for _, field := range data {
+				if field.Key == c.config.TimeField {
+					continue
+				}
+				values, _ := field.Value.(bson.D)
+				for _, value := range values {
+					if value.Key == key {
+						doc = append(doc, bson.E{Key: field.Key, Value: value.Value})
+						break
+					}
+				}
+			}
+
+			list = append(list, bsonkit.Clone(&doc))
+		}
+	}
+
+	return list
+}
+
+func (c *Collection) measure(bucket, doc bsonkit.Doc) {
+	// get control bounds and data
+	minimum, _ := bsonkit.Get(bucket, "control.min").(bson.D)
+	maximum, _ := bsonkit.Get(bucket, "control.max").(bson.D)
+	data, _ := bsonkit.Get(bucket, "data").(bson.D)
+
+	// get index
+	times, _ := bsonkit.Get(bucket, "data."+c.config.TimeField).(bson.D)
+	key := strconv.Itoa(len(times))
+
+	// add fields
+	for _, field := range *doc {
+		// skip meta
+		if field.Key == c.config.MetaField {
+			continue
+		}
+
+		// add value
+		data = appendValue(data, field.Key, key, field.Value)
+
+		// update bounds, the start time is kept
+		if field.Key != c.config.TimeField {
+			minimum = updateBound(minimum, field.Key, field.Value, -1)
+		}
+		maximum = updateBound(maximum, field.Key, field.Value, 1)
+	}
+
+	// set control bounds and data
+	_, _ = bsonkit.Put(bucket, "control.min", minimum, false)
+	_, _ = bsonkit.Put(bucket, "control.max", maximum, false)
+	_, _ = bsonkit.Put(bucket, "data", data, false)
+}
+
+func appendValue(data bson.D, field, key string, value interface{}) bson.D {
+	// append to existing column
+	for i := range data {
+		if data[i].Key == field {
+			column, _ := data[i].Value.(bson.D)
+			data[i].Value = append(column, bson.E{Key: key, Value: value})
+			return data
+		}
+	}
+
+	// otherwise, add column
+	return append(data, bson.E{Key: field, Value: bson.D{
+		bson.E{Key: key, Value: value},
+	}})
+}
+
+func updateBound(bounds bson.D, field string, value interface{}, direction int) bson.D {
+	// update existing bound
+	for i := range bounds {
+		if bounds[i].Key == field {
+			if bsonkit.Compare(value, bounds[i].Value)*direction > 0 {
+				bounds[i].Value = value
+			}
+			return bounds
+		}
+	}
+
+	// otherwise, add bound
+	return append(bounds, bson.E{Key: field, Value: value})
+}
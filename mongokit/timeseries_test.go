@@ -0,0 +1,82 @@
+package mongokit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+func TestCollectionBucket(t *testing.T) {
+	series, err := CreateCollection(CollectionConfig{
+		TimeField: "ts",
+		MetaField: "meta",
+	}, false)
+	assert.NoError(t, err)
+	assert.True(t, series.IsTimeSeries())
+	assert.Equal(t, "seconds", series.Config().Granularity)
+
+	buckets := NewCollection(true)
+
+	start := time.Date(2020, 1, 1, 10, 30, 15, 0, time.UTC)
+	date := func(d time.Duration) primitive.DateTime {
+		return primitive.NewDateTimeFromTime(start.Add(d))
+	}
+
+	/* create */
+
+	doc := bsonkit.MustConvert(bson.M{"ts": date(0), "meta": "a", "n": int32(2)})
+	before, after, err := series.Bucket(buckets, doc)
+	assert.NoError(t, err)
+	assert.Nil(t, before)
+	assert.NotEqual(t, bsonkit.Missing, bsonkit.Get(doc, "_id"))
+	assert.Equal(t, date(-15*time.Second), bsonkit.Get(after, "control.min.ts"))
+	assert.Equal(t, date(0), bsonkit.Get(after, "control.max.ts"))
+	assert.Equal(t, "a", bsonkit.Get(after, "meta"))
+
+	/* append */
+
+	before, after, err = series.Bucket(buckets, bsonkit.MustConvert(bson.M{
+		"_id": 2, "ts": date(time.Minute), "meta": "a", "n": int32(1),
+	}))
+	assert.NoError(t, err)
+	assert.NotNil(t, before)
+	assert.Equal(t, int32(1), bsonkit.Get(after, "control.min.n"))
+	assert.Equal(t, int32(2), bsonkit.Get(after, "control.max.n"))
+	assert.Equal(t, date(time.Minute), bsonkit.Get(after, "control.max.ts"))
+	assert.Len(t, buckets.Documents.List, 1)
+
+	/* meta and span */
+
+	_, _, err = series.Bucket(buckets, bsonkit.MustConvert(bson.M{
+		"ts": date(0), "meta": "b", "n": int32(3),
+	}))
+	assert.NoError(t, err)
+	_, _, err = series.Bucket(buckets, bsonkit.MustConvert(bson.M{
+		"ts": date(2 * time.Hour), "meta": "a", "n": int32(4),
+	}))
+	assert.NoError(t, err)
+	assert.Len(t, buckets.Documents.List, 3)
+
+	/* invalid */
+
+	_, _, err = series.Bucket(buckets, bsonkit.MustConvert(bson.M{
+		"meta": "a", "n": int32(5),
+	}))
+	assert.Error(t, err)
+
+	/* unpack */
+
+	list := series.Unpack(buckets.Documents.List)
+	assert.Len(t, list, 4)
+	assert.Equal(t, &bson.D{
+		{Key: "ts", Value: date(time.Minute)},
+		{Key: "meta", Value: "a"},
+		{Key: "_id", Value: int64(2)},
+		{Key: "n", Value: int32(1)},
+	}, list[1])
+}
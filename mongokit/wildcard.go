@@ -0,0 +1,188 @@
+package mongokit
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+// WildcardKey is the path segment that denotes a wildcard index key.
+const WildcardKey = "$**"
+
+// IsWildcard returns whether the specified index key path is a wildcard path
+// e.g. "$**" or "foo.$**".
+func IsWildcard(path string) bool {
+	return path == WildcardKey || strings.HasSuffix(path, "."+WildcardKey)
+}
+
+// wildcard describes the paths indexed by a wildcard index.
+type wildcard struct {
+	prefix  string
+	fields  []string
+	exclude bool
+	id      bool
+}
+
+func newWildcard(path string, projection bsonkit.Doc) (*wildcard, error) {
+	// prepare wildcard
+	w := &wildcard{
+		prefix:  strings.TrimSuffix(strings.TrimSuffix(path, WildcardKey), "."),
+		exclude: true,
+	}
+
+	// check projection
+	if projection == nil {
+		return w, nil
+	}
+
+	// check prefix
+	if w.prefix != "" {
+		return nil, fmt.Errorf("the field 'wildcardProjection' is only allowed on an index spec on '$**' alone")
+	}
+
+	// check emptiness
+	if len(*projection) == 0 {
+		return nil, fmt.Errorf("the field 'wildcardProjection' must be a non-empty object")
+	}
+
+	// parse projection
+	var include, exclude bool
+	for _, field := range *projection {
+		// check value
+		class, _ := bsonkit.Inspect(field.Value)
+		if class != bsonkit.Number && class != bsonkit.Boolean {
+			return nil, fmt.Errorf("wildcardProjection: expected number or boolean for field %q", field.Key)
+		}
+
+		// get truthiness
+		value := truthy(field.Value)
+
+		// handle id
+		if field.Key == "_id" {
+			w.id = value
+			continue
+		}
+
+		// add field
+		w.fields = append(w.fields, field.Key)
+		if value {
+			include = true
+		} else {
+			exclude = true
+		}
+	}
+
+	// check mixing
+	if include && exclude {
+		return nil, fmt.Errorf("wildcardProjection: cannot mix inclusion and exclusion")
+	}
+
+	// set mode
+	w.exclude = !include
+
+	return w, nil
+}
+
+// includes returns whether the specified path is indexed by the wildcard.
+func (w *wildcard) includes(path string) bool {
+	// check prefix
+	if w.prefix != "" {
+		return path == w.prefix || strings.HasPrefix(path, w.prefix+".")
+	}
+
+	// handle id
+	if bsonkit.PathSegment(path) == "_id" {
+		return w.id
+	}
+
+	// check fields
+	for _, field := range w.fields {
+		if path == field || strings.HasPrefix(path, field+".") {
+			return !w.exclude
+		}
+	}
+
+	return w.exclude
+}
+
+// entries returns the entries for all indexed leaf values of the document.
+func (w *wildcard) entries(doc bsonkit.Doc) bsonkit.List {
+	// prepare list
+	var list bsonkit.List
+
+	// walk document
+	for _, field := range *doc {
+		w.walk(doc, field.Key, field.Value, &list)
+	}
+
+	return list
+}
+
+func (w *wildcard) walk(doc bsonkit.Doc, path string, value interface{}, list *bsonkit.List) {
+	// skip paths that cannot contain indexed paths
+	if !w.includes(path) && !w.contains(path) {
+		return
+	}
+
+	switch value := value.(type) {
+	case bson.D:
+		// walk fields of non-empty documents
+		if len(value) > 0 {
+			for _, field := range value {
+				w.walk(doc, path+"."+field.Key, field.Value, list)
+			}
+			return
+		}
+	case bson.A:
+		// walk elements of non-empty arrays, nested arrays are not traversed
+		if len(value) > 0 {
+			for _, item := range value {
+				if sub, ok := item.(bson.D); ok {
+					w.walk(doc, path, sub, list)
+				} else {
+					w.add(doc, path, item, list)
+				}
+			}
+			return
+		}
+	}
+
+	// add leaf value
+	w.add(doc, path, value, list)
+}
+
+func (w *wildcard) add(doc bsonkit.Doc, path string, value interface{}, list *bsonkit.List) {
+	// check path
+	if !w.includes(path) {
+		return
+	}
+
+	// add entry
+	*list = append(*list, &bson.D{
+		bson.E{Key: "path", Value: path},
+		bson.E{Key: "value", Value: value},
+		bson.E{Key: "doc", Value: doc},
+	})
+}
+
+// contains returns whether the specified path may contain indexed paths.
+func (w *wildcard) contains(path string) bool {
+	// check prefix
+	if w.prefix != "" {
+		return strings.HasPrefix(w.prefix, path+".")
+	}
+
+	// check fields
+	if !w.exclude {
+		for _, field := range w.fields {
+			if strings.HasPrefix(field, path+".") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,347 @@
+package lungo
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+)
+
+// CollectionStats will return the storage statistics of the namespace with the
+// specified handle. The sizes are estimated from the BSON encoded documents
+// and indexed values and divided by the specified scale.
+func (t *Transaction) CollectionStats(handle Handle, scale int64) (bsonkit.Doc, error) {
+	// acquire read lock
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// validate handle
+	err := handle.Validate(true)
+	if err != nil {
+		return nil, err
+	}
+
+	// get stats
+	stats, err := t.collectionStats(handle, scale)
+	if err != nil {
+		return nil, err
+	}
+
+	// add namespace
+	stats = append(bson.D{
+		bson.E{Key: "ns", Value: handle.String()},
+	}, stats...)
+
+	return &stats, nil
+}
+
+// DatabaseStats will return the storage statistics of the specified database.
+// The sizes are divided by the specified scale.
+func (t *Transaction) DatabaseStats(db string, scale int64) (bsonkit.Doc, error) {
+	// acquire read lock
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// validate handle
+	err := Handle{db}.Validate(false)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect stats
+	var collections, views, objects, indexes, dataSize, indexSize int64
+	for handle, namespace := range t.catalog.Namespaces {
+		// check database
+		if handle[0] != db {
+			continue
+		}
+
+		// count views
+		if namespace.IsView() || namespace.IsTimeSeries() {
+			views++
+			continue
+		}
+
+		// collect collection stats
+		size, err := documentsSize(namespace.Documents.List)
+		if err != nil {
+			return nil, err
+		}
+		collections++
+		objects += int64(len(namespace.Documents.List))
+		dataSize += size
+		for _, index := range namespace.Indexes {
+			indexes++
+			indexSize += index.Size()
+		}
+	}
+
+	// compute average object size
+	var avgObjSize float64
+	if objects > 0 {
+		avgObjSize = float64(dataSize) / float64(objects)
+	}
+
+	return &bson.D{
+		bson.E{Key: "db", Value: db},
+		bson.E{Key: "collections", Value: collections},
+		bson.E{Key: "views", Value: views},
+		bson.E{Key: "objects", Value: objects},
+		bson.E{Key: "avgObjSize", Value: avgObjSize},
+		bson.E{Key: "dataSize", Value: float64(dataSize) / float64(scale)},
+		bson.E{Key: "storageSize", Value: float64(dataSize) / float64(scale)},
+		bson.E{Key: "indexes", Value: indexes},
+		bson.E{Key: "indexSize", Value: float64(indexSize) / float64(scale)},
+		bson.E{Key: "totalSize", Value: float64(dataSize+indexSize) / float64(scale)},
+		bson.E{Key: "scaleFactor", Value: scale},
+	}, nil
+}
+
+// IndexStats will return the usage statistics of the indexes of the namespace
+// with the specified handle.
+func (t *Transaction) IndexStats(handle Handle) (bsonkit.List, error) {
+	// acquire read lock
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// validate handle
+	err := handle.Validate(true)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.indexStats(handle)
+}
+
+func (t *Transaction) collectionStats(handle Handle, scale int64) (bson.D, error) {
+	// get namespace
+	namespace := t.catalog.Namespaces[handle]
+	if namespace != nil && namespace.IsView() {
+		return nil, viewError(handle)
+	}
+
+	// get stored namespace, time-series collections store their buckets
+	stored := namespace
+	if namespace != nil && namespace.IsTimeSeries() {
+		stored = t.catalog.Namespaces[handle.Buckets()]
+	}
+	if stored == nil {
+		stored = mongokit.NewCollection(false)
+	}
+
+	// resolve namespace to count documents
+	resolved, err := t.resolve(handle, 0)
+	if err != nil {
+		return nil, err
+	} else if resolved == nil {
+		resolved = stored
+	}
+
+	// compute data size
+	size, err := documentsSize(stored.Documents.List)
+	if err != nil {
+		return nil, err
+	}
+
+	// get sorted index names
+	names := make([]string, 0, len(stored.Indexes))
+	for name := range stored.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// collect index sizes and entries
+	var totalIndexSize int64
+	indexSizes := make(bson.D, 0, len(names))
+	indexEntries := make(bson.D, 0, len(names))
+	for _, name := range names {
+		index := stored.Indexes[name]
+		totalIndexSize += index.Size()
+		indexSizes = append(indexSizes, bson.E{Key: name, Value: float64(index.Size()) / float64(scale)})
+		indexEntries = append(indexEntries, bson.E{Key: name, Value: int64(index.Len())})
+	}
+
+	// compute average object size
+	count := int64(len(resolved.Documents.List))
+	var avgObjSize float64
+	if count > 0 {
+		avgObjSize = float64(size) / float64(count)
+	}
+
+	// prepare stats
+	stats := bson.D{
+		bson.E{Key: "size", Value: float64(size) / float64(scale)},
+		bson.E{Key: "count", Value: count},
+		bson.E{Key: "avgObjSize", Value: avgObjSize},
+		bson.E{Key: "storageSize", Value: float64(size) / float64(scale)},
+		bson.E{Key: "freeStorageSize", Value: float64(0)},
+		bson.E{Key: "capped", Value: stored.IsCapped()},
+	}
+
+	// add capped limits
+	if stored.IsCapped() {
+		config := stored.Config()
+		stats = append(stats, bson.E{Key: "max", Value: config.MaxDocuments})
+		stats = append(stats, bson.E{Key: "maxSize", Value: float64(config.SizeInBytes) / float64(scale)})
+	}
+
+	// add time-series info
+	if namespace != nil && namespace.IsTimeSeries() {
+		stats = append(stats, bson.E{Key: "timeseries", Value: bson.D{
+			bson.E{Key: "bucketsNs", Value: handle.Buckets().String()},
+			bson.E{Key: "bucketCount", Value: int64(len(stored.Documents.List))},
+		}})
+	}
+
+	// add index stats
+	stats = append(stats,
+		bson.E{Key: "nindexes", Value: int64(len(names))},
+		bson.E{Key: "indexBuilds", Value: bson.A{}},
+		bson.E{Key: "totalIndexSize", Value: float64(totalIndexSize) / float64(scale)},
+		bson.E{Key: "totalSize", Value: float64(size+totalIndexSize) / float64(scale)},
+		bson.E{Key: "indexSizes", Value: indexSizes},
+		bson.E{Key: "indexEntries", Value: indexEntries},
+		bson.E{Key: "scaleFactor", Value: scale},
+	)
+
+	return stats, nil
+}
+
+func (t *Transaction) indexStats(handle Handle) (bsonkit.List, error) {
+	// get namespace
+	namespace := t.catalog.Namespaces[handle]
+	if namespace == nil {
+		return nil, nil
+	} else if namespace.IsView() {
+		return nil, viewError(handle)
+	}
+
+	// get host
+	host := hostName()
+
+	// collect stats
+	var list bsonkit.List
+	for _, spec := range listIndexes(namespace) {
+		// get index
+		name, _ := bsonkit.Get(spec, "name").(string)
+		index := namespace.Indexes[name]
+		if index == nil {
+			continue
+		}
+
+		// get accesses
+		ops, since := index.Accesses()
+
+		// add stats
+		list = append(list, &bson.D{
+			bson.E{Key: "name", Value: name},
+			bson.E{Key: "key", Value: bsonkit.Get(spec, "key")},
+			bson.E{Key: "host", Value: host},
+			bson.E{Key: "accesses", Value: bson.D{
+				bson.E{Key: "ops", Value: ops},
+				bson.E{Key: "since", Value: primitive.NewDateTimeFromTime(since)},
+			}},
+			bson.E{Key: "spec", Value: *spec},
+		})
+	}
+
+	return list, nil
+}
+
+func (t *Transaction) statsStages(handle Handle) map[string]mongokit.StageOperator {
+	return map[string]mongokit.StageOperator{
+		"$collStats": func(_ mongokit.PipelineContext, _ bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+			// get options
+			opts, ok := v.(bson.D)
+			if !ok {
+				return nil, commandError(5447000, "FailedToParse", "%s must be specified as an object", stage)
+			}
+
+			// prepare document
+			doc := bson.D{
+				bson.E{Key: "ns", Value: handle.String()},
+				bson.E{Key: "host", Value: hostName()},
+				bson.E{Key: "localTime", Value: primitive.NewDateTimeFromTime(time.Now())},
+			}
+
+			// add requested stats
+			for _, opt := range opts {
+				switch opt.Key {
+				case "storageStats":
+					// get scale
+					scale := int64(1)
+					if doc, ok := opt.Value.(bson.D); ok && len(doc) > 0 {
+						value, err := commandInt(doc, "scale")
+						if err != nil {
+							return nil, err
+						} else if value != 0 {
+							scale = int64(value)
+						}
+					}
+					if scale < 1 {
+						return nil, commandError(2, "BadValue", "scale has to be >= 1")
+					}
+
+					// get stats
+					stats, err := t.collectionStats(handle, scale)
+					if err != nil {
+						return nil, err
+					}
+					doc = append(doc, bson.E{Key: "storageStats", Value: stats})
+				case "count":
+					// resolve namespace
+					namespace, err := t.resolve(handle, 0)
+					if err != nil {
+						return nil, err
+					}
+					var count int64
+					if namespace != nil {
+						count = int64(len(namespace.Documents.List))
+					}
+					doc = append(doc, bson.E{Key: "count", Value: count})
+				default:
+					return nil, commandError(40415, "Location40415", "BSON field '%s.%s' is not supported", stage, opt.Key)
+				}
+			}
+
+			return bsonkit.List{&doc}, nil
+		},
+		"$indexStats": func(_ mongokit.PipelineContext, _ bsonkit.List, stage string, v interface{}) (bsonkit.List, error) {
+			// check options
+			if opts, ok := v.(bson.D); !ok || len(opts) > 0 {
+				return nil, commandError(28803, "Location28803", "The %s stage specification must be an empty object", stage)
+			}
+
+			return t.indexStats(handle)
+		},
+	}
+}
+
+func documentsSize(list bsonkit.List) (int64, error) {
+	// sum up document sizes
+	var size int64
+	for _, doc := range list {
+		bytes, err := bson.Marshal(doc)
+		if err != nil {
+			return 0, err
+		}
+		size += int64(len(bytes))
+	}
+
+	return size, nil
+}
+
+func hostName() string {
+	// get hostname
+	host, err := os.Hostname()
+	if err != nil {
+		return "localhost"
+	}
+
+	return host
+}
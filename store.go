@@ -1,21 +1,30 @@
 package lungo
 
 import (
-	"bytes"
-	"io/ioutil"
+	"errors"
+	"io"
 	"os"
 
-	"go.mongodb.org/mongo-driver/bson"
-
 	"github.com/256dpi/lungo/dbkit"
 )
 
+// ErrReadOnly is returned if a catalog is stored to a read-only store.
+var ErrReadOnly = errors.New("store is read-only")
+
 // Store is the interface that describes storage adapters.
 type Store interface {
 	Load() (*Catalog, error)
 	Store(*Catalog) error
 }
 
+// LockingStore is an optional interface implemented by storage adapters that
+// acquire an inter-process lock when loading the catalog. The engine releases
+// the lock when it is closed.
+type LockingStore interface {
+	Store
+	Unlock() error
+}
+
 // MemoryStore holds the catalog in memory.
 type MemoryStore struct {
 	catalog *Catalog
@@ -39,34 +48,89 @@ func (m *MemoryStore) Store(data *Catalog) error {
 	return nil
 }
 
+// FileLocking configures the advisory inter-process locking of a file store.
+// The lock is acquired on a separate file with a ".lock" suffix.
+type FileLocking struct {
+	// Whether locking should be disabled.
+	Disabled bool
+
+	// Whether a shared lock should be acquired. Multiple processes may hold a
+	// shared lock at the same time, but the store becomes read-only.
+	Shared bool
+
+	// Whether to wait until the lock becomes available. By default, loading
+	// the catalog fails with dbkit.ErrFileLocked if another process holds a
+	// conflicting lock.
+	Wait bool
+}
+
+// FileOptions is used to configure a file store.
+type FileOptions struct {
+	// The compression of the written file.
+	//
+	// Default: NoCompression.
+	Codec FileCodec
+
+	// The encryption of the written file, disabled if nil. Unencrypted files
+	// are still loaded and encrypted when written.
+	Encryption *FileEncryption
+
+	// The advisory inter-process locking, disabled if nil.
+	Locking *FileLocking
+}
+
 // FileStore writes the catalog to a single file on disk.
 type FileStore struct {
 	path string
 	mode os.FileMode
+	opts FileOptions
+	lock *dbkit.FileLock
 }
 
 // NewFileStore creates and returns a new file store.
 func NewFileStore(path string, mode os.FileMode) *FileStore {
+	return NewFileStoreWithOptions(path, mode, FileOptions{})
+}
+
+// NewLockedFileStore creates and returns a new file store that acquires an
+// advisory lock when the catalog is loaded. The lock is released by Unlock,
+// which is called when the engine is closed.
+func NewLockedFileStore(path string, mode os.FileMode, locking FileLocking) *FileStore {
+	return NewFileStoreWithOptions(path, mode, FileOptions{
+		Locking: &locking,
+	})
+}
+
+// NewFileStoreWithOptions creates and returns a new file store using the
+// specified options.
+func NewFileStoreWithOptions(path string, mode os.FileMode, opts FileOptions) *FileStore {
 	return &FileStore{
 		path: path,
 		mode: mode,
+		opts: opts,
 	}
 }
 
 // Load will read the catalog from disk and return it. If no file exists at the
 // specified location an empty catalog is returned.
 func (s *FileStore) Load() (*Catalog, error) {
-	// load file
-	buf, err := ioutil.ReadFile(s.path)
+	// acquire lock
+	err := s.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	// open file
+	f, err := os.Open(s.path)
 	if os.IsNotExist(err) {
 		return NewCatalog(), nil
 	} else if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
-	// decode file
-	var file File
-	err = bson.Unmarshal(buf, &file)
+	// read file
+	file, err := ReadFile(f, s.opts.Encryption)
 	if err != nil {
 		return nil, err
 	}
@@ -82,20 +146,81 @@ func (s *FileStore) Load() (*Catalog, error) {
 
 // Store will atomically write the catalog to disk.
 func (s *FileStore) Store(catalog *Catalog) error {
-	// build file from catalog
-	file := BuildFile(catalog)
+	// check mode
+	if s.opts.Locking != nil && s.opts.Locking.Shared {
+		return ErrReadOnly
+	}
 
-	// encode file
-	buf, err := bson.Marshal(file)
+	// acquire lock
+	err := s.acquire()
 	if err != nil {
 		return err
 	}
 
 	// write file
-	err = dbkit.AtomicWriteFile(s.path, bytes.NewReader(buf), s.mode)
+	err = storeFile(s.path, s.mode, BuildFile(catalog), s.opts.Codec, s.opts.Encryption)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Unlock will release the lock acquired when the catalog was loaded.
+func (s *FileStore) Unlock() error {
+	// release lock
+	err := releaseLock(s.lock)
+	s.lock = nil
+
+	return err
+}
+
+func (s *FileStore) acquire() error {
+	// check locking
+	if s.opts.Locking == nil {
+		return nil
+	}
+
+	// acquire lock
+	lock, err := acquireLock(s.lock, s.path+".lock", s.mode, *s.opts.Locking)
 	if err != nil {
 		return err
 	}
 
+	// set lock
+	s.lock = lock
+
 	return nil
 }
+
+func storeFile(path string, mode os.FileMode, file *File, codec FileCodec, encryption *FileEncryption) error {
+	// encode file incrementally
+	reader, writer := io.Pipe()
+	go func() {
+		_ = writer.CloseWithError(WriteFile(writer, file, codec, encryption))
+	}()
+
+	// write file, closing the reader stops the encoder if writing fails
+	err := dbkit.AtomicWriteFile(path, reader, mode)
+	_ = reader.Close()
+
+	return err
+}
+
+func acquireLock(lock *dbkit.FileLock, path string, mode os.FileMode, locking FileLocking) (*dbkit.FileLock, error) {
+	// check lock
+	if lock != nil || locking.Disabled {
+		return lock, nil
+	}
+
+	return dbkit.LockFile(path, mode, locking.Shared, locking.Wait)
+}
+
+func releaseLock(lock *dbkit.FileLock) error {
+	// check lock
+	if lock == nil {
+		return nil
+	}
+
+	return lock.Unlock()
+}
@@ -4,17 +4,20 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/dbkit"
 	"github.com/256dpi/lungo/mongokit"
 )
 
 func TestFileStore(t *testing.T) {
 	_ = os.Remove("./test.bson")
+	defer os.Remove("./test.bson.lock")
 
 	store := NewFileStore("./test.bson", 0666)
 
@@ -62,6 +65,13 @@ func TestFileStore(t *testing.T) {
 
 	bytes, err := ioutil.ReadFile("./test.bson")
 	assert.NoError(t, err)
+	assert.Equal(t, "LNGO", string(bytes[:4]))
+
+	file, err := DecodeFile(bytes, nil)
+	assert.NoError(t, err)
+
+	bytes, err = bson.Marshal(file)
+	assert.NoError(t, err)
 
 	var out bson.M
 	err = bson.Unmarshal(bytes, &out)
@@ -149,7 +159,7 @@ func TestFileStore(t *testing.T) {
 	txn, err = engine.Begin(nil, false)
 	assert.NoError(t, err)
 
-	res, err = txn.Find(handle, bsonkit.MustConvert(bson.M{}), nil, 0, 0)
+	res, err = txn.Find(handle, bsonkit.MustConvert(bson.M{}), nil, 0, 0, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, bsonkit.List{
 		bsonkit.MustConvert(bson.M{
@@ -185,3 +195,464 @@ func TestFileStore(t *testing.T) {
 
 	engine.Close()
 }
+
+func TestWALStore(t *testing.T) {
+	_ = os.Remove("./test.bson")
+	_ = os.Remove("./test.bson.wal")
+	defer os.Remove("./test.bson")
+	defer os.Remove("./test.bson.wal")
+	defer os.Remove("./test.bson.lock")
+
+	store := NewWALStore("./test.bson", 0666, 4)
+
+	engine, err := CreateEngine(Options{Store: store})
+	assert.NoError(t, err)
+
+	handle := Handle{"foo", "bar"}
+
+	commit := func(fn func(txn *Transaction)) {
+		txn, err := engine.Begin(nil, true)
+		assert.NoError(t, err)
+		fn(txn)
+		err = engine.Commit(txn)
+		assert.NoError(t, err)
+	}
+
+	commit(func(txn *Transaction) {
+		_, err := txn.Insert(handle, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": "a", "n": int32(1)}),
+			bsonkit.MustConvert(bson.M{"_id": "b", "n": int32(2)}),
+			bsonkit.MustConvert(bson.M{"_id": "c", "n": int32(3)}),
+		}, true)
+		assert.NoError(t, err)
+	})
+
+	commit(func(txn *Transaction) {
+		_, err := txn.CreateIndex(handle, "n", mongokit.IndexConfig{
+			Key: bsonkit.MustConvert(bson.M{"n": int32(1)}),
+		})
+		assert.NoError(t, err)
+
+		_, err = txn.Update(handle, bsonkit.MustConvert(bson.M{"_id": "b"}), nil, bsonkit.MustConvert(bson.M{
+			"$set": bson.M{"n": int32(4)},
+		}), 0, 1, false, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = txn.Delete(handle, bsonkit.MustConvert(bson.M{"_id": "a"}), nil, 0, 1, nil)
+		assert.NoError(t, err)
+	})
+
+	commit(func(txn *Transaction) {
+		err := txn.Create(Handle{"foo", "baz"}, mongokit.CollectionConfig{})
+		assert.NoError(t, err)
+	})
+
+	assert.Equal(t, 3, store.records)
+
+	info, err := os.Stat("./test.bson.wal")
+	assert.NoError(t, err)
+	assert.True(t, info.Size() > 0)
+
+	/* reload */
+
+	reload := func() *Catalog {
+		store := NewWALStoreWithOptions("./test.bson", 0666, WALOptions{
+			CompactAfter: 4,
+			Locking:      &FileLocking{Disabled: true},
+		})
+		catalog, err := store.Load()
+		assert.NoError(t, err)
+		return catalog
+	}
+
+	assert.Equal(t, BuildFile(engine.Catalog()), BuildFile(reload()))
+
+	/* torn record */
+
+	log, err := os.OpenFile("./test.bson.wal", os.O_WRONLY|os.O_APPEND, 0666)
+	assert.NoError(t, err)
+	_, err = log.Write([]byte{42, 0, 0, 0, 1, 2})
+	assert.NoError(t, err)
+	assert.NoError(t, log.Close())
+
+	torn := NewWALStoreWithOptions("./test.bson", 0666, WALOptions{
+		CompactAfter: 4,
+		Locking:      &FileLocking{Disabled: true},
+	})
+	catalog, err := torn.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, BuildFile(engine.Catalog()), BuildFile(catalog))
+
+	info2, err := os.Stat("./test.bson.wal")
+	assert.NoError(t, err)
+	assert.Equal(t, info.Size()+6, info2.Size())
+
+	txn := NewTransaction(catalog)
+	_, err = txn.Insert(Handle{"foo", "qux"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "a"}),
+	}, true)
+	assert.NoError(t, err)
+	assert.NoError(t, torn.Store(txn.Catalog()))
+
+	assert.Equal(t, BuildFile(txn.Catalog()), BuildFile(reload()))
+
+	/* compaction */
+
+	commit(func(txn *Transaction) {
+		err := txn.Drop(Handle{"foo", "baz"})
+		assert.NoError(t, err)
+	})
+
+	assert.Equal(t, 0, store.records)
+
+	info, err = os.Stat("./test.bson.wal")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+
+	buf, err := ioutil.ReadFile("./test.bson")
+	assert.NoError(t, err)
+	assert.Equal(t, "LNGO", string(buf[:4]))
+	assert.Equal(t, byte(SnappyCompression), buf[6])
+
+	file, err := DecodeFile(buf, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), file.Sequence)
+
+	commit(func(txn *Transaction) {
+		_, err := txn.Insert(handle, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": "d", "n": int32(5)}),
+		}, true)
+		assert.NoError(t, err)
+	})
+
+	catalog = reload()
+	assert.Equal(t, BuildFile(engine.Catalog()), BuildFile(catalog))
+	assert.Nil(t, catalog.Namespaces[Handle{"foo", "baz"}])
+	assert.Len(t, catalog.Namespaces[handle].Documents.List, 3)
+	assert.Len(t, catalog.Namespaces[handle].Indexes, 2)
+
+	engine.Close()
+}
+
+func TestWALStoreClustered(t *testing.T) {
+	_ = os.Remove("./test.bson")
+	_ = os.Remove("./test.bson.wal")
+	defer os.Remove("./test.bson")
+	defer os.Remove("./test.bson.wal")
+	defer os.Remove("./test.bson.lock")
+
+	engine, err := CreateEngine(Options{Store: NewWALStore("./test.bson", 0666, 0)})
+	assert.NoError(t, err)
+
+	handle := Handle{"foo", "bar"}
+
+	txn, err := engine.Begin(nil, true)
+	assert.NoError(t, err)
+	err = txn.Create(handle, mongokit.CollectionConfig{Clustered: true})
+	assert.NoError(t, err)
+	_, err = txn.Insert(handle, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "d"}),
+		bsonkit.MustConvert(bson.M{"_id": "b"}),
+	}, true)
+	assert.NoError(t, err)
+	assert.NoError(t, engine.Commit(txn))
+
+	txn, err = engine.Begin(nil, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(handle, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "c"}),
+		bsonkit.MustConvert(bson.M{"_id": "a"}),
+		bsonkit.MustConvert(bson.M{"_id": "e"}),
+	}, true)
+	assert.NoError(t, err)
+	assert.NoError(t, engine.Commit(txn))
+
+	engine.Close()
+
+	catalog, err := loadStore(NewWALStore("./test.bson", 0666, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{"a", "b", "c", "d", "e"}, bsonkit.Pick(catalog.Namespaces[handle].Documents.List, "_id", false))
+}
+
+func TestDirStore(t *testing.T) {
+	_ = os.RemoveAll("./test")
+	defer os.RemoveAll("./test")
+
+	store := NewDirStore("./test", 0666)
+
+	engine, err := CreateEngine(Options{Store: store})
+	assert.NoError(t, err)
+
+	commit := func(fn func(txn *Transaction)) {
+		txn, err := engine.Begin(nil, true)
+		assert.NoError(t, err)
+		fn(txn)
+		err = engine.Commit(txn)
+		assert.NoError(t, err)
+	}
+
+	commit(func(txn *Transaction) {
+		_, err := txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": "a", "n": int32(1)}),
+		}, true)
+		assert.NoError(t, err)
+
+		_, err = txn.Insert(Handle{"foo", "baz"}, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": "b", "n": int32(2)}),
+		}, true)
+		assert.NoError(t, err)
+	})
+
+	files := func() map[string]string {
+		return store.manifest.Namespaces
+	}
+
+	first := map[string]string{}
+	for name, file := range files() {
+		first[name] = file
+	}
+	assert.Len(t, first, 3)
+
+	commit(func(txn *Transaction) {
+		_, err := txn.Update(Handle{"foo", "baz"}, bsonkit.MustConvert(bson.M{"_id": "b"}), nil, bsonkit.MustConvert(bson.M{
+			"$set": bson.M{"n": int32(3)},
+		}), 0, 1, false, nil, nil)
+		assert.NoError(t, err)
+	})
+
+	second := files()
+	assert.Equal(t, first["foo.bar"], second["foo.bar"])
+	assert.NotEqual(t, first["foo.baz"], second["foo.baz"])
+	assert.NotEqual(t, first["local.oplog"], second["local.oplog"])
+
+	_, err = os.Stat("./test/" + first["foo.baz"])
+	assert.True(t, os.IsNotExist(err))
+
+	commit(func(txn *Transaction) {
+		err := txn.Drop(Handle{"foo", "bar"})
+		assert.NoError(t, err)
+	})
+
+	_, err = os.Stat("./test/" + first["foo.bar"])
+	assert.True(t, os.IsNotExist(err))
+
+	entries, err := ioutil.ReadDir("./test")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+
+	/* failed cleanup */
+
+	obsolete := files()["foo.baz"]
+	assert.NoError(t, os.Remove("./test/"+obsolete))
+	assert.NoError(t, os.MkdirAll("./test/"+obsolete+"/x", 0777))
+
+	commit(func(txn *Transaction) {
+		_, err := txn.Update(Handle{"foo", "baz"}, bsonkit.MustConvert(bson.M{"_id": "b"}), nil, bsonkit.MustConvert(bson.M{
+			"$set": bson.M{"n": int32(4)},
+		}), 0, 1, false, nil, nil)
+		assert.NoError(t, err)
+	})
+
+	assert.NotEqual(t, obsolete, files()["foo.baz"])
+	assert.NoError(t, os.RemoveAll("./test/"+obsolete))
+
+	engine.Close()
+
+	buf, err := ioutil.ReadFile("./test/" + files()["foo.baz"])
+	assert.NoError(t, err)
+	assert.Equal(t, "LNGO", string(buf[:4]))
+
+	/* orphaned files */
+
+	assert.NoError(t, ioutil.WriteFile("./test/ns-999.bson", buf, 0666))
+	assert.NoError(t, ioutil.WriteFile("./test/ns-1000.bson.tmp", buf, 0666))
+
+	catalog, err := loadStore(NewDirStore("./test", 0666))
+	assert.NoError(t, err)
+	assert.Equal(t, BuildFile(engine.Catalog()), BuildFile(catalog))
+
+	entries, err = ioutil.ReadDir("./test")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+}
+
+func TestLockedFileStore(t *testing.T) {
+	_ = os.Remove("./test.bson")
+	defer os.Remove("./test.bson")
+	defer os.Remove("./test.bson.lock")
+
+	engine1, err := CreateEngine(Options{
+		Store: NewLockedFileStore("./test.bson", 0666, FileLocking{}),
+	})
+	assert.NoError(t, err)
+
+	txn, err := engine1.Begin(nil, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "a"}),
+	}, true)
+	assert.NoError(t, err)
+	assert.NoError(t, engine1.Commit(txn))
+
+	/* exclusive */
+
+	engine2, err := CreateEngine(Options{
+		Store: NewLockedFileStore("./test.bson", 0666, FileLocking{}),
+	})
+	assert.Equal(t, dbkit.ErrFileLocked, err)
+	assert.Nil(t, engine2)
+
+	_, err = loadStore(NewLockedFileStore("./test.bson", 0666, FileLocking{Shared: true}))
+	assert.Equal(t, dbkit.ErrFileLocked, err)
+
+	/* wait */
+
+	done := make(chan *Engine)
+	go func() {
+		engine, err := CreateEngine(Options{
+			Store: NewLockedFileStore("./test.bson", 0666, FileLocking{Wait: true}),
+		})
+		assert.NoError(t, err)
+		done <- engine
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	engine1.Close()
+
+	engine2 = <-done
+	assert.Len(t, engine2.Catalog().Namespaces[Handle{"foo", "bar"}].Documents.List, 1)
+	engine2.Close()
+
+	/* shared */
+
+	engine1, err = CreateEngine(Options{
+		Store: NewLockedFileStore("./test.bson", 0666, FileLocking{Shared: true}),
+	})
+	assert.NoError(t, err)
+
+	engine2, err = CreateEngine(Options{
+		Store: NewLockedFileStore("./test.bson", 0666, FileLocking{Shared: true}),
+	})
+	assert.NoError(t, err)
+
+	_, err = CreateEngine(Options{
+		Store: NewLockedFileStore("./test.bson", 0666, FileLocking{}),
+	})
+	assert.Equal(t, dbkit.ErrFileLocked, err)
+
+	txn, err = engine2.Begin(nil, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "b"}),
+	}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrReadOnly, engine2.Commit(txn))
+
+	engine1.Close()
+	engine2.Close()
+
+	engine1, err = CreateEngine(Options{
+		Store: NewLockedFileStore("./test.bson", 0666, FileLocking{}),
+	})
+	assert.NoError(t, err)
+	engine1.Close()
+}
+
+func loadStore(store LockingStore) (*Catalog, error) {
+	defer store.Unlock()
+	return store.Load()
+}
+
+func TestStoreLocking(t *testing.T) {
+	_ = os.RemoveAll("./test")
+	defer os.RemoveAll("./test")
+	assert.NoError(t, os.Mkdir("./test", 0777))
+
+	for _, item := range []struct {
+		store func() Store
+		lock  string
+	}{
+		{
+			store: func() Store { return NewLockedFileStore("./test/file.bson", 0666, FileLocking{}) },
+			lock:  "./test/file.bson.lock",
+		},
+		{
+			store: func() Store { return NewWALStore("./test/wal.bson", 0666, 0) },
+			lock:  "./test/wal.bson.lock",
+		},
+		{
+			store: func() Store { return NewDirStore("./test/dir", 0666) },
+			lock:  "./test/dir/manifest.bson.lock",
+		},
+	} {
+		engine, err := CreateEngine(Options{Store: item.store()})
+		assert.NoError(t, err)
+		assert.FileExists(t, item.lock)
+
+		_, err = CreateEngine(Options{Store: item.store()})
+		assert.Equal(t, dbkit.ErrFileLocked, err)
+
+		engine.Close()
+
+		engine, err = CreateEngine(Options{Store: item.store()})
+		assert.NoError(t, err)
+		engine.Close()
+	}
+
+	/* wal options */
+
+	shared := func() Store {
+		return NewWALStoreWithOptions("./test/wal.bson", 0666, WALOptions{
+			Locking: &FileLocking{Shared: true},
+		})
+	}
+
+	engine, err := CreateEngine(Options{Store: NewWALStore("./test/wal.bson", 0666, 0)})
+	assert.NoError(t, err)
+
+	_, err = CreateEngine(Options{Store: shared()})
+	assert.Equal(t, dbkit.ErrFileLocked, err)
+
+	engine.Close()
+
+	engine1, err := CreateEngine(Options{Store: shared()})
+	assert.NoError(t, err)
+
+	engine2, err := CreateEngine(Options{Store: shared()})
+	assert.NoError(t, err)
+
+	txn, err := engine2.Begin(nil, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "a"}),
+	}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrReadOnly, engine2.Commit(txn))
+
+	engine1.Close()
+	engine2.Close()
+
+	/* unlocked */
+
+	engine1, err = CreateEngine(Options{Store: NewFileStore("./test/unlocked.bson", 0666)})
+	assert.NoError(t, err)
+
+	engine2, err = CreateEngine(Options{Store: NewFileStore("./test/unlocked.bson", 0666)})
+	assert.NoError(t, err)
+	assert.NoFileExists(t, "./test/unlocked.bson.lock")
+
+	engine1.Close()
+	engine2.Close()
+
+	/* failed load */
+
+	err = ioutil.WriteFile("./test/file.bson", []byte("LNGO"), 0666)
+	assert.NoError(t, err)
+
+	_, err = CreateEngine(Options{Store: NewLockedFileStore("./test/file.bson", 0666, FileLocking{})})
+	assert.Equal(t, ErrFileTruncated, err)
+
+	lock, err := dbkit.LockFile("./test/file.bson.lock", 0666, false, false)
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Unlock())
+}
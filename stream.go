@@ -179,8 +179,8 @@ func (s *Stream) next(ctx context.Context, block bool) bool {
 				continue
 			}
 
-			// check drop and drop database
-			if s.handle[0] != "" && s.handle[1] != "" && opType == "drop" {
+			// check drop, rename and drop database
+			if s.handle[0] != "" && s.handle[1] != "" && (opType == "drop" || opType == "rename") {
 				s.dropped = true
 			} else if s.handle[0] != "" && opType == "dropDatabase" {
 				s.dropped = true
@@ -0,0 +1,295 @@
+package lungo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+)
+
+func (t *Transaction) insertMeasurements(handle Handle, namespace *mongokit.Collection, list bsonkit.List, ordered bool) (*Result, error) {
+	// clone catalog
+	clone := t.catalog.Clone()
+
+	// prepare result
+	result := &Result{}
+
+	// insert measurements
+	for _, doc := range list {
+		// clone buckets and oplog
+		buckets := cloneBuckets(clone, handle)
+		oplog := clone.Namespaces[Oplog].Clone()
+
+		// perform insert
+		res, err := t.bucket(handle, oplog, namespace, buckets, doc)
+		if err != nil {
+			// set error
+			if result.Error == nil {
+				result.Error = err
+			}
+
+			// stop if ordered or continue
+			if ordered {
+				break
+			} else {
+				continue
+			}
+		}
+
+		// replace buckets and oplog
+		clone.Namespaces[handle.Buckets()] = buckets
+		clone.Namespaces[Oplog] = oplog
+
+		// merge result
+		result.Modified = append(result.Modified, res.Modified...)
+	}
+
+	// set catalog and flag
+	if len(result.Modified) > 0 {
+		t.catalog = clone
+		t.dirty = true
+	}
+
+	return result, nil
+}
+
+func (t *Transaction) bulkMeasurements(handle Handle, namespace *mongokit.Collection, ops []Operation, ordered bool) ([]Result, error) {
+	// clone catalog
+	clone := t.catalog.Clone()
+
+	// collect changes
+	changes := 0
+
+	// prepare results
+	results := make([]Result, 0, len(ops))
+
+	// process models
+	for _, op := range ops {
+		// clone buckets and oplog
+		buckets := cloneBuckets(clone, handle)
+		oplog := clone.Namespaces[Oplog].Clone()
+
+		// prepare variables
+		var res *Result
+		var err error
+
+		// run operation
+		switch op.Opcode {
+		case Insert:
+			res, err = t.bucket(handle, oplog, namespace, buckets, op.Document)
+		case Replace, Update:
+			err = timeSeriesError("Cannot perform an update on a time-series collection")
+		case Delete:
+			res, err = t.deleteMeasurements(handle, oplog, namespace, buckets, op.Filter, op.Limit)
+		default:
+			return nil, fmt.Errorf("unsupported bulk opcode %q", op.Opcode.String())
+		}
+
+		// check error
+		if err != nil {
+			// append error
+			results = append(results, Result{
+				Error: err,
+			})
+
+			// stop if ordered
+			if ordered {
+				break
+			} else {
+				continue
+			}
+		}
+
+		// replace buckets and oplog
+		clone.Namespaces[handle.Buckets()] = buckets
+		clone.Namespaces[Oplog] = oplog
+
+		// append result
+		results = append(results, *res)
+
+		// update changes
+		changes += len(res.Modified) + len(res.Matched)
+	}
+
+	// set catalog and flag
+	if changes > 0 {
+		t.catalog = clone
+		t.dirty = true
+	}
+
+	return results, nil
+}
+
+func (t *Transaction) bucket(handle Handle, oplog, namespace, buckets *mongokit.Collection, doc bsonkit.Doc) (*Result, error) {
+	// add measurement
+	before, after, err := namespace.Bucket(buckets, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	// append oplog
+	op := "insert"
+	if before != nil {
+		op = "replace"
+	}
+	err = t.append(oplog, handle.Buckets(), op, after, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Modified: bsonkit.List{doc},
+	}, nil
+}
+
+func (t *Transaction) deleteMeasurements(handle Handle, oplog, namespace, buckets *mongokit.Collection, query bsonkit.Doc, limit int) (*Result, error) {
+	// check limit
+	if limit == 1 {
+		return nil, timeSeriesError("Cannot perform a non-multi delete on a time-series collection")
+	}
+
+	// get query
+	var expr bson.D
+	if query != nil {
+		expr = *query
+	}
+
+	// translate query
+	filter, ok := metaQuery(expr, namespace.Config().MetaField)
+	if !ok {
+		return nil, timeSeriesError("Cannot perform a delete with a non-empty query on a time-series collection that does not exclusively filter on the metaField")
+	}
+
+	// delete buckets
+	res, err := buckets.Delete(&filter, nil, 0, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// append oplog
+	for _, doc := range res.Matched {
+		err = t.append(oplog, handle.Buckets(), "delete", doc, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Result{
+		Matched: namespace.Unpack(res.Matched),
+	}, nil
+}
+
+func (t *Transaction) expireMeasurements(handle Handle, oplog *mongokit.Collection, clone *Catalog) (int, error) {
+	// get config
+	config := clone.Namespaces[handle].Config()
+	if config.ExpireAfterSeconds <= 0 || clone.Namespaces[handle.Buckets()] == nil {
+		return 0, nil
+	}
+
+	// clone buckets
+	buckets := clone.Namespaces[handle.Buckets()].Clone()
+
+	// delete buckets whose newest measurement is expired
+	res, err := buckets.Delete(bsonkit.MustConvert(bson.M{
+		"control.max." + config.TimeField: bson.M{
+			"$lt": time.Now().Add(-time.Duration(config.ExpireAfterSeconds) * time.Second),
+		},
+	}), nil, 0, 0, nil)
+	if err != nil {
+		return 0, err
+	} else if len(res.Matched) == 0 {
+		return 0, nil
+	}
+
+	// append oplog
+	for _, doc := range res.Matched {
+		err = t.append(oplog, handle.Buckets(), "delete", doc, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// replace buckets
+	clone.Namespaces[handle.Buckets()] = buckets
+
+	return len(res.Matched), nil
+}
+
+func (t *Transaction) unpack(handle Handle, namespace *mongokit.Collection) (*mongokit.Collection, error) {
+	// get buckets
+	var list bsonkit.List
+	if buckets := t.catalog.Namespaces[handle.Buckets()]; buckets != nil {
+		list = buckets.Documents.List
+	}
+
+	// create resolved collection
+	resolved, err := mongokit.CreateCollection(mongokit.CollectionConfig{
+		Collation: namespace.Config().Collation,
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// add measurements
+	resolved.Documents = bsonkit.NewSet(namespace.Unpack(list))
+
+	return resolved, nil
+}
+
+func cloneBuckets(catalog *Catalog, handle Handle) *mongokit.Collection {
+	// get buckets
+	buckets := catalog.Namespaces[handle.Buckets()]
+	if buckets == nil {
+		return mongokit.NewCollection(true)
+	}
+
+	return buckets.Clone()
+}
+
+func metaQuery(query bson.D, metaField string) (bson.D, bool) {
+	// prepare filter
+	filter := make(bson.D, 0, len(query))
+
+	// translate expressions
+	for _, exp := range query {
+		switch {
+		case exp.Key == "$and" || exp.Key == "$or" || exp.Key == "$nor":
+			// translate clauses
+			list, _ := exp.Value.(bson.A)
+			clauses := make(bson.A, 0, len(list))
+			for _, item := range list {
+				clause, ok := item.(bson.D)
+				if !ok {
+					return nil, false
+				}
+				clause, ok = metaQuery(clause, metaField)
+				if !ok {
+					return nil, false
+				}
+				clauses = append(clauses, clause)
+			}
+			filter = append(filter, bson.E{Key: exp.Key, Value: clauses})
+		case metaField != "" && exp.Key == metaField:
+			filter = append(filter, bson.E{Key: "meta", Value: exp.Value})
+		case metaField != "" && strings.HasPrefix(exp.Key, metaField+"."):
+			filter = append(filter, bson.E{Key: "meta" + strings.TrimPrefix(exp.Key, metaField), Value: exp.Value})
+		default:
+			return nil, false
+		}
+	}
+
+	return filter, true
+}
+
+func timeSeriesError(msg string) error {
+	return mongo.CommandError{
+		Code:    72,
+		Name:    "InvalidOptions",
+		Message: msg,
+	}
+}
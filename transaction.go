@@ -2,10 +2,13 @@ package lungo
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/256dpi/lungo/bsonkit"
 	"github.com/256dpi/lungo/mongokit"
@@ -63,6 +66,9 @@ type Operation struct {
 
 	// The array filter conditions (update).
 	ArrayFilters bsonkit.List
+
+	// The collation to use for string comparisons (replace, update, delete).
+	Collation bsonkit.Doc
 }
 
 // Result describes the outcome of an operation.
@@ -82,20 +88,24 @@ type Result struct {
 
 // Transaction buffers multiple changes to a catalog.
 type Transaction struct {
-	catalog *Catalog
-	dirty   bool
-	mutex   sync.RWMutex
+	base     *Catalog
+	catalog  *Catalog
+	dirty    bool
+	warnings []error
+	mutex    sync.RWMutex
 }
 
 // NewTransaction creates and returns a new transaction.
 func NewTransaction(catalog *Catalog) *Transaction {
 	return &Transaction{
+		base:    catalog,
 		catalog: catalog,
 	}
 }
 
-// Create will ensure that a namespace for the provided handle exists.
-func (t *Transaction) Create(handle Handle) error {
+// Create will ensure that a namespace for the provided handle exists. The
+// configuration is only used if the namespace is created.
+func (t *Transaction) Create(handle Handle, config mongokit.CollectionConfig) error {
 	// acquire write lock
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
@@ -116,18 +126,89 @@ func (t *Transaction) Create(handle Handle) error {
 		return nil
 	}
 
-	// create collection
+	// create collection, time-series collections store their measurements
+	// in a separate bucket namespace
+	namespace, err := mongokit.CreateCollection(config, config.TimeField == "")
+	if err != nil {
+		return err
+	}
+
+	// add collection
 	t.catalog = t.catalog.Clone()
-	t.catalog.Namespaces[handle] = mongokit.NewCollection(true)
+	t.catalog.Namespaces[handle] = namespace
+	if namespace.IsTimeSeries() && t.catalog.Namespaces[handle.Buckets()] == nil {
+		t.catalog.Namespaces[handle.Buckets()] = mongokit.NewCollection(true)
+	}
+	t.dirty = true
+
+	return nil
+}
+
+// CreateView will create a read-only view in the specified namespace that
+// applies the pipeline to the documents of the source namespace. A collation
+// may be supplied to set the view default collation.
+func (t *Transaction) CreateView(handle Handle, viewOn string, pipeline bsonkit.List, collation bsonkit.Doc) error {
+	// acquire write lock
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// validate handle
+	err := handle.Validate(true)
+	if err != nil {
+		return err
+	}
+
+	// check access
+	if handle[0] == Local {
+		return fmt.Errorf("namespace local.* is read only")
+	}
+
+	// check source
+	if viewOn == "" {
+		return fmt.Errorf("missing view source")
+	}
+
+	// check catalog
+	if t.catalog.Namespaces[handle] != nil {
+		return fmt.Errorf("namespace %q already exists", handle.String())
+	}
+
+	// check pipeline
+	_, err = mongokit.Aggregate(nil, pipeline, mongokit.PipelineContext{})
+	if err != nil {
+		return err
+	}
+
+	// create view
+	namespace, err := mongokit.CreateCollection(mongokit.CollectionConfig{
+		Collation: collation,
+		ViewOn:    viewOn,
+		Pipeline:  pipeline,
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	// check cycles
+	clone := t.catalog.Clone()
+	clone.Namespaces[handle] = namespace
+	_, err = (&Transaction{catalog: clone}).resolve(handle, 0)
+	if err != nil {
+		return err
+	}
+
+	// set catalog and flag
+	t.catalog = clone
 	t.dirty = true
 
 	return nil
 }
 
 // Find will query documents from a namespace. Sort, skip and limit may be
-// supplied to modify the result. The returned results will contain the matched
+// supplied to modify the result. A collation may be supplied to override the
+// namespace default collation. The returned results will contain the matched
 // list of documents.
-func (t *Transaction) Find(handle Handle, query, sort bsonkit.Doc, skip, limit int) (*Result, error) {
+func (t *Transaction) Find(handle Handle, query, sort bsonkit.Doc, skip, limit int, collation bsonkit.Doc) (*Result, error) {
 	// acquire read lock
 	t.mutex.RLock()
 	defer t.mutex.RUnlock()
@@ -138,13 +219,16 @@ func (t *Transaction) Find(handle Handle, query, sort bsonkit.Doc, skip, limit i
 		return nil, err
 	}
 
-	// check namespace
-	if t.catalog.Namespaces[handle] == nil {
+	// resolve namespace
+	namespace, err := t.resolve(handle, 0)
+	if err != nil {
+		return nil, err
+	} else if namespace == nil {
 		return &Result{}, nil
 	}
 
 	// find documents
-	res, err := t.catalog.Namespaces[handle].Find(query, sort, skip, limit)
+	res, err := namespace.Find(query, sort, skip, limit, collation)
 	if err != nil {
 		return nil, err
 	}
@@ -154,6 +238,140 @@ func (t *Transaction) Find(handle Handle, query, sort bsonkit.Doc, skip, limit i
 	}, nil
 }
 
+// Tail will return the documents of a capped namespace that have been added
+// after the specified document and match the query. If after is nil, all
+// matching documents are returned. The last scanned document is returned as
+// well and should be passed as after in the next call. A collation may be
+// supplied to override the namespace default collation.
+func (t *Transaction) Tail(handle Handle, query, after, collation bsonkit.Doc) (bsonkit.List, bsonkit.Doc, error) {
+	// acquire read lock
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// validate handle
+	err := handle.Validate(true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// get namespace
+	namespace := t.catalog.Namespaces[handle]
+	if namespace == nil {
+		return nil, nil, mongo.CommandError{
+			Code:    175,
+			Name:    "QueryPlanKilled",
+			Message: "collection dropped",
+		}
+	} else if !namespace.IsCapped() {
+		return nil, nil, fmt.Errorf("tailable cursor requested on non capped collection")
+	}
+
+	// get collator
+	if collation == nil {
+		collation = namespace.Config().Collation
+	}
+	collator, err := bsonkit.NewCollator(collation)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// find position
+	list := namespace.Documents.List
+	if after != nil {
+		doc := namespace.Get(bsonkit.Get(after, "_id"))
+		if doc == nil {
+			return nil, nil, mongo.CommandError{
+				Code:    136,
+				Name:    "CappedPositionLost",
+				Message: "CollectionScan died due to position in capped collection being deleted",
+			}
+		}
+		list = list[namespace.Documents.Index[doc]+1:]
+	}
+
+	// check list
+	if len(list) == 0 {
+		return nil, after, nil
+	}
+
+	// filter documents
+	matched, err := mongokit.Filter(list, query, 0, collator)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return matched, list[len(list)-1], nil
+}
+
+// Distinct will return the distinct values of the field in the documents that
+// match the query. A collation may be supplied to override the namespace
+// default collation.
+func (t *Transaction) Distinct(handle Handle, field string, query, collation bsonkit.Doc) (bson.A, error) {
+	// acquire read lock
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// validate handle
+	err := handle.Validate(true)
+	if err != nil {
+		return nil, err
+	}
+
+	// resolve namespace
+	namespace, err := t.resolve(handle, 0)
+	if err != nil {
+		return nil, err
+	} else if namespace == nil {
+		return bson.A{}, nil
+	}
+
+	// find distinct values
+	values, err := namespace.Distinct(field, query, collation)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// Aggregate will run the aggregation pipeline on the documents in the
+// specified namespace. A collation may be supplied to override the namespace
+// default collation.
+func (t *Transaction) Aggregate(handle Handle, pipeline bsonkit.List, collation bsonkit.Doc) (bsonkit.List, error) {
+	// acquire read lock
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// validate handle
+	err := handle.Validate(true)
+	if err != nil {
+		return nil, err
+	}
+
+	// check stats stages
+	for i, stage := range pipeline {
+		if len(*stage) > 0 && i > 0 && ((*stage)[0].Key == "$collStats" || (*stage)[0].Key == "$indexStats") {
+			return nil, fmt.Errorf("%s is only valid as the first stage in a pipeline", (*stage)[0].Key)
+		}
+	}
+
+	// resolve namespace
+	namespace, err := t.resolve(handle, 0)
+	if err != nil {
+		return nil, err
+	} else if namespace == nil {
+		namespace = mongokit.NewCollection(false)
+	}
+
+	// run pipeline
+	list, err := namespace.Aggregate(pipeline, t.statsStages(handle), collation)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
 // Bulk performs the specified operations in one go. If ordered is true the
 // process is aborted on the first error.
 func (t *Transaction) Bulk(handle Handle, ops []Operation, ordered bool) ([]Result, error) {
@@ -172,6 +390,16 @@ func (t *Transaction) Bulk(handle Handle, ops []Operation, ordered bool) ([]Resu
 		return nil, fmt.Errorf("namespace local.* is read only")
 	}
 
+	// check view
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && (namespace.IsView() || namespace.IsMaterialized()) {
+		return nil, viewError(handle)
+	}
+
+	// handle time-series
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && namespace.IsTimeSeries() {
+		return t.bulkMeasurements(handle, namespace, ops, ordered)
+	}
+
 	// clone catalog
 	clone := t.catalog.Clone()
 
@@ -204,11 +432,11 @@ func (t *Transaction) Bulk(handle Handle, ops []Operation, ordered bool) ([]Resu
 		case Insert:
 			res, err = t.insert(handle, oplog, namespace, op.Document)
 		case Replace:
-			res, err = t.replace(handle, oplog, namespace, op.Filter, op.Document, op.Sort, op.Upsert)
+			res, err = t.replace(handle, oplog, namespace, op.Filter, op.Document, op.Sort, op.Upsert, op.Collation)
 		case Update:
-			res, err = t.update(handle, oplog, namespace, op.Filter, op.Document, op.Sort, op.Upsert, op.Skip, op.Limit, op.ArrayFilters)
+			res, err = t.update(handle, oplog, namespace, op.Filter, op.Document, op.Sort, op.Upsert, op.Skip, op.Limit, op.ArrayFilters, op.Collation)
 		case Delete:
-			res, err = t.delete(handle, oplog, namespace, op.Filter, op.Sort, op.Skip, op.Limit)
+			res, err = t.delete(handle, oplog, namespace, op.Filter, op.Sort, op.Skip, op.Limit, op.Collation)
 		default:
 			return nil, fmt.Errorf("unsupported bulk opcode %q", op.Opcode.String())
 		}
@@ -274,9 +502,19 @@ func (t *Transaction) Insert(handle Handle, list bsonkit.List, ordered bool) (*R
 		return nil, fmt.Errorf("namespace local.* is read only")
 	}
 
+	// check view
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && (namespace.IsView() || namespace.IsMaterialized()) {
+		return nil, viewError(handle)
+	}
+
 	// clone list
 	list = bsonkit.CloneList(list)
 
+	// handle time-series
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && namespace.IsTimeSeries() {
+		return t.insertMeasurements(handle, namespace, list, ordered)
+	}
+
 	// clone catalog
 	clone := t.catalog.Clone()
 
@@ -337,12 +575,24 @@ func (t *Transaction) insert(handle Handle, oplog, namespace *mongokit.Collectio
 		return nil, err
 	}
 
+	// validate document
+	err = t.validate(namespace, nil, doc)
+	if err != nil {
+		return nil, err
+	}
+
 	// append oplog
 	err = t.append(oplog, handle, "insert", doc, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	// evict documents
+	err = t.evict(handle, oplog, namespace)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Result{
 		Modified: res.Modified,
 	}, nil
@@ -350,9 +600,10 @@ func (t *Transaction) insert(handle Handle, oplog, namespace *mongokit.Collectio
 
 // Replace will replace the first matching document with the specified
 // replacement document. If upsert is enabled, it will insert the replacement
-// document if it is missing. The returned result will contain the matched
+// document if it is missing. A collation may be supplied to override the
+// namespace default collation. The returned result will contain the matched
 // and modified or upserted document.
-func (t *Transaction) Replace(handle Handle, query, sort, repl bsonkit.Doc, upsert bool) (*Result, error) {
+func (t *Transaction) Replace(handle Handle, query, sort, repl bsonkit.Doc, upsert bool, collation bsonkit.Doc) (*Result, error) {
 	// acquire write lock
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
@@ -368,6 +619,16 @@ func (t *Transaction) Replace(handle Handle, query, sort, repl bsonkit.Doc, upse
 		return nil, fmt.Errorf("namespace local.* is read only")
 	}
 
+	// check view
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && (namespace.IsView() || namespace.IsMaterialized()) {
+		return nil, viewError(handle)
+	}
+
+	// check time-series
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && namespace.IsTimeSeries() {
+		return nil, timeSeriesError("Cannot perform an update on a time-series collection")
+	}
+
 	// check namespace
 	if t.catalog.Namespaces[handle] == nil && !upsert {
 		return &Result{}, nil
@@ -394,7 +655,7 @@ func (t *Transaction) Replace(handle Handle, query, sort, repl bsonkit.Doc, upse
 	clone.Namespaces[Oplog] = oplog
 
 	// perform replace
-	res, err := t.replace(handle, oplog, namespace, query, repl, sort, upsert)
+	res, err := t.replace(handle, oplog, namespace, query, repl, sort, upsert, collation)
 	if err != nil {
 		return nil, err
 	}
@@ -408,9 +669,9 @@ func (t *Transaction) Replace(handle Handle, query, sort, repl bsonkit.Doc, upse
 	return res, nil
 }
 
-func (t *Transaction) replace(handle Handle, oplog, namespace *mongokit.Collection, query, repl, sort bsonkit.Doc, upsert bool) (*Result, error) {
+func (t *Transaction) replace(handle Handle, oplog, namespace *mongokit.Collection, query, repl, sort bsonkit.Doc, upsert bool, collation bsonkit.Doc) (*Result, error) {
 	// replace document
-	res, err := namespace.Replace(query, repl, sort)
+	res, err := namespace.Replace(query, repl, sort, collation)
 	if err != nil {
 		return nil, err
 	}
@@ -422,17 +683,37 @@ func (t *Transaction) replace(handle Handle, oplog, namespace *mongokit.Collecti
 			return nil, err
 		}
 
+		// validate document
+		err = t.validate(namespace, nil, res.Upserted)
+		if err != nil {
+			return nil, err
+		}
+
 		// append oplog
 		err = t.append(oplog, handle, "insert", res.Upserted, nil)
 		if err != nil {
 			return nil, err
 		}
 
+		// evict documents
+		err = t.evict(handle, oplog, namespace)
+		if err != nil {
+			return nil, err
+		}
+
 		return &Result{
 			Upserted: res.Upserted,
 		}, nil
 	}
 
+	// validate document
+	if len(res.Modified) > 0 {
+		err = t.validate(namespace, res.Matched[0], res.Modified[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// append oplog
 	if len(res.Modified) > 0 {
 		err = t.append(oplog, handle, "replace", res.Modified[0], nil)
@@ -441,6 +722,12 @@ func (t *Transaction) replace(handle Handle, oplog, namespace *mongokit.Collecti
 		}
 	}
 
+	// evict documents
+	err = t.evict(handle, oplog, namespace)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Result{
 		Matched:  res.Matched,
 		Modified: res.Modified,
@@ -450,9 +737,10 @@ func (t *Transaction) replace(handle Handle, oplog, namespace *mongokit.Collecti
 // Update will apply the update to all matching document. Sort, skip and limit
 // may be supplied to modify the result. If upsert is enabled, it will extract
 // constant parts of the query and apply the update and insert the document if
-// it is missing. The returned result will contain the matched and modified or
+// it is missing. A collation may be supplied to override the namespace default
+// collation. The returned result will contain the matched and modified or
 // upserted document.
-func (t *Transaction) Update(handle Handle, query, sort, update bsonkit.Doc, skip, limit int, upsert bool, arrayFilters bsonkit.List) (*Result, error) {
+func (t *Transaction) Update(handle Handle, query, sort, update bsonkit.Doc, skip, limit int, upsert bool, arrayFilters bsonkit.List, collation bsonkit.Doc) (*Result, error) {
 	// acquire write lock
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
@@ -468,6 +756,16 @@ func (t *Transaction) Update(handle Handle, query, sort, update bsonkit.Doc, ski
 		return nil, fmt.Errorf("namespace local.* is read only")
 	}
 
+	// check view
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && (namespace.IsView() || namespace.IsMaterialized()) {
+		return nil, viewError(handle)
+	}
+
+	// check time-series
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && namespace.IsTimeSeries() {
+		return nil, timeSeriesError("Cannot perform an update on a time-series collection")
+	}
+
 	// check namespace
 	if t.catalog.Namespaces[handle] == nil && !upsert {
 		return &Result{}, nil
@@ -491,7 +789,7 @@ func (t *Transaction) Update(handle Handle, query, sort, update bsonkit.Doc, ski
 	clone.Namespaces[Oplog] = oplog
 
 	// perform update
-	res, err := t.update(handle, oplog, namespace, query, update, sort, upsert, skip, limit, arrayFilters)
+	res, err := t.update(handle, oplog, namespace, query, update, sort, upsert, skip, limit, arrayFilters, collation)
 	if err != nil {
 		return nil, err
 	}
@@ -505,9 +803,9 @@ func (t *Transaction) Update(handle Handle, query, sort, update bsonkit.Doc, ski
 	return res, nil
 }
 
-func (t *Transaction) update(handle Handle, oplog, namespace *mongokit.Collection, query, update, sort bsonkit.Doc, upsert bool, skip, limit int, arrayFilters bsonkit.List) (*Result, error) {
+func (t *Transaction) update(handle Handle, oplog, namespace *mongokit.Collection, query, update, sort bsonkit.Doc, upsert bool, skip, limit int, arrayFilters bsonkit.List, collation bsonkit.Doc) (*Result, error) {
 	// perform update
-	res, err := namespace.Update(query, update, sort, skip, limit, arrayFilters)
+	res, err := namespace.Update(query, update, sort, skip, limit, arrayFilters, collation)
 	if err != nil {
 		return nil, err
 	}
@@ -519,17 +817,37 @@ func (t *Transaction) update(handle Handle, oplog, namespace *mongokit.Collectio
 			return nil, err
 		}
 
+		// validate document
+		err = t.validate(namespace, nil, res.Upserted)
+		if err != nil {
+			return nil, err
+		}
+
 		// append oplog
 		err = t.append(oplog, handle, "insert", res.Upserted, nil)
 		if err != nil {
 			return nil, err
 		}
 
+		// evict documents
+		err = t.evict(handle, oplog, namespace)
+		if err != nil {
+			return nil, err
+		}
+
 		return &Result{
 			Upserted: res.Upserted,
 		}, nil
 	}
 
+	// validate documents
+	for i, doc := range res.Modified {
+		err = t.validate(namespace, res.Matched[i], doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// append oplog
 	for i, doc := range res.Modified {
 		err = t.append(oplog, handle, "update", doc, res.Changes[i])
@@ -538,6 +856,12 @@ func (t *Transaction) update(handle Handle, oplog, namespace *mongokit.Collectio
 		}
 	}
 
+	// evict documents
+	err = t.evict(handle, oplog, namespace)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Result{
 		Matched:  res.Matched,
 		Modified: res.Modified,
@@ -545,9 +869,10 @@ func (t *Transaction) update(handle Handle, oplog, namespace *mongokit.Collectio
 }
 
 // Delete will remove all matching documents from the namespace. Sort, skip and
-// limit may be supplied to modify the result. The returned result will contain
+// limit may be supplied to modify the result. A collation may be supplied to
+// override the namespace default collation. The returned result will contain
 // the matched documents.
-func (t *Transaction) Delete(handle Handle, query, sort bsonkit.Doc, skip, limit int) (*Result, error) {
+func (t *Transaction) Delete(handle Handle, query, sort bsonkit.Doc, skip, limit int, collation bsonkit.Doc) (*Result, error) {
 	// acquire write lock
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
@@ -563,6 +888,11 @@ func (t *Transaction) Delete(handle Handle, query, sort bsonkit.Doc, skip, limit
 		return nil, fmt.Errorf("namespace local.* is read only")
 	}
 
+	// check view
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && (namespace.IsView() || namespace.IsMaterialized()) {
+		return nil, viewError(handle)
+	}
+
 	// check namespace
 	if t.catalog.Namespaces[handle] == nil {
 		return &Result{}, nil
@@ -571,6 +901,29 @@ func (t *Transaction) Delete(handle Handle, query, sort bsonkit.Doc, skip, limit
 	// clone catalog
 	clone := t.catalog.Clone()
 
+	// handle time-series
+	if namespace := clone.Namespaces[handle]; namespace.IsTimeSeries() {
+		// clone buckets and oplog
+		buckets := cloneBuckets(clone, handle)
+		oplog := clone.Namespaces[Oplog].Clone()
+		clone.Namespaces[handle.Buckets()] = buckets
+		clone.Namespaces[Oplog] = oplog
+
+		// perform delete
+		res, err := t.deleteMeasurements(handle, oplog, namespace, buckets, query, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		// set catalog and flag
+		if len(res.Matched) > 0 {
+			t.catalog = clone
+			t.dirty = true
+		}
+
+		return res, nil
+	}
+
 	// clone namespace
 	namespace := clone.Namespaces[handle].Clone()
 	clone.Namespaces[handle] = namespace
@@ -580,7 +933,7 @@ func (t *Transaction) Delete(handle Handle, query, sort bsonkit.Doc, skip, limit
 	clone.Namespaces[Oplog] = oplog
 
 	// perform delete
-	res, err := t.delete(handle, oplog, namespace, query, sort, skip, limit)
+	res, err := t.delete(handle, oplog, namespace, query, sort, skip, limit, collation)
 	if err != nil {
 		return nil, err
 	}
@@ -594,9 +947,18 @@ func (t *Transaction) Delete(handle Handle, query, sort bsonkit.Doc, skip, limit
 	return res, nil
 }
 
-func (t *Transaction) delete(handle Handle, oplog, namespace *mongokit.Collection, query, sort bsonkit.Doc, skip, limit int) (*Result, error) {
+func (t *Transaction) delete(handle Handle, oplog, namespace *mongokit.Collection, query, sort bsonkit.Doc, skip, limit int, collation bsonkit.Doc) (*Result, error) {
+	// check capped
+	if namespace.IsCapped() {
+		return nil, mongo.CommandError{
+			Code:    20,
+			Name:    "IllegalOperation",
+			Message: fmt.Sprintf("cannot remove from a capped collection: %s", handle.String()),
+		}
+	}
+
 	// perform delete
-	res, err := namespace.Delete(query, sort, skip, limit)
+	res, err := namespace.Delete(query, sort, skip, limit, collation)
 	if err != nil {
 		return nil, err
 	}
@@ -614,6 +976,24 @@ func (t *Transaction) delete(handle Handle, oplog, namespace *mongokit.Collectio
 	}, nil
 }
 
+func (t *Transaction) evict(handle Handle, oplog, namespace *mongokit.Collection) error {
+	// evict documents
+	evicted, err := namespace.Evict()
+	if err != nil {
+		return err
+	}
+
+	// append oplog
+	for _, doc := range evicted {
+		err = t.append(oplog, handle, "delete", doc, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Drop will return the namespace with the specified handle from the catalog.
 // If the second part of the handle is empty, it will drop all namespaces
 // matching the first part.
@@ -643,9 +1023,12 @@ func (t *Transaction) Drop(handle Handle) error {
 	// collect dropped
 	dropped := 0
 
-	// drop all matching namespaces
+	// check time-series
+	series := t.catalog.Namespaces[handle] != nil && t.catalog.Namespaces[handle].IsTimeSeries()
+
+	// drop all matching namespaces and the buckets of time-series collections
 	for ns := range clone.Namespaces {
-		if ns == handle || handle[1] == "" && ns[0] == handle[0] {
+		if ns == handle || handle[1] == "" && ns[0] == handle[0] || series && ns == handle.Buckets() {
 			// delete namespace
 			delete(clone.Namespaces, ns)
 			dropped++
@@ -675,6 +1058,75 @@ func (t *Transaction) Drop(handle Handle) error {
 	return nil
 }
 
+// Rename will move the namespace with the first handle to the second handle.
+// The indexes are carried over and the target namespace must not exist.
+func (t *Transaction) Rename(from, to Handle) error {
+	// acquire write lock
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// validate handles
+	err := from.Validate(true)
+	if err != nil {
+		return err
+	}
+	err = to.Validate(true)
+	if err != nil {
+		return err
+	}
+
+	// check access
+	if from[0] == Local || to[0] == Local {
+		return fmt.Errorf("namespace local.* is read only")
+	}
+
+	// check handles
+	if from == to {
+		return commandError(20, "IllegalOperation", "Can't rename a collection to itself")
+	}
+
+	// check source
+	namespace := t.catalog.Namespaces[from]
+	if namespace == nil {
+		return commandError(26, "NamespaceNotFound", "Source collection %s does not exist", from.String())
+	} else if namespace.IsView() {
+		return viewError(from)
+	} else if namespace.IsTimeSeries() || strings.HasPrefix(from[1], BucketsPrefix) || strings.HasPrefix(to[1], BucketsPrefix) {
+		return commandError(20, "IllegalOperation", "Renaming a time-series collection is not allowed")
+	}
+
+	// check target
+	if t.catalog.Namespaces[to] != nil {
+		return commandError(48, "NamespaceExists", "target namespace exists")
+	}
+
+	// clone catalog
+	clone := t.catalog.Clone()
+
+	// clone oplog
+	oplog := clone.Namespaces[Oplog].Clone()
+	clone.Namespaces[Oplog] = oplog
+
+	// move namespace
+	delete(clone.Namespaces, from)
+	clone.Namespaces[to] = namespace
+
+	// append oplog
+	err = t.append(oplog, from, "rename", &bson.D{
+		bson.E{Key: "db", Value: to[0]},
+		bson.E{Key: "coll", Value: to[1]},
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	// set catalog and flag
+	t.catalog = clone
+	t.dirty = true
+
+	return nil
+}
+
 func (t *Transaction) append(oplog *mongokit.Collection, handle Handle, op string, doc bsonkit.Doc, changes *mongokit.Changes) error {
 	// get time
 	now := bsonkit.Now()
@@ -695,8 +1147,10 @@ func (t *Transaction) append(oplog *mongokit.Collection, handle Handle, op strin
 		"operationType": op,
 	}
 
-	// add document info
-	if doc != nil {
+	// add rename target or document info
+	if op == "rename" && doc != nil {
+		event["to"] = *doc
+	} else if doc != nil {
 		// add document key
 		event["documentKey"] = bson.M{
 			"_id": bsonkit.Get(doc, "_id"),
@@ -770,7 +1224,7 @@ func (t *Transaction) ListDatabases(query bsonkit.Doc) (bsonkit.List, error) {
 	}
 
 	// filter list
-	list, err := mongokit.Filter(list, query, 0)
+	list, err := mongokit.Filter(list, query, 0, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -794,30 +1248,14 @@ func (t *Transaction) ListCollections(handle Handle, query bsonkit.Doc) (bsonkit
 	list := make(bsonkit.List, 0, len(t.catalog.Namespaces))
 
 	// add documents
-	for ns := range t.catalog.Namespaces {
+	for ns, namespace := range t.catalog.Namespaces {
 		if ns[0] == handle[0] {
-			list = append(list, &bson.D{
-				bson.E{Key: "name", Value: ns[1]},
-				bson.E{Key: "type", Value: "collection"},
-				bson.E{Key: "options", Value: bson.D{}},
-				bson.E{Key: "info", Value: bson.D{
-					bson.E{Key: "uuid", Value: ns.String()},
-					bson.E{Key: "readOnly", Value: false},
-				}},
-				bson.E{Key: "idIndex", Value: bson.D{
-					bson.E{Key: "v", Value: 2},
-					bson.E{Key: "key", Value: bson.D{
-						bson.E{Key: "_id", Value: 1},
-					}},
-					bson.E{Key: "name", Value: "_id_"},
-					bson.E{Key: "namespace", Value: ns.String()},
-				}},
-			})
+			list = append(list, collectionInfo(ns, namespace))
 		}
 	}
 
 	// filter list
-	list, err = mongokit.Filter(list, query, 0)
+	list, err = mongokit.Filter(list, query, 0, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -825,6 +1263,124 @@ func (t *Transaction) ListCollections(handle Handle, query bsonkit.Doc) (bsonkit
 	return list, nil
 }
 
+func collectionInfo(ns Handle, namespace *mongokit.Collection) bsonkit.Doc {
+	// get config
+	config := namespace.Config()
+
+	// prepare options
+	options := bson.D{}
+	if config.ViewOn != "" {
+		pipeline := bson.A{}
+		for _, stage := range config.Pipeline {
+			pipeline = append(pipeline, *stage)
+		}
+		options = append(options, bson.E{Key: "viewOn", Value: config.ViewOn})
+		options = append(options, bson.E{Key: "pipeline", Value: pipeline})
+		if config.Materialized {
+			options = append(options, bson.E{Key: "materialized", Value: true})
+		}
+	}
+	if config.Collation != nil {
+		options = append(options, bson.E{Key: "collation", Value: *config.Collation})
+	}
+	if config.Capped {
+		options = append(options, bson.E{Key: "capped", Value: true})
+		options = append(options, bson.E{Key: "size", Value: config.SizeInBytes})
+		if config.MaxDocuments > 0 {
+			options = append(options, bson.E{Key: "max", Value: config.MaxDocuments})
+		}
+	}
+	if config.Validator != nil {
+		level := config.ValidationLevel
+		if level == "" {
+			level = "strict"
+		}
+		action := config.ValidationAction
+		if action == "" {
+			action = "error"
+		}
+		options = append(options, bson.E{Key: "validator", Value: *config.Validator})
+		options = append(options, bson.E{Key: "validationLevel", Value: level})
+		options = append(options, bson.E{Key: "validationAction", Value: action})
+	}
+
+	if config.Clustered {
+		options = append(options, bson.E{Key: "clusteredIndex", Value: bson.D{
+			bson.E{Key: "v", Value: 2},
+			bson.E{Key: "key", Value: bson.D{
+				bson.E{Key: "_id", Value: 1},
+			}},
+			bson.E{Key: "name", Value: "_id_"},
+			bson.E{Key: "unique", Value: true},
+		}})
+		if config.ExpireAfterSeconds > 0 {
+			options = append(options, bson.E{Key: "expireAfterSeconds", Value: config.ExpireAfterSeconds})
+		}
+	}
+	if config.TimeField != "" {
+		series := bson.D{
+			bson.E{Key: "timeField", Value: config.TimeField},
+		}
+		if config.MetaField != "" {
+			series = append(series, bson.E{Key: "metaField", Value: config.MetaField})
+		}
+		series = append(series, bson.E{Key: "granularity", Value: config.Granularity})
+		options = append(options, bson.E{Key: "timeseries", Value: series})
+		if config.ExpireAfterSeconds > 0 {
+			options = append(options, bson.E{Key: "expireAfterSeconds", Value: config.ExpireAfterSeconds})
+		}
+	}
+
+	// handle time-series collections
+	if config.TimeField != "" {
+		return &bson.D{
+			bson.E{Key: "name", Value: ns[1]},
+			bson.E{Key: "type", Value: "timeseries"},
+			bson.E{Key: "options", Value: options},
+			bson.E{Key: "info", Value: bson.D{
+				bson.E{Key: "readOnly", Value: false},
+			}},
+		}
+	}
+
+	// handle views
+	if config.ViewOn != "" {
+		return &bson.D{
+			bson.E{Key: "name", Value: ns[1]},
+			bson.E{Key: "type", Value: "view"},
+			bson.E{Key: "options", Value: options},
+			bson.E{Key: "info", Value: bson.D{
+				bson.E{Key: "readOnly", Value: true},
+			}},
+		}
+	}
+
+	// prepare collection
+	doc := bson.D{
+		bson.E{Key: "name", Value: ns[1]},
+		bson.E{Key: "type", Value: "collection"},
+		bson.E{Key: "options", Value: options},
+		bson.E{Key: "info", Value: bson.D{
+			bson.E{Key: "uuid", Value: ns.String()},
+			bson.E{Key: "readOnly", Value: false},
+		}},
+	}
+
+	// add default index, clustered collections have none
+	if !config.Clustered {
+		doc = append(doc, bson.E{Key: "idIndex", Value: bson.D{
+			bson.E{Key: "v", Value: 2},
+			bson.E{Key: "key", Value: bson.D{
+				bson.E{Key: "_id", Value: 1},
+			}},
+			bson.E{Key: "name", Value: "_id_"},
+			bson.E{Key: "namespace", Value: ns.String()},
+		}})
+	}
+
+	return &doc
+}
+
 // CountDocuments will return the number of documents in the specified namespace.
 func (t *Transaction) CountDocuments(handle Handle) (int, error) {
 	// acquire read lock
@@ -837,9 +1393,11 @@ func (t *Transaction) CountDocuments(handle Handle) (int, error) {
 		return 0, err
 	}
 
-	// check namespace
-	namespace, ok := t.catalog.Namespaces[handle]
-	if !ok {
+	// resolve namespace
+	namespace, err := t.resolve(handle, 0)
+	if err != nil {
+		return 0, err
+	} else if namespace == nil {
 		return 0, nil
 	}
 
@@ -866,6 +1424,15 @@ func (t *Transaction) ListIndexes(handle Handle) (bsonkit.List, error) {
 	// get namespace
 	namespace := t.catalog.Namespaces[handle]
 
+	// check view
+	if namespace.IsView() {
+		return nil, viewError(handle)
+	}
+
+	return listIndexes(namespace), nil
+}
+
+func listIndexes(namespace *mongokit.Collection) bsonkit.List {
 	// prepare list
 	var list bsonkit.List
 	for name, index := range namespace.Indexes {
@@ -884,6 +1451,11 @@ func (t *Transaction) ListIndexes(handle Handle) (bsonkit.List, error) {
 			spec = append(spec, bson.E{Key: "unique", Value: true})
 		}
 
+		// add sparse
+		if config.Sparse {
+			spec = append(spec, bson.E{Key: "sparse", Value: true})
+		}
+
 		// add partial
 		if config.Partial != nil {
 			spec = append(spec, bson.E{Key: "partialFilterExpression", Value: *config.Partial})
@@ -894,16 +1466,44 @@ func (t *Transaction) ListIndexes(handle Handle) (bsonkit.List, error) {
 			spec = append(spec, bson.E{Key: "expireAfterSeconds", Value: int32(config.Expiry / time.Second)})
 		}
 
+		// add collation
+		if config.Collation != nil {
+			spec = append(spec, bson.E{Key: "collation", Value: *config.Collation})
+		}
+
+		// add wildcard projection
+		if config.WildcardProjection != nil {
+			spec = append(spec, bson.E{Key: "wildcardProjection", Value: *config.WildcardProjection})
+		}
+
+		// add hidden
+		if config.Hidden {
+			spec = append(spec, bson.E{Key: "hidden", Value: true})
+		}
+
 		// add specification
 		list = append(list, &spec)
 	}
 
+	// add clustered index
+	if namespace.IsClustered() {
+		list = append(list, &bson.D{
+			bson.E{Key: "v", Value: 2},
+			bson.E{Key: "key", Value: bson.D{
+				bson.E{Key: "_id", Value: 1},
+			}},
+			bson.E{Key: "name", Value: "_id_"},
+			bson.E{Key: "unique", Value: true},
+			bson.E{Key: "clustered", Value: true},
+		})
+	}
+
 	// sort list
 	bsonkit.Sort(list, []bsonkit.Column{
 		{Path: "name"},
 	}, true)
 
-	return list, nil
+	return list
 }
 
 // CreateIndex will create the specified index in the specified namespace. It
@@ -924,6 +1524,16 @@ func (t *Transaction) CreateIndex(handle Handle, name string, config mongokit.In
 		return "", fmt.Errorf("namespace local.* is read only")
 	}
 
+	// check view
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && namespace.IsView() {
+		return "", viewError(handle)
+	}
+
+	// check time-series
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && namespace.IsTimeSeries() {
+		return "", timeSeriesError("indexes on time-series collections are not supported")
+	}
+
 	// clone catalog
 	clone := t.catalog.Clone()
 
@@ -967,6 +1577,11 @@ func (t *Transaction) DropIndex(handle Handle, name string) error {
 		return fmt.Errorf("namespace local.* is read only")
 	}
 
+	// check view
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && namespace.IsView() {
+		return viewError(handle)
+	}
+
 	// check namespace
 	if t.catalog.Namespaces[handle] == nil {
 		return fmt.Errorf("missing namespace %q", handle.String())
@@ -994,6 +1609,84 @@ func (t *Transaction) DropIndex(handle Handle, name string) error {
 	return nil
 }
 
+// HideIndex will hide or unhide the specified index in the specified namespace
+// from the query planner.
+func (t *Transaction) HideIndex(handle Handle, name string, hidden bool) error {
+	return t.modify(handle, func(namespace *mongokit.Collection) error {
+		return namespace.HideIndex(name, hidden)
+	})
+}
+
+// ExpireIndex will change the expiry of the specified TTL index in the
+// specified namespace.
+func (t *Transaction) ExpireIndex(handle Handle, name string, expiry time.Duration) error {
+	return t.modify(handle, func(namespace *mongokit.Collection) error {
+		return namespace.ExpireIndex(name, expiry)
+	})
+}
+
+// ConvertIndex will convert the specified index in the specified namespace to
+// a unique index.
+func (t *Transaction) ConvertIndex(handle Handle, name string) error {
+	return t.modify(handle, func(namespace *mongokit.Collection) error {
+		return namespace.ConvertIndex(name)
+	})
+}
+
+// Modify will update the validator, validation level, validation action and
+// collection expiry of the specified namespace.
+func (t *Transaction) Modify(handle Handle, config mongokit.CollectionConfig) error {
+	return t.modify(handle, func(namespace *mongokit.Collection) error {
+		return namespace.Modify(config)
+	})
+}
+
+func (t *Transaction) modify(handle Handle, fn func(*mongokit.Collection) error) error {
+	// acquire write lock
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// validate handle
+	err := handle.Validate(true)
+	if err != nil {
+		return err
+	}
+
+	// check access
+	if handle[0] == Local {
+		return fmt.Errorf("namespace local.* is read only")
+	}
+
+	// check view
+	if namespace := t.catalog.Namespaces[handle]; namespace != nil && namespace.IsView() {
+		return viewError(handle)
+	}
+
+	// check namespace
+	if t.catalog.Namespaces[handle] == nil {
+		return fmt.Errorf("missing namespace %q", handle.String())
+	}
+
+	// clone catalog
+	clone := t.catalog.Clone()
+
+	// clone namespace
+	namespace := clone.Namespaces[handle].Clone()
+	clone.Namespaces[handle] = namespace
+
+	// modify namespace
+	err = fn(namespace)
+	if err != nil {
+		return err
+	}
+
+	// set catalog and flag
+	t.catalog = clone
+	t.dirty = true
+
+	return nil
+}
+
 // Dirty will return whether the transaction contains changes.
 func (t *Transaction) Dirty() bool {
 	// acquire read lock
@@ -1003,6 +1696,16 @@ func (t *Transaction) Dirty() bool {
 	return t.dirty
 }
 
+// Warnings will return the validation errors of documents written to
+// collections that use the "warn" validation action.
+func (t *Transaction) Warnings() []error {
+	// acquire read lock
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.warnings
+}
+
 // Catalog will return the modified catalog by the transaction.
 func (t *Transaction) Catalog() *Catalog {
 	// acquire read lock
@@ -1014,7 +1717,7 @@ func (t *Transaction) Catalog() *Catalog {
 
 // Clean will clean the oplog and only keep up to the specified amount of events
 // and delete events that are older than the specified age.
-func (t *Transaction) Clean(minSize, maxSize int, minAge, maxAge time.Duration) {
+func (t *Transaction) Clean(minSize, maxSize int, minAge, maxAge time.Duration) bsonkit.List {
 	// acquire write lock
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
@@ -1036,8 +1739,8 @@ func (t *Transaction) Clean(minSize, maxSize int, minAge, maxAge time.Duration)
 	minIndex := len(oplog.Documents.List) - minSize
 	maxIndex := len(oplog.Documents.List) - maxSize
 
-	// prepare counter
-	dropped := 0
+	// prepare list
+	var dropped bsonkit.List
 
 	// drop events based on threshold and timestamp, break if none have been
 	// deleted
@@ -1051,18 +1754,24 @@ func (t *Transaction) Clean(minSize, maxSize int, minAge, maxAge time.Duration)
 
 		// remove event if below threshold or timestamp
 		if afterMin && beyondMax {
-			oplog.Documents.Remove(doc)
-			dropped++
+			dropped = append(dropped, doc)
 		} else {
 			break
 		}
 	}
 
+	// remove events
+	for _, doc := range dropped {
+		_ = oplog.Remove(doc)
+	}
+
 	// set flag
-	if dropped > 0 {
+	if len(dropped) > 0 {
 		t.catalog = clone
 		t.dirty = true
 	}
+
+	return dropped
 }
 
 // Expire will remove documents that are expired due to a TTL index.
@@ -1083,6 +1792,16 @@ func (t *Transaction) Expire() error {
 
 	// go through all namespaces
 	for handle, namespace := range clone.Namespaces {
+		// expire time-series buckets
+		if namespace.IsTimeSeries() {
+			n, err := t.expireMeasurements(handle, oplog, clone)
+			if err != nil {
+				return err
+			}
+			deletions += n
+			continue
+		}
+
 		// check indexes
 		var ttlIndexes []*mongokit.Index
 		for _, index := range namespace.Indexes {
@@ -1091,8 +1810,14 @@ func (t *Transaction) Expire() error {
 			}
 		}
 
-		// check if any
-		if len(ttlIndexes) == 0 {
+		// get clustered expiry
+		clusteredExpiry := time.Duration(namespace.Config().ExpireAfterSeconds) * time.Second
+		if !namespace.IsClustered() {
+			clusteredExpiry = 0
+		}
+
+		// check if any, capped collections do not support deletes
+		if len(ttlIndexes) == 0 && clusteredExpiry == 0 || namespace.IsCapped() {
 			continue
 		}
 
@@ -1101,7 +1826,16 @@ func (t *Transaction) Expire() error {
 		clone.Namespaces[handle] = namespace
 
 		// collect conditions
-		conditions := make(bson.A, 0, len(ttlIndexes))
+		conditions := make(bson.A, 0, len(ttlIndexes)+2)
+		if clusteredExpiry > 0 {
+			// the _id may be a date or an object id
+			cutoff := time.Now().Add(-clusteredExpiry)
+			conditions = append(conditions, bson.M{
+				"_id": bson.M{"$lt": cutoff},
+			}, bson.M{
+				"_id": bson.M{"$lt": primitive.NewObjectIDFromTimestamp(cutoff)},
+			})
+		}
 		for _, index := range ttlIndexes {
 			field := (*index.Config().Key)[0].Key
 			expiry := index.Config().Expiry
@@ -1116,7 +1850,7 @@ func (t *Transaction) Expire() error {
 		// delete all expired documents
 		res, err := t.delete(handle, oplog, namespace, bsonkit.MustConvert(bson.M{
 			"$or": conditions,
-		}), nil, 0, 0)
+		}), nil, 0, 0, nil)
 		if err != nil {
 			return err
 		}
@@ -1133,3 +1867,124 @@ func (t *Transaction) Expire() error {
 
 	return nil
 }
+
+func (t *Transaction) resolve(handle Handle, depth int) (*mongokit.Collection, error) {
+	// get namespace
+	namespace := t.catalog.Namespaces[handle]
+	if namespace != nil && namespace.IsTimeSeries() {
+		return t.unpack(handle, namespace)
+	} else if namespace == nil || !namespace.IsView() {
+		return namespace, nil
+	}
+
+	// check depth
+	if depth >= maxViewDepth {
+		return nil, fmt.Errorf("view depth too deep or view cycle detected")
+	}
+
+	// get config
+	config := namespace.Config()
+
+	// resolve source
+	source, err := t.resolve(Handle{handle[0], config.ViewOn}, depth+1)
+	if err != nil {
+		return nil, err
+	} else if source == nil {
+		source = mongokit.NewCollection(false)
+	}
+
+	// views do not inherit the source collation
+	collation := config.Collation
+	if collation == nil {
+		collation = &bson.D{bson.E{Key: "locale", Value: "simple"}}
+	}
+
+	// run pipeline
+	list, err := source.Aggregate(config.Pipeline, nil, collation)
+	if err != nil {
+		return nil, err
+	}
+
+	// create resolved collection
+	resolved, err := mongokit.CreateCollection(mongokit.CollectionConfig{
+		Collation: config.Collation,
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// add documents
+	resolved.Documents = bsonkit.NewSet(list)
+
+	return resolved, nil
+}
+
+const maxViewDepth = 20
+
+func (t *Transaction) validate(namespace *mongokit.Collection, before, after bsonkit.Doc) error {
+	// get config
+	config := namespace.Config()
+
+	// check validator and level
+	if config.Validator == nil || config.ValidationLevel == "off" {
+		return nil
+	}
+
+	// skip updates to invalid documents if moderate
+	if before != nil && config.ValidationLevel == "moderate" {
+		details, err := namespace.Validate(before)
+		if err != nil {
+			return err
+		} else if details != nil {
+			return nil
+		}
+	}
+
+	// validate document
+	details, err := namespace.Validate(after)
+	if err != nil {
+		return err
+	} else if details == nil {
+		return nil
+	}
+
+	// prepare error
+	err = validationError(bsonkit.Get(after, "_id"), details)
+
+	// buffer error if warning
+	if config.ValidationAction == "warn" {
+		t.warnings = append(t.warnings, err)
+		return nil
+	}
+
+	return err
+}
+
+func validationError(id interface{}, details bson.D) error {
+	// encode info
+	info, err := bson.Marshal(bson.D{
+		bson.E{Key: "failingDocumentId", Value: id},
+		bson.E{Key: "details", Value: details},
+	})
+	if err != nil {
+		return err
+	}
+
+	return mongo.WriteException{
+		WriteErrors: mongo.WriteErrors{
+			mongo.WriteError{
+				Code:    121,
+				Message: "Document failed validation",
+				Details: info,
+			},
+		},
+	}
+}
+
+func viewError(handle Handle) error {
+	return mongo.CommandError{
+		Code:    166,
+		Name:    "CommandNotSupportedOnView",
+		Message: fmt.Sprintf("Namespace %s is a view, not a collection", handle.String()),
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
 )
 
 func TestTransactionOplogCleaningBySize(t *testing.T) {
@@ -31,12 +33,12 @@ func TestTransactionOplogCleaningBySize(t *testing.T) {
 		"$set": bson.M{
 			"foo": "baz",
 		},
-	}), 0, 0, false, nil)
+	}), 0, 0, false, nil, nil)
 	assert.NoError(t, err)
 
 	_, err = txn.Delete(Handle{"foo", "bar"}, bsonkit.MustConvert(bson.M{
 		"_id": id1,
-	}), nil, 0, 0)
+	}), nil, 0, 0, nil)
 	assert.NoError(t, err)
 
 	assert.Len(t, txn.Catalog().Namespaces[Oplog].Documents.List, 3)
@@ -47,16 +49,16 @@ func TestTransactionOplogCleaningBySize(t *testing.T) {
 
 	/* clean */
 
-	txn.Clean(3, 0, 0, time.Hour)
+	assert.Empty(t, txn.Clean(3, 0, 0, time.Hour))
 	assert.Equal(t, bsonkit.List{insert, update, delete}, txn.Catalog().Namespaces[Oplog].Documents.List)
 
-	txn.Clean(2, 0, 0, time.Hour)
+	assert.Equal(t, bsonkit.List{insert}, txn.Clean(2, 0, 0, time.Hour))
 	assert.Equal(t, bsonkit.List{update, delete}, txn.Catalog().Namespaces[Oplog].Documents.List)
 
-	txn.Clean(0, 1, 0, time.Hour)
+	assert.Equal(t, bsonkit.List{update}, txn.Clean(0, 1, 0, time.Hour))
 	assert.Equal(t, bsonkit.List{delete}, txn.Catalog().Namespaces[Oplog].Documents.List)
 
-	txn.Clean(0, 0, 0, time.Hour)
+	assert.Equal(t, bsonkit.List{delete}, txn.Clean(0, 0, 0, time.Hour))
 	assert.Empty(t, txn.Catalog().Namespaces[Oplog].Documents.List)
 }
 
@@ -82,14 +84,14 @@ func TestTransactionOplogCleaningByTime(t *testing.T) {
 		"$set": bson.M{
 			"foo": "baz",
 		},
-	}), 0, 0, false, nil)
+	}), 0, 0, false, nil, nil)
 	assert.NoError(t, err)
 
 	time.Sleep(time.Second)
 
 	_, err = txn.Delete(Handle{"foo", "bar"}, bsonkit.MustConvert(bson.M{
 		"_id": id1,
-	}), nil, 0, 0)
+	}), nil, 0, 0, nil)
 	assert.NoError(t, err)
 
 	time.Sleep(time.Second)
@@ -102,16 +104,508 @@ func TestTransactionOplogCleaningByTime(t *testing.T) {
 
 	/* clean */
 
-	txn.Clean(0, 100, 3*time.Second, 0)
+	assert.Empty(t, txn.Clean(0, 100, 3*time.Second, 0))
 	assert.Equal(t, bsonkit.List{insert, update, delete}, txn.Catalog().Namespaces[Oplog].Documents.List)
 
-	txn.Clean(0, 100, 2*time.Second, 0)
-	assert.Equal(t, bsonkit.List{update, delete}, txn.Catalog().Namespaces[Oplog].Documents.List)
+	assert.Equal(t, bsonkit.List{insert, update}, txn.Clean(0, 100, 2*time.Second, 0))
+	assert.Equal(t, bsonkit.List{delete}, txn.Catalog().Namespaces[Oplog].Documents.List)
 
-	txn.Clean(0, 100, 0, 2*time.Second)
+	assert.Empty(t, txn.Clean(0, 100, 0, 2*time.Second))
 	assert.Equal(t, bsonkit.List{delete}, txn.Catalog().Namespaces[Oplog].Documents.List)
 
-	txn.Clean(0, 100, 0, 0)
+	assert.Equal(t, bsonkit.List{delete}, txn.Clean(0, 100, 0, 0))
 	assert.Empty(t, txn.Catalog().Namespaces[Oplog].Documents.List)
 
 }
+
+func TestTransactionOplogCleaningMultiple(t *testing.T) {
+	txn := NewTransaction(NewCatalog())
+
+	_, err := txn.Insert(Handle{"foo", "bar"}, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": 1}),
+		bsonkit.MustConvert(bson.M{"_id": 2}),
+		bsonkit.MustConvert(bson.M{"_id": 3}),
+		bsonkit.MustConvert(bson.M{"_id": 4}),
+	}, true)
+	assert.NoError(t, err)
+
+	events := append(bsonkit.List{}, txn.Catalog().Namespaces[Oplog].Documents.List...)
+	assert.Len(t, events, 4)
+
+	assert.Equal(t, events[:3], txn.Clean(1, 1, 0, time.Hour))
+	assert.Equal(t, events[3:], txn.Catalog().Namespaces[Oplog].Documents.List)
+}
+
+func TestTransactionMaterializedView(t *testing.T) {
+	source := Handle{"foo", "bar"}
+	view := Handle{"foo", "stats"}
+
+	txn := NewTransaction(NewCatalog())
+
+	_, err := txn.Insert(source, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": 1, "cat": "a", "n": 1}),
+		bsonkit.MustConvert(bson.M{"_id": 2, "cat": "a", "n": 2}),
+		bsonkit.MustConvert(bson.M{"_id": 3, "cat": "b", "n": 5}),
+	}, true)
+	assert.NoError(t, err)
+
+	err = txn.CreateMaterializedView(view, "bar", bsonkit.List{
+		bsonkit.MustConvert(bson.M{"$sort": bson.M{"_id": 1}}),
+		bsonkit.MustConvert(bson.M{"$group": bson.M{"_id": "$cat"}}),
+	}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, "unsupported stage in materialized view pipeline: $sort", err.Error())
+
+	err = txn.CreateMaterializedView(view, "bar", bsonkit.List{
+		bsonkit.MustConvert(bson.M{"$match": bson.M{"n": bson.M{"$gt": 0}}}),
+	}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, "materialized view pipeline must end with a $group stage", err.Error())
+
+	err = txn.CreateMaterializedView(view, "bar", bsonkit.List{
+		bsonkit.MustConvert(bson.M{"$match": bson.M{"n": bson.M{"$gt": 0}}}),
+		bsonkit.MustConvert(bson.D{
+			{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$cat"},
+				{Key: "total", Value: bson.M{"$sum": "$n"}},
+			}},
+		}),
+	}, nil)
+	assert.NoError(t, err)
+
+	res, err := txn.Find(view, bsonkit.MustConvert(bson.M{}), bsonkit.MustConvert(bson.M{"_id": 1}), 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, bsonkit.List{
+		bsonkit.MustConvert(bson.D{{Key: "_id", Value: "a"}, {Key: "total", Value: 3}}),
+		bsonkit.MustConvert(bson.D{{Key: "_id", Value: "b"}, {Key: "total", Value: 5}}),
+	}, res.Matched)
+
+	_, err = txn.Insert(view, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": "c"}),
+	}, true)
+	assert.Error(t, err)
+
+	/* incremental */
+
+	txn = NewTransaction(txn.Catalog())
+
+	_, err = txn.Update(source, bsonkit.MustConvert(bson.M{
+		"_id": 3,
+	}), nil, bsonkit.MustConvert(bson.M{
+		"$set": bson.M{"cat": "a"},
+	}), 0, 0, false, nil, nil)
+	assert.NoError(t, err)
+
+	_, err = txn.Delete(source, bsonkit.MustConvert(bson.M{
+		"_id": 1,
+	}), nil, 0, 0, nil)
+	assert.NoError(t, err)
+
+	_, err = txn.Insert(source, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": 4, "cat": "c", "n": 1}),
+	}, true)
+	assert.NoError(t, err)
+
+	err = txn.Refresh()
+	assert.NoError(t, err)
+
+	res, err = txn.Find(view, bsonkit.MustConvert(bson.M{}), bsonkit.MustConvert(bson.M{"_id": 1}), 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, bsonkit.List{
+		bsonkit.MustConvert(bson.D{{Key: "_id", Value: "a"}, {Key: "total", Value: 7}}),
+		bsonkit.MustConvert(bson.D{{Key: "_id", Value: "c"}, {Key: "total", Value: 1}}),
+	}, res.Matched)
+
+	/* drop */
+
+	txn = NewTransaction(txn.Catalog())
+
+	err = txn.Drop(source)
+	assert.NoError(t, err)
+
+	err = txn.Refresh()
+	assert.NoError(t, err)
+
+	res, err = txn.Find(view, bsonkit.MustConvert(bson.M{}), nil, 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, res.Matched)
+}
+
+func TestTransactionMaterializedViewMaintenance(t *testing.T) {
+	source := Handle{"foo", "bar"}
+	view := Handle{"foo", "stats"}
+
+	pipeline := bsonkit.List{
+		bsonkit.MustConvert(bson.M{"$match": bson.M{"n": bson.M{"$gte": 0}}}),
+		bsonkit.MustConvert(bson.D{
+			{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$cat"},
+				{Key: "count", Value: bson.M{"$sum": 1}},
+				{Key: "total", Value: bson.M{"$sum": "$n"}},
+				{Key: "avg", Value: bson.M{"$avg": "$n"}},
+				{Key: "min", Value: bson.M{"$min": "$n"}},
+				{Key: "max", Value: bson.M{"$max": "$n"}},
+				{Key: "all", Value: bson.M{"$push": "$n"}},
+			}},
+		}),
+	}
+
+	txn := NewTransaction(NewCatalog())
+
+	err := txn.CreateMaterializedView(view, "bar", pipeline, nil)
+	assert.NoError(t, err)
+
+	check := func() {
+		expected, err := mongokit.Aggregate(txn.Catalog().Namespaces[source].Documents.List, append(pipeline, bsonkit.MustConvert(bson.M{
+			"$sort": bson.M{"_id": 1},
+		})), mongokit.PipelineContext{})
+		assert.NoError(t, err)
+
+		res, err := txn.Find(view, bsonkit.MustConvert(bson.M{}), bsonkit.MustConvert(bson.M{"_id": 1}), 0, 0, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, len(expected), len(res.Matched))
+		if len(expected) > 0 {
+			assert.Equal(t, expected, res.Matched)
+		}
+	}
+
+	cats := []string{"a", "b", "c"}
+	for i := 0; i < 50; i++ {
+		txn = NewTransaction(txn.Catalog())
+
+		_, err = txn.Insert(source, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": i, "cat": cats[i%3], "n": i % 7}),
+		}, true)
+		assert.NoError(t, err)
+
+		if i%4 == 0 {
+			_, err = txn.Update(source, bsonkit.MustConvert(bson.M{
+				"_id": i / 2,
+			}), nil, bsonkit.MustConvert(bson.M{
+				"$set": bson.M{"cat": cats[(i+1)%3], "n": i % 5},
+			}), 0, 0, false, nil, nil)
+			assert.NoError(t, err)
+		}
+
+		if i%5 == 0 {
+			_, err = txn.Delete(source, bsonkit.MustConvert(bson.M{
+				"n": i % 7,
+			}), nil, 0, 1, nil)
+			assert.NoError(t, err)
+		}
+
+		err = txn.Refresh()
+		assert.NoError(t, err)
+
+		check()
+	}
+
+	/* empty groups */
+
+	txn = NewTransaction(txn.Catalog())
+
+	_, err = txn.Delete(source, bsonkit.MustConvert(bson.M{
+		"cat": "b",
+	}), nil, 0, 0, nil)
+	assert.NoError(t, err)
+
+	err = txn.Refresh()
+	assert.NoError(t, err)
+
+	check()
+	assert.Nil(t, txn.Catalog().Namespaces[view].Get("b"))
+}
+
+func TestTransactionMaterializedViewCollation(t *testing.T) {
+	source := Handle{"foo", "bar"}
+	view := Handle{"foo", "stats"}
+
+	txn := NewTransaction(NewCatalog())
+
+	_, err := txn.Insert(source, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": 1, "cat": "a", "n": 1}),
+	}, true)
+	assert.NoError(t, err)
+
+	err = txn.CreateMaterializedView(view, "bar", bsonkit.List{
+		bsonkit.MustConvert(bson.D{
+			{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$cat"},
+				{Key: "total", Value: bson.M{"$sum": "$n"}},
+			}},
+		}),
+	}, bsonkit.MustConvert(bson.M{"locale": "en", "strength": 2}))
+	assert.NoError(t, err)
+
+	catalog, err := BuildFile(txn.Catalog()).BuildCatalog()
+	assert.NoError(t, err)
+
+	for _, base := range []*Catalog{txn.Catalog(), catalog} {
+		txn = NewTransaction(base)
+
+		_, err = txn.Insert(source, bsonkit.List{
+			bsonkit.MustConvert(bson.M{"_id": 2, "cat": "A", "n": 2}),
+		}, true)
+		assert.NoError(t, err)
+
+		err = txn.Refresh()
+		assert.NoError(t, err)
+
+		assert.Equal(t, bsonkit.List{
+			bsonkit.MustConvert(bson.D{{Key: "_id", Value: "a"}, {Key: "total", Value: 3}}),
+		}, txn.Catalog().Namespaces[view].Documents.List)
+	}
+}
+
+func TestTransactionValidation(t *testing.T) {
+	handle := Handle{"foo", "bar"}
+
+	namespace, err := mongokit.CreateCollection(mongokit.CollectionConfig{
+		Validator: bsonkit.MustConvert(bson.M{
+			"n": bson.M{"$gte": 0},
+		}),
+		ValidationLevel: "moderate",
+	}, true)
+	assert.NoError(t, err)
+
+	_, err = namespace.Insert(bsonkit.MustConvert(bson.M{"_id": 1, "n": -1}))
+	assert.NoError(t, err)
+
+	catalog := NewCatalog()
+	catalog.Namespaces[handle] = namespace
+
+	txn := NewTransaction(catalog)
+
+	_, err = txn.Insert(handle, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": 2, "n": 1}),
+	}, true)
+	assert.NoError(t, err)
+
+	res, err := txn.Insert(handle, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": 3, "n": -1}),
+	}, true)
+	assert.NoError(t, err)
+	assert.Error(t, res.Error)
+
+	/* moderate */
+
+	_, err = txn.Update(handle, bsonkit.MustConvert(bson.M{
+		"_id": 1,
+	}), nil, bsonkit.MustConvert(bson.M{
+		"$set": bson.M{"n": -2},
+	}), 0, 0, false, nil, nil)
+	assert.NoError(t, err)
+
+	_, err = txn.Update(handle, bsonkit.MustConvert(bson.M{
+		"_id": 2,
+	}), nil, bsonkit.MustConvert(bson.M{
+		"$set": bson.M{"n": -2},
+	}), 0, 0, false, nil, nil)
+	assert.Error(t, err)
+
+	/* warn */
+
+	namespace, err = mongokit.CreateCollection(mongokit.CollectionConfig{
+		Validator: bsonkit.MustConvert(bson.M{
+			"n": bson.M{"$gte": 0},
+		}),
+		ValidationAction: "warn",
+	}, true)
+	assert.NoError(t, err)
+
+	catalog = NewCatalog()
+	catalog.Namespaces[handle] = namespace
+
+	txn = NewTransaction(catalog)
+
+	res, err = txn.Insert(handle, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": 1, "n": -1}),
+	}, true)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Error)
+	warnings := txn.Warnings()
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, `write exception: write errors: [Document failed validation: {"failingDocumentId": {"$numberLong":"1"},"details": {"operatorName": "$gte","specifiedAs": {"n": {"$gte": {"$numberLong":"0"}}},"reason": "comparison failed","consideredValue": {"$numberLong":"-1"}}}]`, warnings[0].Error())
+
+	/* invalid config */
+
+	_, err = mongokit.CreateCollection(mongokit.CollectionConfig{
+		ValidationLevel: "foo",
+	}, true)
+	assert.Error(t, err)
+	assert.Equal(t, `invalid validation level "foo"`, err.Error())
+}
+
+func TestTransactionValidationWarnings(t *testing.T) {
+	handle := Handle{"foo", "bar"}
+
+	var warnings []error
+	engine, err := CreateEngine(Options{
+		Store: NewMemoryStore(),
+		ValidationWarnings: func(err error) {
+			warnings = append(warnings, err)
+		},
+	})
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	txn, err := engine.Begin(nil, true)
+	assert.NoError(t, err)
+	err = txn.Create(handle, mongokit.CollectionConfig{
+		Validator: bsonkit.MustConvert(bson.M{
+			"n": bson.M{"$gte": 0},
+		}),
+		ValidationAction: "warn",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, engine.Commit(txn))
+
+	/* abort */
+
+	txn, err = engine.Begin(nil, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(handle, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": 1, "n": -1}),
+	}, true)
+	assert.NoError(t, err)
+	assert.Len(t, txn.Warnings(), 1)
+	engine.Abort(txn)
+	assert.Empty(t, warnings)
+
+	/* commit */
+
+	txn, err = engine.Begin(nil, true)
+	assert.NoError(t, err)
+	_, err = txn.Insert(handle, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": 2, "n": -1}),
+	}, true)
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.NoError(t, engine.Commit(txn))
+	assert.Len(t, warnings, 1)
+}
+func TestTransactionTimeSeries(t *testing.T) {
+	handle := Handle{"foo", "bar"}
+
+	txn := NewTransaction(NewCatalog())
+
+	err := txn.Create(handle, mongokit.CollectionConfig{
+		TimeField:          "ts",
+		MetaField:          "meta",
+		ExpireAfterSeconds: 3600,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, txn.Catalog().Namespaces[handle.Buckets()])
+
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+
+	res, err := txn.Insert(handle, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"ts": old, "meta": "a", "n": 1}),
+		bsonkit.MustConvert(bson.M{"ts": old.Add(time.Second), "meta": "a", "n": 2}),
+		bsonkit.MustConvert(bson.M{"ts": now, "meta": "a", "n": 3}),
+		bsonkit.MustConvert(bson.M{"ts": now, "meta": "b", "n": 4}),
+	}, true)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Error)
+	assert.Len(t, res.Modified, 4)
+
+	buckets := txn.Catalog().Namespaces[handle.Buckets()]
+	assert.Len(t, buckets.Documents.List, 3)
+	assert.Equal(t, int64(1), bsonkit.Get(buckets.Documents.List[0], "data.n.0"))
+	assert.Equal(t, int64(2), bsonkit.Get(buckets.Documents.List[0], "data.n.1"))
+	assert.Equal(t, int64(1), bsonkit.Get(buckets.Documents.List[0], "control.min.n"))
+	assert.Equal(t, int64(2), bsonkit.Get(buckets.Documents.List[0], "control.max.n"))
+
+	res, err = txn.Find(handle, &bson.D{}, nil, 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Len(t, res.Matched, 4)
+	assert.Equal(t, "ts", (*res.Matched[0])[0].Key)
+	assert.Equal(t, "meta", (*res.Matched[0])[1].Key)
+
+	/* persistence */
+
+	catalog, err := BuildFile(txn.Catalog()).BuildCatalog()
+	assert.NoError(t, err)
+	assert.True(t, catalog.Namespaces[handle].IsTimeSeries())
+	assert.Len(t, catalog.Namespaces[handle.Buckets()].Documents.List, 3)
+
+	/* deletes */
+
+	_, err = txn.Delete(handle, bsonkit.MustConvert(bson.M{"n": 1}), nil, 0, 0, nil)
+	assert.Error(t, err)
+
+	_, err = txn.Delete(handle, bsonkit.MustConvert(bson.M{"meta": "b"}), nil, 0, 1, nil)
+	assert.Error(t, err)
+
+	res, err = txn.Delete(handle, bsonkit.MustConvert(bson.M{"meta": "b"}), nil, 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Len(t, res.Matched, 1)
+
+	/* expiry */
+
+	err = txn.Expire()
+	assert.NoError(t, err)
+
+	res, err = txn.Find(handle, &bson.D{}, nil, 0, 0, nil)
+	assert.NoError(t, err)
+	assert.Len(t, res.Matched, 1)
+	assert.Equal(t, int64(3), bsonkit.Get(res.Matched[0], "n"))
+
+	/* drop */
+
+	err = txn.Drop(handle)
+	assert.NoError(t, err)
+	assert.Nil(t, txn.Catalog().Namespaces[handle])
+	assert.Nil(t, txn.Catalog().Namespaces[handle.Buckets()])
+}
+
+func TestTransactionRename(t *testing.T) {
+	from := Handle{"foo", "bar"}
+	to := Handle{"baz", "qux"}
+
+	catalog := NewCatalog()
+	txn := NewTransaction(catalog)
+
+	err := txn.Rename(from, to)
+	assert.Error(t, err)
+	assert.Equal(t, int32(26), err.(mongo.CommandError).Code)
+
+	err = txn.Create(from, mongokit.CollectionConfig{})
+	assert.NoError(t, err)
+
+	_, err = txn.Insert(from, bsonkit.List{
+		bsonkit.MustConvert(bson.M{"_id": 1, "n": 1}),
+	}, true)
+	assert.NoError(t, err)
+
+	_, err = txn.CreateIndex(from, "", mongokit.IndexConfig{
+		Key:    bsonkit.MustConvert(bson.M{"n": 1}),
+		Unique: true,
+	})
+	assert.NoError(t, err)
+
+	err = txn.Rename(from, from)
+	assert.Error(t, err)
+	assert.Equal(t, int32(20), err.(mongo.CommandError).Code)
+
+	err = txn.Rename(from, to)
+	assert.NoError(t, err)
+	assert.Nil(t, txn.Catalog().Namespaces[from])
+	assert.Len(t, txn.Catalog().Namespaces[to].Documents.List, 1)
+	assert.Len(t, txn.Catalog().Namespaces[to].Indexes, 2)
+
+	oplog := txn.Catalog().Namespaces[Oplog].Documents.List
+	event := oplog[len(oplog)-1]
+	assert.Equal(t, "rename", bsonkit.Get(event, "operationType"))
+	assert.Equal(t, "bar", bsonkit.Get(event, "ns.coll"))
+	assert.Equal(t, "baz", bsonkit.Get(event, "to.db"))
+	assert.Equal(t, "qux", bsonkit.Get(event, "to.coll"))
+
+	err = txn.Create(from, mongokit.CollectionConfig{})
+	assert.NoError(t, err)
+
+	err = txn.Rename(from, to)
+	assert.Error(t, err)
+	assert.Equal(t, int32(48), err.(mongo.CommandError).Code)
+}
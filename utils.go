@@ -4,6 +4,10 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/256dpi/lungo/bsonkit"
 )
 
 const (
@@ -42,6 +46,15 @@ func assertOptions(opts interface{}, fields map[string]string) {
 	}
 }
 
+func transformCollation(collation *options.Collation) (bsonkit.Doc, error) {
+	// check collation
+	if collation == nil {
+		return nil, nil
+	}
+
+	return bsonkit.Transform(collation.ToDocument())
+}
+
 func useTransaction(ctx context.Context, engine *Engine, lock bool, fn func(*Transaction) (interface{}, error)) (interface{}, error) {
 	// ensure context
 	ctx = ensureContext(ctx)
@@ -0,0 +1,144 @@
+package lungo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+)
+
+// Validate will check the consistency of the documents and indexes of all
+// namespaces in the current catalog. It returns a report per namespace in the
+// shape of the "validate" command reply.
+func (e *Engine) Validate() (bsonkit.List, error) {
+	// begin snapshot transaction
+	txn, err := e.Begin(context.Background(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	// get sorted handles
+	handles := make([]Handle, 0, len(txn.catalog.Namespaces))
+	for handle, namespace := range txn.catalog.Namespaces {
+		if !namespace.IsView() && !namespace.IsTimeSeries() {
+			handles = append(handles, handle)
+		}
+	}
+	sort.Slice(handles, func(i, j int) bool {
+		return handles[i].String() < handles[j].String()
+	})
+
+	// validate namespaces
+	list := make(bsonkit.List, 0, len(handles))
+	for _, handle := range handles {
+		report, err := validateNamespace(handle, txn.catalog.Namespaces[handle])
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, report)
+	}
+
+	return list, nil
+}
+
+// Validate will check the consistency of the documents and indexes of the
+// namespace with the specified handle. The buckets of time-series collections
+// are checked instead. The report has the shape of the "validate" command
+// reply.
+func (t *Transaction) Validate(handle Handle) (bsonkit.Doc, error) {
+	// acquire read lock
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// validate handle
+	err := handle.Validate(true)
+	if err != nil {
+		return nil, err
+	}
+
+	// get namespace
+	namespace := t.catalog.Namespaces[handle]
+	if namespace == nil {
+		return nil, commandError(26, "NamespaceNotFound", "Collection '%s' does not exist to validate.", handle.String())
+	} else if namespace.IsView() {
+		return nil, viewError(handle)
+	}
+
+	// use buckets of time-series collections
+	if namespace.IsTimeSeries() {
+		namespace = t.catalog.Namespaces[handle.Buckets()]
+		if namespace == nil {
+			namespace = mongokit.NewCollection(false)
+		}
+	}
+
+	// validate namespace
+	report, err := validateNamespace(handle, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func validateNamespace(handle Handle, namespace *mongokit.Collection) (bsonkit.Doc, error) {
+	// verify namespace
+	verification, err := namespace.Verify()
+	if err != nil {
+		return nil, err
+	}
+
+	// collect errors
+	errs := bson.A{}
+	for _, msg := range verification.Errors {
+		errs = append(errs, msg)
+	}
+
+	// get sorted index names
+	names := make([]string, 0, len(namespace.Indexes))
+	for name := range namespace.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// collect index details
+	keysPerIndex := bson.D{}
+	indexDetails := bson.D{}
+	for _, name := range names {
+		problems := verification.Indexes[name]
+		keysPerIndex = append(keysPerIndex, bson.E{Key: name, Value: int64(namespace.Indexes[name].Len())})
+		indexDetails = append(indexDetails, bson.E{Key: name, Value: bson.D{
+			bson.E{Key: "valid", Value: len(problems) == 0},
+		}})
+		for _, problem := range problems {
+			errs = append(errs, fmt.Sprintf("index %s: %s", name, problem))
+		}
+	}
+
+	// collect warnings
+	warnings := bson.A{}
+	if verification.NonCompliant > 0 {
+		warnings = append(warnings, "Detected one or more documents not compliant to the collection's schema.")
+	}
+
+	return &bson.D{
+		bson.E{Key: "ns", Value: handle.String()},
+		bson.E{Key: "nInvalidDocuments", Value: int64(0)},
+		bson.E{Key: "nNonCompliantDocuments", Value: int64(verification.NonCompliant)},
+		bson.E{Key: "nrecords", Value: int64(len(namespace.Documents.List))},
+		bson.E{Key: "nIndexes", Value: int64(len(names))},
+		bson.E{Key: "keysPerIndex", Value: keysPerIndex},
+		bson.E{Key: "indexDetails", Value: indexDetails},
+		bson.E{Key: "valid", Value: len(errs) == 0},
+		bson.E{Key: "repaired", Value: false},
+		bson.E{Key: "warnings", Value: warnings},
+		bson.E{Key: "errors", Value: errs},
+		bson.E{Key: "extraIndexEntries", Value: bson.A{}},
+		bson.E{Key: "missingIndexEntries", Value: bson.A{}},
+		bson.E{Key: "corruptRecords", Value: bson.A{}},
+	}, nil
+}
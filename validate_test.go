@@ -0,0 +1,65 @@
+package lungo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/256dpi/lungo/bsonkit"
+)
+
+func TestEngineValidate(t *testing.T) {
+	client, engine, err := Open(nil, Options{
+		Store: NewMemoryStore(),
+	})
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	coll := client.Database("foo").Collection("bar")
+
+	_, err = coll.InsertMany(nil, []interface{}{
+		bson.M{"_id": 1, "n": int32(1)},
+		bson.M{"_id": 2, "n": int32(2)},
+	})
+	assert.NoError(t, err)
+
+	_, err = coll.Indexes().CreateOne(nil, mongo.IndexModel{
+		Keys: bson.M{"n": 1},
+	})
+	assert.NoError(t, err)
+
+	list, err := engine.Validate()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "foo.bar", bsonkit.Get(list[0], "ns"))
+	assert.Equal(t, true, bsonkit.Get(list[0], "valid"))
+	assert.Equal(t, int64(2), bsonkit.Get(list[0], "nrecords"))
+	assert.Equal(t, bson.D{
+		{Key: "_id_", Value: int64(2)},
+		{Key: "n_1", Value: int64(2)},
+	}, bsonkit.Get(list[0], "keysPerIndex"))
+	assert.Equal(t, "local.oplog", bsonkit.Get(list[1], "ns"))
+	assert.Equal(t, true, bsonkit.Get(list[1], "valid"))
+
+	/* corrupt */
+
+	namespace := engine.Catalog().Namespaces[Handle{"foo", "bar"}]
+	doc := namespace.Documents.List[0]
+	namespace.Documents.Index[doc] = 1
+	_, err = namespace.Indexes["n_1"].Remove(doc)
+	assert.NoError(t, err)
+
+	list, err = engine.Validate()
+	assert.NoError(t, err)
+	assert.Equal(t, false, bsonkit.Get(list[0], "valid"))
+	assert.Equal(t, bson.A{
+		"set index has position 1 for the document at position 0",
+		"index n_1: index has 1 entries, expected 2",
+		"index n_1: index misses 1 documents",
+	}, bsonkit.Get(list[0], "errors"))
+	assert.Equal(t, bson.D{
+		{Key: "valid", Value: false},
+	}, bsonkit.Get(list[0], "indexDetails.n_1"))
+}
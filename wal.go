@@ -0,0 +1,598 @@
+package lungo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/dbkit"
+)
+
+// the size of the log record header (length and checksum)
+const walHeaderSize = 8
+
+// errTornRecord is returned if a log record has not been fully written
+var errTornRecord = errors.New("torn log record")
+
+// WALStore appends the changes of every stored catalog to a checksummed
+// write-ahead log and periodically compacts the log into a snapshot file. The
+// cost of storing a catalog is therefore proportional to the size of the
+// changes rather than the size of the catalog. An exclusive lock is acquired
+// by default when the catalog is loaded.
+type WALStore struct {
+	path         string
+	mode         os.FileMode
+	compactAfter int
+	locking      FileLocking
+	lock         *dbkit.FileLock
+	catalog      *Catalog
+	sequence     int64
+	records      int
+	valid        int64
+	torn         bool
+	mutex        sync.Mutex
+}
+
+// WALOptions is used to configure a WAL store.
+type WALOptions struct {
+	// The number of records after which the log is compacted.
+	//
+	// Default: 1000.
+	CompactAfter int
+
+	// The advisory inter-process locking.
+	//
+	// Default: An exclusive lock that fails if already held.
+	Locking *FileLocking
+}
+
+// NewWALStore creates and returns a new WAL store. The snapshot is written to
+// the specified path using the snappy compressed file format and the log to
+// the same path with a ".wal" suffix. The log is compacted after the specified
+// number of records (default: 1000).
+func NewWALStore(path string, mode os.FileMode, compactAfter int) *WALStore {
+	return NewWALStoreWithOptions(path, mode, WALOptions{
+		CompactAfter: compactAfter,
+	})
+}
+
+// NewWALStoreWithOptions creates and returns a new WAL store using the
+// specified options.
+func NewWALStoreWithOptions(path string, mode os.FileMode, opts WALOptions) *WALStore {
+	// set default
+	if opts.CompactAfter <= 0 {
+		opts.CompactAfter = 1000
+	}
+
+	// get locking
+	var locking FileLocking
+	if opts.Locking != nil {
+		locking = *opts.Locking
+	}
+
+	return &WALStore{
+		path:         path,
+		mode:         mode,
+		compactAfter: opts.CompactAfter,
+		locking:      locking,
+	}
+}
+
+type walRecord struct {
+	Sequence int64       `bson:"seq"`
+	Changes  []walChange `bson:"changes"`
+}
+
+type walChange struct {
+	Namespace string         `bson:"ns"`
+	Drop      bool           `bson:"drop,omitempty"`
+	Meta      *FileNamespace `bson:"meta,omitempty"`
+	Insert    bsonkit.List   `bson:"insert,omitempty"`
+	Replace   bsonkit.List   `bson:"replace,omitempty"`
+	Remove    bson.A         `bson:"remove,omitempty"`
+}
+
+// Load will read the snapshot and replay the log to return the catalog. A
+// partially written record at the end of the log is ignored and truncated
+// before the next record is appended. If no snapshot and log exist an empty
+// catalog is returned.
+func (s *WALStore) Load() (*Catalog, error) {
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// acquire lock
+	err := s.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	// read snapshot
+	snapshot, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	// ensure namespaces
+	if snapshot.Namespaces == nil {
+		snapshot.Namespaces = map[string]FileNamespace{}
+	}
+
+	// replay log
+	sequence, records, err := s.replay(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	// build catalog
+	catalog, err := snapshot.BuildCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	// set state
+	s.catalog = catalog
+	s.sequence = sequence
+	s.records = records
+
+	return catalog, nil
+}
+
+// Store will append the changes between the previously stored and the
+// provided catalog to the log. The log is compacted if the record limit has
+// been reached.
+func (s *WALStore) Store(catalog *Catalog) error {
+	// check mode
+	if s.locking.Shared && !s.locking.Disabled {
+		return ErrReadOnly
+	}
+
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// acquire lock
+	err := s.acquire()
+	if err != nil {
+		return err
+	}
+
+	// compact if not loaded
+	if s.catalog == nil {
+		return s.compact(catalog)
+	}
+
+	return s.store(catalog, Diff(s.catalog, catalog))
+}
+
+// StoreDelta will append the provided changes to the log. The log is compacted
+// if the record limit has been reached.
+func (s *WALStore) StoreDelta(catalog *Catalog, deltas []Delta) error {
+	// check mode
+	if s.locking.Shared && !s.locking.Disabled {
+		return ErrReadOnly
+	}
+
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// acquire lock
+	err := s.acquire()
+	if err != nil {
+		return err
+	}
+
+	// compact if not loaded
+	if s.catalog == nil {
+		return s.compact(catalog)
+	}
+
+	return s.store(catalog, deltas)
+}
+
+// Unlock will release the lock acquired when the catalog was loaded.
+func (s *WALStore) Unlock() error {
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// release lock
+	err := releaseLock(s.lock)
+	s.lock = nil
+
+	return err
+}
+
+func (s *WALStore) acquire() error {
+	// acquire lock
+	lock, err := acquireLock(s.lock, s.path+".lock", s.mode, s.locking)
+	if err != nil {
+		return err
+	}
+
+	// set lock
+	s.lock = lock
+
+	return nil
+}
+
+func (s *WALStore) store(catalog *Catalog, deltas []Delta) error {
+	// check deltas
+	if len(deltas) == 0 {
+		s.catalog = catalog
+		return nil
+	}
+
+	// prepare record
+	record := walRecord{
+		Sequence: s.sequence + 1,
+		Changes:  make([]walChange, 0, len(deltas)),
+	}
+	for _, d := range deltas {
+		record.Changes = append(record.Changes, buildChange(d))
+	}
+
+	// append record
+	err := s.append(record)
+	if err != nil {
+		return err
+	}
+
+	// update state
+	s.sequence = record.Sequence
+	s.records++
+	s.catalog = catalog
+
+	// compact log if limit has been reached
+	if s.records >= s.compactAfter {
+		return s.compact(catalog)
+	}
+
+	return nil
+}
+
+// Compact will write the last stored catalog to the snapshot file and truncate
+// the log.
+func (s *WALStore) Compact() error {
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// check catalog
+	if s.catalog == nil {
+		return fmt.Errorf("catalog not loaded")
+	}
+
+	return s.compact(s.catalog)
+}
+
+func (s *WALStore) compact(catalog *Catalog) error {
+	// build snapshot
+	file := BuildFile(catalog)
+	file.Sequence = s.sequence
+
+	// write snapshot, records up to the sequence are skipped when the log is
+	// replayed if the log has not yet been truncated
+	err := storeFile(s.path, s.mode, file, SnappyCompression, nil)
+	if err != nil {
+		return err
+	}
+
+	// truncate log
+	err = os.Truncate(s.path+".wal", 0)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// update state
+	s.catalog = catalog
+	s.records = 0
+	s.valid = 0
+	s.torn = false
+
+	return nil
+}
+
+func (s *WALStore) append(record walRecord) error {
+	// encode record
+	payload, err := bson.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	// discard torn record
+	if s.torn {
+		err = os.Truncate(s.path+".wal", s.valid)
+		if err != nil {
+			return err
+		}
+		s.torn = false
+	}
+
+	// append record, a partially written record is truncated before the next
+	// record is appended
+	err = appendFrames(s.path+".wal", s.mode, [][]byte{payload})
+	if err != nil {
+		s.torn = true
+		return err
+	}
+
+	// update offset
+	s.valid += walHeaderSize + int64(len(payload))
+
+	return nil
+}
+
+func (s *WALStore) read() (*File, error) {
+	// open snapshot
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return &File{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadFile(f, nil)
+}
+
+func (s *WALStore) replay(snapshot *File) (int64, int, error) {
+	// reset state
+	s.valid = 0
+	s.torn = false
+
+	// open log
+	log, err := os.Open(s.path + ".wal")
+	if os.IsNotExist(err) {
+		return snapshot.Sequence, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	defer log.Close()
+
+	// get size
+	info, err := log.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// read records
+	sequence := snapshot.Sequence
+	records := 0
+	reader := bufio.NewReader(log)
+	var offset int64
+	for {
+		// read record
+		record, n, err := readRecord(reader, info.Size()-offset)
+		if err == io.EOF {
+			break
+		} else if err == errTornRecord {
+			// ignore torn record at the end of the log
+			if offset+n >= info.Size() {
+				s.torn = true
+				break
+			}
+
+			return 0, 0, fmt.Errorf("corrupt log record at offset %d", offset)
+		} else if err != nil {
+			return 0, 0, err
+		}
+
+		// apply record if not yet included in snapshot
+		if record.Sequence > snapshot.Sequence {
+			for _, change := range record.Changes {
+				applyChange(snapshot, change)
+			}
+			sequence = record.Sequence
+			records++
+		}
+
+		// advance offset
+		offset += n
+	}
+
+	// set offset
+	s.valid = offset
+
+	return sequence, records, nil
+}
+
+func readRecord(r io.Reader, remaining int64) (*walRecord, int64, error) {
+	// read frame
+	payload, n, err := readFrame(r, remaining)
+	if err != nil {
+		return nil, n, err
+	}
+
+	// decode record
+	var record walRecord
+	err = bson.Unmarshal(payload, &record)
+	if err != nil {
+		return nil, n, errTornRecord
+	}
+
+	return &record, n, nil
+}
+
+func appendFrames(path string, mode os.FileMode, payloads [][]byte) error {
+	// prepare frames
+	var buf []byte
+	for _, payload := range payloads {
+		header := make([]byte, walHeaderSize)
+		binary.LittleEndian.PutUint32(header[0:], uint32(len(payload)))
+		binary.LittleEndian.PutUint32(header[4:], crc32.Checksum(payload, crc32c))
+		buf = append(append(buf, header...), payload...)
+	}
+
+	// set default mode
+	if mode == 0 {
+		mode = 0666
+	}
+
+	// open file
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, mode)
+	if err != nil {
+		return err
+	}
+
+	// write frames
+	_, err = file.Write(buf)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	// sync file
+	err = file.Sync()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func readFrame(r io.Reader, remaining int64) ([]byte, int64, error) {
+	// read header
+	header := make([]byte, walHeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	} else if err == io.ErrUnexpectedEOF {
+		return nil, int64(n), errTornRecord
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	// check length, the remaining size is unknown if negative
+	length := binary.LittleEndian.Uint32(header[0:])
+	if remaining >= 0 && int64(length) > remaining-walHeaderSize {
+		return nil, remaining, errTornRecord
+	}
+
+	// read payload, the buffer grows with the read data to not allocate
+	// invalid lengths upfront
+	var payload bytes.Buffer
+	m, err := io.CopyN(&payload, r, int64(length))
+	if err == io.EOF {
+		return nil, int64(n) + m, errTornRecord
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	// verify checksum
+	if crc32.Checksum(payload.Bytes(), crc32c) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, int64(n) + m, errTornRecord
+	}
+
+	return payload.Bytes(), int64(n) + m, nil
+}
+
+func buildChange(d Delta) walChange {
+	// prepare change
+	change := walChange{
+		Namespace: d.Handle.String(),
+	}
+
+	// handle dropped namespace
+	if d.Dropped {
+		change.Drop = true
+		return change
+	}
+
+	// add meta
+	if d.Options || len(d.CreatedIndexes) > 0 || len(d.DroppedIndexes) > 0 {
+		meta := BuildFileNamespace(d.Namespace)
+		meta.Documents = nil
+		change.Meta = &meta
+	}
+
+	// add documents
+	change.Insert = d.Inserted
+	change.Replace = d.Replaced
+	for _, doc := range d.Removed {
+		change.Remove = append(change.Remove, bsonkit.Get(doc, "_id"))
+	}
+
+	return change
+}
+
+func applyChange(file *File, change walChange) {
+	// handle drop
+	if change.Drop {
+		delete(file.Namespaces, change.Namespace)
+		return
+	}
+
+	// get namespace
+	ns := file.Namespaces[change.Namespace]
+
+	// apply meta
+	if change.Meta != nil {
+		documents := ns.Documents
+		ns = *change.Meta
+		ns.Documents = documents
+	}
+
+	// remove documents
+	if len(change.Remove) > 0 {
+		keys := make(map[string]bool, len(change.Remove))
+		for _, id := range change.Remove {
+			keys[idKey(id)] = true
+		}
+		list := make(bsonkit.List, 0, len(ns.Documents))
+		for _, doc := range ns.Documents {
+			if !keys[idKey(bsonkit.Get(doc, "_id"))] {
+				list = append(list, doc)
+			}
+		}
+		ns.Documents = list
+	}
+
+	// replace documents in place
+	if len(change.Replace) > 0 {
+		positions := make(map[string]int, len(ns.Documents))
+		for i, doc := range ns.Documents {
+			positions[idKey(bsonkit.Get(doc, "_id"))] = i
+		}
+		for _, doc := range change.Replace {
+			if i, ok := positions[idKey(bsonkit.Get(doc, "_id"))]; ok {
+				ns.Documents[i] = doc
+			} else {
+				ns.Documents = append(ns.Documents, doc)
+			}
+		}
+	}
+
+	// insert documents, clustered namespaces are ordered by _id
+	for _, doc := range change.Insert {
+		// append document
+		if !ns.Clustered {
+			ns.Documents = append(ns.Documents, doc)
+			continue
+		}
+
+		// insert document at position
+		id := bsonkit.Get(doc, "_id")
+		i := sort.Search(len(ns.Documents), func(i int) bool {
+			return bsonkit.Compare(bsonkit.Get(ns.Documents[i], "_id"), id) > 0
+		})
+		ns.Documents = append(ns.Documents, nil)
+		copy(ns.Documents[i+1:], ns.Documents[i:])
+		ns.Documents[i] = doc
+	}
+
+	// set namespace
+	file.Namespaces[change.Namespace] = ns
+}